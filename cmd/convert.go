@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
 	"strings"
 
 	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
 
 	"github.com/spf13/cobra"
 )
@@ -16,7 +19,30 @@ var (
 	fromFormat string
 	toFormat   string
 	dryRun     bool
+	force      bool
 	stream     bool
+	workers    int
+
+	// Flattening of nested values for tabular targets (and the reverse for nested ones)
+	flattenSep string
+	arrayMode  string
+	joinSep    string
+	noFlatten  bool
+
+	// Whole-document conversion between nested formats, instead of records
+	document bool
+
+	// Per-format reader/writer options as key=value pairs
+	inOpts  []string
+	outOpts []string
+
+	// What happens to bad records
+	onError     string
+	rejectsFile string
+	maxErrors   int
+
+	// Schema file the records are conformed to
+	schemaFile string
 )
 
 // convertCmd defines the "convert" subcommand for the CLI.
@@ -31,11 +57,48 @@ var convertCmd = &cobra.Command{
 	Long: `Convert structured data between supported formats.
 Supports CSV, JSON, XML, XLSX (extensible).`,
 	Example: `
+  omnidata convert -i data.csv -o data.json
   omnidata convert -i data.csv -o data.json --from csv --to json
   cat data.csv | omnidata convert -i - -o - --from csv --to json
+  omnidata convert -i api.json -o api.csv --from json --to csv --arrays explode
+  omnidata convert -i eu.csv -o report.xlsx --in-opt delimiter=';' --out-opt sheet=Report
+  omnidata convert -i 'exports/*.xlsx' -o 'out/{name}.json' --workers 4
+  omnidata convert -i exports/ -o out/ --to csv
+  omnidata convert -i nightly.csv -o sqlite3://db.sqlite?table=t --on-error quarantine --rejects rejects.jsonl
+  omnidata convert -i users.csv -o users.json --schema users.schema.json --on-error skip
+  omnidata convert -i sensors.csv -o sensors.json --no-header --columns time,device,value
+  omnidata convert -i request.xml -o request.json --document --in-opt convention=badgerfish
   omnidata convert --list-formats`,
 	// RunE allows returning errors to Cobra which prints them and exits with code 1
 	RunE: func(cmd *cobra.Command, args []string) error {
+		arrays, err := dataset.ParseArrayMode(arrayMode)
+		if err != nil {
+			return err
+		}
+
+		inOptions, err := convert.ParseFormatOptions(inOpts)
+		if err != nil {
+			return fmt.Errorf("invalid --in-opt: %w", err)
+		}
+		convertHeader.apply(inOptions)
+		outOptions, err := convert.ParseFormatOptions(outOpts)
+		if err != nil {
+			return fmt.Errorf("invalid --out-opt: %w", err)
+		}
+
+		var schema *convert.Schema
+		if schemaFile != "" {
+			if schema, err = convert.LoadSchema(schemaFile); err != nil {
+				return err
+			}
+		}
+
+		policy, closeRejects, err := errorPolicy()
+		if err != nil {
+			return err
+		}
+		defer closeRejects()
+
 		// Prepare conversion options
 		opts := convert.Options{
 			InputFile:  inputFile,
@@ -43,12 +106,38 @@ Supports CSV, JSON, XML, XLSX (extensible).`,
 			From:       strings.ToLower(fromFormat),
 			To:         strings.ToLower(toFormat),
 			DryRun:     dryRun,
+			Force:      force,
 			Stream:     stream,
+			Flatten: dataset.FlattenOptions{
+				Separator:     flattenSep,
+				Arrays:        arrays,
+				JoinSeparator: joinSep,
+			},
+			NoFlatten:  noFlatten,
+			InOptions:  inOptions,
+			OutOptions: outOptions,
+			Workers:    workers,
+			OnError:    policy,
+			Schema:     schema,
+			Document:   document,
+		}
+
+		// Globs and directories convert every matched file, reporting each one
+		if convert.IsBatch(opts) {
+			result, err := convert.RunBatch(cmd.Context(), opts)
+			if err != nil {
+				return err
+			}
+			result.WriteSummary(cmd.OutOrStdout())
+			if result.Failed > 0 {
+				return fmt.Errorf("%d of %d files failed to convert", result.Failed, len(result.Items))
+			}
+			return nil
 		}
 
 		// Delegate actual conversion to the internal convert engine
 		// This will handle validation, reading, writing, and dry-run simulation
-		if err := convert.Run(opts); err != nil {
+		if err := convert.Run(cmd.Context(), opts); err != nil {
 			// Wrap the error with context before returning to Cobra
 			return err
 		}
@@ -64,12 +153,26 @@ func init() {
 	// ---------------------------
 	// Define CLI flags
 	// ---------------------------
-	convertCmd.Flags().StringVarP(&inputFile, "input", "i", "", "Input file path ('-' for STDIN)")
-	convertCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file path ('-' for STDOUT)")
-	convertCmd.Flags().StringVar(&fromFormat, "from", "", "Source format (csv/json/xml/xlsx)")
-	convertCmd.Flags().StringVar(&toFormat, "to", "", "Target format (csv/json/xml/xlsx)")
+	convertCmd.Flags().StringVarP(&inputFile, "input", "i", "", "Input file path ('-' for STDIN), glob pattern, or directory")
+	convertCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file path ('-' for STDOUT), or for batches a directory or template like 'out/{name}.json'")
+	convertCmd.Flags().StringVar(&fromFormat, "from", "", "Source format (detected from the input file name or content when omitted)")
+	convertCmd.Flags().StringVar(&toFormat, "to", "", "Target format (detected from the output file name when omitted)")
 	convertCmd.Flags().BoolVarP(&dryRun, "dry-run", "d", false, "Preview conversion without writing output")
+	convertCmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite the output file if it already exists")
 	convertCmd.Flags().BoolVarP(&stream, "stream", "s", false, "Use streaming mode for large files (memory-efficient)")
+	convertCmd.Flags().IntVarP(&workers, "workers", "w", 0, "Files converted at once in batch mode (default: number of CPUs)")
+	convertCmd.Flags().StringVar(&flattenSep, "flatten-sep", ".", "Separator between nested keys in flattened column names")
+	convertCmd.Flags().StringVar(&arrayMode, "arrays", "index", "How arrays are flattened: index (tags[0]), join, or explode (one row per element)")
+	convertCmd.Flags().StringVar(&joinSep, "join-sep", ",", "Separator used by --arrays join")
+	convertCmd.Flags().StringArrayVar(&inOpts, "in-opt", nil, "Reader option as key=value, e.g. delimiter=';' (repeatable, see 'omnidata formats <name>')")
+	convertHeader.register(convertCmd)
+	convertCmd.Flags().StringArrayVar(&outOpts, "out-opt", nil, "Writer option as key=value, e.g. sheet=Report (repeatable)")
+	convertCmd.Flags().BoolVar(&noFlatten, "no-flatten", false, "Keep nested values as JSON text instead of flattening/unflattening")
+	convertCmd.Flags().BoolVar(&document, "document", false, "Convert the input as one document between JSON, YAML and XML instead of as records")
+	convertCmd.Flags().StringVar(&onError, "on-error", "fail", "What to do with a bad record: fail, skip, or quarantine (write it to --rejects)")
+	convertCmd.Flags().StringVar(&rejectsFile, "rejects", "", "File receiving quarantined records as JSON lines (with --on-error quarantine)")
+	convertCmd.Flags().StringVar(&schemaFile, "schema", "", "JSON schema file (e.g. from 'peek --output-format json') to cast and check every record against")
+	convertCmd.Flags().IntVar(&maxErrors, "max-errors", 0, "Fail anyway once more than this many records of an input were dropped (0: no limit)")
 
 	// Mark required flags for input/output
 	err := convertCmd.MarkFlagRequired("input")
@@ -81,3 +184,29 @@ func init() {
 		return
 	}
 }
+
+// errorPolicy builds the bad-record policy from the flags, creating the rejects
+// file when quarantining. The returned function closes it.
+func errorPolicy() (convert.ErrorPolicy, func(), error) {
+	mode, err := convert.ParseErrorMode(onError)
+	if err != nil {
+		return convert.ErrorPolicy{}, nil, err
+	}
+	policy := convert.ErrorPolicy{Mode: mode, MaxErrors: maxErrors}
+
+	switch {
+	case mode == convert.OnErrorQuarantine && rejectsFile == "":
+		return policy, nil, fmt.Errorf("--on-error quarantine requires --rejects")
+	case mode != convert.OnErrorQuarantine && rejectsFile != "":
+		return policy, nil, fmt.Errorf("--rejects requires --on-error quarantine")
+	case rejectsFile == "":
+		return policy, func() {}, nil
+	}
+
+	rejects, err := os.Create(rejectsFile)
+	if err != nil {
+		return policy, nil, fmt.Errorf("failed to create rejects file: %w", err)
+	}
+	policy.Rejects = rejects
+	return policy, func() { rejects.Close() }, nil
+}
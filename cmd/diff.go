@@ -1,8 +1,8 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"os"
 
 	"omnidata/internal/convert"
 	"omnidata/internal/inspect"
@@ -18,6 +18,9 @@ var (
 	diffFormat2    string
 	diffOutputFile string
 	diffOutputFmt  string
+	diffInOpts     []string
+	diffInOpts1    []string
+	diffInOpts2    []string
 )
 
 // diffCmd defines the "diff" subcommand for the CLI.
@@ -27,22 +30,61 @@ var diffCmd = &cobra.Command{
 	Long: `Compare the schemas of two data files and show differences.
 Displays added, removed, and changed columns.`,
 	Example: `
-  omnidata diff -1 data1.csv -2 data2.csv --format1 csv --format2 csv
+  omnidata diff -1 data1.csv -2 data2.csv
+  omnidata diff -1 data1.csv -2 export.dat --format2 csv
+  omnidata diff -1 eu.csv -2 us.csv --in-opt1 "delimiter=;"
   omnidata diff -1 old.json -2 new.json --format1 json --format2 json`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		// Detect formats from the file names or content when not given
+		if diffFormat1 == "" {
+			detected, err := convert.DetectFormat(diffFile1)
+			if err != nil {
+				return fmt.Errorf("%w (use --format1)", err)
+			}
+			diffFormat1 = detected
+		}
+		if diffFormat2 == "" {
+			detected, err := convert.DetectFormat(diffFile2)
+			if err != nil {
+				return fmt.Errorf("%w (use --format2)", err)
+			}
+			diffFormat2 = detected
+		}
+
+		readerOpts, err := convert.ParseFormatOptions(diffInOpts)
+		if err != nil {
+			return err
+		}
+		readerOpts1, err := convert.ParseFormatOptions(diffInOpts1)
+		if err != nil {
+			return err
+		}
+		readerOpts2, err := convert.ParseFormatOptions(diffInOpts2)
+		if err != nil {
+			return err
+		}
+
+		// The header flags only concern the CSV/TSV side of a diff
+		headerOpts := convert.FormatOptions{}
+		diffHeader.apply(headerOpts)
+
 		opts := inspect.DiffOptions{
-			File1:   diffFile1,
-			File2:   diffFile2,
-			Format1: diffFormat1,
-			Format2: diffFormat2,
+			File1:          diffFile1,
+			File2:          diffFile2,
+			Format1:        diffFormat1,
+			Format2:        diffFormat2,
+			ReaderOptions:  readerOpts,
+			ReaderOptions1: readerOpts1,
+			ReaderOptions2: readerOpts2,
+			HeaderOptions:  headerOpts,
 		}
 
 		// If output format is specified, use formatter
 		if diffOutputFmt != "" {
-			return runDiffWithOutput(opts, diffOutputFmt, diffOutputFile)
+			return runDiffWithOutput(cmd.Context(), opts, diffOutputFmt, diffOutputFile)
 		}
 
-		if err := inspect.RunDiff(opts); err != nil {
+		if err := inspect.RunDiff(cmd.Context(), opts); err != nil {
 			return err
 		}
 
@@ -55,10 +97,14 @@ func init() {
 
 	diffCmd.Flags().StringVarP(&diffFile1, "file1", "1", "", "First file to compare")
 	diffCmd.Flags().StringVarP(&diffFile2, "file2", "2", "", "Second file to compare")
-	diffCmd.Flags().StringVar(&diffFormat1, "format1", "", "Format of first file (csv/json/xml/xlsx)")
-	diffCmd.Flags().StringVar(&diffFormat2, "format2", "", "Format of second file (csv/json/xml/xlsx)")
+	diffCmd.Flags().StringVar(&diffFormat1, "format1", "", "Format of first file (detected when omitted)")
+	diffCmd.Flags().StringVar(&diffFormat2, "format2", "", "Format of second file (detected when omitted)")
 	diffCmd.Flags().StringVarP(&diffOutputFile, "output", "o", "", "Output file path (optional, '-' for STDOUT)")
 	diffCmd.Flags().StringVar(&diffOutputFmt, "output-format", "", "Output format (markdown/html/json)")
+	diffHeader.register(diffCmd)
+	diffCmd.Flags().StringArrayVar(&diffInOpts, "in-opt", nil, "Reader option as key=value for each file whose format accepts it (repeatable)")
+	diffCmd.Flags().StringArrayVar(&diffInOpts1, "in-opt1", nil, "Reader option for the first file as key=value (repeatable)")
+	diffCmd.Flags().StringArrayVar(&diffInOpts2, "in-opt2", nil, "Reader option for the second file as key=value (repeatable)")
 
 	err := diffCmd.MarkFlagRequired("file1")
 	if err != nil {
@@ -68,17 +114,9 @@ func init() {
 	if err != nil {
 		return
 	}
-	err = diffCmd.MarkFlagRequired("format1")
-	if err != nil {
-		return
-	}
-	err = diffCmd.MarkFlagRequired("format2")
-	if err != nil {
-		return
-	}
 }
 
-func runDiffWithOutput(opts inspect.DiffOptions, outputFormat, outputFile string) error {
+func runDiffWithOutput(ctx context.Context, opts inspect.DiffOptions, outputFormat, outputFile string) error {
 	// Get format handlers
 	handler1, ok := convert.GetFormat(opts.Format1)
 	if !ok {
@@ -89,26 +127,30 @@ func runDiffWithOutput(opts inspect.DiffOptions, outputFormat, outputFile string
 	if !ok {
 		return fmt.Errorf("unsupported format: %s", opts.Format2)
 	}
+	readerOpts1, readerOpts2, err := opts.FileReaderOptions(handler1, handler2)
+	if err != nil {
+		return err
+	}
 
 	// Read data from both files
-	f1, err := os.Open(opts.File1)
+	f1, err := convert.OpenInput(opts.File1)
 	if err != nil {
 		return fmt.Errorf("failed to open file1: %w", err)
 	}
 	defer f1.Close()
 
-	data1, err := handler1.ReaderFn(f1, opts.File1)
+	data1, err := handler1.ReaderFn(ctx, convert.ContextReader(ctx, f1), opts.File1, readerOpts1)
 	if err != nil {
 		return fmt.Errorf("failed to read file1: %w", err)
 	}
 
-	f2, err := os.Open(opts.File2)
+	f2, err := convert.OpenInput(opts.File2)
 	if err != nil {
 		return fmt.Errorf("failed to open file2: %w", err)
 	}
 	defer f2.Close()
 
-	data2, err := handler2.ReaderFn(f2, opts.File2)
+	data2, err := handler2.ReaderFn(ctx, convert.ContextReader(ctx, f2), opts.File2, readerOpts2)
 	if err != nil {
 		return fmt.Errorf("failed to read file2: %w", err)
 	}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"omnidata/internal/convert"
+
+	"github.com/spf13/cobra"
+)
+
+// formatsCmd defines the "formats" subcommand for the CLI.
+// Responsibilities:
+// - List every registered format with its capabilities.
+// - Show the reader/writer options accepted by a single format.
+var formatsCmd = &cobra.Command{
+	Use:   "formats [format]",
+	Short: "List supported formats and their options",
+	Long: `List the registered data formats, or show the reader and writer options
+accepted by one format through --in-opt and --out-opt.`,
+	Example: `
+  omnidata formats
+  omnidata formats csv`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := cmd.OutOrStdout()
+		if len(args) == 0 {
+			listFormats(out)
+			return nil
+		}
+
+		handler, ok := convert.GetFormat(args[0])
+		if !ok {
+			return fmt.Errorf("unsupported format: %s", args[0])
+		}
+		describeFormat(out, handler)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(formatsCmd)
+}
+
+// listFormats prints one line per registered format.
+func listFormats(out io.Writer) {
+	names := convert.ListFormats()
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FORMAT\tEXTENSIONS\tSTREAMING\tNESTED")
+	for _, name := range names {
+		handler, _ := convert.GetFormat(name)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n",
+			name,
+			orDash(strings.Join(handler.Extensions, " ")),
+			yesNo(handler.StreamReaderFn != nil || handler.StreamWriterFn != nil),
+			yesNo(handler.Nested))
+	}
+	tw.Flush()
+}
+
+// describeFormat prints the reader and writer options of a format.
+func describeFormat(out io.Writer, handler convert.FormatHandler) {
+	fmt.Fprintf(out, "Format: %s\n", handler.Name)
+	if len(handler.Extensions) > 0 {
+		fmt.Fprintf(out, "Extensions: %s\n", strings.Join(handler.Extensions, " "))
+	}
+	if handler.Plugin != "" {
+		fmt.Fprintf(out, "Plugin: %s\n", handler.Plugin)
+	}
+
+	printOptions(out, "Reader options (--in-opt)", handler.ReaderOptions)
+	printOptions(out, "Writer options (--out-opt)", handler.WriterOptions)
+}
+
+// printOptions prints a titled table of option specs.
+func printOptions(out io.Writer, title string, specs []convert.OptionSpec) {
+	fmt.Fprintf(out, "\n%s:\n", title)
+	if len(specs) == 0 {
+		fmt.Fprintln(out, "  (none)")
+		return
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, spec := range specs {
+		description := spec.Description
+		if len(spec.Values) > 0 {
+			description += " (" + strings.Join(spec.Values, "|") + ")"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n",
+			spec.Name, spec.Type, orDash(spec.Default), description)
+	}
+	tw.Flush()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+
+	"omnidata/internal/convert"
+
+	"github.com/spf13/cobra"
+)
+
+// headerFlags are the header-row controls shared by convert, peek and diff. They are
+// shorthands for the header reader options of the CSV and TSV formats.
+type headerFlags struct {
+	noHeader  bool
+	columns   []string
+	skipRows  int
+	headerRow int
+}
+
+var (
+	convertHeader headerFlags
+	peekHeader    headerFlags
+	diffHeader    headerFlags
+)
+
+// register adds the header flags to cmd.
+func (h *headerFlags) register(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&h.noHeader, "no-header", false, "CSV/TSV input has no header row (columns are named col1..colN or by --columns)")
+	cmd.Flags().StringSliceVar(&h.columns, "columns", nil, "CSV/TSV column names, replacing the header row (comma-separated)")
+	cmd.Flags().IntVar(&h.skipRows, "skip-rows", 0, "CSV/TSV lines to skip before the header, e.g. a preamble")
+	cmd.Flags().IntVar(&h.headerRow, "header-row", 0, "CSV/TSV line holding the header, counted after --skip-rows (default 1)")
+}
+
+// apply sets the reader options selected by the flags, which win over --in-opt.
+func (h *headerFlags) apply(opts convert.FormatOptions) {
+	if h.noHeader {
+		opts["header"] = "false"
+	}
+	if len(h.columns) > 0 {
+		opts["columns"] = strings.Join(h.columns, ",")
+	}
+	if h.skipRows != 0 {
+		opts["skip_rows"] = strconv.Itoa(h.skipRows)
+	}
+	if h.headerRow != 0 {
+		opts["header_row"] = strconv.Itoa(h.headerRow)
+	}
+}
@@ -1,9 +1,8 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"io"
-	"os"
 
 	"omnidata/internal/convert"
 	"omnidata/internal/inspect"
@@ -19,6 +18,8 @@ var (
 	peekShowStats  bool
 	peekOutputFile string
 	peekOutputFmt  string
+	peekInOpts     []string
+	peekSniff      bool
 )
 
 // peekCmd defines the "peek" subcommand for the CLI.
@@ -26,25 +27,55 @@ var peekCmd = &cobra.Command{
 	Use:   "peek",
 	Short: "Preview data and show schema information",
 	Long: `Preview the first rows of a data file and display schema information.
-Shows column names, types, and statistics.`,
+Shows column names, types, and statistics.
+
+With --sniff, the first KB of a CSV-like input is sampled instead to detect its
+delimiter, quote character, line endings, encoding and header row. The report
+lists the --in-opt flags that pin the detected dialect in later runs.`,
 	Example: `
-  omnidata peek -i data.csv --format csv
+  omnidata peek -i data.csv
+  omnidata peek -i export.dat --format csv
   omnidata peek -i data.json --format json --rows 10 --stats
-  cat data.csv | omnidata peek -i - --format csv`,
+  omnidata peek -i export.dat --sniff
+  omnidata peek -i report.csv --skip-rows 3 --no-header
+  cat data.json | omnidata peek -i -`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if peekSniff {
+			if peekOutputFmt != "" {
+				return runSniffWithOutput(cmd.Context(), peekInputFile, peekOutputFmt, peekOutputFile)
+			}
+			return inspect.RunSniff(cmd.Context(), peekInputFile)
+		}
+
+		// Detect the format from the file name or content when not given
+		if peekFormat == "" {
+			detected, err := convert.DetectFormat(peekInputFile)
+			if err != nil {
+				return fmt.Errorf("%w (use --format)", err)
+			}
+			peekFormat = detected
+		}
+
+		readerOpts, err := convert.ParseFormatOptions(peekInOpts)
+		if err != nil {
+			return err
+		}
+		peekHeader.apply(readerOpts)
+
 		opts := inspect.PeekOptions{
-			InputFile: peekInputFile,
-			Format:    peekFormat,
-			Rows:      peekRows,
-			ShowStats: peekShowStats,
+			InputFile:     peekInputFile,
+			Format:        peekFormat,
+			Rows:          peekRows,
+			ShowStats:     peekShowStats,
+			ReaderOptions: readerOpts,
 		}
 
 		// If output format is specified, use formatter
 		if peekOutputFmt != "" {
-			return runPeekWithOutput(opts, peekOutputFmt, peekOutputFile)
+			return runPeekWithOutput(cmd.Context(), opts, peekOutputFmt, peekOutputFile)
 		}
 
-		if err := inspect.RunPeek(opts); err != nil {
+		if err := inspect.RunPeek(cmd.Context(), opts); err != nil {
 			return err
 		}
 
@@ -56,46 +87,44 @@ func init() {
 	rootCmd.AddCommand(peekCmd)
 
 	peekCmd.Flags().StringVarP(&peekInputFile, "input", "i", "", "Input file path ('-' for STDIN)")
-	peekCmd.Flags().StringVar(&peekFormat, "format", "", "Data format (csv/json/xml/xlsx)")
+	peekCmd.Flags().StringVar(&peekFormat, "format", "", "Data format (detected from the file name or content when omitted)")
 	peekCmd.Flags().IntVarP(&peekRows, "rows", "n", 5, "Number of preview rows to show")
 	peekCmd.Flags().BoolVar(&peekShowStats, "stats", false, "Show detailed column statistics")
 	peekCmd.Flags().StringVarP(&peekOutputFile, "output", "o", "", "Output file path (optional, '-' for STDOUT)")
 	peekCmd.Flags().StringVar(&peekOutputFmt, "output-format", "", "Output format (markdown/html/json)")
+	peekCmd.Flags().BoolVar(&peekSniff, "sniff", false, "Detect the dialect, encoding and header of a CSV-like input")
+	peekHeader.register(peekCmd)
+	peekCmd.Flags().StringArrayVar(&peekInOpts, "in-opt", nil, "Reader option as key=value (repeatable, see 'omnidata formats <name>')")
 
 	err := peekCmd.MarkFlagRequired("input")
 	if err != nil {
 		return
 	}
-	err = peekCmd.MarkFlagRequired("format")
-	if err != nil {
-		return
-	}
 }
 
-func runPeekWithOutput(opts inspect.PeekOptions, outputFormat, outputFile string) error {
+func runPeekWithOutput(ctx context.Context, opts inspect.PeekOptions, outputFormat, outputFile string) error {
 	// Get format handler
 	handler, ok := convert.GetFormat(opts.Format)
 	if !ok {
 		return fmt.Errorf("unsupported format: %s", opts.Format)
 	}
+	if err := handler.ValidateReaderOptions(opts.ReaderOptions); err != nil {
+		return fmt.Errorf("invalid input options: %w", err)
+	}
 
 	// Prepare reader
-	var r io.Reader
 	inputPath := opts.InputFile
 	if inputPath == "-" {
-		r = os.Stdin
 		inputPath = ""
-	} else {
-		f, err := os.Open(inputPath)
-		if err != nil {
-			return fmt.Errorf("failed to open input file: %w", err)
-		}
-		defer f.Close()
-		r = f
 	}
+	r, err := convert.OpenInput(inputPath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
 
 	// Read data and infer schema
-	data, err := handler.ReaderFn(r, inputPath)
+	data, err := handler.ReaderFn(ctx, convert.ContextReader(ctx, r), inputPath, opts.ReaderOptions)
 	if err != nil {
 		return fmt.Errorf("failed to read input: %w", err)
 	}
@@ -120,3 +149,31 @@ func runPeekWithOutput(opts inspect.PeekOptions, outputFormat, outputFile string
 	// Write output
 	return output.WriteOutput(content, outputFile)
 }
+
+func runSniffWithOutput(ctx context.Context, inputFile, outputFormat, outputFile string) error {
+	formatter, err := output.GetFormatter(outputFormat)
+	if err != nil {
+		return err
+	}
+
+	inputPath := inputFile
+	if inputPath == "-" {
+		inputPath = ""
+	}
+	r, err := convert.OpenInput(inputPath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	result, err := inspect.Sniff(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	content, err := formatter.FormatSniff(result)
+	if err != nil {
+		return fmt.Errorf("failed to format dialect: %w", err)
+	}
+	return output.WriteOutput(content, outputFile)
+}
@@ -1,12 +1,26 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+// timeout limits how long a command may run (--timeout); 0 means no limit.
+var timeout time.Duration
+
+// cancelTimeout releases the --timeout context once the command is done.
+var cancelTimeout context.CancelFunc = func() {}
+
+// errInterrupted is the cause of a command cancelled by Ctrl-C or SIGTERM.
+var errInterrupted = errors.New("interrupted")
+
 var rootCmd = &cobra.Command{
 	Use:   "omnidata",
 	Short: "OmniData - Universal Data Translator",
@@ -17,15 +31,48 @@ Supports multiple formats like CSV, JSON, XML, XLSX, and is easily extensible.`,
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := interruptContext()
+	err := rootCmd.ExecuteContext(ctx)
+	cancelTimeout()
+	stop()
+
+	if err != nil {
 		// Just print the error — no need to assign to fprintf
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(context.Cause(ctx), errInterrupted) {
+			// Conventional status for a process stopped by SIGINT
+			os.Exit(130)
+		}
 		os.Exit(1)
 	}
 }
 
+// interruptContext returns a context cancelled by the first SIGINT or SIGTERM, so
+// that reads and writes in flight stop and partial outputs are discarded. Handling
+// is then restored to the default, so a second signal terminates immediately.
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-signals:
+			signal.Stop(signals)
+			cancel(errInterrupted)
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel(nil)
+	}
+}
+
 func init() {
 	rootCmd.PersistentFlags().BoolP("version", "v", false, "Show OmniData version")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Stop the command after this long, e.g. 30s or 5m (partial outputs are discarded)")
 
 	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
 		versionFlag, err := cmd.Flags().GetBool("version")
@@ -37,6 +84,13 @@ func init() {
 			fmt.Println("OmniData CLI v1.0.0")
 			os.Exit(0)
 		}
+
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeoutCause(cmd.Context(), timeout,
+				fmt.Errorf("timed out after %s", timeout))
+			cancelTimeout = cancel
+			cmd.SetContext(ctx)
+		}
 	}
 
 	// Attach subcommands here
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"omnidata/internal/pipeline"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	runVars   []string
+	runDryRun bool
+	runForce  bool
+)
+
+// runCmd defines the "run" subcommand for the CLI.
+// Responsibilities:
+// - Load a YAML pipeline recipe, substituting variables from --var and the environment.
+// - Execute its sources, transforms, validations and sinks through the convert engine.
+var runCmd = &cobra.Command{
+	Use:   "run <pipeline.yaml>",
+	Short: "Run a declarative pipeline recipe",
+	Long: `Run a multi-step job declared in a YAML recipe: sources are read into named
+datasets, transformed, validated, and written to one or more sinks.
+
+Values may refer to variables as ${NAME} or ${NAME:-default}, resolved from --var,
+then the environment, then the recipe's vars block.`,
+	Example: `
+  omnidata run pipeline.yaml
+  DATA_DIR=/mnt/exports omnidata run nightly.yaml --var DAY=2024-01-31
+  omnidata run pipeline.yaml --dry-run`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		overrides := make(map[string]string, len(runVars))
+		for _, pair := range runVars {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok || name == "" {
+				return fmt.Errorf("invalid --var %q, expected NAME=value", pair)
+			}
+			overrides[name] = value
+		}
+
+		recipe, err := pipeline.Load(args[0], overrides)
+		if err != nil {
+			return err
+		}
+
+		return recipe.Run(cmd.Context(), pipeline.RunOptions{
+			DryRun: runDryRun,
+			Force:  runForce,
+			Log:    cmd.ErrOrStderr(),
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+
+	runCmd.Flags().StringArrayVar(&runVars, "var", nil, "Set a recipe variable as NAME=value (repeatable, overrides the environment)")
+	runCmd.Flags().BoolVarP(&runDryRun, "dry-run", "d", false, "Read, transform and validate without writing any sink")
+	runCmd.Flags().BoolVarP(&runForce, "force", "f", false, "Overwrite existing sink outputs")
+}
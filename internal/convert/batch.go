@@ -0,0 +1,223 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BatchItem is the outcome of converting a single file in a batch.
+type BatchItem struct {
+	Input  string
+	Output string
+	Err    error
+}
+
+// BatchResult collects the outcome of every file in a batch, in input order.
+type BatchResult struct {
+	Items  []BatchItem
+	Failed int
+	DryRun bool
+}
+
+/*
+IsBatch reports whether opts describe a batch conversion: the input is a directory,
+or a glob pattern (e.g. "exports/*.xlsx") that is not itself the name of a file
+such as "report[1].csv".
+*/
+func IsBatch(opts Options) bool {
+	if opts.InputFile == "" || opts.InputFile == "-" {
+		return false
+	}
+	if info, err := os.Stat(opts.InputFile); err == nil {
+		return info.IsDir()
+	}
+	return strings.ContainsAny(opts.InputFile, "*?[")
+}
+
+/*
+RunBatch converts every file matched by a glob pattern or contained in a directory.
+
+Responsibilities:
+- Expand the input into a sorted list of files (directories are not recursed).
+- Map each input to an output using a directory or a "{name}" template.
+- Run up to opts.Workers conversions at once (default: number of CPUs).
+- Continue past individual failures and report every outcome in the result.
+- Once ctx is done, conversions in flight are stopped and pending files fail without starting.
+
+Output templates support {name} (input file name without extensions) and {ext}
+(input extension without the dot). When the output is a directory, files are named
+{name} plus the extension of the target format.
+
+Returns an error only if the batch cannot be set up; per-file errors are in the result.
+*/
+func RunBatch(ctx context.Context, opts Options) (*BatchResult, error) {
+	inputs, err := expandInputs(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]BatchItem, len(inputs))
+	owners := make(map[string]string)
+	for i, input := range inputs {
+		items[i].Input = input
+		output, err := batchOutputPath(opts, input)
+		if err != nil {
+			return nil, err
+		}
+		items[i].Output = output
+
+		// Two inputs must never race to write the same output
+		if first, ok := owners[output]; ok {
+			items[i].Err = fmt.Errorf("output %s is also produced from %s", output, first)
+			continue
+		}
+		owners[output] = input
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				items[i].Err = runBatchItem(ctx, opts, items[i])
+			}
+		}()
+	}
+	for i := range items {
+		if items[i].Err == nil {
+			jobs <- i
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	result := &BatchResult{Items: items, DryRun: opts.DryRun}
+	for _, item := range items {
+		if item.Err != nil {
+			result.Failed++
+		}
+	}
+	return result, nil
+}
+
+// runBatchItem converts a single file of a batch.
+func runBatchItem(ctx context.Context, opts Options, item BatchItem) error {
+	if err := ctx.Err(); err != nil {
+		return Stopped(ctx, err)
+	}
+	if dir := filepath.Dir(item.Output); dir != "" && !opts.DryRun {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	opts.InputFile = item.Input
+	opts.OutputFile = item.Output
+	opts.Quiet = true
+	return Run(ctx, opts)
+}
+
+// WriteSummary prints one line per file followed by a totals line.
+func (r *BatchResult) WriteSummary(w io.Writer) {
+	for _, item := range r.Items {
+		if item.Err != nil {
+			fmt.Fprintf(w, "FAIL  %s: %v\n", item.Input, item.Err)
+			continue
+		}
+		fmt.Fprintf(w, "OK    %s -> %s\n", item.Input, item.Output)
+	}
+	verb := "Converted"
+	if r.DryRun {
+		verb = "[Dry-run] Would convert"
+	}
+	fmt.Fprintf(w, "%s %d of %d files (%d failed)\n",
+		verb, len(r.Items)-r.Failed, len(r.Items), r.Failed)
+}
+
+// expandInputs returns the sorted list of files to convert.
+// For a directory with an explicit source format, only files with that format's
+// extensions are included.
+func expandInputs(opts Options) ([]string, error) {
+	var candidates []string
+	info, err := os.Stat(opts.InputFile)
+	if err == nil && info.IsDir() {
+		entries, err := os.ReadDir(opts.InputFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input directory: %w", err)
+		}
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			path := filepath.Join(opts.InputFile, entry.Name())
+			if opts.From != "" {
+				if detected, ok := DetectFormatFromPath(path); !ok || detected != opts.From {
+					continue
+				}
+			}
+			candidates = append(candidates, path)
+		}
+	} else {
+		matches, err := filepath.Glob(opts.InputFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input pattern '%s': %w", opts.InputFile, err)
+		}
+		candidates = matches
+	}
+
+	files := make([]string, 0, len(candidates))
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no input files match '%s'", opts.InputFile)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// batchOutputPath maps an input file to its output path.
+func batchOutputPath(opts Options, input string) (string, error) {
+	target := opts.OutputFile
+	if target == "" || target == "-" {
+		return "", fmt.Errorf("batch conversion needs an output directory or a {name} template, not STDOUT")
+	}
+
+	base := filepath.Base(input)
+	base = strings.TrimSuffix(base, ".gz")
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+
+	if strings.Contains(target, "{name}") {
+		out := strings.ReplaceAll(target, "{name}", name)
+		return strings.ReplaceAll(out, "{ext}", strings.TrimPrefix(ext, ".")), nil
+	}
+
+	info, err := os.Stat(target)
+	isDir := err == nil && info.IsDir()
+	if !isDir && !strings.HasSuffix(target, "/") && !strings.HasSuffix(target, string(os.PathSeparator)) {
+		return "", fmt.Errorf("output '%s' must be a directory or contain {name} for batch conversion", target)
+	}
+
+	handler, ok := GetFormat(opts.To)
+	if !ok || len(handler.Extensions) == 0 {
+		return "", fmt.Errorf("cannot name outputs in '%s' without a target format (use --to or a {name} template)", target)
+	}
+	return filepath.Join(target, name+handler.Extensions[0]), nil
+}
@@ -0,0 +1,74 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ctxReader fails reads once its context is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+/*
+ContextReader returns r, failing every read with ctx.Err() once ctx is done.
+
+Format readers only see an io.Reader, so this is how a cancelled conversion stops
+a reader in the middle of its input.
+*/
+func ContextReader(ctx context.Context, r io.Reader) io.Reader {
+	if r == nil || ctx.Done() == nil {
+		return r
+	}
+	return &ctxReader{ctx: ctx, r: r}
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// File returns the file of the wrapped reader.
+func (c *ctxReader) File() *os.File {
+	return InputFile(c.r)
+}
+
+// ctxWriter fails writes once its context is done.
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+// ContextWriter returns w, failing every write with ctx.Err() once ctx is done.
+func ContextWriter(ctx context.Context, w io.Writer) io.Writer {
+	if w == nil || ctx.Done() == nil {
+		return w
+	}
+	return &ctxWriter{ctx: ctx, w: w}
+}
+
+func (c *ctxWriter) Write(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.w.Write(p)
+}
+
+/*
+Stopped reports err as a stopped conversion when ctx is done.
+
+A read or write failing because of the cancellation is only a symptom, so the
+context's cause (e.g. "interrupted" or a timeout) is returned instead, wrapped
+so that errors.Is matches it.
+*/
+func Stopped(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	return fmt.Errorf("conversion stopped: %w", context.Cause(ctx))
+}
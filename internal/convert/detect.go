@@ -0,0 +1,158 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sniffLen is the number of leading bytes inspected when detecting a format from content.
+const sniffLen = 512
+
+// gzipMagic is the signature of a gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// stdinBuffer is the buffered STDIN shared by format detection and readers,
+// so bytes peeked while sniffing are still consumed by the conversion.
+var stdinBuffer struct {
+	file   *os.File
+	reader *bufio.Reader
+}
+
+// bufferedStdin returns the shared buffered reader for the current os.Stdin.
+func bufferedStdin() *bufio.Reader {
+	if stdinBuffer.file != os.Stdin {
+		stdinBuffer.file = os.Stdin
+		stdinBuffer.reader = bufio.NewReaderSize(os.Stdin, sniffLen)
+	}
+	return stdinBuffer.reader
+}
+
+/*
+DetectFormat infers the format of an input from its path, then from its content.
+
+- The extension is matched first; a trailing ".gz" is ignored (data.csv.gz -> csv).
+- Otherwise the first bytes are matched against registered signatures (Gzip is decompressed first).
+- "-" or "" reads the signature from STDIN without consuming it.
+*/
+func DetectFormat(path string) (string, error) {
+	if name, ok := DetectFormatFromPath(path); ok {
+		return name, nil
+	}
+
+	var head []byte
+	if path == "-" || path == "" {
+		// Peek returns what is available along with io.EOF for short inputs
+		head, _ = bufferedStdin().Peek(sniffLen)
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to open '%s' for format detection: %w", path, err)
+		}
+		defer f.Close()
+
+		head = make([]byte, sniffLen)
+		n, err := io.ReadFull(f, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return "", fmt.Errorf("failed to read '%s' for format detection: %w", path, err)
+		}
+		head = head[:n]
+	}
+
+	if name, ok := DetectFormatFromContent(head); ok {
+		return name, nil
+	}
+
+	source := path
+	if source == "-" || source == "" {
+		source = "STDIN"
+	}
+	return "", fmt.Errorf("cannot detect the format of '%s'", source)
+}
+
+// DetectFormatFromPath returns the registered format whose extensions match the path.
+func DetectFormatFromPath(path string) (string, bool) {
+	lower := strings.ToLower(path)
+	lower = strings.TrimSuffix(lower, ".gz")
+	ext := filepath.Ext(lower)
+	if ext == "" {
+		return "", false
+	}
+
+	for _, name := range sortedFormats() {
+		for _, candidate := range Registry[name].Extensions {
+			if strings.ToLower(candidate) == ext {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// DetectFormatFromContent returns the registered format whose signature prefixes the content.
+// Gzip content is decompressed first; leading whitespace and a UTF-8 BOM are skipped.
+func DetectFormatFromContent(head []byte) (string, bool) {
+	if bytes.HasPrefix(head, gzipMagic) {
+		gz, err := gzip.NewReader(bytes.NewReader(head))
+		if err != nil {
+			return "", false
+		}
+		// The head is truncated, so an unexpected EOF is normal here
+		inner, _ := io.ReadAll(io.LimitReader(gz, sniffLen))
+		head = inner
+	}
+
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	head = bytes.TrimLeft(head, " \t\r\n")
+	if len(head) == 0 {
+		return "", false
+	}
+
+	for _, name := range sortedFormats() {
+		for _, signature := range Registry[name].Signatures {
+			if bytes.HasPrefix(head, signature) {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// resolveFormats fills in missing source and target formats from the paths and,
+// for the source, the input content. Explicit formats are always kept.
+func resolveFormats(opts *Options) error {
+	if opts.From == "" {
+		from, err := DetectFormat(opts.InputFile)
+		if err != nil {
+			return fmt.Errorf("%w (use --from)", err)
+		}
+		opts.From = from
+	}
+
+	if opts.To == "" {
+		to, ok := DetectFormatFromPath(opts.OutputFile)
+		if !ok {
+			target := opts.OutputFile
+			if target == "-" || target == "" {
+				target = "STDOUT"
+			}
+			return fmt.Errorf("cannot detect the format of '%s' (use --to)", target)
+		}
+		opts.To = to
+	}
+
+	return nil
+}
+
+// sortedFormats returns the registered format names in a stable order.
+func sortedFormats() []string {
+	names := ListFormats()
+	sort.Strings(names)
+	return names
+}
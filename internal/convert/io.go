@@ -0,0 +1,161 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"omnidata/internal/dataset"
+)
+
+/*
+ReadDataset reads opts.InputFile into the canonical dataset.
+
+The source format is opts.From, detected from the path or content when empty, and
+opts.InOptions are passed to its reader. Only the input side of opts is used.
+Cancelling ctx stops the read (see Run).
+*/
+func ReadDataset(ctx context.Context, opts Options) (*dataset.Dataset, error) {
+	if opts.From == "" {
+		from, err := DetectFormat(opts.InputFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w (set a format)", err)
+		}
+		opts.From = from
+	}
+
+	handler, ok := GetFormat(opts.From)
+	if !ok {
+		return nil, fmt.Errorf("unsupported source format: %s", opts.From)
+	}
+	if err := handler.ValidateReaderOptions(opts.InOptions); err != nil {
+		return nil, fmt.Errorf("invalid input options: %w", err)
+	}
+	if opts.InputFile == "-" {
+		opts.InputFile = ""
+	}
+
+	ds, err := readDataset(ctx, opts, handler)
+	return ds, Stopped(ctx, err)
+}
+
+/*
+WriteDataset writes ds to opts.OutputFile, with the same guarantees as Run.
+
+- The target format is opts.To, detected from the output path when empty.
+- Nested values are flattened for tabular targets; when opts.From names a tabular
+format and the target is nested, flattened columns are rebuilt (see Run).
+- Existing files are only replaced with opts.Force, and always atomically.
+- Cancelling ctx stops the write and discards the partial output.
+*/
+func WriteDataset(ctx context.Context, opts Options, ds *dataset.Dataset) error {
+	if opts.To == "" {
+		to, ok := DetectFormatFromPath(opts.OutputFile)
+		if !ok {
+			return fmt.Errorf("cannot detect the format of '%s' (set a format)", opts.OutputFile)
+		}
+		opts.To = to
+	}
+
+	toHandler, ok := GetFormat(opts.To)
+	if !ok {
+		return fmt.Errorf("unsupported target format: %s", opts.To)
+	}
+	if err := toHandler.ValidateWriterOptions(opts.OutOptions); err != nil {
+		return fmt.Errorf("invalid output options: %w", err)
+	}
+
+	outputPath, err := resolveOutputPath(opts)
+	if err != nil {
+		return err
+	}
+	opts.OutputFile = outputPath
+
+	if !opts.NoFlatten {
+		fromHandler, known := GetFormat(opts.From)
+		if (known && needsReshape(opts, fromHandler, toHandler)) || (!known && !toHandler.Nested) {
+			ds, err = reshape(opts, toHandler, ds)
+			if err != nil {
+				return fmt.Errorf("failed to reshape data for %s: %w", opts.To, err)
+			}
+		}
+	}
+
+	return Stopped(ctx, writeDataset(ctx, opts, toHandler, ds))
+}
+
+// readDataset opens and reads the input described by opts with the given handler.
+func readDataset(ctx context.Context, opts Options, fromHandler FormatHandler) (*dataset.Dataset, error) {
+	reader, err := openInput(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if reader != nil {
+		defer reader.Close()
+	}
+	return decodeDataset(ctx, opts, fromHandler, reader)
+}
+
+// decodeDataset reads r with the given handler and normalizes the result to a dataset.
+func decodeDataset(ctx context.Context, opts Options, fromHandler FormatHandler, r io.Reader) (*dataset.Dataset, error) {
+	data, err := fromHandler.ReaderFn(ctx, r, opts.InputFile, opts.InOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input '%s': %w", opts.InputFile, err)
+	}
+
+	// Normalize to the canonical dataset so any reader can feed any writer
+	ds, err := fromHandler.AsDataset(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert input '%s' to a dataset: %w", opts.InputFile, err)
+	}
+	return ds, nil
+}
+
+// readDocument opens and reads the input described by opts as one document.
+func readDocument(ctx context.Context, opts Options, fromHandler FormatHandler) (interface{}, error) {
+	reader, err := openInput(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if reader != nil {
+		defer reader.Close()
+	}
+	return decodeDocument(ctx, opts, fromHandler, reader)
+}
+
+// decodeDocument reads r with the handler's DocumentFn, or its ReaderFn when it has none.
+func decodeDocument(ctx context.Context, opts Options, fromHandler FormatHandler, r io.Reader) (interface{}, error) {
+	read := fromHandler.DocumentFn
+	if read == nil {
+		read = fromHandler.ReaderFn
+	}
+	doc, err := read(ctx, r, opts.InputFile, opts.InOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input '%s': %w", opts.InputFile, err)
+	}
+	return doc, nil
+}
+
+// writeDataset writes ds to the output described by opts with the given handler.
+// The output only replaces the destination once it has been written completely.
+func writeDataset(ctx context.Context, opts Options, toHandler FormatHandler, ds *dataset.Dataset) error {
+	return writeData(ctx, opts, toHandler, ds)
+}
+
+// writeData writes a Dataset or a document to the output described by opts (see writeDataset).
+func writeData(ctx context.Context, opts Options, toHandler FormatHandler, data interface{}) error {
+	out, err := openOutput(ctx, opts)
+	if err != nil {
+		return err
+	}
+	// Discards the partial output unless it was committed below
+	defer out.Close()
+
+	if err := toHandler.WriterFn(ctx, out.Writer(), opts.OutputFile, data, opts.OutOptions); err != nil {
+		return fmt.Errorf("failed to write output '%s': %w", opts.OutputFile, err)
+	}
+	if err := out.Commit(); err != nil {
+		return fmt.Errorf("failed to write output '%s': %w", opts.OutputFile, err)
+	}
+	return nil
+}
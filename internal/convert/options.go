@@ -0,0 +1,183 @@
+package convert
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// OptionType identifies the kind of value a format option accepts.
+type OptionType string
+
+const (
+	OptionString OptionType = "string"
+	OptionInt    OptionType = "int"
+	OptionBool   OptionType = "bool"
+	OptionChar   OptionType = "char"
+)
+
+/*
+OptionSpec declares a single reader or writer option accepted by a format.
+
+Fields:
+- Name: option key as written on the command line (e.g. "delimiter").
+- Type: expected value type; values are validated before the handler runs.
+- Default: default value shown in help output (the handler applies it).
+- Description: one-line help text.
+- Values: allowed values for enumerated string options (empty means any).
+*/
+type OptionSpec struct {
+	Name        string
+	Type        OptionType
+	Default     string
+	Description string
+	Values      []string
+}
+
+// FormatOptions holds reader or writer settings for a single format, keyed by option name.
+// Values are kept as text and read back through the typed getters below.
+type FormatOptions map[string]string
+
+/*
+ParseFormatOptions parses "key=value" pairs as given to --in-opt / --out-opt.
+
+- Keys are case-insensitive and stored in lowercase.
+- The value may be empty ("comment=") and may itself contain "=".
+*/
+func ParseFormatOptions(pairs []string) (FormatOptions, error) {
+	opts := FormatOptions{}
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid option %q, expected key=value", pair)
+		}
+		opts[key] = value
+	}
+	return opts, nil
+}
+
+// String returns the option value, or def if it is not set.
+func (o FormatOptions) String(name, def string) string {
+	if v, ok := o[name]; ok {
+		return v
+	}
+	return def
+}
+
+// Int returns the option as an integer, or def if it is not set or invalid.
+func (o FormatOptions) Int(name string, def int) int {
+	if v, ok := o[name]; ok {
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+// Bool returns the option as a boolean, or def if it is not set or invalid.
+func (o FormatOptions) Bool(name string, def bool) bool {
+	if v, ok := o[name]; ok {
+		if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+			return b
+		}
+	}
+	return def
+}
+
+// Char returns the option as a single character, or def if it is not set or invalid.
+// An empty value yields 0, which handlers treat as "disabled" (e.g. no comment character).
+func (o FormatOptions) Char(name string, def rune) rune {
+	if v, ok := o[name]; ok {
+		if r, err := parseChar(v); err == nil {
+			return r
+		}
+	}
+	return def
+}
+
+// parseChar parses a single character, accepting "\t" and "tab" for a tab.
+func parseChar(v string) (rune, error) {
+	switch v {
+	case "":
+		return 0, nil
+	case `\t`, "tab":
+		return '\t', nil
+	}
+	if utf8.RuneCountInString(v) != 1 {
+		return 0, fmt.Errorf("expected a single character, got %q", v)
+	}
+	r, _ := utf8.DecodeRuneInString(v)
+	return r, nil
+}
+
+/*
+ValidateOptions checks opts against the declared specs.
+
+Returns an error if:
+- An option is not declared (the message lists the accepted options).
+- A value does not parse as the declared type or is not one of the allowed values.
+*/
+func ValidateOptions(specs []OptionSpec, opts FormatOptions) error {
+	byName := make(map[string]OptionSpec, len(specs))
+	for _, spec := range specs {
+		byName[spec.Name] = spec
+	}
+
+	keys := make([]string, 0, len(opts))
+	for k := range opts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := opts[key]
+		spec, ok := byName[key]
+		if !ok {
+			return fmt.Errorf("unknown option %q (accepted: %s)", key, optionNames(specs))
+		}
+
+		var err error
+		switch spec.Type {
+		case OptionInt:
+			_, err = strconv.Atoi(strings.TrimSpace(value))
+		case OptionBool:
+			_, err = strconv.ParseBool(strings.TrimSpace(value))
+		case OptionChar:
+			_, err = parseChar(value)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value %q for option %q: expected %s", value, key, spec.Type)
+		}
+
+		if len(spec.Values) > 0 && !containsFold(spec.Values, value) {
+			return fmt.Errorf("invalid value %q for option %q: expected one of %s",
+				value, key, strings.Join(spec.Values, ", "))
+		}
+	}
+	return nil
+}
+
+// optionNames lists the declared option names for error messages.
+func optionNames(specs []OptionSpec) string {
+	if len(specs) == 0 {
+		return "none"
+	}
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		names[i] = spec.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// containsFold reports whether values contains v, ignoring case.
+func containsFold(values []string, v string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package convert
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+/*
+atomicFile writes to a temporary file in the destination directory and renames it
+over the destination only on Commit.
+
+- A failed or interrupted conversion never leaves a truncated file behind.
+- An existing destination keeps its permissions when it is replaced.
+- Close without Commit discards the temporary file.
+*/
+type atomicFile struct {
+	*os.File
+	path string
+	done bool
+}
+
+// createAtomic opens a temporary file that will replace path on Commit.
+func createAtomic(path string) (*atomicFile, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	// CreateTemp uses 0600; match what os.Create would have produced instead
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("failed to set output file permissions: %w", err)
+	}
+
+	return &atomicFile{File: tmp, path: path}, nil
+}
+
+// Commit flushes the temporary file to disk and renames it over the destination.
+func (f *atomicFile) Commit() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+
+	if err := f.File.Sync(); err != nil {
+		f.discard()
+		return fmt.Errorf("failed to sync output file: %w", err)
+	}
+	if err := f.File.Close(); err != nil {
+		os.Remove(f.File.Name())
+		return fmt.Errorf("failed to close output file: %w", err)
+	}
+	if err := os.Rename(f.File.Name(), f.path); err != nil {
+		os.Remove(f.File.Name())
+		return fmt.Errorf("failed to move output into place: %w", err)
+	}
+	return nil
+}
+
+// Close discards the temporary file unless Commit already succeeded.
+func (f *atomicFile) Close() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+	f.discard()
+	return nil
+}
+
+// discard closes and removes the temporary file.
+func (f *atomicFile) discard() {
+	f.File.Close()
+	os.Remove(f.File.Name())
+}
+
+/*
+outputTarget is an open conversion output (a file or STDOUT, optionally Gzip-compressed).
+
+Data written to it only reaches the destination once Commit succeeds, which also
+writes the Gzip footer. Close without Commit discards a file output.
+*/
+type outputTarget struct {
+	w    io.Writer
+	gzip io.Closer
+	file *atomicFile
+}
+
+// Writer returns where the converted data goes; nil for targets such as SQL that
+// have no output stream.
+func (o *outputTarget) Writer() io.Writer {
+	if o == nil {
+		return nil
+	}
+	return o.w
+}
+
+// Commit finishes compression and moves a file output into place.
+func (o *outputTarget) Commit() error {
+	if o == nil {
+		return nil
+	}
+	if o.gzip != nil {
+		if err := o.gzip.Close(); err != nil {
+			o.Close()
+			return fmt.Errorf("failed to finish gzip stream: %w", err)
+		}
+		o.gzip = nil
+	}
+	if o.file != nil {
+		return o.file.Commit()
+	}
+	return nil
+}
+
+// Close releases the output, discarding it if it was not committed.
+func (o *outputTarget) Close() error {
+	if o != nil && o.file != nil {
+		return o.file.Close()
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package convert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"omnidata/internal/dataset"
+)
+
+// ErrorMode selects what happens to a record that cannot be read or written.
+type ErrorMode string
+
+const (
+	// OnErrorFail aborts the conversion on the first bad record (the default).
+	OnErrorFail ErrorMode = "fail"
+	// OnErrorSkip drops bad records and carries on.
+	OnErrorSkip ErrorMode = "skip"
+	// OnErrorQuarantine drops bad records and writes them to the rejects output.
+	OnErrorQuarantine ErrorMode = "quarantine"
+)
+
+// ParseErrorMode parses an error mode name as accepted on the command line.
+func ParseErrorMode(s string) (ErrorMode, error) {
+	switch mode := ErrorMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return OnErrorFail, nil
+	case OnErrorFail, OnErrorSkip, OnErrorQuarantine:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid error mode %q (expected fail, skip or quarantine)", s)
+	}
+}
+
+/*
+ErrorPolicy decides what happens to bad records during a conversion.
+
+Fields:
+- Mode: fail (the zero value), skip or quarantine.
+- MaxErrors: the conversion still fails once more than this many records were rejected (0 means no limit).
+- Rejects: where quarantined records are written, one JSON object per line (see RecordError).
+
+Only record-level problems go through the policy (e.g. a malformed CSV line or a row
+rejected by the database); a document that cannot be parsed at all still fails.
+*/
+type ErrorPolicy struct {
+	Mode      ErrorMode
+	MaxErrors int
+	Rejects   io.Writer
+}
+
+// Validate checks that the policy is complete.
+func (p ErrorPolicy) Validate() error {
+	if _, err := ParseErrorMode(string(p.Mode)); err != nil {
+		return err
+	}
+	if p.MaxErrors < 0 {
+		return fmt.Errorf("max errors must not be negative, got %d", p.MaxErrors)
+	}
+	if p.Mode == OnErrorQuarantine && p.Rejects == nil {
+		return fmt.Errorf("quarantine needs a rejects output")
+	}
+	return nil
+}
+
+/*
+RecordError describes a record rejected by a reader or a writer.
+
+Fields:
+- Input: the input the record comes from (set by the engine).
+- Line: line number of the record in the input, for line-based formats (0 if unknown).
+- Row: 1-based number of the record among the data records.
+- Record: the offending record: its raw text, or its values as a column map.
+- Err: why the record was rejected.
+*/
+type RecordError struct {
+	Input  string
+	Line   int
+	Row    int
+	Record interface{}
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// rejectsMu serializes lines written to a rejects output shared by batch conversions.
+var rejectsMu sync.Mutex
+
+// rejecter applies an error policy to the records of a single conversion.
+type rejecter struct {
+	policy ErrorPolicy
+	input  string
+	mu     sync.Mutex
+	count  int
+}
+
+type rejecterKey struct{}
+
+// withRejecter returns a context carrying the policy for the conversion of input.
+func withRejecter(ctx context.Context, policy ErrorPolicy, input string) context.Context {
+	return context.WithValue(ctx, rejecterKey{}, &rejecter{policy: policy, input: input})
+}
+
+// rejectedIn returns the number of records rejected so far by the conversion running under ctx.
+func rejectedIn(ctx context.Context) int {
+	r, ok := ctx.Value(rejecterKey{}).(*rejecter)
+	if !ok {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count
+}
+
+/*
+SkipsBadRecords reports whether the conversion running under ctx drops bad records
+instead of failing. Handlers check it before doing any extra work to recover from a
+bad record (such as keeping its raw text), then pass the record to Reject.
+*/
+func SkipsBadRecords(ctx context.Context) bool {
+	r, ok := ctx.Value(rejecterKey{}).(*rejecter)
+	return ok && r.policy.Mode != "" && r.policy.Mode != OnErrorFail
+}
+
+/*
+Reject hands a bad record to the error policy of the conversion running under ctx.
+
+- It returns nil when the record was dropped (and quarantined if so configured); the
+handler then carries on with the next record.
+- It returns an error when the conversion must stop: the policy is fail, the
+--max-errors threshold was passed, or the rejects output cannot be written.
+*/
+func Reject(ctx context.Context, rec *RecordError) error {
+	r, ok := ctx.Value(rejecterKey{}).(*rejecter)
+	if !ok || !SkipsBadRecords(ctx) {
+		return rec
+	}
+	if rec.Input == "" {
+		rec.Input = r.input
+	}
+
+	r.mu.Lock()
+	r.count++
+	count := r.count
+	r.mu.Unlock()
+
+	if max := r.policy.MaxErrors; max > 0 && count > max {
+		return fmt.Errorf("more than %d bad records, giving up: %w", max, rec)
+	}
+	if r.policy.Mode == OnErrorQuarantine {
+		if err := writeReject(r.policy.Rejects, rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeReject appends a record to the rejects output as a single JSON line.
+func writeReject(w io.Writer, rec *RecordError) error {
+	record := rec.Record
+	if values, ok := record.(map[string]interface{}); ok {
+		// Typed cells are written in their canonical text form
+		fields := make(map[string]interface{}, len(values))
+		for k, v := range values {
+			fields[k] = dataset.FormatValue(v)
+			if v == nil {
+				fields[k] = nil
+			}
+		}
+		record = fields
+	}
+
+	line, err := json.Marshal(struct {
+		Input  string      `json:"input,omitempty"`
+		Line   int         `json:"line,omitempty"`
+		Row    int         `json:"row,omitempty"`
+		Error  string      `json:"error"`
+		Record interface{} `json:"record"`
+	}{rec.Input, rec.Line, rec.Row, rec.Err.Error(), record})
+	if err != nil {
+		return fmt.Errorf("failed to encode rejected record: %w", err)
+	}
+
+	rejectsMu.Lock()
+	defer rejectsMu.Unlock()
+	if _, err := w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write rejected record: %w", err)
+	}
+	return nil
+}
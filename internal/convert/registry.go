@@ -1,8 +1,13 @@
 package convert
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"strings"
+
+	"omnidata/internal/dataset"
+	"omnidata/internal/stream"
 )
 
 /*
@@ -10,13 +15,69 @@ FormatHandler defines a data format and its associated read/write functions.
 
 Responsibilities:
 - Name: the canonical format name (e.g., "csv", "json", "xml", "xlsx").
-- ReaderFn: function to read data from a given file path.
-- WriterFn: function to write data to a given file path.
+- ReaderFn: function to read data from a given file path, with the reader options.
+- WriterFn: function to write data to a given file path, with the writer options; accepts *dataset.Dataset too.
+- ToDataset: adapter from the native ReaderFn output to *dataset.Dataset (nil if already one).
+- StreamReaderFn: optional row-by-row reader used by streaming conversions.
+- StreamWriterFn: optional row-by-row writer used by streaming conversions.
+- DocumentFn: optional reader of the whole input as one document of ordered objects, lists and values, used by document conversions instead of ReaderFn.
+- SummaryFn: optional reader of the row count, column types and statistics a file keeps in its metadata, used by peek instead of reading every row.
+- Nested: true if the format can hold nested objects and arrays; tabular targets get flattened values.
+- Extensions: file extensions (e.g. ".csv") used to detect the format from a path.
+- Signatures: leading bytes (e.g. "PAR1") used to detect the format from content.
+- ReaderOptions / WriterOptions: options accepted through --in-opt / --out-opt.
+- Plugin: path of the external executable implementing the format (empty for built-in formats).
+
+Reader and writer functions receive the conversion's context first; handlers that manage
+connections or processes of their own (SQL, plugins) must stop when it is cancelled.
 */
 type FormatHandler struct {
-	Name     string
-	ReaderFn func(r io.Reader, resource string) (interface{}, error)
-	WriterFn func(w io.Writer, resource string, data interface{}) error
+	Name           string
+	ReaderFn       func(ctx context.Context, r io.Reader, resource string, opts FormatOptions) (interface{}, error)
+	WriterFn       func(ctx context.Context, w io.Writer, resource string, data interface{}, opts FormatOptions) error
+	ToDataset      func(data interface{}) (*dataset.Dataset, error)
+	StreamReaderFn func(ctx context.Context, r io.Reader, resource string, opts FormatOptions) (stream.StreamingReader, error)
+	StreamWriterFn func(ctx context.Context, w io.Writer, resource string, columns []string, opts FormatOptions) (stream.StreamingWriter, error)
+	SummaryFn      func(ctx context.Context, r io.Reader, resource string, opts FormatOptions, rows int) (*Summary, error)
+	DocumentFn     func(ctx context.Context, r io.Reader, resource string, opts FormatOptions) (interface{}, error)
+	Nested         bool
+	Extensions     []string
+	Signatures     [][]byte
+	ReaderOptions  []OptionSpec
+	WriterOptions  []OptionSpec
+	Plugin         string
+}
+
+/*
+AsDataset converts data produced by this handler's ReaderFn into a canonical Dataset.
+
+- Data that is already a *dataset.Dataset is returned unchanged.
+- Otherwise the handler's ToDataset adapter is applied.
+*/
+func (h FormatHandler) AsDataset(data interface{}) (*dataset.Dataset, error) {
+	if ds, ok := data.(*dataset.Dataset); ok {
+		return ds, nil
+	}
+	if h.ToDataset == nil {
+		return nil, fmt.Errorf("format %s does not provide a dataset adapter", h.Name)
+	}
+	return h.ToDataset(data)
+}
+
+// ValidateReaderOptions checks options passed to this handler's reader.
+func (h FormatHandler) ValidateReaderOptions(opts FormatOptions) error {
+	if err := ValidateOptions(h.ReaderOptions, opts); err != nil {
+		return fmt.Errorf("%s reader: %w", h.Name, err)
+	}
+	return nil
+}
+
+// ValidateWriterOptions checks options passed to this handler's writer.
+func (h FormatHandler) ValidateWriterOptions(opts FormatOptions) error {
+	if err := ValidateOptions(h.WriterOptions, opts); err != nil {
+		return fmt.Errorf("%s writer: %w", h.Name, err)
+	}
+	return nil
 }
 
 /*
@@ -1,11 +1,16 @@
 package convert
 
 import (
+	"bufio"
+	"bytes"
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+
+	"omnidata/internal/dataset"
 )
 
 /*
@@ -17,7 +22,16 @@ Fields:
 - From: source format name (csv, json, xml, xlsx).
 - To: target format name (csv, json, xml, xlsx).
 - DryRun: if true, simulates conversion without writing output.
+- Force: if true, an existing output file is replaced (atomically, once the conversion succeeds).
 - Stream: if true, uses streaming mode for large files (memory-efficient).
+- Flatten: how nested values are flattened into columns for tabular targets (and rebuilt for nested ones).
+- NoFlatten: if true, nested values are kept as-is (JSON text in tabular targets) and never rebuilt.
+- InOptions: reader options for the source format (--in-opt key=value).
+- OutOptions: writer options for the target format (--out-opt key=value).
+- Workers: maximum number of files converted at once by RunBatch (0 means one per CPU).
+- Quiet: if true, success and dry-run messages are not printed.
+- OnError: what happens to bad records (--on-error, --max-errors, --rejects); fails by default.
+- Schema: if set, records are cast to it and checked before writing (--schema); non-conforming ones go through OnError.
 */
 type Options struct {
 	InputFile  string
@@ -25,27 +39,64 @@ type Options struct {
 	From       string
 	To         string
 	DryRun     bool
+	Force      bool
 	Stream     bool
+	Flatten    dataset.FlattenOptions
+	NoFlatten  bool
+	InOptions  FormatOptions
+	OutOptions FormatOptions
+	Workers    int
+	Quiet      bool
+	OnError    ErrorPolicy
+	Schema     *Schema
+	// Document converts the input as one document between formats that nest values,
+	// without going through a Dataset (see FormatHandler.DocumentFn)
+	Document bool
 }
 
 /*
 Run executes a conversion job based on Options.
 
+Cancelling ctx stops reads and writes in flight; the partial output is discarded
+and the returned error wraps the context's cause (see Stopped).
+
 Responsibilities:
-- Validate formats and file paths.
+- Detect missing formats from file extensions or content, then validate them and file paths.
 - Handle dry-run simulations.
 - Read from the source format and write to the target format.
 - Wrap errors with detailed context.
 - Support cross-platform STDIN/STDOUT.
 - Support transparent Gzip compression/decompression.
 */
-func Run(opts Options) error {
+func Run(ctx context.Context, opts Options) error {
+	return Stopped(ctx, run(ctx, opts))
+}
+
+// run implements Run; errors caused by a cancelled ctx are reported by Run.
+func run(ctx context.Context, opts Options) error {
 	// ---------------------------
-	// Step 1: Validate formats
+	// Step 1: Detect and validate formats
 	// ---------------------------
+	if err := resolveFormats(&opts); err != nil {
+		return fmt.Errorf("invalid format selection: %w", err)
+	}
 	if err := ValidateFormats(opts.From, opts.To); err != nil {
 		return fmt.Errorf("invalid format selection: %w", err)
 	}
+	if err := Registry[opts.From].ValidateReaderOptions(opts.InOptions); err != nil {
+		return fmt.Errorf("invalid input options: %w", err)
+	}
+	if err := Registry[opts.To].ValidateWriterOptions(opts.OutOptions); err != nil {
+		return fmt.Errorf("invalid output options: %w", err)
+	}
+	if err := opts.OnError.Validate(); err != nil {
+		return fmt.Errorf("invalid error policy: %w", err)
+	}
+	if opts.Schema != nil {
+		if err := opts.Schema.Validate(); err != nil {
+			return fmt.Errorf("invalid schema: %w", err)
+		}
+	}
 
 	// ---------------------------
 	// Step 2: Resolve paths
@@ -74,8 +125,10 @@ func Run(opts Options) error {
 			}
 		}
 
-		fmt.Printf("[Dry-run] Conversion simulation succeeded: %s (%s) -> %s (%s)\n",
-			opts.InputFile, opts.From, opts.OutputFile, opts.To)
+		if !opts.Quiet {
+			fmt.Printf("[Dry-run] Conversion simulation succeeded: %s (%s) -> %s (%s)\n",
+				opts.InputFile, opts.From, opts.OutputFile, opts.To)
+		}
 		return nil
 	}
 
@@ -91,100 +144,253 @@ func Run(opts Options) error {
 		return fmt.Errorf("no writer registered for format: %s", opts.To)
 	}
 
-	// ---------------------------
-	// Step 5: Prepare Input Reader
-	// ---------------------------
-	var reader io.ReadCloser
-	if opts.From != "sql" {
-		if opts.InputFile == "-" {
-			reader = os.Stdin
-		} else {
-			f, err := os.Open(opts.InputFile)
-			if err != nil {
-				return fmt.Errorf("failed to open input file: %w", err)
-			}
-			reader = f
-		}
+	// Bad records reach the error policy through the context
+	ctx = withRejecter(ctx, opts.OnError, opts.InputFile)
 
-		// Handle Gzip input
-		if strings.HasSuffix(opts.InputFile, ".gz") {
-			gzReader, err := gzip.NewReader(reader)
-			if err != nil {
-				reader.Close()
-				return fmt.Errorf("failed to create gzip reader: %w", err)
-			}
-			// Wrap to close both
-			reader = &readCloserWrapper{Reader: gzReader, Closer: reader}
+	if opts.Document {
+		if err := checkDocument(opts, fromHandler, toHandler); err != nil {
+			return fmt.Errorf("invalid document conversion: %w", err)
 		}
-	} else {
-		// For SQL, we don't provide a reader, the handler manages the connection string
-		reader = nil
+		doc, err := readDocument(ctx, opts, fromHandler)
+		if err != nil {
+			return err
+		}
+		if err := writeData(ctx, opts, toHandler, doc); err != nil {
+			return err
+		}
+		fmt.Fprintf(statusWriter(opts), "Successfully converted %s (%s) -> %s (%s)\n",
+			opts.InputFile, opts.From, opts.OutputFile, opts.To)
+		return nil
 	}
 
-	if reader != nil && opts.InputFile != "-" {
-		defer reader.Close()
+	// ---------------------------
+	// Step 5: Streaming mode
+	// ---------------------------
+	if opts.Stream {
+		if fromHandler.StreamReaderFn != nil && toHandler.StreamWriterFn != nil {
+			return runStream(ctx, opts, fromHandler, toHandler)
+		}
+		fmt.Fprintf(os.Stderr, "Streaming is not supported for %s -> %s, falling back to in-memory conversion\n",
+			opts.From, opts.To)
 	}
 
 	// ---------------------------
 	// Step 6: Read input data
 	// ---------------------------
-	data, err := fromHandler.ReaderFn(reader, opts.InputFile)
+	ds, err := readDataset(ctx, opts, fromHandler)
 	if err != nil {
-		return fmt.Errorf("failed to read input '%s': %w", opts.InputFile, err)
+		return err
 	}
-
-	// ---------------------------
-	// Step 7: Prepare Output Writer
-	// ---------------------------
-	var writer io.WriteCloser
-	if opts.To != "sql" {
-		if opts.OutputFile == "-" {
-			writer = os.Stdout
-		} else {
-			f, err := os.Create(opts.OutputFile)
-			if err != nil {
-				return fmt.Errorf("failed to create output file: %w", err)
-			}
-			writer = f
-		}
-
-		// Handle Gzip output
-		if strings.HasSuffix(opts.OutputFile, ".gz") {
-			gzWriter := gzip.NewWriter(writer)
-			// Wrap to close both (gzip flush + file close)
-			writer = &writeCloserWrapper{Writer: gzWriter, Closer: writer}
+	if opts.Schema != nil {
+		ds, err = applySchema(ctx, opts.Schema, ds)
+		if err != nil {
+			return fmt.Errorf("input '%s' does not match the schema: %w", opts.InputFile, err)
 		}
-	} else {
-		// For SQL, writer is nil
-		writer = nil
 	}
 
-	if writer != nil && opts.OutputFile != "-" {
-		defer writer.Close()
+	// Flatten nested values for tabular targets, or rebuild them for nested ones
+	if needsReshape(opts, fromHandler, toHandler) {
+		ds, err = reshape(opts, toHandler, ds)
+		if err != nil {
+			return fmt.Errorf("failed to reshape input '%s' for %s: %w", opts.InputFile, opts.To, err)
+		}
 	}
 
 	// ---------------------------
-	// Step 8: Write output data
+	// Step 7: Write output data
 	// ---------------------------
-	if err := toHandler.WriterFn(writer, opts.OutputFile, data); err != nil {
-		return fmt.Errorf("failed to write output '%s': %w", opts.OutputFile, err)
+	if err := writeDataset(ctx, opts, toHandler, ds); err != nil {
+		return err
 	}
 
 	// ---------------------------
-	// Step 9: Success message
+	// Step 8: Success message
 	// ---------------------------
-	fmt.Printf("Successfully converted %s (%s) -> %s (%s)\n",
+	fmt.Fprintf(statusWriter(opts), "Successfully converted %s (%s) -> %s (%s)\n",
 		opts.InputFile, opts.From, opts.OutputFile, opts.To)
+	reportRejected(ctx, opts)
 
 	return nil
 }
 
+// reportRejected notes how many bad records the error policy dropped.
+func reportRejected(ctx context.Context, opts Options) {
+	n := rejectedIn(ctx)
+	if n == 0 {
+		return
+	}
+	if opts.OnError.Mode == OnErrorQuarantine {
+		fmt.Fprintf(statusWriter(opts), "Quarantined %d bad record(s)\n", n)
+		return
+	}
+	fmt.Fprintf(statusWriter(opts), "Skipped %d bad record(s)\n", n)
+}
+
+// statusWriter returns where progress messages go: STDERR when the converted
+// data itself is written to STDOUT, so the two never mix, and nowhere when quiet.
+func statusWriter(opts Options) io.Writer {
+	if opts.Quiet {
+		return io.Discard
+	}
+	if opts.OutputFile == "" && opts.To != "sql" {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// checkDocument reports why a document conversion between the given formats is not possible.
+func checkDocument(opts Options, fromHandler, toHandler FormatHandler) error {
+	for _, h := range []FormatHandler{fromHandler, toHandler} {
+		if !h.Nested {
+			return fmt.Errorf("%s is tabular; documents convert between formats that nest values (json, yaml, xml)", h.Name)
+		}
+	}
+	if opts.Stream {
+		return fmt.Errorf("documents are converted in memory and cannot be streamed")
+	}
+	if opts.Schema != nil {
+		return fmt.Errorf("a schema applies to records, not documents")
+	}
+	return nil
+}
+
+// needsReshape reports whether records must be flattened or unflattened between
+// the source and target formats.
+func needsReshape(opts Options, fromHandler, toHandler FormatHandler) bool {
+	return !opts.NoFlatten && fromHandler.Nested != toHandler.Nested
+}
+
+// reshape flattens nested values when the target is tabular and rebuilds nested
+// values from flattened column names when the target supports nesting.
+func reshape(opts Options, toHandler FormatHandler, ds *dataset.Dataset) (*dataset.Dataset, error) {
+	if toHandler.Nested {
+		return dataset.Unflatten(ds, opts.Flatten)
+	}
+	return dataset.Flatten(ds, opts.Flatten), nil
+}
+
+// openInput opens the input described by opts; reads fail once ctx is done.
+// Returns a nil reader for SQL sources, whose handler manages the connection itself.
+func openInput(ctx context.Context, opts Options) (io.ReadCloser, error) {
+	if opts.From == "sql" {
+		return nil, nil
+	}
+	reader, err := OpenInput(opts.InputFile)
+	if err != nil {
+		return nil, err
+	}
+	return &readCloserWrapper{Reader: ContextReader(ctx, reader), Closer: reader}, nil
+}
+
+/*
+OpenInput opens a file for reading, or STDIN for "-" or "".
+
+Gzip input is decompressed transparently, recognized by a ".gz" extension or by
+its magic bytes. Closing the returned reader never closes STDIN.
+*/
+func OpenInput(path string) (io.ReadCloser, error) {
+	var reader io.ReadCloser
+	var buffered *bufio.Reader
+	if path == "-" || path == "" {
+		buffered = bufferedStdin()
+		reader = io.NopCloser(buffered)
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input file: %w", err)
+		}
+		buffered = bufio.NewReader(f)
+		reader = &readCloserWrapper{Reader: buffered, Closer: f}
+	}
+
+	// Handle Gzip input
+	magic, _ := buffered.Peek(len(gzipMagic))
+	if strings.HasSuffix(path, ".gz") || bytes.Equal(magic, gzipMagic) {
+		gzReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			reader.Close()
+			return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+		// Wrap to close both
+		reader = &readCloserWrapper{Reader: gzReader, Closer: reader}
+	}
+
+	return reader, nil
+}
+
+// fileInput is implemented by readers that read a file of their own.
+type fileInput interface {
+	File() *os.File
+}
+
+/*
+InputFile returns the regular file r reads from, or nil when r reads STDIN,
+decompressed data or anything else.
+
+Formats that need random access, such as Parquet with its footer, read through the
+file instead of buffering the whole input. Readers from OpenInput and ContextReader
+expose their file.
+*/
+func InputFile(r io.Reader) *os.File {
+	switch v := r.(type) {
+	case *os.File:
+		if info, err := v.Stat(); err == nil && info.Mode().IsRegular() {
+			return v
+		}
+	case fileInput:
+		return v.File()
+	}
+	return nil
+}
+
+// openOutput creates the output described by opts, transparently compressing ".gz" files.
+// File outputs are written atomically (see atomicFile) and must be committed; writes
+// fail once ctx is done.
+// Returns a nil target for SQL targets, whose handler manages the connection itself.
+func openOutput(ctx context.Context, opts Options) (*outputTarget, error) {
+	if opts.To == "sql" {
+		return nil, nil
+	}
+
+	out := &outputTarget{}
+	if opts.OutputFile == "-" || opts.OutputFile == "" {
+		out.w = os.Stdout
+	} else {
+		f, err := createAtomic(opts.OutputFile)
+		if err != nil {
+			return nil, err
+		}
+		out.w = f
+		out.file = f
+	}
+
+	// Handle Gzip output; the footer is written on Commit
+	if strings.HasSuffix(opts.OutputFile, ".gz") {
+		gzWriter := gzip.NewWriter(out.w)
+		out.w = gzWriter
+		out.gzip = gzWriter
+	}
+	out.w = ContextWriter(ctx, out.w)
+
+	return out, nil
+}
+
 // readCloserWrapper wraps a Reader (e.g. gzip) and an underlying Closer (e.g. file)
 type readCloserWrapper struct {
 	io.Reader
 	Closer io.Closer
 }
 
+// File returns the file a buffered file reader reads, or the file of the wrapped reader.
+func (w *readCloserWrapper) File() *os.File {
+	if f, ok := w.Closer.(*os.File); ok {
+		if _, buffered := w.Reader.(*bufio.Reader); buffered {
+			return InputFile(f)
+		}
+	}
+	return InputFile(w.Reader)
+}
+
 func (w *readCloserWrapper) Close() error {
 	// First convert Reader to Closer if possible (e.g. gzip reader should be closed?)
 	// gzip.Reader.Close is actually important if it has checksums, but it implements ReadCloser since go1.10?
@@ -197,20 +403,3 @@ func (w *readCloserWrapper) Close() error {
 	}
 	return w.Closer.Close()
 }
-
-// writeCloserWrapper wraps a Writer (e.g. gzip) and an underlying Closer (e.g. file)
-type writeCloserWrapper struct {
-	io.Writer
-	Closer io.Closer
-}
-
-func (w *writeCloserWrapper) Close() error {
-	// Must close gzip writer to flush footer
-	if wc, ok := w.Writer.(io.Closer); ok {
-		if err := wc.Close(); err != nil {
-			w.Closer.Close()
-			return err
-		}
-	}
-	return w.Closer.Close()
-}
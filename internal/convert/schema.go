@@ -0,0 +1,206 @@
+package convert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"omnidata/internal/dataset"
+)
+
+/*
+Schema declares the columns of the records a conversion writes (--schema).
+
+Every record is cast to the declared column types and checked for nullability, and
+its columns are put in the declared order. A record that does not conform goes through
+the error policy (see ErrorPolicy), so it fails the conversion unless bad records are
+skipped. An input whose columns do not match the schema fails as a whole.
+*/
+type Schema struct {
+	Columns []SchemaColumn
+}
+
+/*
+SchemaColumn is a single column of a Schema.
+
+Fields:
+- Name: the column name.
+- Type: a type name accepted by dataset.ParseKind (integer, number, date, ...); "" or
+"null" leaves the values untyped.
+- Nullable: whether the column may hold nulls. Empty text counts as null, since text
+formats cannot express it otherwise. A non-nullable column must be present in the
+input; a nullable one that is missing is filled with nulls.
+*/
+type SchemaColumn struct {
+	Name     string
+	Type     string
+	Nullable bool
+}
+
+// LoadSchema reads a schema file (see ParseSchema).
+func LoadSchema(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	schema, err := ParseSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema '%s': %w", path, err)
+	}
+	return schema, nil
+}
+
+/*
+ParseSchema parses a schema from JSON.
+
+It accepts the output of 'omnidata peek --output-format json' (the JSON schema formatter) as
+well as hand-written files such as:
+
+	{"columns": [{"name": "id", "type": "integer", "nullable": false}, {"name": "email"}]}
+
+Keys are matched case-insensitively and other keys are ignored. Columns are nullable
+unless "nullable" is false.
+*/
+func ParseSchema(data []byte) (*Schema, error) {
+	var doc struct {
+		Columns []struct {
+			Name     string
+			Type     string
+			Nullable *bool
+		}
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Columns) == 0 {
+		return nil, fmt.Errorf("no columns declared")
+	}
+
+	schema := &Schema{}
+	for _, col := range doc.Columns {
+		schema.Columns = append(schema.Columns, SchemaColumn{
+			Name:     col.Name,
+			Type:     col.Type,
+			Nullable: col.Nullable == nil || *col.Nullable,
+		})
+	}
+	if err := schema.Validate(); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// Validate checks that the column names are unique and the types known.
+func (s *Schema) Validate() error {
+	seen := make(map[string]bool, len(s.Columns))
+	for i, col := range s.Columns {
+		if col.Name == "" {
+			return fmt.Errorf("column %d has no name", i+1)
+		}
+		if seen[col.Name] {
+			return fmt.Errorf("column %q is declared twice", col.Name)
+		}
+		seen[col.Name] = true
+		if _, _, err := col.kind(); err != nil {
+			return fmt.Errorf("column %q: %w", col.Name, err)
+		}
+	}
+	return nil
+}
+
+// kind returns the kind values are cast to, and false for untyped columns.
+func (c SchemaColumn) kind() (dataset.Kind, bool, error) {
+	switch strings.ToLower(strings.TrimSpace(c.Type)) {
+	case "", "null":
+		return dataset.KindNull, false, nil
+	}
+	kind, err := dataset.ParseKind(c.Type)
+	return kind, err == nil, err
+}
+
+// names returns the declared column names in order.
+func (s *Schema) names() []string {
+	names := make([]string, len(s.Columns))
+	for i, col := range s.Columns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+// checkColumns verifies that the input columns can be conformed to the schema.
+func (s *Schema) checkColumns(columns []string) error {
+	present := make(map[string]bool, len(columns))
+	for _, name := range columns {
+		present[name] = true
+	}
+	declared := make(map[string]bool, len(s.Columns))
+	for _, col := range s.Columns {
+		declared[col.Name] = true
+		if !present[col.Name] && !col.Nullable {
+			return fmt.Errorf("column %q is missing from the input", col.Name)
+		}
+	}
+	for _, name := range columns {
+		if !declared[name] {
+			return fmt.Errorf("input column %q is not declared in the schema", name)
+		}
+	}
+	return nil
+}
+
+// conform casts the values of a record to the schema, returning them in column order.
+func (s *Schema) conform(record map[string]interface{}) ([]interface{}, error) {
+	values := make([]interface{}, len(s.Columns))
+	for i, col := range s.Columns {
+		v := record[col.Name]
+		if kind, typed, _ := col.kind(); typed {
+			cast, err := dataset.Cast(v, kind)
+			if err != nil {
+				return nil, fmt.Errorf("column %q: %w", col.Name, err)
+			}
+			v = cast
+		}
+		if (v == nil || v == "") && !col.Nullable {
+			return nil, fmt.Errorf("column %q: null value in a non-nullable column", col.Name)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// conformRow conforms a streamed row, returning it as a map.
+func (s *Schema) conformRow(row map[string]interface{}) (map[string]interface{}, error) {
+	values, err := s.conform(row)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]interface{}, len(values))
+	for i, col := range s.Columns {
+		out[col.Name] = values[i]
+	}
+	return out, nil
+}
+
+// applySchema conforms every record of ds to the schema, handing the records that
+// do not conform to the error policy of the conversion running under ctx.
+func applySchema(ctx context.Context, s *Schema, ds *dataset.Dataset) (*dataset.Dataset, error) {
+	if err := s.checkColumns(ds.Columns); err != nil {
+		return nil, err
+	}
+
+	out := dataset.New(s.names())
+	for i := range ds.Records {
+		record := ds.Map(i)
+		values, err := s.conform(record)
+		if err != nil {
+			if err := Reject(ctx, &RecordError{Row: i + 1, Record: record, Err: err}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		out.Append(values...)
+	}
+	return out, nil
+}
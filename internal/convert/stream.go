@@ -0,0 +1,224 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"omnidata/internal/dataset"
+	"omnidata/internal/stream"
+)
+
+/*
+runStream executes a conversion row by row with constant memory.
+
+Responsibilities:
+- Open the input and the output described by opts.
+- Pipe the rows from one to the other with streamRows.
+- Move the output into place only once every row has been written.
+*/
+func runStream(ctx context.Context, opts Options, fromHandler, toHandler FormatHandler) error {
+	reader, err := openInput(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if reader != nil && opts.InputFile != "" {
+		defer reader.Close()
+	}
+
+	target, err := openOutput(ctx, opts)
+	if err != nil {
+		return err
+	}
+	// Discards the partial output unless it was committed below
+	defer target.Close()
+
+	result, err := streamRows(ctx, opts, fromHandler, toHandler, reader, target.Writer())
+	if err != nil {
+		return err
+	}
+	if err := target.Commit(); err != nil {
+		return fmt.Errorf("failed to finish output '%s': %w", opts.OutputFile, err)
+	}
+
+	fmt.Fprintf(statusWriter(opts), "Successfully streamed %d rows: %s (%s) -> %s (%s)\n",
+		result.RowsWritten, opts.InputFile, opts.From, opts.OutputFile, opts.To)
+	reportRejected(ctx, opts)
+
+	return nil
+}
+
+/*
+streamRows pipes every row of r into w through the handlers' streaming reader and writer.
+
+- The output columns come from the reader header or the first row, or from opts.Schema.
+- With opts.Schema, rows are conformed to it and the others go through the error policy.
+- Each row is flattened or unflattened when the source and target disagree on nesting.
+- The row writer is closed before returning so trailers get flushed; w itself is left open.
+*/
+func streamRows(ctx context.Context, opts Options, fromHandler, toHandler FormatHandler, r io.Reader, w io.Writer) (*Result, error) {
+	rows, err := fromHandler.StreamReaderFn(ctx, r, opts.InputFile, opts.InOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream for input '%s': %w", opts.InputFile, err)
+	}
+	defer rows.Close()
+
+	// Read the first row up front so the writer knows the columns
+	row, err := rows.ReadRow()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read input '%s': %w", opts.InputFile, err)
+	}
+	inColumns := streamColumns(rows, row)
+	if opts.Schema != nil {
+		if err := opts.Schema.checkColumns(inColumns); err != nil {
+			return nil, fmt.Errorf("input '%s' does not match the schema: %w", opts.InputFile, err)
+		}
+		inColumns = opts.Schema.names()
+	}
+	columns := inColumns
+
+	result := &Result{From: opts.From, To: opts.To}
+	// nextRow returns the next row conforming to the schema, starting with the first one
+	nextRow := func() (map[string]interface{}, error) {
+		for {
+			if row == nil {
+				next, err := rows.ReadRow()
+				if err == io.EOF {
+					return nil, err
+				}
+				if err != nil {
+					return nil, fmt.Errorf("failed to read input '%s' after row %d: %w", opts.InputFile, result.RowsRead, err)
+				}
+				row = next
+			}
+			current := row
+			row = nil
+			result.RowsRead++
+			if opts.Schema == nil {
+				return current, nil
+			}
+
+			conformed, err := opts.Schema.conformRow(current)
+			if err == nil {
+				return conformed, nil
+			}
+			if err := Reject(ctx, &RecordError{Row: result.RowsRead, Record: current, Err: err}); err != nil {
+				return nil, fmt.Errorf("input '%s' does not match the schema: %w", opts.InputFile, err)
+			}
+		}
+	}
+
+	// Reshaped rows may gain columns; the first one defines the output header
+	reshapeRows := needsReshape(opts, fromHandler, toHandler)
+	var batch []map[string]interface{}
+	first, err := nextRow()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if first != nil {
+		batch, columns, err = streamBatch(opts, toHandler, reshapeRows, inColumns, first)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reshape row %d of '%s': %w", result.RowsRead, opts.InputFile, err)
+		}
+	}
+
+	out, err := toHandler.StreamWriterFn(ctx, w, opts.OutputFile, columns, opts.OutOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream for output '%s': %w", opts.OutputFile, err)
+	}
+
+	// Tabular targets keep that header, so a later row must not bring values for other columns
+	var header map[string]bool
+	if !toHandler.Nested {
+		header = make(map[string]bool, len(columns))
+		for _, col := range columns {
+			header[col] = true
+		}
+	}
+
+	result.Columns = columns
+	for batch != nil {
+		for _, outRow := range batch {
+			if col, ok := extraColumn(header, outRow); ok {
+				out.Close()
+				return nil, fmt.Errorf("row %d of '%s' has a value for column %q, which is not in the header taken from the first row; "+
+					"convert without --stream or declare the columns with --schema", result.RowsRead, opts.InputFile, col)
+			}
+			if err := out.WriteRow(outRow); err != nil {
+				out.Close()
+				return nil, fmt.Errorf("failed to write row %d to '%s': %w", result.RowsWritten+1, opts.OutputFile, err)
+			}
+			result.RowsWritten++
+		}
+
+		next, err := nextRow()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			out.Close()
+			return nil, err
+		}
+		batch, _, err = streamBatch(opts, toHandler, reshapeRows, inColumns, next)
+		if err != nil {
+			out.Close()
+			return nil, fmt.Errorf("failed to reshape row %d of '%s': %w", result.RowsRead, opts.InputFile, err)
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish output '%s': %w", opts.OutputFile, err)
+	}
+	return result, nil
+}
+
+// streamBatch returns the rows to write for a single input row together with their
+// columns. When reshape is set the row is flattened or unflattened, and exploded
+// arrays can turn it into several rows; otherwise it is passed through unchanged.
+func streamBatch(opts Options, toHandler FormatHandler, reshapeRow bool, columns []string, row map[string]interface{}) ([]map[string]interface{}, []string, error) {
+	if !reshapeRow {
+		return []map[string]interface{}{row}, columns, nil
+	}
+	ds, err := reshape(opts, toHandler, dataset.FromMapsOrdered(columns, []map[string]interface{}{row}))
+	if err != nil {
+		return nil, nil, err
+	}
+	return ds.Maps(), ds.Columns, nil
+}
+
+// extraColumn returns the first key, in sorted order, holding a value in row that is not
+// in header. A nil header accepts every key.
+func extraColumn(header map[string]bool, row map[string]interface{}) (string, bool) {
+	if header == nil {
+		return "", false
+	}
+	var extra []string
+	for k, v := range row {
+		if v != nil && !header[k] {
+			extra = append(extra, k)
+		}
+	}
+	if len(extra) == 0 {
+		return "", false
+	}
+	sort.Strings(extra)
+	return extra[0], true
+}
+
+// streamColumns returns the column order for a stream: the reader's own header when it
+// has one, otherwise the keys of the first row in sorted order.
+func streamColumns(rows stream.StreamingReader, first map[string]interface{}) []string {
+	if cr, ok := rows.(stream.ColumnReader); ok {
+		if cols := cr.Columns(); len(cols) > 0 {
+			return cols
+		}
+	}
+
+	columns := make([]string, 0, len(first))
+	for k := range first {
+		columns = append(columns, k)
+	}
+	sort.Strings(columns)
+	return columns
+}
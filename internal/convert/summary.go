@@ -0,0 +1,31 @@
+package convert
+
+import (
+	"omnidata/internal/dataset"
+)
+
+/*
+Summary describes a file from the metadata it keeps, without decoding all of its rows.
+
+- RowCount: the number of rows in the file.
+- Columns: the top-level columns in file order.
+- Preview: the first rows asked for, decoded on their own.
+*/
+type Summary struct {
+	RowCount int64
+	Columns  []ColumnSummary
+	Preview  *dataset.Dataset
+}
+
+// ColumnSummary describes a column from file metadata. Type is a schema type name
+// as reported by peek ("number", "string", "date", ...). HasStats tells whether the
+// file keeps statistics for the column; Min and Max are empty when it keeps no bounds.
+type ColumnSummary struct {
+	Name      string
+	Type      string
+	Nullable  bool
+	HasStats  bool
+	NullCount int64
+	Min       string
+	Max       string
+}
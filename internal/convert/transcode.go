@@ -0,0 +1,182 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+)
+
+/*
+Result describes a completed conversion.
+
+Fields:
+- From / To: the source and target format names.
+- RowsRead: records read from the source.
+- RowsWritten: records written to the target (exploded arrays can add rows).
+- Columns: the columns of the written records.
+- Rejected: bad records dropped by the error policy (see ErrorPolicy).
+- Warnings: problems that did not stop the conversion (e.g. a streaming fallback).
+*/
+type Result struct {
+	From        string
+	To          string
+	RowsRead    int
+	RowsWritten int
+	Columns     []string
+	Rejected    int
+	Warnings    []string
+}
+
+/*
+PrepareInput readies an arbitrary reader for a format reader.
+
+- Gzip content is decompressed transparently, recognized by its magic bytes.
+- When format is empty it is detected from the first bytes (see DetectFormatFromContent).
+- The returned reader yields the whole input, including the bytes inspected here.
+*/
+func PrepareInput(r io.Reader, format string) (io.Reader, string, error) {
+	buffered := bufio.NewReaderSize(r, sniffLen)
+	var input io.Reader = buffered
+
+	magic, _ := buffered.Peek(len(gzipMagic))
+	if bytes.Equal(magic, gzipMagic) {
+		gzReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+		buffered = bufio.NewReaderSize(gzReader, sniffLen)
+		input = buffered
+	}
+
+	if format == "" {
+		head, _ := buffered.Peek(sniffLen)
+		name, ok := DetectFormatFromContent(head)
+		if !ok {
+			return nil, "", fmt.Errorf("cannot detect the format of the input (set a format)")
+		}
+		format = name
+	}
+	return input, format, nil
+}
+
+/*
+Transcode converts the data read from r into opts.To and writes it to w.
+
+Unlike Run it works on streams instead of paths and prints nothing: the outcome is
+returned as a Result. Paths, dry-run, Force and batch settings of opts are ignored.
+
+- opts.From is detected from the content when empty; opts.To is required.
+- Gzip input is decompressed; the output is written uncompressed.
+- With opts.Stream, formats that support it are converted row by row.
+- With opts.Document, the input is converted as one document rather than records.
+- SQL needs a connection rather than a stream and is not supported here.
+- Cancelling ctx stops reading and writing (see Run).
+*/
+func Transcode(ctx context.Context, r io.Reader, w io.Writer, opts Options) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, Stopped(ctx, err)
+	}
+	result, err := transcode(ctx, ContextReader(ctx, r), ContextWriter(ctx, w), opts)
+	return result, Stopped(ctx, err)
+}
+
+// transcode implements Transcode; errors caused by a cancelled ctx are reported by Transcode.
+func transcode(ctx context.Context, r io.Reader, w io.Writer, opts Options) (*Result, error) {
+	if opts.To == "" {
+		return nil, fmt.Errorf("invalid format selection: a target format is required")
+	}
+	if opts.From == "sql" || opts.To == "sql" {
+		return nil, fmt.Errorf("invalid format selection: sql cannot be converted from or to a stream")
+	}
+
+	input, from, err := PrepareInput(r, opts.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid format selection: %w", err)
+	}
+	opts.From = from
+	opts.InputFile, opts.OutputFile = "", ""
+
+	if err := ValidateFormats(opts.From, opts.To); err != nil {
+		return nil, fmt.Errorf("invalid format selection: %w", err)
+	}
+	fromHandler, toHandler := Registry[opts.From], Registry[opts.To]
+	if err := fromHandler.ValidateReaderOptions(opts.InOptions); err != nil {
+		return nil, fmt.Errorf("invalid input options: %w", err)
+	}
+	if err := toHandler.ValidateWriterOptions(opts.OutOptions); err != nil {
+		return nil, fmt.Errorf("invalid output options: %w", err)
+	}
+	if err := opts.OnError.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid error policy: %w", err)
+	}
+	if opts.Schema != nil {
+		if err := opts.Schema.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid schema: %w", err)
+		}
+	}
+	ctx = withRejecter(ctx, opts.OnError, "")
+
+	if opts.Document {
+		if err := checkDocument(opts, fromHandler, toHandler); err != nil {
+			return nil, fmt.Errorf("invalid document conversion: %w", err)
+		}
+		doc, err := decodeDocument(ctx, opts, fromHandler, input)
+		if err != nil {
+			return nil, err
+		}
+		if err := toHandler.WriterFn(ctx, w, "", doc, opts.OutOptions); err != nil {
+			return nil, fmt.Errorf("failed to write output: %w", err)
+		}
+		return &Result{From: opts.From, To: opts.To}, nil
+	}
+
+	var warnings []string
+	if opts.Stream {
+		if fromHandler.StreamReaderFn != nil && toHandler.StreamWriterFn != nil {
+			result, err := streamRows(ctx, opts, fromHandler, toHandler, input, w)
+			if err != nil {
+				return nil, err
+			}
+			result.Rejected = rejectedIn(ctx)
+			return result, nil
+		}
+		warnings = append(warnings, fmt.Sprintf("streaming is not supported for %s -> %s, converted in memory",
+			opts.From, opts.To))
+	}
+
+	ds, err := decodeDataset(ctx, opts, fromHandler, input)
+	if err != nil {
+		return nil, err
+	}
+	rowsRead := len(ds.Records)
+	if opts.Schema != nil {
+		ds, err = applySchema(ctx, opts.Schema, ds)
+		if err != nil {
+			return nil, fmt.Errorf("input does not match the schema: %w", err)
+		}
+	}
+
+	if needsReshape(opts, fromHandler, toHandler) {
+		ds, err = reshape(opts, toHandler, ds)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reshape input for %s: %w", opts.To, err)
+		}
+	}
+
+	if err := toHandler.WriterFn(ctx, w, "", ds, opts.OutOptions); err != nil {
+		return nil, fmt.Errorf("failed to write output: %w", err)
+	}
+
+	return &Result{
+		From:        opts.From,
+		To:          opts.To,
+		RowsRead:    rowsRead,
+		RowsWritten: len(ds.Records),
+		Columns:     ds.Columns,
+		Rejected:    rejectedIn(ctx),
+		Warnings:    warnings,
+	}, nil
+}
@@ -27,12 +27,12 @@ func ValidateFormats(from, to string) error {
 // Supports:
 // - "-" for STDIN/STDOUT (cross-platform).
 // - Ensures input file exists and is not a directory.
-// - Prevents accidental overwrite of output file unless DryRun is true.
+// - Prevents accidental overwrite of output file unless Force or DryRun is true.
+// - Rejects an output path that is a directory.
 //
 // Returns normalized input and output paths (empty string indicates STDIN/STDOUT).
 func ResolvePaths(opts Options) (string, string, error) {
 	inputPath := opts.InputFile
-	outputPath := opts.OutputFile
 
 	// ---------------------------
 	// Handle Input
@@ -56,17 +56,9 @@ func ResolvePaths(opts Options) (string, string, error) {
 	// ---------------------------
 	// Handle Output
 	// ---------------------------
-	if outputPath == "-" {
-		// Cross-platform STDOUT placeholder
-		outputPath = ""
-	} else if !opts.DryRun {
-		if _, err := os.Stat(outputPath); err == nil {
-			// File exists: prevent accidental overwrite
-			return "", "", fmt.Errorf("output file already exists: %s (use --force to overwrite)", outputPath)
-		} else if !os.IsNotExist(err) {
-			// Unexpected error accessing file
-			return "", "", fmt.Errorf("cannot access output file '%s': %w", outputPath, err)
-		}
+	outputPath, err := resolveOutputPath(opts)
+	if err != nil {
+		return "", "", err
 	}
 
 	// On Windows, normalize STDIN/STDOUT for handlers
@@ -81,3 +73,30 @@ func ResolvePaths(opts Options) (string, string, error) {
 
 	return inputPath, outputPath, nil
 }
+
+// resolveOutputPath normalizes the output path ("" for STDOUT) and refuses to
+// replace an existing file unless Force or DryRun is set.
+func resolveOutputPath(opts Options) (string, error) {
+	outputPath := opts.OutputFile
+	if outputPath == "-" {
+		// Cross-platform STDOUT placeholder
+		return "", nil
+	}
+	if opts.DryRun {
+		return outputPath, nil
+	}
+
+	if info, err := os.Stat(outputPath); err == nil {
+		if info.IsDir() {
+			return "", fmt.Errorf("output path is a directory: %s", outputPath)
+		}
+		// File exists: prevent accidental overwrite
+		if !opts.Force {
+			return "", fmt.Errorf("output file already exists: %s (use --force to overwrite)", outputPath)
+		}
+	} else if !os.IsNotExist(err) {
+		// Unexpected error accessing file
+		return "", fmt.Errorf("cannot access output file '%s': %w", outputPath, err)
+	}
+	return outputPath, nil
+}
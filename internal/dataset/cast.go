@@ -0,0 +1,269 @@
+package dataset
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kindAliases maps the type names accepted in recipes and schemas to kinds,
+// in addition to the canonical names of kindNames.
+var kindAliases = map[string]Kind{
+	"int":      KindInt,
+	"bool":     KindBool,
+	"number":   KindFloat,
+	"double":   KindFloat,
+	"text":     KindString,
+	"datetime": KindTimestamp,
+	"binary":   KindBytes,
+}
+
+// ParseKind returns the kind named by a type name such as "integer", "int" or "date".
+func ParseKind(name string) (Kind, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for kind, kindName := range kindNames {
+		if kindName == name {
+			return kind, nil
+		}
+	}
+	if kind, ok := kindAliases[name]; ok {
+		return kind, nil
+	}
+	return KindNull, fmt.Errorf("unknown type %q", name)
+}
+
+// timestampLayouts are the text forms accepted when casting to a timestamp.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+/*
+Cast converts a canonical value to the given kind.
+
+- nil stays nil, and so does an empty string for any kind but string.
+- Numbers convert between int, float and decimal when no information is lost.
+- Strings are parsed (dates as YYYY-MM-DD, timestamps as RFC 3339, arrays and objects as JSON).
+- Any value casts to a string through FormatValue.
+*/
+func Cast(v interface{}, kind Kind) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if s, ok := v.(string); ok && kind != KindString {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return nil, nil
+		}
+		v = s
+	}
+	if KindOf(v) == kind {
+		return v, nil
+	}
+
+	switch kind {
+	case KindString:
+		return FormatValue(v), nil
+	case KindBool:
+		switch val := v.(type) {
+		case string:
+			switch strings.ToLower(val) {
+			case "true", "t", "yes", "y", "1":
+				return true, nil
+			case "false", "f", "no", "n", "0":
+				return false, nil
+			}
+		case int64:
+			if val == 0 || val == 1 {
+				return val == 1, nil
+			}
+		}
+	case KindInt:
+		switch val := v.(type) {
+		case string:
+			if i, err := strconv.ParseInt(val, 10, 64); err == nil {
+				return i, nil
+			}
+			if f, err := strconv.ParseFloat(val, 64); err == nil && f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+				return int64(f), nil
+			}
+		case float64:
+			if val == math.Trunc(val) && math.Abs(val) < 1<<63 {
+				return int64(val), nil
+			}
+		case Decimal:
+			if i, ok := decimalInt(val); ok {
+				return i, nil
+			}
+		case bool:
+			if val {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		}
+	case KindFloat:
+		switch val := v.(type) {
+		case string:
+			if f, err := strconv.ParseFloat(val, 64); err == nil {
+				return f, nil
+			}
+		case int64:
+			return float64(val), nil
+		case Decimal:
+			return val.Float64(), nil
+		}
+	case KindDecimal:
+		switch val := v.(type) {
+		case string, int64, float64:
+			if d, err := ParseDecimal(FormatValue(val)); err == nil {
+				return d, nil
+			}
+		}
+	case KindDate:
+		switch val := v.(type) {
+		case string:
+			if d, err := ParseDate(val); err == nil {
+				return d, nil
+			}
+			if t, ok := parseTimestamp(val); ok {
+				return DateOf(t), nil
+			}
+		case time.Time:
+			return DateOf(val), nil
+		}
+	case KindTimestamp:
+		switch val := v.(type) {
+		case string:
+			if t, ok := parseTimestamp(val); ok {
+				return t, nil
+			}
+		case Date:
+			return val.Time(), nil
+		}
+	case KindBytes:
+		if s, ok := v.(string); ok {
+			return []byte(s), nil
+		}
+	case KindList, KindMap:
+		if s, ok := v.(string); ok {
+			dec := json.NewDecoder(strings.NewReader(s))
+			dec.UseNumber()
+			var parsed interface{}
+			if dec.Decode(&parsed) == nil && !dec.More() && KindOf(Normalize(parsed)) == kind {
+				return Normalize(parsed), nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("cannot cast %s %q to %s", KindOf(v), FormatValue(v), kind)
+}
+
+// parseTimestamp parses a timestamp in one of timestampLayouts.
+func parseTimestamp(s string) (time.Time, bool) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// decimalInt returns the decimal as an int64 if it is integral and in range.
+func decimalInt(d Decimal) (int64, bool) {
+	if d.Unscaled == nil {
+		return 0, true
+	}
+	n := new(big.Int).Set(d.Unscaled)
+	if d.Scale > 0 {
+		var rem big.Int
+		n.QuoRem(n, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.Scale)), nil), &rem)
+		if rem.Sign() != 0 {
+			return 0, false
+		}
+	} else if d.Scale < 0 {
+		n.Mul(n, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-d.Scale)), nil))
+	}
+	if !n.IsInt64() {
+		return 0, false
+	}
+	return n.Int64(), true
+}
+
+/*
+Compare orders two canonical values, returning -1, 0 or 1.
+
+- nil sorts before everything else.
+- Numbers (int, float, decimal) compare numerically, even across kinds.
+- Dates and timestamps compare chronologically.
+- Everything else compares by its FormatValue text.
+*/
+func Compare(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	if x, ok := numeric(a); ok {
+		if y, ok := numeric(b); ok {
+			return x.Cmp(y)
+		}
+	}
+	if x, ok := instant(a); ok {
+		if y, ok := instant(b); ok {
+			return x.Compare(y)
+		}
+	}
+	if x, ok := a.(bool); ok {
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0
+			case !x:
+				return -1
+			default:
+				return 1
+			}
+		}
+	}
+	return strings.Compare(FormatValue(a), FormatValue(b))
+}
+
+// numeric returns a numeric value as an exact rational.
+func numeric(v interface{}) (*big.Rat, bool) {
+	switch val := v.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(val), true
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(val), true
+	case Decimal:
+		r, ok := new(big.Rat).SetString(val.String())
+		return r, ok
+	}
+	return nil, false
+}
+
+// instant returns a date or timestamp as a point in time.
+func instant(v interface{}) (time.Time, bool) {
+	switch val := v.(type) {
+	case Date:
+		return val.Time(), true
+	case time.Time:
+		return val, true
+	}
+	return time.Time{}, false
+}
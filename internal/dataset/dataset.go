@@ -0,0 +1,238 @@
+package dataset
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Dataset is the canonical tabular representation shared by every format handler.
+//
+// Readers produce a Dataset (directly or through an adapter) and writers consume it,
+// so any reader can be paired with any writer. Columns holds the ordered column names
+// and each Record is aligned positionally with Columns.
+type Dataset struct {
+	Columns []string
+	Records []Record
+}
+
+// Record is a single row of values aligned with Dataset.Columns.
+type Record []interface{}
+
+// New creates an empty Dataset with the given columns.
+func New(columns []string) *Dataset {
+	return &Dataset{
+		Columns: columns,
+		Records: make([]Record, 0),
+	}
+}
+
+// Len returns the number of records in the dataset.
+func (d *Dataset) Len() int {
+	return len(d.Records)
+}
+
+// ColumnIndex returns the position of the named column, or -1 if it does not exist.
+func (d *Dataset) ColumnIndex(name string) int {
+	for i, col := range d.Columns {
+		if col == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddColumn appends a column if it does not already exist and returns its index.
+// Existing records are padded with nil for the new column.
+func (d *Dataset) AddColumn(name string) int {
+	if idx := d.ColumnIndex(name); idx >= 0 {
+		return idx
+	}
+	d.Columns = append(d.Columns, name)
+	for i := range d.Records {
+		d.Records[i] = append(d.Records[i], nil)
+	}
+	return len(d.Columns) - 1
+}
+
+// Append adds a record, padding it with nil (or truncating it) to match the column count.
+func (d *Dataset) Append(values ...interface{}) {
+	rec := make(Record, len(d.Columns))
+	copy(rec, values)
+	d.Records = append(d.Records, rec)
+}
+
+// Value returns the value of a column in the given row, or nil if either is out of range.
+func (d *Dataset) Value(row int, column string) interface{} {
+	idx := d.ColumnIndex(column)
+	if idx < 0 || row < 0 || row >= len(d.Records) || idx >= len(d.Records[row]) {
+		return nil
+	}
+	return d.Records[row][idx]
+}
+
+// Map returns the given row as a column-name -> value map.
+func (d *Dataset) Map(row int) map[string]interface{} {
+	m := make(map[string]interface{}, len(d.Columns))
+	for i, col := range d.Columns {
+		if i < len(d.Records[row]) {
+			m[col] = d.Records[row][i]
+		} else {
+			m[col] = nil
+		}
+	}
+	return m
+}
+
+// FromRows builds a Dataset from string rows where the first row holds the column names.
+// Short rows are padded with empty strings.
+func FromRows(rows [][]string) *Dataset {
+	if len(rows) == 0 {
+		return New([]string{})
+	}
+
+	columns := make([]string, len(rows[0]))
+	copy(columns, rows[0])
+	ds := New(columns)
+
+	for _, row := range rows[1:] {
+		rec := make(Record, len(columns))
+		for i := range columns {
+			if i < len(row) {
+				rec[i] = row[i]
+			} else {
+				rec[i] = ""
+			}
+		}
+		ds.Records = append(ds.Records, rec)
+	}
+
+	return ds
+}
+
+// UniqueColumns returns names made usable as column names: blank names become colN,
+// N being the 1-based position, and repeated names get a _2, _3, ... suffix.
+func UniqueColumns(names []string) []string {
+	unique := make([]string, len(names))
+	seen := make(map[string]bool, len(names))
+	for i, name := range names {
+		if strings.TrimSpace(name) == "" {
+			name = fmt.Sprintf("col%d", i+1)
+		}
+		candidate := name
+		for n := 2; seen[candidate]; n++ {
+			candidate = fmt.Sprintf("%s_%d", name, n)
+		}
+		seen[candidate] = true
+		unique[i] = candidate
+	}
+	return unique
+}
+
+// StringRows renders the dataset as string rows with a leading header row.
+// This is the shape expected by text-based tabular writers such as CSV.
+func (d *Dataset) StringRows() [][]string {
+	rows := make([][]string, 0, len(d.Records)+1)
+
+	header := make([]string, len(d.Columns))
+	copy(header, d.Columns)
+	rows = append(rows, header)
+
+	for _, rec := range d.Records {
+		row := make([]string, len(d.Columns))
+		for i := range d.Columns {
+			if i < len(rec) {
+				row[i] = FormatValue(rec[i])
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// FromMaps builds a Dataset from a list of objects.
+//
+// Column order is deterministic: keys of each object are taken in sorted order and
+// appended the first time they are seen, so columns introduced by later objects
+// follow the columns of earlier ones. Missing keys become nil.
+func FromMaps(objs []map[string]interface{}) *Dataset {
+	return FromMapsOrdered(nil, objs)
+}
+
+// FromMapsOrdered is like FromMaps but starts with a known column order, for sources
+// that preserve key order. Keys not listed in columns are appended as in FromMaps.
+func FromMapsOrdered(columns []string, objs []map[string]interface{}) *Dataset {
+	ds := New(make([]string, 0, len(columns)))
+	seen := make(map[string]bool)
+
+	for _, col := range columns {
+		if !seen[col] {
+			seen[col] = true
+			ds.Columns = append(ds.Columns, col)
+		}
+	}
+
+	for _, obj := range objs {
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if !seen[k] {
+				seen[k] = true
+				ds.Columns = append(ds.Columns, k)
+			}
+		}
+	}
+
+	for _, obj := range objs {
+		rec := make(Record, len(ds.Columns))
+		for i, col := range ds.Columns {
+			rec[i] = obj[col]
+		}
+		ds.Records = append(ds.Records, rec)
+	}
+
+	return ds
+}
+
+// Maps returns every record as a column-name -> value map.
+func (d *Dataset) Maps() []map[string]interface{} {
+	result := make([]map[string]interface{}, len(d.Records))
+	for i := range d.Records {
+		result[i] = d.Map(i)
+	}
+	return result
+}
+
+// FormatValue renders a value as text for string-based targets.
+// nil renders as an empty string, bytes as base64, timestamps as RFC 3339 and
+// nested lists and objects as compact JSON.
+func FormatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []byte:
+		return formatBytes(val)
+	case float64:
+		return formatFloat(val)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprintf("%v", val)
+		}
+		return string(b)
+	case fmt.Stringer:
+		return val.String()
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
@@ -0,0 +1,458 @@
+package dataset
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ArrayMode controls how lists are mapped onto columns when flattening.
+type ArrayMode string
+
+const (
+	// ArrayIndex writes one column per element: tags[0], tags[1], ...
+	ArrayIndex ArrayMode = "index"
+	// ArrayJoin writes a single column holding the elements joined by a separator.
+	ArrayJoin ArrayMode = "join"
+	// ArrayExplode writes one record per element, repeating the other columns.
+	ArrayExplode ArrayMode = "explode"
+)
+
+// ParseArrayMode parses an array mode name as accepted on the command line.
+func ParseArrayMode(s string) (ArrayMode, error) {
+	switch mode := ArrayMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return ArrayIndex, nil
+	case ArrayIndex, ArrayJoin, ArrayExplode:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid array mode %q (expected index, join or explode)", s)
+	}
+}
+
+// FlattenOptions configures Flatten and Unflatten. The zero value flattens with
+// "." between keys, one indexed column per array element and "," as join separator.
+type FlattenOptions struct {
+	Separator     string
+	Arrays        ArrayMode
+	JoinSeparator string
+}
+
+// withDefaults fills in unset options.
+func (o FlattenOptions) withDefaults() FlattenOptions {
+	if o.Separator == "" {
+		o.Separator = "."
+	}
+	if o.Arrays == "" {
+		o.Arrays = ArrayIndex
+	}
+	if o.JoinSeparator == "" {
+		o.JoinSeparator = ","
+	}
+	return o
+}
+
+// HasNested reports whether any value in the dataset is a list or an object.
+func (d *Dataset) HasNested() bool {
+	for _, rec := range d.Records {
+		for _, v := range rec {
+			switch v.(type) {
+			case map[string]interface{}, []interface{}:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Flatten expands nested objects and lists into scalar columns for tabular targets.
+//
+// Object keys are joined with the separator (user.address.city) and list elements are
+// mapped according to the array mode: indexed columns (tags[0]), a single joined column
+// or one record per element. Keys of nested objects are taken in sorted order, and the
+// columns of a path are kept together in the order they are first seen (see groupColumns).
+// A dataset without nested values is returned unchanged.
+func Flatten(ds *Dataset, opts FlattenOptions) *Dataset {
+	if !ds.HasNested() {
+		return ds
+	}
+	opts = opts.withDefaults()
+
+	out := New(make([]string, 0, len(ds.Columns)))
+	index := make(map[string]int)
+	for _, rec := range ds.Records {
+		rows := []flatRow{{}}
+		for i, col := range ds.Columns {
+			var v interface{}
+			if i < len(rec) {
+				v = rec[i]
+			}
+			rows = product(rows, flattenValue(col, v, opts))
+		}
+
+		for _, row := range rows {
+			values := make(Record, len(out.Columns), len(out.Columns)+len(row))
+			for _, f := range row {
+				idx, ok := index[f.key]
+				if !ok {
+					idx = out.AddColumn(f.key)
+					index[f.key] = idx
+					values = append(values, nil)
+				}
+				values[idx] = f.value
+			}
+			out.Records = append(out.Records, values)
+		}
+	}
+
+	return groupColumns(out, opts.Separator)
+}
+
+// pathNode is a step of the column paths of a flattened dataset, with the columns
+// ending there and the steps below it in order of first appearance.
+type pathNode struct {
+	columns  []int
+	children map[string]*pathNode
+	order    []string
+}
+
+// child returns the step below n with the given name, adding it when new.
+func (n *pathNode) child(name string) *pathNode {
+	if c, ok := n.children[name]; ok {
+		return c
+	}
+	if n.children == nil {
+		n.children = make(map[string]*pathNode)
+	}
+	c := &pathNode{}
+	n.children[name] = c
+	n.order = append(n.order, name)
+	return c
+}
+
+/*
+groupColumns orders the columns of a flattened dataset by path, so that user.name and
+user.email stay together even when records add them at different times.
+
+- Paths are ordered by their first appearance at every level.
+- A parent column without values (from null or empty objects and lists) is dropped when other columns lie below it.
+*/
+func groupColumns(ds *Dataset, sep string) *Dataset {
+	root := &pathNode{}
+	for i, col := range ds.Columns {
+		node := root
+		for _, seg := range parsePath(col, sep) {
+			name := seg.key
+			if seg.isIdx {
+				name = "[" + strconv.Itoa(seg.index) + "]"
+			}
+			node = node.child(name)
+		}
+		node.columns = append(node.columns, i)
+	}
+
+	empty := func(col int) bool {
+		for _, rec := range ds.Records {
+			if col < len(rec) && rec[col] != nil {
+				return false
+			}
+		}
+		return true
+	}
+	order := make([]int, 0, len(ds.Columns))
+	var walk func(n *pathNode)
+	walk = func(n *pathNode) {
+		for _, col := range n.columns {
+			if len(n.order) == 0 || !empty(col) {
+				order = append(order, col)
+			}
+		}
+		for _, name := range n.order {
+			walk(n.children[name])
+		}
+	}
+	walk(root)
+
+	if len(order) == len(ds.Columns) {
+		same := true
+		for i, col := range order {
+			same = same && i == col
+		}
+		if same {
+			return ds
+		}
+	}
+	out := New(make([]string, len(order)))
+	for i, col := range order {
+		out.Columns[i] = ds.Columns[col]
+	}
+	for _, rec := range ds.Records {
+		values := make(Record, len(order))
+		for i, col := range order {
+			if col < len(rec) {
+				values[i] = rec[col]
+			}
+		}
+		out.Records = append(out.Records, values)
+	}
+	return out
+}
+
+// flatField is a single flattened column value.
+type flatField struct {
+	key   string
+	value interface{}
+}
+
+// flatRow is one flattened record in column order.
+type flatRow []flatField
+
+// flattenValue returns the alternative flattened rows for a value; only exploded
+// lists produce more than one.
+func flattenValue(key string, v interface{}, opts FlattenOptions) []flatRow {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if len(val) == 0 {
+			return []flatRow{{{key: key}}}
+		}
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		rows := []flatRow{{}}
+		for _, k := range keys {
+			rows = product(rows, flattenValue(key+opts.Separator+k, val[k], opts))
+		}
+		return rows
+	case []interface{}:
+		if len(val) == 0 {
+			return []flatRow{{{key: key}}}
+		}
+		switch opts.Arrays {
+		case ArrayJoin:
+			parts := make([]string, len(val))
+			for i, item := range val {
+				parts[i] = FormatValue(item)
+			}
+			return []flatRow{{{key: key, value: strings.Join(parts, opts.JoinSeparator)}}}
+		case ArrayExplode:
+			rows := make([]flatRow, 0, len(val))
+			for _, item := range val {
+				rows = append(rows, flattenValue(key, item, opts)...)
+			}
+			return rows
+		default:
+			rows := []flatRow{{}}
+			for i, item := range val {
+				rows = product(rows, flattenValue(key+"["+strconv.Itoa(i)+"]", item, opts))
+			}
+			return rows
+		}
+	default:
+		return []flatRow{{{key: key, value: v}}}
+	}
+}
+
+// product combines every row of a with every row of b.
+func product(a, b []flatRow) []flatRow {
+	result := make([]flatRow, 0, len(a)*len(b))
+	for _, left := range a {
+		for _, right := range b {
+			row := make(flatRow, 0, len(left)+len(right))
+			row = append(row, left...)
+			row = append(row, right...)
+			result = append(result, row)
+		}
+	}
+	return result
+}
+
+// pathSegment is one step of a flattened column name: an object key or a list index.
+type pathSegment struct {
+	key   string
+	index int
+	isIdx bool
+}
+
+// parsePath splits a flattened column name into segments, e.g.
+// "items[0].sku" -> items, [0], sku.
+func parsePath(column, sep string) []pathSegment {
+	var segments []pathSegment
+	for _, part := range strings.Split(column, sep) {
+		name := part
+		var indexes []int
+		// Peel trailing [n] suffixes off the key
+		for strings.HasSuffix(name, "]") {
+			open := strings.LastIndex(name, "[")
+			if open < 0 {
+				break
+			}
+			n, err := strconv.Atoi(name[open+1 : len(name)-1])
+			if err != nil || n < 0 {
+				break
+			}
+			indexes = append([]int{n}, indexes...)
+			name = name[:open]
+		}
+		if name == "" && len(indexes) > 0 && len(segments) == 0 {
+			// A column such as "[0]" has no key to hang the list on
+			return []pathSegment{{key: column}}
+		}
+		if name != "" || len(indexes) == 0 {
+			segments = append(segments, pathSegment{key: name})
+		}
+		for _, n := range indexes {
+			segments = append(segments, pathSegment{index: n, isIdx: true})
+		}
+	}
+	return segments
+}
+
+// Unflatten rebuilds nested objects and lists from flattened column names, reversing
+// Flatten in index mode. Columns without a separator or index are kept as they are.
+//
+// Empty values at list positions are dropped so that padding columns produced for
+// shorter lists do not reappear as empty elements. A dataset whose columns contain no
+// paths is returned unchanged.
+func Unflatten(ds *Dataset, opts FlattenOptions) (*Dataset, error) {
+	opts = opts.withDefaults()
+
+	paths := make([][]pathSegment, len(ds.Columns))
+	nested := false
+	for i, col := range ds.Columns {
+		paths[i] = parsePath(col, opts.Separator)
+		if len(paths[i]) > 1 {
+			nested = true
+		}
+	}
+	if !nested {
+		return ds, nil
+	}
+
+	// Top-level columns in order of first appearance
+	out := New(make([]string, 0, len(ds.Columns)))
+	for _, path := range paths {
+		out.AddColumn(path[0].key)
+	}
+
+	for _, rec := range ds.Records {
+		values := make(Record, len(out.Columns))
+		for i, path := range paths {
+			var v interface{}
+			if i < len(rec) {
+				v = rec[i]
+			}
+			if len(path) == 1 {
+				// Never let a plain column overwrite values nested under the same name
+				if idx := out.ColumnIndex(path[0].key); values[idx] == nil {
+					values[idx] = v
+				}
+				continue
+			}
+			if v == nil || (v == "" && hasIndex(path)) {
+				continue
+			}
+
+			idx := out.ColumnIndex(path[0].key)
+			updated, err := setPath(values[idx], path[1:], v)
+			if err != nil {
+				return nil, fmt.Errorf("column %q: %w", ds.Columns[i], err)
+			}
+			values[idx] = updated
+		}
+		for i := range values {
+			values[i] = trimLists(values[i])
+		}
+		out.Records = append(out.Records, values)
+	}
+
+	return out, nil
+}
+
+// hasIndex reports whether a path addresses a list element.
+func hasIndex(path []pathSegment) bool {
+	for _, seg := range path {
+		if seg.isIdx {
+			return true
+		}
+	}
+	return false
+}
+
+// setPath stores v at path inside container, creating objects and lists as needed,
+// and returns the updated container.
+func setPath(container interface{}, path []pathSegment, v interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	seg := path[0]
+
+	if seg.isIdx {
+		var list []interface{}
+		switch c := container.(type) {
+		case nil:
+		case []interface{}:
+			list = c
+		case string:
+			if c != "" {
+				return nil, fmt.Errorf("cannot index into string value")
+			}
+		default:
+			return nil, fmt.Errorf("cannot index into %s value", KindOf(c))
+		}
+		for len(list) <= seg.index {
+			list = append(list, nil)
+		}
+		child, err := setPath(list[seg.index], path[1:], v)
+		if err != nil {
+			return nil, err
+		}
+		list[seg.index] = child
+		return list, nil
+	}
+
+	var obj map[string]interface{}
+	switch c := container.(type) {
+	case nil:
+		obj = make(map[string]interface{})
+	case map[string]interface{}:
+		obj = c
+	case string:
+		if c != "" {
+			return nil, fmt.Errorf("cannot set key %q on string value", seg.key)
+		}
+		obj = make(map[string]interface{})
+	default:
+		return nil, fmt.Errorf("cannot set key %q on %s value", seg.key, KindOf(c))
+	}
+	child, err := setPath(obj[seg.key], path[1:], v)
+	if err != nil {
+		return nil, err
+	}
+	obj[seg.key] = child
+	return obj, nil
+}
+
+// trimLists removes trailing nil elements from every list inside v.
+func trimLists(v interface{}) interface{} {
+	switch val := v.(type) {
+	case []interface{}:
+		for len(val) > 0 && val[len(val)-1] == nil {
+			val = val[:len(val)-1]
+		}
+		for i := range val {
+			val[i] = trimLists(val[i])
+		}
+		return val
+	case map[string]interface{}:
+		for k := range val {
+			val[k] = trimLists(val[k])
+		}
+		return val
+	default:
+		return v
+	}
+}
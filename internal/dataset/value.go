@@ -0,0 +1,287 @@
+package dataset
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind identifies the type of a cell value.
+//
+// Records hold plain Go values; the canonical representation of each kind is:
+//
+//	KindNull      nil
+//	KindBool      bool
+//	KindInt       int64
+//	KindFloat     float64
+//	KindDecimal   Decimal
+//	KindString    string
+//	KindBytes     []byte
+//	KindDate      Date
+//	KindTimestamp time.Time
+//	KindList      []interface{}
+//	KindMap       map[string]interface{}
+//
+// Readers populate records with these types (see Normalize) and writers render them
+// natively where the target format allows it.
+type Kind int
+
+const (
+	KindNull Kind = iota
+	KindBool
+	KindInt
+	KindFloat
+	KindDecimal
+	KindString
+	KindBytes
+	KindDate
+	KindTimestamp
+	KindList
+	KindMap
+)
+
+// kindNames maps kinds to the names used in schemas and messages.
+var kindNames = map[Kind]string{
+	KindNull:      "null",
+	KindBool:      "boolean",
+	KindInt:       "integer",
+	KindFloat:     "float",
+	KindDecimal:   "decimal",
+	KindString:    "string",
+	KindBytes:     "bytes",
+	KindDate:      "date",
+	KindTimestamp: "timestamp",
+	KindList:      "array",
+	KindMap:       "object",
+}
+
+// String returns the schema name of the kind.
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// KindOf returns the kind of a canonical value. Non-canonical values report KindString.
+func KindOf(v interface{}) Kind {
+	switch v.(type) {
+	case nil:
+		return KindNull
+	case bool:
+		return KindBool
+	case int64:
+		return KindInt
+	case float64:
+		return KindFloat
+	case Decimal:
+		return KindDecimal
+	case string:
+		return KindString
+	case []byte:
+		return KindBytes
+	case Date:
+		return KindDate
+	case time.Time:
+		return KindTimestamp
+	case []interface{}:
+		return KindList
+	case map[string]interface{}:
+		return KindMap
+	default:
+		return KindString
+	}
+}
+
+// Normalize converts a Go value produced by a decoder or database driver into its
+// canonical cell representation. Nested lists and maps are normalized recursively.
+func Normalize(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil, bool, int64, float64, Decimal, string, []byte, Date, time.Time:
+		return val
+	case int:
+		return int64(val)
+	case int8:
+		return int64(val)
+	case int16:
+		return int64(val)
+	case int32:
+		return int64(val)
+	case uint:
+		return normalizeUint(uint64(val))
+	case uint8:
+		return int64(val)
+	case uint16:
+		return int64(val)
+	case uint32:
+		return int64(val)
+	case uint64:
+		return normalizeUint(val)
+	case float32:
+		return float64(val)
+	case *big.Int:
+		return Decimal{Unscaled: new(big.Int).Set(val)}
+	case json.Number:
+		return parseNumber(string(val))
+	case *time.Time:
+		if val == nil {
+			return nil
+		}
+		return *val
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = Normalize(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = Normalize(item)
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[fmt.Sprintf("%v", k)] = Normalize(item)
+		}
+		return out
+	default:
+		return FormatValue(val)
+	}
+}
+
+// normalizeUint keeps unsigned values that overflow int64 as decimals.
+func normalizeUint(v uint64) interface{} {
+	if v <= math.MaxInt64 {
+		return int64(v)
+	}
+	return Decimal{Unscaled: new(big.Int).SetUint64(v)}
+}
+
+// parseNumber converts a numeric literal into int64, float64 or Decimal,
+// choosing the narrowest type that preserves the value.
+func parseNumber(s string) interface{} {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if !strings.ContainsAny(s, ".eE") {
+		// Integer too large for int64
+		if d, err := ParseDecimal(s); err == nil {
+			return d
+		}
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+// Decimal is an exact decimal number: Unscaled * 10^-Scale.
+type Decimal struct {
+	Unscaled *big.Int
+	Scale    int32
+}
+
+// ParseDecimal parses a plain decimal literal such as "-12.340".
+func ParseDecimal(s string) (Decimal, error) {
+	text := strings.TrimSpace(s)
+	digits := text
+	scale := int32(0)
+	if idx := strings.IndexByte(text, '.'); idx >= 0 {
+		digits = text[:idx] + text[idx+1:]
+		scale = int32(len(text) - idx - 1)
+	}
+
+	unscaled, ok := new(big.Int).SetString(digits, 10)
+	if !ok || digits == "" || digits == "-" || digits == "+" {
+		return Decimal{}, fmt.Errorf("invalid decimal: %q", s)
+	}
+	return Decimal{Unscaled: unscaled, Scale: scale}, nil
+}
+
+// String returns the plain decimal representation (e.g. "-12.340").
+func (d Decimal) String() string {
+	if d.Unscaled == nil {
+		return "0"
+	}
+	digits := new(big.Int).Abs(d.Unscaled).String()
+	sign := ""
+	if d.Unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	if d.Scale <= 0 {
+		return sign + digits + strings.Repeat("0", int(-d.Scale))
+	}
+	scale := int(d.Scale)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+// Float64 returns the nearest float64 value of the decimal.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// MarshalJSON writes the decimal as a JSON number without losing precision.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Date is a calendar date without a time of day or time zone.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// DateOf returns the calendar date of t in its own location.
+func DateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{Year: y, Month: m, Day: d}
+}
+
+// ParseDate parses a date in ISO 8601 form (YYYY-MM-DD).
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse("2006-01-02", strings.TrimSpace(s))
+	if err != nil {
+		return Date{}, fmt.Errorf("invalid date: %q", s)
+	}
+	return DateOf(t), nil
+}
+
+// Time returns midnight UTC of the date.
+func (d Date) Time() time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, time.UTC)
+}
+
+// String returns the date in ISO 8601 form (YYYY-MM-DD).
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, int(d.Month), d.Day)
+}
+
+// MarshalJSON writes the date as an ISO 8601 string.
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// formatFloat renders floats without exponents for common magnitudes.
+func formatFloat(f float64) string {
+	if math.Abs(f) < 1e21 && (f == 0 || math.Abs(f) >= 1e-6) {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// formatBytes renders binary values as base64, matching encoding/json.
+func formatBytes(b []byte) string {
+	return base64.StdEncoding.EncodeToString(b)
+}
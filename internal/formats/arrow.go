@@ -0,0 +1,517 @@
+package formats
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+	"omnidata/internal/stream"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/decimal128"
+	"github.com/apache/arrow-go/v18/arrow/decimal256"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+)
+
+// init registers the Arrow IPC format handler in the global Registry
+func init() {
+	convert.RegisterFormat("arrow", convert.FormatHandler{
+		Name:           "arrow",
+		ReaderFn:       readArrow,
+		WriterFn:       writeArrow,
+		StreamReaderFn: streamReadArrow,
+		StreamWriterFn: streamWriteArrow,
+		Extensions:     []string{".arrow", ".feather", ".arrows", ".ipc"},
+		Signatures:     [][]byte{arrowMagic, []byte("\xff\xff\xff\xff")},
+		WriterOptions: []convert.OptionSpec{
+			{Name: "ipc", Type: convert.OptionString, Default: "file", Description: "IPC layout: file (Feather v2) or stream", Values: []string{"file", "stream"}},
+			{Name: "compression", Type: convert.OptionString, Default: "none", Description: "Buffer compression", Values: []string{"none", "lz4", "zstd"}},
+			{Name: "batch_size", Type: convert.OptionInt, Default: "10000", Description: "Rows per record batch; streaming output infers its schema from the first batch"},
+		},
+	})
+}
+
+// arrowMagic starts and ends Arrow IPC files; IPC streams start with a message instead.
+var arrowMagic = []byte("ARROW1")
+
+// arrowReader reads the rows of an Arrow IPC file or stream one record batch at a time.
+type arrowReader struct {
+	ctx     context.Context
+	schema  *arrow.Schema
+	columns []string
+	next    func() (arrow.RecordBatch, error) // returns io.EOF after the last batch
+	close   func()
+	batch   arrow.RecordBatch
+	row     int
+}
+
+// newArrowReader opens the Arrow data r reads: files through their footer, read in
+// place or buffered in memory, and streams message by message.
+func newArrowReader(ctx context.Context, r io.Reader) (*arrowReader, error) {
+	reader := &arrowReader{ctx: ctx}
+	buffered := bufio.NewReader(r)
+	if magic, _ := buffered.Peek(len(arrowMagic)); bytes.Equal(magic, arrowMagic) {
+		input := io.Reader(buffered)
+		if convert.InputFile(r) != nil {
+			input = r
+		}
+		seekable, _, err := seekableInput(input)
+		if err != nil {
+			return nil, err
+		}
+		file, err := ipc.NewFileReader(seekable, ipc.WithAllocator(memory.DefaultAllocator))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Arrow IPC file: %w", err)
+		}
+		batch := 0
+		reader.schema, reader.close = file.Schema(), func() { file.Close() }
+		reader.next = func() (arrow.RecordBatch, error) {
+			if batch >= file.NumRecords() {
+				return nil, io.EOF
+			}
+			batch++
+			return file.RecordBatch(batch - 1)
+		}
+	} else {
+		ipcStream, err := ipc.NewReader(buffered, ipc.WithAllocator(memory.DefaultAllocator))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Arrow IPC stream: %w", err)
+		}
+		reader.schema, reader.close = ipcStream.Schema(), ipcStream.Release
+		reader.next = func() (arrow.RecordBatch, error) {
+			if ipcStream.Next() {
+				return ipcStream.RecordBatch(), nil
+			}
+			if err := ipcStream.Err(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+	}
+
+	names := make([]string, reader.schema.NumFields())
+	for i, field := range reader.schema.Fields() {
+		names[i] = field.Name
+	}
+	reader.columns = dataset.UniqueColumns(names)
+	return reader, nil
+}
+
+// Columns returns the field names of the schema, made unique.
+func (r *arrowReader) Columns() []string {
+	return r.columns
+}
+
+// ReadRow returns the next row, reading the next record batch when the current one is done.
+func (r *arrowReader) ReadRow() (map[string]interface{}, error) {
+	for r.batch == nil || r.row >= int(r.batch.NumRows()) {
+		if err := r.ctx.Err(); err != nil {
+			return nil, err
+		}
+		batch, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		r.batch, r.row = batch, 0
+	}
+
+	row := make(map[string]interface{}, len(r.columns))
+	for i, name := range r.columns {
+		row[name] = arrowValue(r.batch.Column(i), r.row)
+	}
+	r.row++
+	return row, nil
+}
+
+// Close releases the reader; the underlying input is closed by its owner.
+func (r *arrowReader) Close() error {
+	r.close()
+	return nil
+}
+
+// arrowValue converts the value at index i of an Arrow array into a dataset value.
+// Lists, structs and maps become nested values, dictionary values their entries, and
+// types without a dataset kind (intervals, unions) their text.
+func arrowValue(arr arrow.Array, i int) interface{} {
+	if arr.IsNull(i) {
+		return nil
+	}
+	switch a := arr.(type) {
+	case *array.Boolean:
+		return a.Value(i)
+	case *array.Int8:
+		return int64(a.Value(i))
+	case *array.Int16:
+		return int64(a.Value(i))
+	case *array.Int32:
+		return int64(a.Value(i))
+	case *array.Int64:
+		return a.Value(i)
+	case *array.Uint8:
+		return int64(a.Value(i))
+	case *array.Uint16:
+		return int64(a.Value(i))
+	case *array.Uint32:
+		return int64(a.Value(i))
+	case *array.Uint64:
+		return dataset.Normalize(a.Value(i))
+	case *array.Float16:
+		return float64(a.Value(i).Float32())
+	case *array.Float32:
+		return float64(a.Value(i))
+	case *array.Float64:
+		return a.Value(i)
+	case *array.Decimal128:
+		return dataset.Decimal{Unscaled: a.Value(i).BigInt(), Scale: a.DataType().(*arrow.Decimal128Type).Scale}
+	case *array.Decimal256:
+		return dataset.Decimal{Unscaled: a.Value(i).BigInt(), Scale: a.DataType().(*arrow.Decimal256Type).Scale}
+	case *array.String:
+		return a.Value(i)
+	case *array.LargeString:
+		return a.Value(i)
+	case *array.StringView:
+		return a.Value(i)
+	case *array.Binary:
+		return bytes.Clone(a.Value(i))
+	case *array.LargeBinary:
+		return bytes.Clone(a.Value(i))
+	case *array.BinaryView:
+		return bytes.Clone(a.Value(i))
+	case *array.FixedSizeBinary:
+		return bytes.Clone(a.Value(i))
+	case *array.Date32:
+		return dataset.DateOf(a.Value(i).ToTime())
+	case *array.Date64:
+		return dataset.DateOf(a.Value(i).ToTime())
+	case *array.Timestamp:
+		return a.Value(i).ToTime(a.DataType().(*arrow.TimestampType).Unit).UTC()
+	case *array.Time32:
+		return a.Value(i).ToTime(a.DataType().(*arrow.Time32Type).Unit).Format("15:04:05.999999999")
+	case *array.Time64:
+		return a.Value(i).ToTime(a.DataType().(*arrow.Time64Type).Unit).Format("15:04:05.999999999")
+	case *array.Duration:
+		return (time.Duration(a.Value(i)) * a.DataType().(*arrow.DurationType).Unit.Multiplier()).String()
+	case *array.Dictionary:
+		return arrowValue(a.Dictionary(), a.GetValueIndex(i))
+	case *array.Struct:
+		fields := a.DataType().(*arrow.StructType).Fields()
+		m := make(map[string]interface{}, len(fields))
+		for j, field := range fields {
+			m[field.Name] = arrowValue(a.Field(j), i)
+		}
+		return m
+	case *array.Map:
+		start, end := a.ValueOffsets(i)
+		m := make(map[string]interface{}, end-start)
+		for j := int(start); j < int(end); j++ {
+			m[dataset.FormatValue(arrowValue(a.Keys(), j))] = arrowValue(a.Items(), j)
+		}
+		return m
+	case array.ListLike:
+		start, end := a.ValueOffsets(i)
+		items := make([]interface{}, 0, end-start)
+		for j := int(start); j < int(end); j++ {
+			items = append(items, arrowValue(a.ListValues(), j))
+		}
+		return items
+	}
+	return arr.ValueStr(i)
+}
+
+// readArrow reads Arrow IPC data from the given reader.
+func readArrow(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (interface{}, error) {
+	if r == nil {
+		return nil, fmt.Errorf("readArrow requires a valid reader")
+	}
+
+	reader, err := newArrowReader(ctx, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Arrow from '%s': %w", resource, err)
+	}
+	defer reader.Close()
+
+	var rows []map[string]interface{}
+	for {
+		row, err := reader.ReadRow()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Arrow from '%s' after row %d: %w", resource, len(rows), err)
+		}
+		rows = append(rows, row)
+	}
+
+	return dataset.FromMapsOrdered(reader.Columns(), rows), nil
+}
+
+/*
+arrowField returns the Arrow field a column is stored as.
+
+- Integers, floats and booleans become int64, float64 and bool.
+- Decimals become decimal128 up to 38 digits, decimal256 up to 76, and strings beyond.
+- Dates become date32 and timestamps microsecond timestamps in UTC; other columns become utf8.
+*/
+func arrowField(name string, column *columnType) arrow.Field {
+	var t arrow.DataType
+	switch column.Kind {
+	case dataset.KindBool:
+		t = arrow.FixedWidthTypes.Boolean
+	case dataset.KindInt:
+		t = arrow.PrimitiveTypes.Int64
+	case dataset.KindFloat:
+		t = arrow.PrimitiveTypes.Float64
+	case dataset.KindDecimal:
+		switch {
+		case column.Precision <= 38:
+			t = &arrow.Decimal128Type{Precision: int32(column.Precision), Scale: int32(column.Scale)}
+		case column.Precision <= 76:
+			t = &arrow.Decimal256Type{Precision: int32(column.Precision), Scale: int32(column.Scale)}
+		default:
+			column.Kind = dataset.KindString
+			t = arrow.BinaryTypes.String
+		}
+	case dataset.KindDate:
+		t = arrow.FixedWidthTypes.Date32
+	case dataset.KindTimestamp:
+		t = &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+	case dataset.KindBytes:
+		t = arrow.BinaryTypes.Binary
+	default:
+		column.Kind = dataset.KindString
+		t = arrow.BinaryTypes.String
+	}
+	return arrow.Field{Name: name, Type: t, Nullable: column.Nullable}
+}
+
+// appendArrow appends a dataset value to the builder of a column, casting it (see
+// dataset.Cast) when its kind differs. Blank strings are null in nullable columns.
+func appendArrow(b array.Builder, column columnType, v interface{}) error {
+	if s, ok := v.(string); ok && column.Nullable && s == "" {
+		v = nil
+	}
+	cast, err := dataset.Cast(v, column.Kind)
+	if err != nil {
+		return err
+	}
+	if cast == nil {
+		if !column.Nullable {
+			return fmt.Errorf("null is not allowed by the Arrow column type %s", column.Kind)
+		}
+		b.AppendNull()
+		return nil
+	}
+
+	switch b := b.(type) {
+	case *array.BooleanBuilder:
+		b.Append(cast.(bool))
+	case *array.Int64Builder:
+		b.Append(cast.(int64))
+	case *array.Float64Builder:
+		b.Append(cast.(float64))
+	case *array.Decimal128Builder:
+		unscaled, err := column.unscaled(cast.(dataset.Decimal))
+		if err != nil {
+			return err
+		}
+		b.Append(decimal128.FromBigInt(unscaled))
+	case *array.Decimal256Builder:
+		unscaled, err := column.unscaled(cast.(dataset.Decimal))
+		if err != nil {
+			return err
+		}
+		b.Append(decimal256.FromBigInt(unscaled))
+	case *array.Date32Builder:
+		b.Append(arrow.Date32FromTime(cast.(dataset.Date).Time()))
+	case *array.TimestampBuilder:
+		b.Append(arrow.Timestamp(cast.(time.Time).UnixMicro()))
+	case *array.BinaryBuilder:
+		b.Append(cast.([]byte))
+	case *array.StringBuilder:
+		b.Append(cast.(string))
+	default:
+		return fmt.Errorf("unsupported Arrow builder %T", b)
+	}
+	return nil
+}
+
+// arrowBatchWriter writes record batches in the IPC file or stream layout.
+type arrowBatchWriter interface {
+	Write(arrow.RecordBatch) error
+	Close() error
+}
+
+// arrowWriter writes rows to Arrow IPC data, one record batch per batch_size rows.
+// The column types are inferred from the first batch unless given.
+type arrowWriter struct {
+	w         io.Writer
+	columns   []string
+	types     []columnType
+	opts      convert.FormatOptions
+	batchSize int
+	batch     []map[string]interface{}
+	schema    *arrow.Schema
+	writer    arrowBatchWriter
+	rows      int
+	inferred  int // rows the column types were inferred from, 0 if they were not
+}
+
+// newArrowWriter creates a writer for the given columns, checking the options.
+func newArrowWriter(w io.Writer, columns []string, opts convert.FormatOptions) (*arrowWriter, error) {
+	writer := &arrowWriter{w: w, columns: columns, opts: opts, batchSize: opts.Int("batch_size", 10000)}
+	if writer.batchSize < 1 {
+		return nil, fmt.Errorf("batch_size must be 1 or more")
+	}
+	if layout := opts.String("ipc", "file"); layout != "file" && layout != "stream" {
+		return nil, fmt.Errorf("unknown Arrow IPC layout %q", layout)
+	}
+	if compression := opts.String("compression", "none"); compression != "none" && compression != "lz4" && compression != "zstd" {
+		return nil, fmt.Errorf("unknown Arrow compression %q", compression)
+	}
+	return writer, nil
+}
+
+// WriteRow buffers a row, writing a record batch once batch_size rows are buffered.
+func (w *arrowWriter) WriteRow(row map[string]interface{}) error {
+	w.batch = append(w.batch, row)
+	if len(w.batch) < w.batchSize {
+		return nil
+	}
+	return w.flush()
+}
+
+// start creates the IPC writer, inferring the column types from the buffered rows
+// when they are not known yet.
+func (w *arrowWriter) start() error {
+	if w.types == nil {
+		w.types = inferColumnTypes(dataset.FromMapsOrdered(w.columns, w.batch), true)
+		w.inferred = len(w.batch)
+	}
+	fields := make([]arrow.Field, len(w.columns))
+	for i, name := range w.columns {
+		fields[i] = arrowField(name, &w.types[i])
+	}
+	w.schema = arrow.NewSchema(fields, nil)
+
+	options := []ipc.Option{ipc.WithSchema(w.schema), ipc.WithAllocator(memory.DefaultAllocator)}
+	switch w.opts.String("compression", "none") {
+	case "lz4":
+		options = append(options, ipc.WithLZ4())
+	case "zstd":
+		options = append(options, ipc.WithZstd())
+	}
+	if w.opts.String("ipc", "file") == "stream" {
+		w.writer = ipc.NewWriter(w.w, options...)
+		return nil
+	}
+	writer, err := ipc.NewFileWriter(w.w, options...)
+	if err != nil {
+		return fmt.Errorf("failed to start Arrow file: %w", err)
+	}
+	w.writer = writer
+	return nil
+}
+
+// flush writes the buffered rows as a record batch, starting the output first.
+func (w *arrowWriter) flush() error {
+	if w.writer == nil {
+		if err := w.start(); err != nil {
+			return err
+		}
+	}
+	if len(w.batch) == 0 {
+		return nil
+	}
+
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, w.schema)
+	defer builder.Release()
+	for _, record := range w.batch {
+		w.rows++
+		for i, name := range w.columns {
+			if err := appendArrow(builder.Field(i), w.types[i], record[name]); err != nil {
+				if w.inferred > 0 {
+					return fmt.Errorf("row %d, column %q: %w (the column types were inferred from the first %d rows; "+
+						"raise batch_size)", w.rows, name, err, w.inferred)
+				}
+				return fmt.Errorf("row %d, column %q: %w", w.rows, name, err)
+			}
+		}
+	}
+	w.batch = w.batch[:0]
+
+	batch := builder.NewRecordBatch()
+	defer batch.Release()
+	return w.writer.Write(batch)
+}
+
+// Close writes the remaining rows and the end of the output; an empty input still
+// gets a schema.
+func (w *arrowWriter) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	return w.writer.Close()
+}
+
+// writeArrow writes data as Arrow IPC to the given writer.
+func writeArrow(ctx context.Context, w io.Writer, resource string, data interface{}, opts convert.FormatOptions) error {
+	if w == nil {
+		return fmt.Errorf("writeArrow requires a valid writer")
+	}
+
+	var ds *dataset.Dataset
+	switch v := data.(type) {
+	case *dataset.Dataset:
+		ds = v
+	case [][]string:
+		ds = dataset.FromRows(v)
+	default:
+		return fmt.Errorf("invalid data type for Arrow writer, expected [][]string or dataset")
+	}
+
+	writer, err := newArrowWriter(w, dataset.UniqueColumns(ds.Columns), opts)
+	if err != nil {
+		return err
+	}
+	// The whole dataset is at hand, so every row informs the column types
+	writer.types = inferColumnTypes(ds, false)
+	for i := range ds.Records {
+		row := make(map[string]interface{}, len(writer.columns))
+		for j, name := range writer.columns {
+			if j < len(ds.Records[i]) {
+				row[name] = ds.Records[i][j]
+			}
+		}
+		if err := writer.WriteRow(row); err != nil {
+			return fmt.Errorf("failed to write Arrow to '%s': %w", resource, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write Arrow to '%s': %w", resource, err)
+	}
+
+	return nil
+}
+
+// streamReadArrow opens a batch-at-a-time reader over an Arrow IPC file or stream.
+func streamReadArrow(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (stream.StreamingReader, error) {
+	if r == nil {
+		return nil, fmt.Errorf("streamReadArrow requires a valid reader")
+	}
+	return newArrowReader(ctx, r)
+}
+
+// streamWriteArrow creates a writer that buffers one record batch at a time.
+func streamWriteArrow(ctx context.Context, w io.Writer, resource string, columns []string, opts convert.FormatOptions) (stream.StreamingWriter, error) {
+	if w == nil {
+		return nil, fmt.Errorf("streamWriteArrow requires a valid writer")
+	}
+	return newArrowWriter(w, dataset.UniqueColumns(columns), opts)
+}
@@ -1,44 +1,700 @@
 package formats
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math"
+	"math/big"
+	"os"
+	"strings"
+	"time"
 
 	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+	"omnidata/internal/stream"
+
+	"github.com/linkedin/goavro/v2"
 )
 
 // init registers the Avro format handler in the global Registry
-// Note: Avro support requires additional dependencies
 func init() {
 	convert.RegisterFormat("avro", convert.FormatHandler{
-		Name:     "avro",
-		ReaderFn: readAvro,
-		WriterFn: writeAvro,
+		Name:           "avro",
+		ReaderFn:       readAvro,
+		WriterFn:       writeAvro,
+		StreamReaderFn: streamReadAvro,
+		StreamWriterFn: streamWriteAvro,
+		Extensions:     []string{".avro"},
+		Signatures:     [][]byte{[]byte("Obj\x01")},
+		WriterOptions: []convert.OptionSpec{
+			{Name: "codec", Type: convert.OptionString, Default: "null", Description: "Block compression", Values: []string{"null", "deflate", "snappy"}},
+			{Name: "schema", Type: convert.OptionString, Description: "Avro schema file (.avsc) to write instead of the inferred schema"},
+			{Name: "name", Type: convert.OptionString, Default: "Record", Description: "Name of the inferred record schema"},
+			{Name: "namespace", Type: convert.OptionString, Description: "Namespace of the inferred record schema"},
+			{Name: "block_size", Type: convert.OptionInt, Default: "1000", Description: "Records per block; streaming output infers its schema from the first block"},
+		},
 	})
 }
 
+// avroType is the part of an Avro schema needed to map records to and from datasets.
+type avroType struct {
+	Type      string // primitive type name, or record, enum, fixed, array, map or union
+	Name      string // full name of records, enums and fixed types
+	Logical   string
+	Precision int
+	Scale     int
+	Fields    []avroField
+	Items     *avroType // array items or map values
+	Members   []*avroType
+}
+
+// avroField is a field of an Avro record, holding the dataset column of the same
+// name up to the characters Avro does not allow in names.
+type avroField struct {
+	Name   string
+	Column string
+	Type   *avroType
+}
+
+// avroPrimitives are the type names that need no definition in a schema.
+var avroPrimitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// avroLogicalNames are the logical types goavro converts to Go values; union members of
+// these types are named type.logicalType, the others by their type alone.
+var avroLogicalNames = map[string]bool{
+	"int.date": true, "int.time-millis": true, "long.time-micros": true,
+	"long.timestamp-millis": true, "long.timestamp-micros": true, "bytes.decimal": true,
+}
+
+// parseAvroSchema parses the JSON text of an Avro schema.
+func parseAvroSchema(text string) (*avroType, error) {
+	var schema interface{}
+	if err := json.Unmarshal([]byte(text), &schema); err != nil {
+		return nil, fmt.Errorf("invalid Avro schema: %w", err)
+	}
+	return parseAvroType(schema, "", map[string]*avroType{})
+}
+
+// parseAvroType parses a schema node, resolving references against the named types
+// defined so far.
+func parseAvroType(schema interface{}, namespace string, named map[string]*avroType) (*avroType, error) {
+	switch s := schema.(type) {
+	case string:
+		if avroPrimitives[s] {
+			return &avroType{Type: s}, nil
+		}
+		if t, ok := named[s]; ok {
+			return t, nil
+		}
+		if t, ok := named[namespace+"."+s]; ok {
+			return t, nil
+		}
+		return nil, fmt.Errorf("unknown Avro type %q", s)
+	case []interface{}:
+		union := &avroType{Type: "union"}
+		for _, member := range s {
+			t, err := parseAvroType(member, namespace, named)
+			if err != nil {
+				return nil, err
+			}
+			union.Members = append(union.Members, t)
+		}
+		return union, nil
+	case map[string]interface{}:
+		typeName, ok := s["type"].(string)
+		if !ok {
+			return parseAvroType(s["type"], namespace, named)
+		}
+		switch typeName {
+		case "record", "error", "enum", "fixed":
+			name, _ := s["name"].(string)
+			if ns, ok := s["namespace"].(string); ok && !strings.Contains(name, ".") {
+				namespace = ns
+			}
+			if namespace != "" && !strings.Contains(name, ".") {
+				name = namespace + "." + name
+			}
+			if idx := strings.LastIndex(name, "."); idx >= 0 {
+				namespace = name[:idx]
+			}
+			t := &avroType{Type: typeName, Name: name}
+			if typeName == "error" {
+				t.Type = "record"
+			}
+			named[name] = t
+
+			fields, _ := s["fields"].([]interface{})
+			for _, f := range fields {
+				field, _ := f.(map[string]interface{})
+				fieldName, _ := field["name"].(string)
+				fieldType, err := parseAvroType(field["type"], namespace, named)
+				if err != nil {
+					return nil, fmt.Errorf("field %q: %w", fieldName, err)
+				}
+				t.Fields = append(t.Fields, avroField{Name: fieldName, Column: fieldName, Type: fieldType})
+			}
+			return t, nil
+		case "array", "map":
+			key := "items"
+			if typeName == "map" {
+				key = "values"
+			}
+			items, err := parseAvroType(s[key], namespace, named)
+			if err != nil {
+				return nil, err
+			}
+			return &avroType{Type: typeName, Items: items}, nil
+		default:
+			t, err := parseAvroType(typeName, namespace, named)
+			if err != nil || !avroPrimitives[typeName] {
+				return t, err
+			}
+			t.Logical, _ = s["logicalType"].(string)
+			precision, _ := s["precision"].(float64)
+			scale, _ := s["scale"].(float64)
+			t.Precision, t.Scale = int(precision), int(scale)
+			return t, nil
+		}
+	default:
+		return nil, fmt.Errorf("invalid Avro schema node: %v", schema)
+	}
+}
+
+// unionName returns the name goavro uses for the type as a union member.
+func (t *avroType) unionName() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	if name := t.Type + "." + t.Logical; avroLogicalNames[name] {
+		return name
+	}
+	return t.Type
+}
+
+// member returns the union member called name, or the only non-null member when none
+// matches.
+func (t *avroType) member(name string) *avroType {
+	var others []*avroType
+	for _, m := range t.Members {
+		if m.unionName() == name {
+			return m
+		}
+		if m.Type != "null" {
+			others = append(others, m)
+		}
+	}
+	if len(others) == 1 {
+		return others[0]
+	}
+	return &avroType{}
+}
+
+// schema returns the JSON form of a type built by inferAvroSchema.
+func (t *avroType) schema() interface{} {
+	switch {
+	case t.Type == "union":
+		members := make([]interface{}, len(t.Members))
+		for i, m := range t.Members {
+			members[i] = m.schema()
+		}
+		return members
+	case t.Type == "record":
+		fields := make([]interface{}, len(t.Fields))
+		for i, f := range t.Fields {
+			field := map[string]interface{}{"name": f.Name, "type": f.Type.schema()}
+			if f.Type.Type == "union" && f.Type.Members[0].Type == "null" {
+				field["default"] = nil
+			}
+			fields[i] = field
+		}
+		return map[string]interface{}{"type": "record", "name": t.Name, "fields": fields}
+	case t.Logical == "decimal":
+		return map[string]interface{}{"type": t.Type, "logicalType": t.Logical, "precision": t.Precision, "scale": t.Scale}
+	case t.Logical != "":
+		return map[string]interface{}{"type": t.Type, "logicalType": t.Logical}
+	default:
+		return t.Type
+	}
+}
+
+// value converts a datum decoded by goavro into a dataset value: unions are unwrapped,
+// ints widened, dates, timestamps and decimals mapped to their dataset kinds, and
+// records, arrays and maps converted recursively.
+func (t *avroType) value(datum interface{}) interface{} {
+	switch v := datum.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		switch t.Type {
+		case "union":
+			for name, inner := range v {
+				return t.member(name).value(inner)
+			}
+		case "record":
+			for _, f := range t.Fields {
+				out[f.Name] = f.Type.value(v[f.Name])
+			}
+			return out
+		}
+		items := t.Items
+		if items == nil {
+			items = &avroType{}
+		}
+		for k, item := range v {
+			out[k] = items.value(item)
+		}
+		return out
+	case []interface{}:
+		items := t.Items
+		if items == nil {
+			items = &avroType{}
+		}
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = items.value(item)
+		}
+		return out
+	case time.Time:
+		if t.Logical == "date" {
+			return dataset.DateOf(v.UTC())
+		}
+		return v.UTC()
+	case time.Duration:
+		return time.Time{}.Add(v).Format("15:04:05.999999")
+	case *big.Rat:
+		unscaled := new(big.Int).Mul(v.Num(), pow10(t.Scale))
+		return dataset.Decimal{Unscaled: unscaled.Quo(unscaled, v.Denom()), Scale: int32(t.Scale)}
+	}
+	return dataset.Normalize(datum)
+}
+
+// native converts a dataset value into the datum goavro encodes for the type, casting
+// it (see dataset.Cast) when its kind differs. Blank strings are null in unions with
+// null unless a string member accepts them.
+func (t *avroType) native(v interface{}) (interface{}, error) {
+	switch t.Type {
+	case "union":
+		var firstErr error
+		for _, m := range t.Members {
+			if m.Type == "null" {
+				continue
+			}
+			datum, err := m.native(v)
+			if err == nil {
+				return goavro.Union(m.unionName(), datum), nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if s, ok := v.(string); v == nil || ok && strings.TrimSpace(s) == "" {
+			for _, m := range t.Members {
+				if m.Type == "null" {
+					return nil, nil
+				}
+			}
+		}
+		return nil, firstErr
+	case "null":
+		if v != nil {
+			return nil, fmt.Errorf("value %q is not null", dataset.FormatValue(v))
+		}
+		return nil, nil
+	case "record", "map":
+		cast, err := dataset.Cast(v, dataset.KindMap)
+		if err == nil && cast == nil {
+			return nil, fmt.Errorf("null is not allowed by the Avro type %s", t.unionName())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("value %q is not an object", dataset.FormatValue(v))
+		}
+		m, out := cast.(map[string]interface{}), make(map[string]interface{})
+		if t.Type == "record" {
+			for _, f := range t.Fields {
+				datum, err := f.Type.native(m[f.Name])
+				if err != nil {
+					return nil, fmt.Errorf("field %q: %w", f.Name, err)
+				}
+				out[f.Name] = datum
+			}
+			return out, nil
+		}
+		for k, item := range m {
+			datum, err := t.Items.native(item)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", k, err)
+			}
+			out[k] = datum
+		}
+		return out, nil
+	case "array":
+		cast, err := dataset.Cast(v, dataset.KindList)
+		if err == nil && cast == nil {
+			return nil, fmt.Errorf("null is not allowed by the Avro type %s", t.unionName())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("value %q is not an array", dataset.FormatValue(v))
+		}
+		items := cast.([]interface{})
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			datum, err := t.Items.native(item)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %w", i+1, err)
+			}
+			out[i] = datum
+		}
+		return out, nil
+	}
+
+	kind := dataset.KindString
+	switch {
+	case t.Logical == "date":
+		kind = dataset.KindDate
+	case strings.HasPrefix(t.Logical, "timestamp-"):
+		kind = dataset.KindTimestamp
+	case t.Logical == "decimal":
+		kind = dataset.KindDecimal
+	case t.Type == "boolean":
+		kind = dataset.KindBool
+	case t.Type == "int" || t.Type == "long":
+		kind = dataset.KindInt
+	case t.Type == "float" || t.Type == "double":
+		kind = dataset.KindFloat
+	case t.Type == "bytes" || t.Type == "fixed":
+		kind = dataset.KindBytes
+	}
+	cast, err := dataset.Cast(v, kind)
+	if err != nil {
+		return nil, err
+	}
+	if cast == nil {
+		return nil, fmt.Errorf("null is not allowed by the Avro type %s", t.unionName())
+	}
+
+	switch c := cast.(type) {
+	case dataset.Date:
+		return c.Time(), nil
+	case dataset.Decimal:
+		// goavro rounds to the schema scale silently, so check the digits first
+		unscaled, err := columnType{Precision: t.Precision, Scale: t.Scale}.unscaled(c)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Rat).SetFrac(unscaled, pow10(t.Scale)), nil
+	case int64:
+		if t.Type == "int" {
+			if c < math.MinInt32 || c > math.MaxInt32 {
+				return nil, fmt.Errorf("value %d is out of range for an Avro int", c)
+			}
+			return int32(c), nil
+		}
+	case float64:
+		if t.Type == "float" {
+			return float32(c), nil
+		}
+	}
+	return cast, nil
+}
+
+// avroName turns a column name into a valid Avro name: letters, digits and
+// underscores, not starting with a digit.
+func avroName(column string) string {
+	var b strings.Builder
+	for _, r := range column {
+		if r == '_' || r < 128 && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	name := b.String()
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+/*
+inferAvroSchema builds a record schema for ds from its inferred column types (see
+inferColumnTypes).
+
+- Integers, floats and booleans become long, double and boolean.
+- Decimals, dates and timestamps become the decimal, date and timestamp-millis logical types.
+- Nullable columns become unions of null and their type, defaulting to null.
+*/
+func inferAvroSchema(ds *dataset.Dataset, name, namespace string, nullable bool) *avroType {
+	record := &avroType{Type: "record", Name: avroName(name)}
+	if namespace != "" {
+		record.Name = namespace + "." + record.Name
+	}
+
+	names := make([]string, len(ds.Columns))
+	for i, column := range ds.Columns {
+		names[i] = avroName(column)
+	}
+	names = dataset.UniqueColumns(names)
+
+	for i, column := range inferColumnTypes(ds, nullable) {
+		var t *avroType
+		switch column.Kind {
+		case dataset.KindBool:
+			t = &avroType{Type: "boolean"}
+		case dataset.KindInt:
+			t = &avroType{Type: "long"}
+		case dataset.KindFloat:
+			t = &avroType{Type: "double"}
+		case dataset.KindDecimal:
+			t = &avroType{Type: "bytes", Logical: "decimal", Precision: column.Precision, Scale: column.Scale}
+		case dataset.KindDate:
+			t = &avroType{Type: "int", Logical: "date"}
+		case dataset.KindTimestamp:
+			t = &avroType{Type: "long", Logical: "timestamp-millis"}
+		case dataset.KindBytes:
+			t = &avroType{Type: "bytes"}
+		default:
+			t = &avroType{Type: "string"}
+		}
+		if column.Nullable {
+			t = &avroType{Type: "union", Members: []*avroType{{Type: "null"}, t}}
+		}
+		record.Fields = append(record.Fields, avroField{Name: names[i], Column: ds.Columns[i], Type: t})
+	}
+	return record
+}
+
+// avroReader reads the records of an Avro object container file one block at a time.
+type avroReader struct {
+	ocf    *goavro.OCFReader
+	record *avroType
+}
+
+// newAvroReader reads the file header of r and parses the schema it holds, which must
+// be a record.
+func newAvroReader(r io.Reader) (*avroReader, error) {
+	ocf, err := goavro.NewOCFReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Avro object container file: %w", err)
+	}
+	record, err := parseAvroSchema(string(ocf.MetaData()["avro.schema"]))
+	if err != nil {
+		return nil, err
+	}
+	if record.Type != "record" {
+		return nil, fmt.Errorf("Avro files are read as tables of records, but the schema holds %s values", record.unionName())
+	}
+	return &avroReader{ocf: ocf, record: record}, nil
+}
+
+// Columns returns the field names of the record schema.
+func (r *avroReader) Columns() []string {
+	columns := make([]string, len(r.record.Fields))
+	for i, f := range r.record.Fields {
+		columns[i] = f.Name
+	}
+	return columns
+}
+
+// ReadRow returns the next record, decoding a new block when the current one is done.
+func (r *avroReader) ReadRow() (map[string]interface{}, error) {
+	if !r.ocf.Scan() {
+		if err := r.ocf.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	datum, err := r.ocf.Read()
+	if err != nil {
+		return nil, err
+	}
+	row, ok := r.record.value(datum).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected Avro record: %v", datum)
+	}
+	return row, nil
+}
+
+// Close releases the reader; the underlying input is closed by its owner.
+func (r *avroReader) Close() error {
+	return nil
+}
+
 // readAvro reads Avro data from the given reader.
-func readAvro(r io.Reader, resource string) (interface{}, error) {
+func readAvro(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (interface{}, error) {
 	if r == nil {
 		return nil, fmt.Errorf("readAvro requires a valid reader")
 	}
 
-	// TODO: Implement actual Avro reading
-	return nil, fmt.Errorf("Avro format support requires additional dependencies. " +
-		"Install with: go get github.com/linkedin/goavro")
+	reader, err := newAvroReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Avro from '%s': %w", resource, err)
+	}
+	var rows []map[string]interface{}
+	for {
+		row, err := reader.ReadRow()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Avro from '%s' after record %d: %w", resource, len(rows), err)
+		}
+		rows = append(rows, row)
+	}
+
+	return dataset.FromMapsOrdered(reader.Columns(), rows), nil
+}
+
+// avroWriter writes records to an Avro object container file, one block per
+// block_size records. Without a schema, it is inferred from the first block.
+type avroWriter struct {
+	w         io.Writer
+	columns   []string
+	record    *avroType
+	schema    string
+	opts      convert.FormatOptions
+	blockSize int
+	block     []map[string]interface{}
+	ocf       *goavro.OCFWriter
+	rows      int
+	inferred  int // records the schema was inferred from, 0 if it was not
+}
+
+// newAvroWriter creates a writer for the given columns, loading the schema option.
+func newAvroWriter(w io.Writer, columns []string, opts convert.FormatOptions) (*avroWriter, error) {
+	writer := &avroWriter{w: w, columns: columns, opts: opts, blockSize: opts.Int("block_size", 1000)}
+	if writer.blockSize < 1 {
+		return nil, fmt.Errorf("block_size must be 1 or more")
+	}
+
+	if path := opts.String("schema", ""); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Avro schema: %w", err)
+		}
+		record, err := parseAvroSchema(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Avro schema '%s': %w", path, err)
+		}
+		if record.Type != "record" {
+			return nil, fmt.Errorf("invalid Avro schema '%s': expected a record schema", path)
+		}
+		writer.record, writer.schema = record, string(content)
+	}
+	return writer, nil
+}
+
+// WriteRow buffers a row, writing a block once block_size rows are buffered.
+func (w *avroWriter) WriteRow(row map[string]interface{}) error {
+	w.block = append(w.block, row)
+	if len(w.block) < w.blockSize {
+		return nil
+	}
+	return w.flush()
+}
+
+// flush writes the buffered rows as a block, writing the file header first.
+func (w *avroWriter) flush() error {
+	if w.ocf == nil {
+		if w.record == nil {
+			w.record = inferAvroSchema(dataset.FromMapsOrdered(w.columns, w.block), w.opts.String("name", "Record"), w.opts.String("namespace", ""), true)
+			w.inferred = len(w.block)
+		}
+		if w.schema == "" {
+			schema, err := json.Marshal(w.record.schema())
+			if err != nil {
+				return err
+			}
+			w.schema = string(schema)
+		}
+
+		ocf, err := goavro.NewOCFWriter(goavro.OCFConfig{W: w.w, Schema: w.schema, CompressionName: w.opts.String("codec", "null")})
+		if err != nil {
+			return fmt.Errorf("failed to start Avro file: %w", err)
+		}
+		w.ocf = ocf
+	}
+	if len(w.block) == 0 {
+		return nil
+	}
+
+	data := make([]interface{}, len(w.block))
+	for i, row := range w.block {
+		w.rows++
+		datum := make(map[string]interface{}, len(w.record.Fields))
+		for _, f := range w.record.Fields {
+			value, err := f.Type.native(row[f.Column])
+			if err != nil {
+				if w.inferred > 0 {
+					return fmt.Errorf("record %d, column %q: %w (the schema was inferred from the first %d records; "+
+						"raise block_size or give a schema file)", w.rows, f.Column, err, w.inferred)
+				}
+				return fmt.Errorf("record %d, column %q: %w", w.rows, f.Column, err)
+			}
+			datum[f.Name] = value
+		}
+		data[i] = datum
+	}
+	w.block = w.block[:0]
+	return w.ocf.Append(data)
+}
+
+// Close writes the remaining rows; an empty input still gets a file header.
+func (w *avroWriter) Close() error {
+	return w.flush()
 }
 
 // writeAvro writes data to an Avro file to the given writer.
-func writeAvro(w io.Writer, resource string, data interface{}) error {
+func writeAvro(ctx context.Context, w io.Writer, resource string, data interface{}, opts convert.FormatOptions) error {
 	if w == nil {
 		return fmt.Errorf("writeAvro requires a valid writer")
 	}
 
-	if _, ok := data.([][]string); !ok {
-		return fmt.Errorf("invalid data type for Avro writer, expected [][]string")
+	var ds *dataset.Dataset
+	switch v := data.(type) {
+	case *dataset.Dataset:
+		ds = v
+	case [][]string:
+		ds = dataset.FromRows(v)
+	default:
+		return fmt.Errorf("invalid data type for Avro writer, expected [][]string or dataset")
 	}
 
-	// TODO: Implement actual Avro writing
-	return fmt.Errorf("Avro format support requires additional dependencies. " +
-		"Install with: go get github.com/linkedin/goavro")
+	writer, err := newAvroWriter(w, ds.Columns, opts)
+	if err != nil {
+		return err
+	}
+	// The whole dataset is at hand, so every record informs the schema
+	if writer.record == nil {
+		writer.record = inferAvroSchema(ds, opts.String("name", "Record"), opts.String("namespace", ""), false)
+	}
+	for i := range ds.Records {
+		if err := writer.WriteRow(ds.Map(i)); err != nil {
+			return fmt.Errorf("failed to write Avro to '%s': %w", resource, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write Avro to '%s': %w", resource, err)
+	}
+
+	return nil
+}
+
+// streamReadAvro opens a block-at-a-time reader over an Avro object container file.
+func streamReadAvro(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (stream.StreamingReader, error) {
+	if r == nil {
+		return nil, fmt.Errorf("streamReadAvro requires a valid reader")
+	}
+	return newAvroReader(r)
+}
+
+// streamWriteAvro opens a block-at-a-time writer producing an Avro object container file.
+func streamWriteAvro(ctx context.Context, w io.Writer, resource string, columns []string, opts convert.FormatOptions) (stream.StreamingWriter, error) {
+	if w == nil {
+		return nil, fmt.Errorf("streamWriteAvro requires a valid writer")
+	}
+	return newAvroWriter(w, columns, opts)
 }
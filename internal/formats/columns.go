@@ -0,0 +1,119 @@
+package formats
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"omnidata/internal/dataset"
+	"omnidata/internal/inspect"
+)
+
+// columnType is the type a typed binary format (Avro, Parquet) stores a column as.
+type columnType struct {
+	Kind      dataset.Kind // bool, int, float, decimal, string, bytes, date or timestamp
+	Precision int          // total digits of decimal columns
+	Scale     int          // fractional digits of decimal columns
+	Nullable  bool
+}
+
+// unscaled returns the unscaled value of d at the scale of a decimal column, failing
+// when d has more fractional digits or more digits in all than the column holds.
+func (t columnType) unscaled(d dataset.Decimal) (*big.Int, error) {
+	if int(d.Scale) > t.Scale {
+		return nil, fmt.Errorf("decimal %s has more than %d fractional digits", d, t.Scale)
+	}
+	unscaled := new(big.Int).Mul(d.Unscaled, pow10(t.Scale-int(d.Scale)))
+	if len(new(big.Int).Abs(unscaled).String()) > t.Precision {
+		return nil, fmt.Errorf("decimal %s does not fit in %d digits", d, t.Precision)
+	}
+	return unscaled, nil
+}
+
+/*
+inferColumnTypes picks the storage type of each column of ds from its inferred schema
+(see inspect.InferDatasetSchema).
+
+- Numbers are ints if all are integers, else decimals if exact decimals appear, else floats.
+- Booleans, dates, timestamps and bytes keep their kind.
+- Arrays, objects, mixed and empty columns are stored as strings.
+- Columns with null or blank values are nullable, and so is every column with nullable set.
+*/
+func inferColumnTypes(ds *dataset.Dataset, nullable bool) []columnType {
+	schema := inspect.InferDatasetSchema(ds, "")
+	types := make([]columnType, len(schema.Columns))
+	for i, column := range schema.Columns {
+		t := columnType{Kind: dataset.KindString, Nullable: nullable || column.Nullable || column.Type == "null"}
+		switch column.Type {
+		case "boolean":
+			t.Kind = dataset.KindBool
+		case "number":
+			t.Kind, t.Precision, t.Scale = inferNumberKind(ds, i)
+		case "date":
+			t.Kind = dataset.KindDate
+		case "timestamp":
+			t.Kind = dataset.KindTimestamp
+		case "bytes":
+			t.Kind = dataset.KindBytes
+		}
+		types[i] = t
+	}
+	return types
+}
+
+// inferNumberKind picks the kind of a numeric column from its values, with the
+// precision and scale that fit all of them when it is decimal.
+func inferNumberKind(ds *dataset.Dataset, col int) (kind dataset.Kind, precision, scale int) {
+	isDecimal, isFloat := false, false
+	intDigits := 1
+	for _, rec := range ds.Records {
+		if col >= len(rec) {
+			continue
+		}
+		switch v := rec[col].(type) {
+		case int64:
+			intDigits = max(intDigits, len(strconv.FormatInt(v, 10))-boolInt(v < 0))
+		case dataset.Decimal:
+			isDecimal = true
+			digits := len(new(big.Int).Abs(v.Unscaled).String())
+			intDigits = max(intDigits, digits-int(v.Scale))
+			scale = max(scale, int(v.Scale))
+		case float64:
+			isFloat = true
+		case string:
+			// Text columns only get here when every value is a plain number (see inspect.isNumberText)
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				intDigits = max(intDigits, len(strconv.FormatInt(n, 10))-boolInt(n < 0))
+			} else if d, err := dataset.ParseDecimal(v); err == nil && !strings.Contains(v, ".") {
+				// Integers beyond int64 stay exact as decimals
+				isDecimal = true
+				intDigits = max(intDigits, len(new(big.Int).Abs(d.Unscaled).String()))
+			} else if v != "" {
+				isFloat = true
+			}
+		}
+	}
+
+	switch {
+	case isFloat:
+		return dataset.KindFloat, 0, 0
+	case isDecimal:
+		return dataset.KindDecimal, intDigits + scale, scale
+	default:
+		return dataset.KindInt, 0, 0
+	}
+}
+
+// boolInt returns 1 for true and 0 for false.
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// pow10 returns 10^n as a big integer.
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
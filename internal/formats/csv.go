@@ -1,53 +1,309 @@
 package formats
 
 import (
+	"bufio"
+	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+	"omnidata/internal/stream"
 )
 
 // init registers the CSV format handler in the global Registry
 func init() {
 	convert.RegisterFormat("csv", convert.FormatHandler{
-		Name:     "csv",
-		ReaderFn: readCSV,
-		WriterFn: writeCSV,
+		Name:           "csv",
+		ReaderFn:       readCSV,
+		WriterFn:       writeCSV,
+		ToDataset:      rowsToDataset,
+		StreamReaderFn: streamReadCSV,
+		StreamWriterFn: streamWriteCSV,
+		Extensions:     []string{".csv"},
+		ReaderOptions:  csvReaderOptions(","),
+		WriterOptions:  csvWriterOptions(","),
 	})
 }
 
+// csvReaderOptions declares the dialect options of delimited readers (CSV, TSV).
+func csvReaderOptions(delimiter string) []convert.OptionSpec {
+	return []convert.OptionSpec{
+		{Name: "delimiter", Type: convert.OptionChar, Default: delimiter, Description: "Field delimiter (use \\t for tab)"},
+		{Name: "quote", Type: convert.OptionChar, Default: `"`, Description: "Quote character"},
+		{Name: "escape", Type: convert.OptionString, Default: "double", Description: "How quotes are escaped inside quoted fields", Values: []string{"double", "backslash"}},
+		{Name: "comment", Type: convert.OptionString, Description: "Lines starting with this prefix are ignored, e.g. # or //"},
+		{Name: "lazy_quotes", Type: convert.OptionBool, Default: "false", Description: "Allow quotes inside unquoted fields"},
+		{Name: "trim_space", Type: convert.OptionBool, Default: "false", Description: "Ignore leading white space in fields"},
+		{Name: "encoding", Type: convert.OptionString, Description: "Text encoding (detected when omitted)", Values: stream.Encodings},
+		{Name: "header", Type: convert.OptionBool, Default: "true", Description: "The first row holds the column names (otherwise columns are named col1..colN)"},
+		{Name: "columns", Type: convert.OptionString, Description: "Comma-separated column names, replacing the header row or naming a headerless input"},
+		{Name: "skip_rows", Type: convert.OptionInt, Default: "0", Description: "Lines skipped before the header, e.g. a preamble"},
+		{Name: "header_row", Type: convert.OptionInt, Default: "1", Description: "Line holding the header, counted after skip_rows; earlier lines are skipped"},
+	}
+}
+
+// csvWriterOptions declares the dialect options of delimited writers (CSV, TSV).
+func csvWriterOptions(delimiter string) []convert.OptionSpec {
+	return []convert.OptionSpec{
+		{Name: "delimiter", Type: convert.OptionChar, Default: delimiter, Description: "Field delimiter (use \\t for tab)"},
+		{Name: "quote", Type: convert.OptionChar, Default: `"`, Description: "Quote character"},
+		{Name: "escape", Type: convert.OptionString, Default: "double", Description: "How quotes are escaped inside quoted fields", Values: []string{"double", "backslash"}},
+		{Name: "crlf", Type: convert.OptionBool, Default: "false", Description: "End lines with \\r\\n instead of \\n"},
+		{Name: "always_quote", Type: convert.OptionBool, Default: "false", Description: "Quote every field, not only those that need it"},
+	}
+}
+
+// csvDialect returns the dialect selected by reader or writer options; delimiter is the
+// default of the format.
+func csvDialect(opts convert.FormatOptions, delimiter rune) stream.Dialect {
+	d := stream.Dialect{
+		Delimiter:        opts.Char("delimiter", delimiter),
+		Quote:            opts.Char("quote", '"'),
+		Comment:          opts.String("comment", ""),
+		LazyQuotes:       opts.Bool("lazy_quotes", false),
+		TrimLeadingSpace: opts.Bool("trim_space", false),
+		CRLF:             opts.Bool("crlf", false),
+		AlwaysQuote:      opts.Bool("always_quote", false),
+	}
+	if strings.EqualFold(opts.String("escape", "double"), "backslash") {
+		d.Escape = '\\'
+	}
+	return d
+}
+
+// sniffConfidence is the confidence a sniffed dialect needs to replace the defaults.
+const sniffConfidence = 0.5
+
+// openDelimited returns a record reader over delimited input in the dialect selected
+// by opts; delimiter is the default of the format. Its first record holds the column
+// names (see stream.Header), taken from the header row or the columns option.
+//
+// The start of r is sniffed (see stream.Sniff) to decode the text to UTF-8 unless an
+// encoding is given and, when sniff is set and opts pin none of the delimiter, quote
+// and escape characters, to pick the dialect and, unless header, columns or header_row
+// is given, whether there is a header row at all. Lines before the header row are skipped
+// before parsing, so a preamble need not be valid CSV.
+func openDelimited(ctx context.Context, r io.Reader, opts convert.FormatOptions, delimiter rune, sniff bool) (stream.RecordReader, error) {
+	header := stream.Header{Absent: !opts.Bool("header", true)}
+	if columns := opts.String("columns", ""); columns != "" {
+		for _, name := range strings.Split(columns, ",") {
+			header.Columns = append(header.Columns, strings.TrimSpace(name))
+		}
+	}
+	skip, headerRow := opts.Int("skip_rows", 0), opts.Int("header_row", 1)
+	switch {
+	case skip < 0:
+		return nil, fmt.Errorf("skip_rows must be 0 or more, got %d", skip)
+	case headerRow < 1:
+		return nil, fmt.Errorf("header_row must be 1 or more, got %d", headerRow)
+	case headerRow > 1 && header.Absent:
+		return nil, fmt.Errorf("header_row cannot be combined with header=false (use skip_rows)")
+	}
+	skip += headerRow - 1
+
+	raw := bufio.NewReader(r)
+	sample, _ := raw.Peek(stream.SniffSize)
+	sniffed := stream.Sniff(sample)
+	decoded, err := stream.NewDecoder(raw, opts.String("encoding", sniffed.Encoding))
+	if err != nil {
+		return nil, err
+	}
+
+	text := bufio.NewReader(decoded)
+	for i := 0; i < skip; i++ {
+		if _, err := text.ReadString('\n'); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to skip rows: %w", err)
+		}
+	}
+
+	_, headerSet := opts["header"]
+	for _, name := range []string{"columns", "header_row"} {
+		if _, ok := opts[name]; ok {
+			headerSet = true
+		}
+	}
+	_, pinned := opts["delimiter"]
+	for _, name := range []string{"quote", "escape"} {
+		if _, ok := opts[name]; ok {
+			pinned = true
+		}
+	}
+	dialect := csvDialect(opts, delimiter)
+	if sniff && !pinned {
+		// Sniff again past the skipped lines, which are often not delimited at all
+		if skip > 0 {
+			sample, _ = text.Peek(stream.SniffSize)
+			sniffed = stream.Sniff(sample)
+		}
+		if sniffed.Confidence >= sniffConfidence {
+			dialect.Delimiter = sniffed.Dialect.Delimiter
+			dialect.Quote = sniffed.Dialect.Quote
+			dialect.Escape = sniffed.Dialect.Escape
+			if !headerSet {
+				header.Absent = !sniffed.Header
+			}
+		}
+	}
+	if err := dialect.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid dialect: %w", err)
+	}
+
+	records := newCSVRecordReader(ctx, text, dialect)
+	records.skipped = skip
+	if header.Absent {
+		records.headers = 0
+	}
+	return header.NewReader(records), nil
+}
+
+// csvRecordReader reads CSV records, handing malformed data records to the error
+// policy of the conversion (see convert.Reject) instead of failing.
+type csvRecordReader struct {
+	ctx     context.Context
+	reader  stream.OffsetRecordReader
+	raw     *rawRecorder // nil unless bad records are skipped
+	count   int          // records read so far, header included
+	headers int          // leading records that are a header (0 or 1)
+	skipped int          // lines skipped before the first record
+}
+
+// newCSVRecordReader creates a record reader for the dialect.
+func newCSVRecordReader(ctx context.Context, r io.Reader, dialect stream.Dialect) *csvRecordReader {
+	c := &csvRecordReader{ctx: ctx, headers: 1}
+	if convert.SkipsBadRecords(ctx) {
+		c.raw = &rawRecorder{r: r}
+		r = c.raw
+	}
+	c.reader = dialect.NewReader(r)
+	return c
+}
+
+// Read returns the next well-formed record. A malformed header always fails. Line
+// numbers in errors count the skipped lines.
+func (c *csvRecordReader) Read() ([]string, error) {
+	for {
+		record, err := c.reader.Read()
+		var raw string
+		if c.raw != nil {
+			raw = c.raw.take(c.reader.InputOffset())
+		}
+		if err == nil || err == io.EOF {
+			c.count++
+			return record, err
+		}
+
+		var parseErr *csv.ParseError
+		isParseErr := errors.As(err, &parseErr)
+		if isParseErr {
+			parseErr.StartLine += c.skipped
+			parseErr.Line += c.skipped
+		}
+		if c.raw == nil || c.count < c.headers || !isParseErr {
+			return nil, err
+		}
+		c.count++
+		if rejectErr := convert.Reject(c.ctx, &convert.RecordError{
+			Line:   parseErr.StartLine,
+			Row:    c.count - c.headers,
+			Record: strings.TrimRight(raw, "\r\n"),
+			Err:    err,
+		}); rejectErr != nil {
+			return nil, rejectErr
+		}
+	}
+}
+
+// rawRecorder keeps the input bytes not yet claimed by a record, so the raw text of a
+// malformed record can be quarantined.
+type rawRecorder struct {
+	r    io.Reader
+	buf  []byte
+	base int64 // input offset of buf[0]
+}
+
+func (rr *rawRecorder) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	rr.buf = append(rr.buf, p[:n]...)
+	return n, err
+}
+
+// take returns the input up to offset end and forgets it.
+func (rr *rawRecorder) take(end int64) string {
+	n := int(end - rr.base)
+	n = max(0, min(n, len(rr.buf)))
+	raw := string(rr.buf[:n])
+	rr.buf = append(rr.buf[:0], rr.buf[n:]...)
+	rr.base = end
+	return raw
+}
+
 // readCSV reads CSV data from the given reader.
-func readCSV(r io.Reader, resource string) (interface{}, error) {
+func readCSV(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (interface{}, error) {
 	if r == nil {
 		return nil, fmt.Errorf("readCSV requires a valid reader")
 	}
+	return readDelimited(ctx, r, resource, opts, ',', true)
+}
 
-	reader := csv.NewReader(r)
+// readDelimited reads every record of a delimited file, header row first; delimiter
+// and sniff are as for openDelimited.
+func readDelimited(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions, delimiter rune, sniff bool) (interface{}, error) {
+	reader, err := openDelimited(ctx, r, opts, delimiter, sniff)
+	if err != nil {
+		return nil, err
+	}
 
 	// Read all records
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV from '%s': %w", resource, err)
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV from '%s': %w", resource, err)
+		}
+		records = append(records, record)
 	}
 
 	return records, nil
 }
 
 // writeCSV writes data as CSV to the given writer.
-func writeCSV(w io.Writer, resource string, data interface{}) error {
+// Accepts [][]string (header row first) or a *dataset.Dataset.
+func writeCSV(ctx context.Context, w io.Writer, resource string, data interface{}, opts convert.FormatOptions) error {
 	if w == nil {
 		return fmt.Errorf("writeCSV requires a valid writer")
 	}
+	return writeDelimited(w, resource, data, csvDialect(opts, ','))
+}
 
-	records, ok := data.([][]string)
-	if !ok {
-		return fmt.Errorf("invalid data type for CSV writer, expected [][]string")
+// writeDelimited writes data as a delimited file in the given dialect.
+func writeDelimited(w io.Writer, resource string, data interface{}, dialect stream.Dialect) error {
+	var records [][]string
+	switch v := data.(type) {
+	case [][]string:
+		records = v
+	case *dataset.Dataset:
+		records = v.StringRows()
+	default:
+		return fmt.Errorf("invalid data type for CSV writer, expected [][]string or dataset")
+	}
+	if err := dialect.Validate(); err != nil {
+		return fmt.Errorf("invalid dialect: %w", err)
 	}
 
-	writer := csv.NewWriter(w)
-	if err := writer.WriteAll(records); err != nil {
-		return fmt.Errorf("failed to write CSV to '%s': %w", resource, err)
+	writer := dialect.NewWriter(w)
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV to '%s': %w", resource, err)
+		}
 	}
 
 	writer.Flush()
@@ -57,3 +313,46 @@ func writeCSV(w io.Writer, resource string, data interface{}) error {
 
 	return nil
 }
+
+// streamReadCSV opens a row-by-row CSV reader on top of r.
+func streamReadCSV(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (stream.StreamingReader, error) {
+	if r == nil {
+		return nil, fmt.Errorf("streamReadCSV requires a valid reader")
+	}
+	return streamReadDelimited(ctx, r, opts, ',', true)
+}
+
+// streamReadDelimited opens a row-by-row reader over a delimited file; delimiter and
+// sniff are as for openDelimited.
+func streamReadDelimited(ctx context.Context, r io.Reader, opts convert.FormatOptions, delimiter rune, sniff bool) (stream.StreamingReader, error) {
+	reader, err := openDelimited(ctx, r, opts, delimiter, sniff)
+	if err != nil {
+		return nil, err
+	}
+	return stream.NewCSVStreamingReaderFromRecords(reader)
+}
+
+// streamWriteCSV opens a row-by-row CSV writer on top of w.
+func streamWriteCSV(ctx context.Context, w io.Writer, resource string, columns []string, opts convert.FormatOptions) (stream.StreamingWriter, error) {
+	if w == nil {
+		return nil, fmt.Errorf("streamWriteCSV requires a valid writer")
+	}
+	return streamWriteDelimited(w, columns, csvDialect(opts, ','))
+}
+
+// streamWriteDelimited opens a row-by-row writer producing a delimited file in the given dialect.
+func streamWriteDelimited(w io.Writer, columns []string, dialect stream.Dialect) (stream.StreamingWriter, error) {
+	if err := dialect.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid dialect: %w", err)
+	}
+	return stream.NewCSVStreamingWriterWith(dialect.NewWriter(w), columns), nil
+}
+
+// rowsToDataset adapts [][]string rows (header row first) into a Dataset.
+func rowsToDataset(data interface{}) (*dataset.Dataset, error) {
+	rows, ok := data.([][]string)
+	if !ok {
+		return nil, fmt.Errorf("invalid row data type %T, expected [][]string", data)
+	}
+	return dataset.FromRows(rows), nil
+}
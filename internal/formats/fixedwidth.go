@@ -0,0 +1,474 @@
+package formats
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+	"omnidata/internal/stream"
+
+	"gopkg.in/yaml.v3"
+)
+
+// init registers the fixed-width text format handler in the global Registry
+func init() {
+	convert.RegisterFormat("fixedwidth", convert.FormatHandler{
+		Name:           "fixedwidth",
+		ReaderFn:       readFixedWidth,
+		WriterFn:       writeFixedWidth,
+		StreamReaderFn: streamReadFixedWidth,
+		StreamWriterFn: streamWriteFixedWidth,
+		Extensions:     []string{".fwf"},
+		ReaderOptions: append(fixedWidthSpecOptions(),
+			convert.OptionSpec{Name: "trim", Type: convert.OptionBool, Default: "true", Description: "Strip the padding of each field"},
+			convert.OptionSpec{Name: "header", Type: convert.OptionBool, Default: "false", Description: "The first line is a header and is skipped (names come from the spec)"},
+			convert.OptionSpec{Name: "skip_rows", Type: convert.OptionInt, Default: "0", Description: "Lines skipped before the data, e.g. a preamble"},
+		),
+		WriterOptions: append(fixedWidthSpecOptions(),
+			convert.OptionSpec{Name: "header", Type: convert.OptionBool, Default: "false", Description: "Write the column names as the first line"},
+			convert.OptionSpec{Name: "truncate", Type: convert.OptionBool, Default: "false", Description: "Cut values longer than their column instead of failing"},
+		),
+	})
+}
+
+// fixedWidthSpecOptions declares the options giving the column spec, for readers and writers.
+func fixedWidthSpecOptions() []convert.OptionSpec {
+	return []convert.OptionSpec{
+		{Name: "columns", Type: convert.OptionString, Description: "Inline column spec: name:start:width[:align[:pad]],... (start is 1-based, empty to follow the previous column)"},
+		{Name: "spec", Type: convert.OptionString, Description: "JSON or YAML file with the column spec ({\"columns\": [{\"name\", \"start\", \"width\", \"align\", \"pad\"}]})"},
+	}
+}
+
+// fixedWidthColumn is a field of a fixed-width record.
+type fixedWidthColumn struct {
+	Name  string `yaml:"name"`
+	Start int    `yaml:"start"` // 1-based position of the first character; 0 follows the previous column
+	Width int    `yaml:"width"`
+	Align string `yaml:"align"` // left (default) or right
+	Pad   string `yaml:"pad"`   // padding character (default space)
+}
+
+// fixedWidthSpec lists the columns of a fixed-width file, in order.
+type fixedWidthSpec struct {
+	Columns []fixedWidthColumn `yaml:"columns"`
+}
+
+// loadFixedWidthSpec returns the column spec given by the spec or columns option, or
+// nil when neither is set.
+func loadFixedWidthSpec(opts convert.FormatOptions) (*fixedWidthSpec, error) {
+	path, inline := opts.String("spec", ""), opts.String("columns", "")
+	spec := &fixedWidthSpec{}
+	switch {
+	case path != "" && inline != "":
+		return nil, fmt.Errorf("use either the spec or the columns option, not both")
+	case path != "":
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixed-width spec: %w", err)
+		}
+		// YAML is a superset of JSON, so both kinds of spec files decode here
+		if err := yaml.Unmarshal(content, spec); err != nil {
+			return nil, fmt.Errorf("invalid fixed-width spec '%s': %w", path, err)
+		}
+	case inline != "":
+		for _, entry := range strings.Split(inline, ",") {
+			column, err := parseFixedWidthColumn(strings.TrimSpace(entry))
+			if err != nil {
+				return nil, err
+			}
+			spec.Columns = append(spec.Columns, column)
+		}
+	default:
+		return nil, nil
+	}
+
+	if err := spec.normalize(); err != nil {
+		return nil, fmt.Errorf("invalid fixed-width spec: %w", err)
+	}
+	return spec, nil
+}
+
+// parseFixedWidthColumn parses an inline column: name:start:width[:align[:pad]].
+func parseFixedWidthColumn(entry string) (fixedWidthColumn, error) {
+	parts := strings.Split(entry, ":")
+	if len(parts) < 3 || len(parts) > 5 {
+		return fixedWidthColumn{}, fmt.Errorf("invalid column spec %q, expected name:start:width[:align[:pad]]", entry)
+	}
+
+	column := fixedWidthColumn{Name: parts[0]}
+	var err error
+	if parts[1] != "" {
+		if column.Start, err = strconv.Atoi(parts[1]); err != nil {
+			return column, fmt.Errorf("invalid start in column spec %q: %w", entry, err)
+		}
+	}
+	if column.Width, err = strconv.Atoi(parts[2]); err != nil {
+		return column, fmt.Errorf("invalid width in column spec %q: %w", entry, err)
+	}
+	if len(parts) > 3 {
+		column.Align = parts[3]
+	}
+	if len(parts) > 4 {
+		column.Pad = parts[4]
+	}
+	return column, nil
+}
+
+// normalize fills in defaults and checks that columns are named, in order and do not overlap.
+func (s *fixedWidthSpec) normalize() error {
+	if len(s.Columns) == 0 {
+		return fmt.Errorf("no columns declared")
+	}
+
+	next := 1 // first position after the previous column
+	seen := make(map[string]bool, len(s.Columns))
+	for i := range s.Columns {
+		c := &s.Columns[i]
+		c.Align = strings.ToLower(c.Align)
+		switch {
+		case c.Name == "":
+			return fmt.Errorf("column %d has no name", i+1)
+		case seen[c.Name]:
+			return fmt.Errorf("column %q is declared twice", c.Name)
+		case c.Width < 1:
+			return fmt.Errorf("column %q must be at least 1 character wide", c.Name)
+		case c.Start < 0:
+			return fmt.Errorf("column %q starts before position 1", c.Name)
+		case c.Align != "" && c.Align != "left" && c.Align != "right":
+			return fmt.Errorf("column %q has an unknown alignment %q (expected left or right)", c.Name, c.Align)
+		case c.Pad != "" && utf8.RuneCountInString(c.Pad) != 1:
+			return fmt.Errorf("column %q must be padded with a single character, got %q", c.Name, c.Pad)
+		}
+		seen[c.Name] = true
+
+		if c.Start == 0 {
+			c.Start = next
+		}
+		if c.Start < next {
+			return fmt.Errorf("column %q overlaps the previous column", c.Name)
+		}
+		if c.Align == "" {
+			c.Align = "left"
+		}
+		if c.Pad == "" {
+			c.Pad = " "
+		}
+		next = c.Start + c.Width
+	}
+	return nil
+}
+
+// names returns the column names in order.
+func (s *fixedWidthSpec) names() []string {
+	names := make([]string, len(s.Columns))
+	for i, c := range s.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// deriveFixedWidthSpec lays out the columns of ds side by side, one space apart, each as
+// wide as its longest value or name.
+func deriveFixedWidthSpec(ds *dataset.Dataset) *fixedWidthSpec {
+	spec := &fixedWidthSpec{}
+	start := 1
+	for i, name := range ds.Columns {
+		width := max(1, utf8.RuneCountInString(name))
+		for _, record := range ds.Records {
+			if i < len(record) {
+				width = max(width, utf8.RuneCountInString(dataset.FormatValue(record[i])))
+			}
+		}
+		spec.Columns = append(spec.Columns, fixedWidthColumn{Name: name, Start: start, Width: width, Align: "left", Pad: " "})
+		start += width + 1
+	}
+	return spec
+}
+
+// extract returns the value of the column in line, with its padding stripped if trim is set.
+func (c fixedWidthColumn) extract(line []rune, trim bool) string {
+	start := c.Start - 1
+	if start >= len(line) {
+		return ""
+	}
+	value := string(line[start:min(start+c.Width, len(line))])
+	if !trim {
+		return value
+	}
+
+	if c.Align == "right" {
+		trimmed := strings.TrimLeft(value, c.Pad)
+		// A zero-padded zero is still a number
+		if trimmed == "" && c.Pad == "0" && strings.Contains(value, "0") {
+			trimmed = "0"
+		}
+		return trimmed
+	}
+	return strings.TrimRight(value, c.Pad)
+}
+
+// format pads value to the width of the column. Longer values are cut if truncate is
+// set and are an error otherwise.
+func (c fixedWidthColumn) format(value string, truncate bool) (string, error) {
+	n := utf8.RuneCountInString(value)
+	if n > c.Width {
+		if !truncate {
+			return "", fmt.Errorf("value %q of column %q is longer than %d characters (use the truncate option to cut it)", value, c.Name, c.Width)
+		}
+		value, n = string([]rune(value)[:c.Width]), c.Width
+	}
+
+	padding := strings.Repeat(c.Pad, c.Width-n)
+	if c.Align == "right" {
+		return padding + value, nil
+	}
+	return value + padding, nil
+}
+
+// fixedWidthReader reads fixed-width records line by line. Blank lines are skipped.
+type fixedWidthReader struct {
+	reader *bufio.Reader
+	spec   *fixedWidthSpec
+	trim   bool
+}
+
+// newFixedWidthReader creates a reader over r using the spec given in opts.
+func newFixedWidthReader(r io.Reader, opts convert.FormatOptions) (*fixedWidthReader, error) {
+	spec, err := loadFixedWidthSpec(opts)
+	if err != nil {
+		return nil, err
+	}
+	if spec == nil {
+		return nil, fmt.Errorf("fixed-width input needs a column spec (use the columns or spec option)")
+	}
+
+	reader := &fixedWidthReader{reader: bufio.NewReader(r), spec: spec, trim: opts.Bool("trim", true)}
+	skip := opts.Int("skip_rows", 0)
+	if opts.Bool("header", false) {
+		skip++
+	}
+	for i := 0; i < skip; i++ {
+		if _, err := reader.reader.ReadString('\n'); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to skip rows: %w", err)
+		}
+	}
+	return reader, nil
+}
+
+// Columns returns the column names of the spec.
+func (r *fixedWidthReader) Columns() []string {
+	return r.spec.names()
+}
+
+// Read returns the fields of the next non-blank line.
+func (r *fixedWidthReader) Read() ([]string, error) {
+	for {
+		line, err := r.reader.ReadString('\n')
+		if line == "" && err != nil {
+			return nil, err
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		runes := []rune(line)
+		record := make([]string, len(r.spec.Columns))
+		for i, c := range r.spec.Columns {
+			record[i] = c.extract(runes, r.trim)
+		}
+		return record, nil
+	}
+}
+
+// ReadRow returns the next record as a column-name -> value map.
+func (r *fixedWidthReader) ReadRow() (map[string]interface{}, error) {
+	record, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	row := make(map[string]interface{}, len(record))
+	for i, c := range r.spec.Columns {
+		row[c.Name] = record[i]
+	}
+	return row, nil
+}
+
+// Close is a no-op; the caller owns the underlying reader.
+func (r *fixedWidthReader) Close() error {
+	return nil
+}
+
+// readFixedWidth reads fixed-width text into a dataset of string values.
+func readFixedWidth(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (interface{}, error) {
+	if r == nil {
+		return nil, fmt.Errorf("readFixedWidth requires a valid reader")
+	}
+
+	reader, err := newFixedWidthReader(r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ds := dataset.New(reader.Columns())
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixed-width data from '%s': %w", resource, err)
+		}
+		values := make([]interface{}, len(record))
+		for i, v := range record {
+			values[i] = v
+		}
+		ds.Append(values...)
+	}
+
+	return ds, nil
+}
+
+// fixedWidthWriter writes records as fixed-width lines.
+type fixedWidthWriter struct {
+	writer        *bufio.Writer
+	spec          *fixedWidthSpec
+	truncate      bool
+	header        bool
+	headerWritten bool
+}
+
+// newFixedWidthWriter creates a writer on top of w laying out columns as spec says.
+func newFixedWidthWriter(w io.Writer, spec *fixedWidthSpec, opts convert.FormatOptions) *fixedWidthWriter {
+	return &fixedWidthWriter{
+		writer:   bufio.NewWriter(w),
+		spec:     spec,
+		truncate: opts.Bool("truncate", false),
+		header:   opts.Bool("header", false),
+	}
+}
+
+// writeLine writes values, aligned with the spec columns, as one line.
+func (w *fixedWidthWriter) writeLine(values []string, truncate bool) error {
+	var line strings.Builder
+	pos := 1
+	for i, c := range w.spec.Columns {
+		if c.Start > pos {
+			line.WriteString(strings.Repeat(" ", c.Start-pos))
+		}
+		field, err := c.format(values[i], truncate)
+		if err != nil {
+			return err
+		}
+		line.WriteString(field)
+		pos = c.Start + c.Width
+	}
+	line.WriteByte('\n')
+
+	_, err := w.writer.WriteString(line.String())
+	return err
+}
+
+// writeHeader writes the column names, cut to fit, if a header was asked for.
+func (w *fixedWidthWriter) writeHeader() error {
+	if !w.header || w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+	return w.writeLine(w.spec.names(), true)
+}
+
+// WriteRow writes a row; columns missing from the spec are not written.
+func (w *fixedWidthWriter) WriteRow(row map[string]interface{}) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	values := make([]string, len(w.spec.Columns))
+	for i, c := range w.spec.Columns {
+		values[i] = dataset.FormatValue(row[c.Name])
+	}
+	return w.writeLine(values, w.truncate)
+}
+
+// Close writes the header of an empty output and flushes buffered lines.
+func (w *fixedWidthWriter) Close() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	return w.writer.Flush()
+}
+
+// writeFixedWidth writes data as fixed-width text. Without a spec, the columns are laid
+// out one space apart, each as wide as its longest value.
+func writeFixedWidth(ctx context.Context, w io.Writer, resource string, data interface{}, opts convert.FormatOptions) error {
+	if w == nil {
+		return fmt.Errorf("writeFixedWidth requires a valid writer")
+	}
+
+	var ds *dataset.Dataset
+	switch v := data.(type) {
+	case *dataset.Dataset:
+		ds = v
+	case [][]string:
+		ds = dataset.FromRows(v)
+	default:
+		return fmt.Errorf("invalid data type for fixed-width writer, expected [][]string or dataset")
+	}
+
+	spec, err := loadFixedWidthSpec(opts)
+	if err != nil {
+		return err
+	}
+	if spec == nil {
+		spec = deriveFixedWidthSpec(ds)
+	}
+
+	writer := newFixedWidthWriter(w, spec, opts)
+	for i := range ds.Records {
+		if err := writer.WriteRow(ds.Map(i)); err != nil {
+			return fmt.Errorf("failed to write fixed-width row %d to '%s': %w", i+1, resource, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write fixed-width data to '%s': %w", resource, err)
+	}
+	return nil
+}
+
+// streamReadFixedWidth opens a line-at-a-time fixed-width reader on top of r.
+func streamReadFixedWidth(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (stream.StreamingReader, error) {
+	if r == nil {
+		return nil, fmt.Errorf("streamReadFixedWidth requires a valid reader")
+	}
+	return newFixedWidthReader(r, opts)
+}
+
+// streamWriteFixedWidth opens a line-at-a-time fixed-width writer on top of w. Rows are
+// not known in advance, so the column spec is required.
+func streamWriteFixedWidth(ctx context.Context, w io.Writer, resource string, columns []string, opts convert.FormatOptions) (stream.StreamingWriter, error) {
+	if w == nil {
+		return nil, fmt.Errorf("streamWriteFixedWidth requires a valid writer")
+	}
+
+	spec, err := loadFixedWidthSpec(opts)
+	if err != nil {
+		return nil, err
+	}
+	if spec == nil {
+		return nil, fmt.Errorf("streaming fixed-width output needs a column spec (use the columns or spec option)")
+	}
+	return newFixedWidthWriter(w, spec, opts), nil
+}
@@ -0,0 +1,32 @@
+package formats
+
+import (
+	"bytes"
+	"io"
+
+	"omnidata/internal/convert"
+)
+
+// readAtSeeker is input read at random offsets, as file formats with a footer need.
+type readAtSeeker interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+}
+
+// seekableInput returns the file r reads (see convert.InputFile) with its size, or
+// else the content of r buffered in memory.
+func seekableInput(r io.Reader) (readAtSeeker, int64, error) {
+	if f := convert.InputFile(r); f != nil {
+		info, err := f.Stat()
+		if err != nil {
+			return nil, 0, err
+		}
+		return f, info.Size(), nil
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(content), int64(len(content)), nil
+}
@@ -1,47 +1,261 @@
 package formats
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"strings"
 
 	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+	"omnidata/internal/stream"
 )
 
 // init registers the JSON format handler in the global Registry
 func init() {
 	convert.RegisterFormat("json", convert.FormatHandler{
-		Name:     "json",
-		ReaderFn: readJSON,
-		WriterFn: writeJSON,
+		Name:           "json",
+		ReaderFn:       readJSON,
+		WriterFn:       writeJSON,
+		ToDataset:      treeToDataset,
+		StreamReaderFn: streamReadJSON,
+		StreamWriterFn: streamWriteJSON,
+		DocumentFn:     readJSONDocument,
+		Nested:         true,
+		Extensions:     []string{".json"},
+		Signatures:     [][]byte{[]byte("["), []byte("{")},
+		WriterOptions: []convert.OptionSpec{
+			{Name: "indent", Type: convert.OptionInt, Default: "2", Description: "Spaces per indentation level; 0 writes compact JSON"},
+		},
 	})
 }
 
 // readJSON reads JSON data from the given reader.
-func readJSON(r io.Reader, resource string) (interface{}, error) {
+func readJSON(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (interface{}, error) {
 	if r == nil {
 		return nil, fmt.Errorf("readJSON requires a valid reader")
 	}
 
-	var data interface{}
-	if err := json.NewDecoder(r).Decode(&data); err != nil {
+	// Decode numbers losslessly and keep key order so columns follow the document
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	data, err := decodeJSONValue(decoder)
+	if err != nil {
 		return nil, fmt.Errorf("failed to decode JSON from '%s': %w", resource, err)
 	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("failed to decode JSON from '%s': unexpected data after the first value (use the ndjson format for one object per line)", resource)
+	}
 
 	return data, nil
 }
 
+// readJSONDocument reads a JSON document, keeping the key order of its objects.
+func readJSONDocument(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (interface{}, error) {
+	if r == nil {
+		return nil, fmt.Errorf("readJSONDocument requires a valid reader")
+	}
+
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	doc, err := decodeJSONValue(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JSON from '%s': %w", resource, err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("failed to decode JSON from '%s': unexpected data after the first value", resource)
+	}
+	return doc, nil
+}
+
+// decodeJSONValue decodes the next value from its tokens, objects as orderedObject
+// values. A repeated key keeps its first position and its last value.
+func decodeJSONValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return dataset.Normalize(token), nil
+	}
+
+	if delim == '[' {
+		list := make([]interface{}, 0)
+		for decoder.More() {
+			value, err := decodeJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := decoder.Token()
+		return list, err
+	}
+
+	var obj orderedObject
+	index := make(map[string]int)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := token.(string)
+		value, err := decodeJSONValue(decoder)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := index[key]; ok {
+			obj.values[i] = value
+			continue
+		}
+		index[key] = len(obj.keys)
+		obj.keys = append(obj.keys, key)
+		obj.values = append(obj.values, value)
+	}
+	_, err = decoder.Token()
+	return obj, err
+}
+
 // writeJSON writes data to the given writer as pretty-printed JSON.
-func writeJSON(w io.Writer, resource string, data interface{}) error {
+// A *dataset.Dataset is written as an array of objects with keys in column order.
+func writeJSON(ctx context.Context, w io.Writer, resource string, data interface{}, opts convert.FormatOptions) error {
 	if w == nil {
 		return fmt.Errorf("writeJSON requires a valid writer")
 	}
 
+	if ds, ok := data.(*dataset.Dataset); ok {
+		data = datasetToObjects(ds)
+	}
+
 	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
+	if indent := opts.Int("indent", 2); indent > 0 {
+		enc.SetIndent("", strings.Repeat(" ", indent))
+	}
 	if err := enc.Encode(data); err != nil {
 		return fmt.Errorf("failed to encode JSON to '%s': %w", resource, err)
 	}
 
 	return nil
 }
+
+// streamReadJSON opens a row-by-row reader over a top-level JSON array of objects.
+func streamReadJSON(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (stream.StreamingReader, error) {
+	if r == nil {
+		return nil, fmt.Errorf("streamReadJSON requires a valid reader")
+	}
+	return stream.NewJSONStreamingReaderFrom(r)
+}
+
+// streamWriteJSON opens a row-by-row writer producing a JSON array of objects.
+func streamWriteJSON(ctx context.Context, w io.Writer, resource string, columns []string, opts convert.FormatOptions) (stream.StreamingWriter, error) {
+	if w == nil {
+		return nil, fmt.Errorf("streamWriteJSON requires a valid writer")
+	}
+	return stream.NewJSONStreamingWriterIndent(w, columns, opts.Int("indent", 2)), nil
+}
+
+// treeToDataset adapts a decoded JSON/YAML document into a Dataset.
+//
+// Supported shapes:
+// - Array of objects: one record per object, columns are the union of keys in order.
+// - Single object: one record.
+// - Array of primitives: one record per element in a single "value" column.
+func treeToDataset(data interface{}) (*dataset.Dataset, error) {
+	switch v := data.(type) {
+	case nil:
+		return dataset.New([]string{}), nil
+	case []interface{}:
+		var columns []string
+		seen := make(map[string]bool)
+		objs := make([]map[string]interface{}, 0, len(v))
+		for _, item := range v {
+			keys, values, ok := documentEntries(item)
+			if !ok {
+				// Not an array of objects: treat every element as a scalar value
+				ds := dataset.New([]string{"value"})
+				for _, elem := range v {
+					ds.Append(tabularValue(elem))
+				}
+				return ds, nil
+			}
+			obj := make(map[string]interface{}, len(keys))
+			for i, key := range keys {
+				if !seen[key] {
+					seen[key] = true
+					columns = append(columns, key)
+				}
+				obj[key] = tabularValue(values[i])
+			}
+			objs = append(objs, obj)
+		}
+		return dataset.FromMapsOrdered(columns, objs), nil
+	case orderedObject, map[string]interface{}:
+		return treeToDataset([]interface{}{v})
+	default:
+		return nil, fmt.Errorf("unsupported document structure %T for tabular conversion", data)
+	}
+}
+
+// tabularValue turns a document value into a dataset cell: ordered objects become
+// plain maps and scalars are normalized.
+func tabularValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case orderedObject:
+		obj := make(map[string]interface{}, len(v.keys))
+		for i, key := range v.keys {
+			obj[key] = tabularValue(v.values[i])
+		}
+		return obj
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = tabularValue(item)
+		}
+		return list
+	}
+	return dataset.Normalize(value)
+}
+
+// orderedObject is a JSON object that preserves key order when marshaled.
+type orderedObject struct {
+	keys   []string
+	values []interface{}
+}
+
+// MarshalJSON writes the object keys in their original order.
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// datasetToObjects converts a Dataset into a list of objects with keys in column order.
+func datasetToObjects(ds *dataset.Dataset) []orderedObject {
+	objs := make([]orderedObject, len(ds.Records))
+	for i, rec := range ds.Records {
+		values := make([]interface{}, len(ds.Columns))
+		copy(values, rec)
+		objs[i] = orderedObject{keys: ds.Columns, values: values}
+	}
+	return objs
+}
@@ -0,0 +1,137 @@
+package formats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+	"omnidata/internal/stream"
+)
+
+// init registers the newline-delimited JSON format handler in the global Registry,
+// under both of its common names.
+func init() {
+	handler := convert.FormatHandler{
+		Name:           "ndjson",
+		ReaderFn:       readNDJSON,
+		WriterFn:       writeNDJSON,
+		StreamReaderFn: streamReadNDJSON,
+		StreamWriterFn: streamWriteNDJSON,
+		Nested:         true,
+		Extensions:     []string{".ndjson", ".jsonl"},
+	}
+	convert.RegisterFormat("ndjson", handler)
+
+	// Extensions stay with ndjson so path detection always picks one name
+	handler.Name = "jsonl"
+	handler.Extensions = nil
+	convert.RegisterFormat("jsonl", handler)
+}
+
+// ndjsonRowReader reads NDJSON rows, handing malformed lines to the error policy of
+// the conversion (see convert.Reject) instead of failing.
+type ndjsonRowReader struct {
+	*stream.NDJSONStreamingReader
+	ctx  context.Context
+	rows int // objects read so far, rejected ones included
+}
+
+// newNDJSONRowReader creates a row reader over r.
+func newNDJSONRowReader(ctx context.Context, r io.Reader) *ndjsonRowReader {
+	return &ndjsonRowReader{NDJSONStreamingReader: stream.NewNDJSONStreamingReaderFrom(r), ctx: ctx}
+}
+
+// ReadRow returns the object on the next valid line.
+func (r *ndjsonRowReader) ReadRow() (map[string]interface{}, error) {
+	for {
+		row, err := r.NDJSONStreamingReader.ReadRow()
+		var lineErr *stream.LineError
+		if err == nil || !errors.As(err, &lineErr) {
+			if err == nil {
+				r.rows++
+			}
+			return row, err
+		}
+
+		r.rows++
+		if rejectErr := convert.Reject(r.ctx, &convert.RecordError{
+			Line:   lineErr.Line,
+			Row:    r.rows,
+			Record: lineErr.Text,
+			Err:    lineErr.Err,
+		}); rejectErr != nil {
+			return nil, rejectErr
+		}
+	}
+}
+
+// readNDJSON reads one JSON object per line into a dataset, with columns in order of
+// first appearance. Blank lines are skipped.
+func readNDJSON(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (interface{}, error) {
+	if r == nil {
+		return nil, fmt.Errorf("readNDJSON requires a valid reader")
+	}
+
+	reader := newNDJSONRowReader(ctx, r)
+	var rows []map[string]interface{}
+	for {
+		row, err := reader.ReadRow()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read NDJSON from '%s': %w", resource, err)
+		}
+		rows = append(rows, row)
+	}
+
+	return dataset.FromMapsOrdered(reader.Columns(), rows), nil
+}
+
+// writeNDJSON writes each record as a compact JSON object on its own line.
+func writeNDJSON(ctx context.Context, w io.Writer, resource string, data interface{}, opts convert.FormatOptions) error {
+	if w == nil {
+		return fmt.Errorf("writeNDJSON requires a valid writer")
+	}
+
+	var ds *dataset.Dataset
+	switch v := data.(type) {
+	case *dataset.Dataset:
+		ds = v
+	case [][]string:
+		ds = dataset.FromRows(v)
+	default:
+		return fmt.Errorf("invalid data type for NDJSON writer, expected [][]string or dataset")
+	}
+
+	writer := stream.NewNDJSONStreamingWriterTo(w, ds.Columns)
+	for i := range ds.Records {
+		if err := writer.WriteRow(ds.Map(i)); err != nil {
+			return fmt.Errorf("failed to write NDJSON to '%s': %w", resource, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write NDJSON to '%s': %w", resource, err)
+	}
+
+	return nil
+}
+
+// streamReadNDJSON opens a line-at-a-time reader over newline-delimited JSON.
+func streamReadNDJSON(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (stream.StreamingReader, error) {
+	if r == nil {
+		return nil, fmt.Errorf("streamReadNDJSON requires a valid reader")
+	}
+	return newNDJSONRowReader(ctx, r), nil
+}
+
+// streamWriteNDJSON opens a line-at-a-time writer producing newline-delimited JSON.
+func streamWriteNDJSON(ctx context.Context, w io.Writer, resource string, columns []string, opts convert.FormatOptions) (stream.StreamingWriter, error) {
+	if w == nil {
+		return nil, fmt.Errorf("streamWriteNDJSON requires a valid writer")
+	}
+	return stream.NewNDJSONStreamingWriterTo(w, columns), nil
+}
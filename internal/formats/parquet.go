@@ -1,44 +1,757 @@
 package formats
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"math/big"
+	"reflect"
+	"time"
 
 	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+	"omnidata/internal/stream"
+
+	"github.com/google/uuid"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+	"github.com/parquet-go/parquet-go/format"
 )
 
 // init registers the Parquet format handler in the global Registry
-// Note: Parquet support requires additional dependencies
 func init() {
 	convert.RegisterFormat("parquet", convert.FormatHandler{
-		Name:     "parquet",
-		ReaderFn: readParquet,
-		WriterFn: writeParquet,
+		Name:           "parquet",
+		ReaderFn:       readParquet,
+		WriterFn:       writeParquet,
+		StreamReaderFn: streamReadParquet,
+		StreamWriterFn: streamWriteParquet,
+		SummaryFn:      summarizeParquet,
+		Extensions:     []string{".parquet"},
+		Signatures:     [][]byte{[]byte("PAR1")},
+		WriterOptions: []convert.OptionSpec{
+			{Name: "compression", Type: convert.OptionString, Default: "snappy", Description: "Page compression", Values: []string{"snappy", "gzip", "uncompressed"}},
+			{Name: "row_group_size", Type: convert.OptionInt, Default: "10000", Description: "Rows per row group; streaming output infers its schema from the first row group"},
+		},
 	})
 }
 
+// parquetCodecs are the page compression codecs the writer offers.
+var parquetCodecs = map[string]compress.Codec{
+	"snappy":       &parquet.Snappy,
+	"gzip":         &parquet.Gzip,
+	"uncompressed": &parquet.Uncompressed,
+}
+
+// openParquet opens the Parquet file r reads. Files are read in place, starting from
+// their footer; other input is buffered in memory first.
+func openParquet(r io.Reader) (*parquet.File, error) {
+	input, size, err := seekableInput(r)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := parquet.OpenFile(input, size, parquet.SkipPageIndex(true), parquet.SkipBloomFilters(true))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Parquet file: %w", err)
+	}
+	return file, nil
+}
+
+// parquetNode is a node of a Parquet schema with the levels needed to assemble its
+// values from the leaf column values of a row.
+type parquetNode struct {
+	name    string
+	node    parquet.Node
+	def     int   // definition level of a present value
+	rep     int   // repetition level of a repeated node's elements
+	columns []int // indexes of the leaf columns below the node, in schema order
+	fields  []*parquetNode
+}
+
+// newParquetNode builds the node tree of a schema node below the given levels,
+// numbering leaf columns from next.
+func newParquetNode(name string, node parquet.Node, def, rep int, next *int) *parquetNode {
+	n := &parquetNode{name: name, node: node, def: def, rep: rep}
+	if node.Optional() || node.Repeated() {
+		n.def++
+	}
+	if node.Repeated() {
+		n.rep++
+	}
+
+	if node.Leaf() {
+		n.columns = []int{*next}
+		*next++
+		return n
+	}
+	for _, field := range node.Fields() {
+		child := newParquetNode(field.Name(), field, n.def, n.rep, next)
+		n.fields = append(n.fields, child)
+		n.columns = append(n.columns, child.columns...)
+	}
+	return n
+}
+
+// parquetFields returns the top-level fields of a schema.
+func parquetFields(schema *parquet.Schema) []*parquetNode {
+	next := 0
+	return newParquetNode("", schema, 0, 0, &next).fields
+}
+
+// value assembles the value of n from the values of its leaf columns, indexed by
+// column, which hold one instance of the node's parent. Repeated nodes give lists.
+func (n *parquetNode) value(values [][]parquet.Value) interface{} {
+	first := values[n.columns[0]]
+	if len(first) == 0 {
+		return nil
+	}
+	if n.node.Repeated() {
+		items := []interface{}{}
+		if first[0].DefinitionLevel() < n.def {
+			return items
+		}
+		for _, element := range n.elements(values) {
+			items = append(items, n.present(element))
+		}
+		return items
+	}
+	if n.node.Optional() && first[0].DefinitionLevel() < n.def {
+		return nil
+	}
+	return n.present(values)
+}
+
+// elements splits the leaf column values of a repeated node into one set per element:
+// a value repeated at the node's level starts a new element.
+func (n *parquetNode) elements(values [][]parquet.Value) [][][]parquet.Value {
+	var elements [][][]parquet.Value
+	for _, column := range n.columns {
+		k, start := 0, 0
+		for i, v := range values[column] {
+			if i == 0 || v.RepetitionLevel() > n.rep {
+				continue
+			}
+			elements = n.setElement(elements, values, k, column, values[column][start:i])
+			k, start = k+1, i
+		}
+		elements = n.setElement(elements, values, k, column, values[column][start:])
+	}
+	return elements
+}
+
+// setElement sets the values of a column for element k, adding the element if needed.
+func (n *parquetNode) setElement(elements [][][]parquet.Value, values [][]parquet.Value, k, column int, part []parquet.Value) [][][]parquet.Value {
+	for len(elements) <= k {
+		elements = append(elements, append([][]parquet.Value(nil), values...))
+	}
+	elements[k][column] = part
+	return elements
+}
+
+// present returns the value of a node known to be present. Groups become maps, LIST
+// groups lists of their elements and MAP groups maps of their keys.
+func (n *parquetNode) present(values [][]parquet.Value) interface{} {
+	if n.node.Leaf() {
+		v := values[n.columns[0]][0]
+		if v.IsNull() {
+			return nil
+		}
+		return parquetValue(n.node.Type(), v)
+	}
+
+	if logical := n.node.Type().LogicalType(); logical != nil && len(n.fields) == 1 && n.fields[0].node.Repeated() {
+		entries, _ := n.fields[0].value(values).([]interface{})
+		entry := n.fields[0]
+		switch {
+		case logical.List != nil:
+			if !entry.node.Leaf() && len(entry.fields) == 1 {
+				for i, item := range entries {
+					entries[i] = item.(map[string]interface{})[entry.fields[0].name]
+				}
+			}
+			return entries
+		case logical.Map != nil && len(entry.fields) == 2:
+			m := make(map[string]interface{}, len(entries))
+			for _, item := range entries {
+				kv := item.(map[string]interface{})
+				m[dataset.FormatValue(kv[entry.fields[0].name])] = kv[entry.fields[1].name]
+			}
+			return m
+		}
+	}
+
+	m := make(map[string]interface{}, len(n.fields))
+	for _, field := range n.fields {
+		m[field.name] = field.value(values)
+	}
+	return m
+}
+
+// parquetValue converts a leaf value into a dataset value by its logical type, or by
+// its physical type when it has none.
+func parquetValue(t parquet.Type, v parquet.Value) interface{} {
+	logical := t.LogicalType()
+	if logical == nil {
+		logical = &format.LogicalType{}
+	}
+	switch {
+	case logical.Date != nil:
+		return dataset.DateOf(time.Unix(int64(v.Int32())*86400, 0).UTC())
+	case logical.Timestamp != nil:
+		return time.Unix(0, v.Int64()*parquetUnit(logical.Timestamp.Unit)).UTC()
+	case logical.Time != nil:
+		nanos := v.Int64()
+		if t.Kind() == parquet.Int32 {
+			nanos = int64(v.Int32())
+		}
+		return time.Time{}.Add(time.Duration(nanos * parquetUnit(logical.Time.Unit))).Format("15:04:05.999999999")
+	case logical.Decimal != nil:
+		unscaled := new(big.Int)
+		switch t.Kind() {
+		case parquet.Int32:
+			unscaled.SetInt64(int64(v.Int32()))
+		case parquet.Int64:
+			unscaled.SetInt64(v.Int64())
+		default:
+			b := v.ByteArray()
+			unscaled.SetBytes(b)
+			if len(b) > 0 && b[0]&0x80 != 0 {
+				unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+			}
+		}
+		return dataset.Decimal{Unscaled: unscaled, Scale: logical.Decimal.Scale}
+	case logical.UUID != nil:
+		if id, err := uuid.FromBytes(v.ByteArray()); err == nil {
+			return id.String()
+		}
+	case logical.Integer != nil && !logical.Integer.IsSigned:
+		if t.Kind() == parquet.Int32 {
+			return int64(v.Uint32())
+		}
+		return dataset.Normalize(v.Uint64())
+	}
+
+	switch t.Kind() {
+	case parquet.Boolean:
+		return v.Boolean()
+	case parquet.Int32:
+		return int64(v.Int32())
+	case parquet.Int64:
+		return v.Int64()
+	case parquet.Int96:
+		// Nanoseconds of the day, then the Julian day
+		i := v.Int96()
+		nanos := int64(i[1])<<32 | int64(i[0])
+		return time.Unix((int64(i[2])-2440588)*86400, nanos).UTC()
+	case parquet.Float:
+		return float64(v.Float())
+	case parquet.Double:
+		return v.Double()
+	}
+	if logical.UTF8 != nil || logical.Enum != nil || logical.Json != nil {
+		return string(v.ByteArray())
+	}
+	return bytes.Clone(v.ByteArray())
+}
+
+// parquetUnit returns the nanoseconds in a time unit.
+func parquetUnit(unit format.TimeUnit) int64 {
+	switch {
+	case unit.Millis != nil:
+		return int64(time.Millisecond)
+	case unit.Micros != nil:
+		return int64(time.Microsecond)
+	}
+	return 1
+}
+
+// parquetTypeName returns the schema type name peek reports for a node.
+func parquetTypeName(n *parquetNode) string {
+	if n.node.Repeated() {
+		return "array"
+	}
+	logical := n.node.Type().LogicalType()
+	if logical == nil {
+		logical = &format.LogicalType{}
+	}
+	if !n.node.Leaf() {
+		if logical.List != nil {
+			return "array"
+		}
+		return "object"
+	}
+
+	switch {
+	case logical.Date != nil:
+		return "date"
+	case logical.Timestamp != nil:
+		return "timestamp"
+	case logical.Decimal != nil, logical.Integer != nil:
+		return "number"
+	case logical.UTF8 != nil, logical.Enum != nil, logical.Json != nil, logical.UUID != nil, logical.Time != nil:
+		return "string"
+	}
+	switch n.node.Type().Kind() {
+	case parquet.Boolean:
+		return "boolean"
+	case parquet.Int32, parquet.Int64, parquet.Float, parquet.Double:
+		return "number"
+	case parquet.Int96:
+		return "timestamp"
+	}
+	return "bytes"
+}
+
+// parquetReader reads the rows of a Parquet file one row group at a time.
+type parquetReader struct {
+	ctx    context.Context
+	file   *parquet.File
+	fields []*parquetNode
+	group  int
+	rows   parquet.Rows
+	buffer []parquet.Row
+	n, pos int
+	values [][]parquet.Value // leaf column values of the current row
+}
+
+// newParquetReader opens the Parquet file r reads.
+func newParquetReader(ctx context.Context, r io.Reader) (*parquetReader, error) {
+	file, err := openParquet(r)
+	if err != nil {
+		return nil, err
+	}
+	return &parquetReader{
+		ctx:    ctx,
+		file:   file,
+		fields: parquetFields(file.Schema()),
+		buffer: make([]parquet.Row, 256),
+		values: make([][]parquet.Value, len(file.Schema().Columns())),
+	}, nil
+}
+
+// Columns returns the top-level field names of the file schema.
+func (r *parquetReader) Columns() []string {
+	columns := make([]string, len(r.fields))
+	for i, f := range r.fields {
+		columns[i] = f.name
+	}
+	return columns
+}
+
+// ReadRow returns the next row, reading the next row group when the current one is done.
+func (r *parquetReader) ReadRow() (map[string]interface{}, error) {
+	for r.pos >= r.n {
+		if err := r.ctx.Err(); err != nil {
+			return nil, err
+		}
+		if r.rows == nil {
+			groups := r.file.RowGroups()
+			if r.group >= len(groups) {
+				return nil, io.EOF
+			}
+			r.rows = groups[r.group].Rows()
+			r.group++
+		}
+
+		n, err := r.rows.ReadRows(r.buffer)
+		r.n, r.pos = n, 0
+		if err == io.EOF {
+			r.rows.Close()
+			r.rows = nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	row := r.buffer[r.pos]
+	r.pos++
+	for i := range r.values {
+		r.values[i] = r.values[i][:0]
+	}
+	for _, v := range row {
+		r.values[v.Column()] = append(r.values[v.Column()], v)
+	}
+	record := make(map[string]interface{}, len(r.fields))
+	for _, f := range r.fields {
+		record[f.name] = f.value(r.values)
+	}
+	return record, nil
+}
+
+// Close releases the row group being read; the underlying input is closed by its owner.
+func (r *parquetReader) Close() error {
+	if r.rows != nil {
+		return r.rows.Close()
+	}
+	return nil
+}
+
 // readParquet reads Parquet data from the given reader.
-func readParquet(r io.Reader, resource string) (interface{}, error) {
+func readParquet(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (interface{}, error) {
 	if r == nil {
 		return nil, fmt.Errorf("readParquet requires a valid reader")
 	}
 
-	// TODO: Implement actual Parquet reading
-	return nil, fmt.Errorf("Parquet format support requires additional dependencies. " +
-		"Install with: go get github.com/xitongsys/parquet-go")
+	reader, err := newParquetReader(ctx, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Parquet from '%s': %w", resource, err)
+	}
+	defer reader.Close()
+
+	var rows []map[string]interface{}
+	for {
+		row, err := reader.ReadRow()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Parquet from '%s' after row %d: %w", resource, len(rows), err)
+		}
+		rows = append(rows, row)
+	}
+
+	return dataset.FromMapsOrdered(reader.Columns(), rows), nil
+}
+
+/*
+summarizeParquet describes a Parquet file from its footer: the row count, the column
+types and, for top-level leaf columns, the null counts and bounds of every row group.
+Only the first rows asked for are decoded.
+*/
+func summarizeParquet(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions, rows int) (*convert.Summary, error) {
+	if r == nil {
+		return nil, fmt.Errorf("summarizeParquet requires a valid reader")
+	}
+
+	reader, err := newParquetReader(ctx, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Parquet from '%s': %w", resource, err)
+	}
+	defer reader.Close()
+
+	summary := &convert.Summary{RowCount: reader.file.NumRows()}
+	for _, field := range reader.fields {
+		column := convert.ColumnSummary{Name: field.name, Type: parquetTypeName(field), Nullable: field.node.Optional()}
+		if field.node.Leaf() {
+			parquetStats(reader.file, field, &column)
+		}
+		summary.Columns = append(summary.Columns, column)
+	}
+
+	var preview []map[string]interface{}
+	for len(preview) < rows {
+		row, err := reader.ReadRow()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Parquet from '%s' after row %d: %w", resource, len(preview), err)
+		}
+		preview = append(preview, row)
+	}
+	summary.Preview = dataset.FromMapsOrdered(reader.Columns(), preview)
+
+	return summary, nil
+}
+
+// parquetStats merges the column chunk statistics of a leaf column over all row
+// groups into column, leaving it as it is when a row group keeps none.
+func parquetStats(file *parquet.File, field *parquetNode, column *convert.ColumnSummary) {
+	t := field.node.Type()
+	var nulls int64
+	var min, max parquet.Value
+	for _, group := range file.Metadata().RowGroups {
+		chunk := group.Columns[field.columns[0]].MetaData
+		stats := chunk.Statistics
+		if stats.MinValue == nil || stats.MaxValue == nil {
+			if stats.NullCount < chunk.NumValues {
+				return
+			}
+			// Only nulls, so there are no bounds
+			nulls += stats.NullCount
+			continue
+		}
+		nulls += stats.NullCount
+		low, high := t.Kind().Value(stats.MinValue), t.Kind().Value(stats.MaxValue)
+		if min.IsNull() || t.Compare(low, min) < 0 {
+			min = low
+		}
+		if max.IsNull() || t.Compare(high, max) > 0 {
+			max = high
+		}
+	}
+
+	column.HasStats, column.NullCount, column.Nullable = true, nulls, nulls > 0
+	if !min.IsNull() {
+		column.Min = dataset.FormatValue(parquetValue(t, min))
+		column.Max = dataset.FormatValue(parquetValue(t, max))
+	}
+}
+
+// parquetGroup is a group node keeping its fields in column order, where
+// parquet.Group sorts them by name.
+type parquetGroup struct {
+	parquet.Group
+	fields []parquet.Field
+}
+
+func (g parquetGroup) Fields() []parquet.Field {
+	return g.fields
+}
+
+// parquetField is a named field of a parquetGroup.
+type parquetField struct {
+	parquet.Node
+	name string
+}
+
+func (f parquetField) Name() string {
+	return f.name
+}
+
+func (f parquetField) Value(base reflect.Value) reflect.Value {
+	return base.MapIndex(reflect.ValueOf(f.name))
+}
+
+/*
+parquetColumnNode returns the schema node a column is stored as.
+
+- Integers, floats and booleans become INT64, DOUBLE and BOOLEAN.
+- Decimals become DECIMAL on INT64 up to 18 digits and on 16 bytes up to 38, and strings beyond.
+- Dates and timestamps become DATE and microsecond TIMESTAMP; other columns become STRING.
+- Nullable columns are optional, the others required.
+*/
+func parquetColumnNode(column *columnType) parquet.Node {
+	var node parquet.Node
+	switch column.Kind {
+	case dataset.KindBool:
+		node = parquet.Leaf(parquet.BooleanType)
+	case dataset.KindInt:
+		node = parquet.Int(64)
+	case dataset.KindFloat:
+		node = parquet.Leaf(parquet.DoubleType)
+	case dataset.KindDecimal:
+		switch {
+		case column.Precision <= 18:
+			node = parquet.Decimal(column.Scale, column.Precision, parquet.Int64Type)
+		case column.Precision <= 38:
+			node = parquet.Decimal(column.Scale, column.Precision, parquet.FixedLenByteArrayType(16))
+		default:
+			column.Kind = dataset.KindString
+			node = parquet.String()
+		}
+	case dataset.KindDate:
+		node = parquet.Date()
+	case dataset.KindTimestamp:
+		node = parquet.Timestamp(parquet.Microsecond)
+	case dataset.KindBytes:
+		node = parquet.Leaf(parquet.ByteArrayType)
+	default:
+		column.Kind = dataset.KindString
+		node = parquet.String()
+	}
+	if column.Nullable {
+		node = parquet.Optional(node)
+	}
+	return node
+}
+
+// parquetLeaf converts a dataset value into the value of leaf column index of the
+// given type, casting it (see dataset.Cast) when its kind differs. Blank strings are
+// null in nullable columns.
+func parquetLeaf(column columnType, index int, v interface{}) (parquet.Value, error) {
+	if s, ok := v.(string); ok && column.Nullable && s == "" {
+		v = nil
+	}
+	cast, err := dataset.Cast(v, column.Kind)
+	if err != nil {
+		return parquet.Value{}, err
+	}
+	if cast == nil {
+		if !column.Nullable {
+			return parquet.Value{}, fmt.Errorf("null is not allowed by the Parquet column type %s", column.Kind)
+		}
+		return parquet.Value{}.Level(0, 0, index), nil
+	}
+
+	var value parquet.Value
+	switch c := cast.(type) {
+	case bool:
+		value = parquet.BooleanValue(c)
+	case int64:
+		value = parquet.Int64Value(c)
+	case float64:
+		value = parquet.DoubleValue(c)
+	case dataset.Decimal:
+		unscaled, err := column.unscaled(c)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		if column.Precision <= 18 {
+			value = parquet.Int64Value(unscaled.Int64())
+		} else {
+			if unscaled.Sign() < 0 {
+				unscaled.Add(unscaled, new(big.Int).Lsh(big.NewInt(1), 128))
+			}
+			value = parquet.FixedLenByteArrayValue(unscaled.FillBytes(make([]byte, 16)))
+		}
+	case dataset.Date:
+		value = parquet.Int32Value(int32(c.Time().Unix() / 86400))
+	case time.Time:
+		value = parquet.Int64Value(c.UnixMicro())
+	case []byte:
+		value = parquet.ByteArrayValue(c)
+	case string:
+		value = parquet.ByteArrayValue([]byte(c))
+	default:
+		return parquet.Value{}, fmt.Errorf("unsupported value %v", cast)
+	}
+	return value.Level(0, boolInt(column.Nullable), index), nil
+}
+
+// parquetWriter writes rows to a Parquet file, one row group per row_group_size rows.
+// The column types are inferred from the first row group unless given.
+type parquetWriter struct {
+	w         io.Writer
+	columns   []string
+	types     []columnType
+	codec     compress.Codec
+	groupSize int
+	group     []map[string]interface{}
+	writer    *parquet.Writer
+	rows      int
+	inferred  int // rows the column types were inferred from, 0 if they were not
+}
+
+// newParquetWriter creates a writer for the given columns, checking the options.
+func newParquetWriter(w io.Writer, columns []string, opts convert.FormatOptions) (*parquetWriter, error) {
+	writer := &parquetWriter{w: w, columns: columns, groupSize: opts.Int("row_group_size", 10000)}
+	if writer.groupSize < 1 {
+		return nil, fmt.Errorf("row_group_size must be 1 or more")
+	}
+	compression := opts.String("compression", "snappy")
+	writer.codec = parquetCodecs[compression]
+	if writer.codec == nil {
+		return nil, fmt.Errorf("unknown Parquet compression %q", compression)
+	}
+	return writer, nil
+}
+
+// WriteRow buffers a row, writing a row group once row_group_size rows are buffered.
+func (w *parquetWriter) WriteRow(row map[string]interface{}) error {
+	w.group = append(w.group, row)
+	if len(w.group) < w.groupSize {
+		return nil
+	}
+	return w.flush()
+}
+
+// flush writes the buffered rows as a row group, creating the file writer first.
+func (w *parquetWriter) flush() error {
+	if w.writer == nil {
+		if w.types == nil {
+			w.types = inferColumnTypes(dataset.FromMapsOrdered(w.columns, w.group), true)
+			w.inferred = len(w.group)
+		}
+		root := parquetGroup{Group: parquet.Group{}}
+		for i, name := range w.columns {
+			node := parquetColumnNode(&w.types[i])
+			root.Group[name] = node
+			root.fields = append(root.fields, parquetField{Node: node, name: name})
+		}
+		w.writer = parquet.NewWriter(w.w, parquet.NewSchema("schema", root),
+			parquet.Compression(w.codec), parquet.MaxRowsPerRowGroup(int64(w.groupSize)))
+	}
+	if len(w.group) == 0 {
+		return nil
+	}
+
+	rows := make([]parquet.Row, len(w.group))
+	for i, record := range w.group {
+		w.rows++
+		row := make(parquet.Row, len(w.columns))
+		for j, name := range w.columns {
+			value, err := parquetLeaf(w.types[j], j, record[name])
+			if err != nil {
+				if w.inferred > 0 {
+					return fmt.Errorf("row %d, column %q: %w (the column types were inferred from the first %d rows; "+
+						"raise row_group_size)", w.rows, name, err, w.inferred)
+				}
+				return fmt.Errorf("row %d, column %q: %w", w.rows, name, err)
+			}
+			row[j] = value
+		}
+		rows[i] = row
+	}
+	w.group = w.group[:0]
+	if _, err := w.writer.WriteRows(rows); err != nil {
+		return err
+	}
+	return w.writer.Flush()
+}
+
+// Close writes the remaining rows and the footer; an empty input still gets a schema.
+func (w *parquetWriter) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	return w.writer.Close()
 }
 
 // writeParquet writes data to a Parquet file to the given writer.
-func writeParquet(w io.Writer, resource string, data interface{}) error {
+func writeParquet(ctx context.Context, w io.Writer, resource string, data interface{}, opts convert.FormatOptions) error {
 	if w == nil {
 		return fmt.Errorf("writeParquet requires a valid writer")
 	}
 
-	if _, ok := data.([][]string); !ok {
-		return fmt.Errorf("invalid data type for Parquet writer, expected [][]string")
+	var ds *dataset.Dataset
+	switch v := data.(type) {
+	case *dataset.Dataset:
+		ds = v
+	case [][]string:
+		ds = dataset.FromRows(v)
+	default:
+		return fmt.Errorf("invalid data type for Parquet writer, expected [][]string or dataset")
+	}
+
+	writer, err := newParquetWriter(w, dataset.UniqueColumns(ds.Columns), opts)
+	if err != nil {
+		return err
+	}
+	// The whole dataset is at hand, so every row informs the column types
+	writer.types = inferColumnTypes(ds, false)
+	for i := range ds.Records {
+		row := make(map[string]interface{}, len(writer.columns))
+		for j, name := range writer.columns {
+			if j < len(ds.Records[i]) {
+				row[name] = ds.Records[i][j]
+			}
+		}
+		if err := writer.WriteRow(row); err != nil {
+			return fmt.Errorf("failed to write Parquet to '%s': %w", resource, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write Parquet to '%s': %w", resource, err)
+	}
+
+	return nil
+}
+
+// streamReadParquet opens a row-group-at-a-time reader over a Parquet file.
+func streamReadParquet(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (stream.StreamingReader, error) {
+	if r == nil {
+		return nil, fmt.Errorf("streamReadParquet requires a valid reader")
 	}
+	return newParquetReader(ctx, r)
+}
 
-	// TODO: Implement actual Parquet writing
-	return fmt.Errorf("Parquet format support requires additional dependencies. " +
-		"Install with: go get github.com/xitongsys/parquet-go")
+// streamWriteParquet creates a writer that buffers one row group at a time.
+func streamWriteParquet(ctx context.Context, w io.Writer, resource string, columns []string, opts convert.FormatOptions) (stream.StreamingWriter, error) {
+	if w == nil {
+		return nil, fmt.Errorf("streamWriteParquet requires a valid writer")
+	}
+	return newParquetWriter(w, dataset.UniqueColumns(columns), opts)
 }
@@ -1,12 +1,16 @@
 package formats
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
+	"time"
 
 	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
@@ -32,8 +36,8 @@ type SQLConnection struct {
 
 // readSQL reads data from a SQL database.
 // The resource parameter matches the connection string.
-// r (io.Reader) is ignored for SQL sources.
-func readSQL(r io.Reader, resource string) (interface{}, error) {
+// r (io.Reader) is ignored for SQL sources; cancelling ctx aborts the query.
+func readSQL(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (interface{}, error) {
 	// r is ignored, we use resource as conn string
 	path := resource
 	if path == "" {
@@ -53,7 +57,7 @@ func readSQL(r io.Reader, resource string) (interface{}, error) {
 	defer db.Close()
 
 	// Test connection
-	if err := db.Ping(); err != nil {
+	if err := db.PingContext(ctx); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
@@ -67,23 +71,23 @@ func readSQL(r io.Reader, resource string) (interface{}, error) {
 	}
 
 	// Execute query
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
 	defer rows.Close()
 
-	// Get column names
+	// Get column names and database types
 	columns, err := rows.Columns()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get columns: %w", err)
 	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get column types: %w", err)
+	}
 
-	// Read all rows
-	result := make([][]string, 0)
-
-	// Add header row
-	result = append(result, columns)
+	result := dataset.New(columns)
 
 	// Read data rows
 	for rows.Next() {
@@ -99,16 +103,12 @@ func readSQL(r io.Reader, resource string) (interface{}, error) {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
-		// Convert values to strings
-		row := make([]string, len(columns))
+		// Convert driver values to typed cells
+		rec := make(dataset.Record, len(columns))
 		for i, val := range values {
-			if val == nil {
-				row[i] = ""
-			} else {
-				row[i] = fmt.Sprintf("%v", val)
-			}
+			rec[i] = sqlValue(val, columnTypes[i].DatabaseTypeName())
 		}
-		result = append(result, row)
+		result.Records = append(result.Records, rec)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -118,20 +118,90 @@ func readSQL(r io.Reader, resource string) (interface{}, error) {
 	return result, nil
 }
 
+// sqlValue converts a scanned driver value into a typed cell.
+// NULL becomes nil; raw bytes (as returned by text protocols) are decoded
+// according to the column's database type name.
+func sqlValue(val interface{}, dbType string) interface{} {
+	raw, ok := val.([]byte)
+	if !ok {
+		switch v := val.(type) {
+		case string:
+			return parseSQLText(v, dbType)
+		case time.Time:
+			// Drivers scan DATE columns as midnight timestamps
+			if strings.ToUpper(dbType) == "DATE" {
+				return dataset.DateOf(v)
+			}
+		}
+		return dataset.Normalize(val)
+	}
+
+	switch strings.ToUpper(dbType) {
+	case "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY", "BYTEA":
+		b := make([]byte, len(raw))
+		copy(b, raw)
+		return b
+	default:
+		return parseSQLText(string(raw), dbType)
+	}
+}
+
+// parseSQLText converts the textual form of a column value based on its database type.
+// Values that do not parse are kept as strings.
+func parseSQLText(text, dbType string) interface{} {
+	upper := strings.ToUpper(dbType)
+	switch {
+	case strings.Contains(upper, "INT") || upper == "SERIAL" || upper == "BIGSERIAL" || upper == "YEAR":
+		if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return i
+		}
+	case upper == "DECIMAL" || upper == "NUMERIC":
+		if d, err := dataset.ParseDecimal(text); err == nil {
+			return d
+		}
+	case upper == "FLOAT" || upper == "DOUBLE" || upper == "REAL" || strings.HasPrefix(upper, "FLOAT") || upper == "DOUBLE PRECISION":
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+	case upper == "BOOL" || upper == "BOOLEAN":
+		if b, err := strconv.ParseBool(text); err == nil {
+			return b
+		}
+	case upper == "DATE":
+		if d, err := dataset.ParseDate(text); err == nil {
+			return d
+		}
+	case upper == "DATETIME" || strings.HasPrefix(upper, "TIMESTAMP"):
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02 15:04:05.999999999-07:00"} {
+			if t, err := time.Parse(layout, text); err == nil {
+				return t
+			}
+		}
+	}
+	return text
+}
+
 // writeSQL writes data to a SQL database table.
-// w (io.Writer) is ignored.
-func writeSQL(w io.Writer, resource string, data interface{}) error {
+// Accepts [][]string (header row first) or a *dataset.Dataset.
+// w (io.Writer) is ignored. Rows are inserted in a single transaction, rolled back
+// when an insert fails or ctx is cancelled, so the table never holds partial output.
+func writeSQL(ctx context.Context, w io.Writer, resource string, data interface{}, opts convert.FormatOptions) error {
 	path := resource
 	if path == "" {
 		return fmt.Errorf("SQL write to STDOUT is not supported")
 	}
 
-	records, ok := data.([][]string)
-	if !ok {
-		return fmt.Errorf("invalid data type for SQL writer, expected [][]string")
+	var ds *dataset.Dataset
+	switch v := data.(type) {
+	case [][]string:
+		ds = dataset.FromRows(v)
+	case *dataset.Dataset:
+		ds = v
+	default:
+		return fmt.Errorf("invalid data type for SQL writer, expected [][]string or dataset")
 	}
 
-	if len(records) == 0 {
+	if len(ds.Columns) == 0 {
 		return fmt.Errorf("no data to write")
 	}
 
@@ -152,12 +222,12 @@ func writeSQL(w io.Writer, resource string, data interface{}) error {
 	defer db.Close()
 
 	// Test connection
-	if err := db.Ping(); err != nil {
+	if err := db.PingContext(ctx); err != nil {
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	// Get headers (first row)
-	headers := records[0]
+	// Column names become the INSERT column list
+	headers := ds.Columns
 	placeholders := make([]string, len(headers))
 	for i := range placeholders {
 		placeholders[i] = "?"
@@ -169,37 +239,95 @@ func writeSQL(w io.Writer, resource string, data interface{}) error {
 		strings.Join(headers, ", "),
 		strings.Join(placeholders, ", "))
 
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// Rolls back unless the transaction was committed below
+	defer tx.Rollback()
+
 	// Prepare statement
-	stmt, err := db.Prepare(insertSQL)
+	stmt, err := tx.PrepareContext(ctx, insertSQL)
 	if err != nil {
 		return fmt.Errorf("failed to prepare INSERT statement: %w", err)
 	}
 	defer stmt.Close()
 
 	// Insert rows
-	for i := 1; i < len(records); i++ {
-		row := records[i]
+	skipBad := convert.SkipsBadRecords(ctx)
+	for i, rec := range ds.Records {
 		values := make([]interface{}, len(headers))
-		for j := 0; j < len(headers) && j < len(row); j++ {
-			if row[j] == "" {
-				values[j] = nil
-			} else {
-				values[j] = row[j]
+		for j := range headers {
+			if j < len(rec) {
+				values[j] = sqlArg(rec[j])
 			}
 		}
-		// Pad with nil if row is shorter than headers
-		for j := len(row); j < len(headers); j++ {
-			values[j] = nil
+
+		if !skipBad {
+			if _, err := stmt.ExecContext(ctx, values...); err != nil {
+				return fmt.Errorf("failed to insert row %d: %w", i+1, err)
+			}
+			continue
 		}
 
-		if _, err := stmt.Exec(values...); err != nil {
-			return fmt.Errorf("failed to insert row %d: %w", i, err)
+		// A savepoint keeps the transaction usable after a failed insert
+		// (PostgreSQL aborts the whole transaction otherwise)
+		if err := insertRow(ctx, tx, stmt, values); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			rejectErr := convert.Reject(ctx, &convert.RecordError{
+				Row:    i + 1,
+				Record: ds.Map(i),
+				Err:    fmt.Errorf("failed to insert row: %w", err),
+			})
+			if rejectErr != nil {
+				return rejectErr
+			}
 		}
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
 }
 
+// insertRow runs a prepared INSERT inside a savepoint, rolling back to it on failure.
+func insertRow(ctx context.Context, tx *sql.Tx, stmt *sql.Stmt, values []interface{}) error {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT omnidata_row"); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, values...); err != nil {
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT omnidata_row"); rbErr != nil {
+			return fmt.Errorf("%w (rollback to savepoint failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	_, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT omnidata_row")
+	return err
+}
+
+// sqlArg converts a typed cell into a database/sql argument.
+// Empty strings are written as NULL since text sources cannot express NULL otherwise.
+func sqlArg(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case string:
+		if val == "" {
+			return nil
+		}
+		return val
+	case dataset.Decimal, dataset.Date:
+		return dataset.FormatValue(val)
+	case map[string]interface{}, []interface{}:
+		return dataset.FormatValue(val)
+	default:
+		return val
+	}
+}
+
 // parseSQLPath parses a SQL connection string
 // Format: "driver://dsn?query=SELECT * FROM table&table=table_name"
 func parseSQLPath(path string) (*SQLConnection, error) {
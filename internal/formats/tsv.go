@@ -0,0 +1,57 @@
+package formats
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"omnidata/internal/convert"
+	"omnidata/internal/stream"
+)
+
+// init registers the TSV format handler: CSV with a tab delimiter by default.
+func init() {
+	convert.RegisterFormat("tsv", convert.FormatHandler{
+		Name:           "tsv",
+		ReaderFn:       readTSV,
+		WriterFn:       writeTSV,
+		ToDataset:      rowsToDataset,
+		StreamReaderFn: streamReadTSV,
+		StreamWriterFn: streamWriteTSV,
+		Extensions:     []string{".tsv", ".tab"},
+		ReaderOptions:  csvReaderOptions(`\t`),
+		WriterOptions:  csvWriterOptions(`\t`),
+	})
+}
+
+// readTSV reads tab-separated data from the given reader.
+func readTSV(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (interface{}, error) {
+	if r == nil {
+		return nil, fmt.Errorf("readTSV requires a valid reader")
+	}
+	return readDelimited(ctx, r, resource, opts, '\t', false)
+}
+
+// writeTSV writes data as tab-separated values to the given writer.
+func writeTSV(ctx context.Context, w io.Writer, resource string, data interface{}, opts convert.FormatOptions) error {
+	if w == nil {
+		return fmt.Errorf("writeTSV requires a valid writer")
+	}
+	return writeDelimited(w, resource, data, csvDialect(opts, '\t'))
+}
+
+// streamReadTSV opens a row-by-row TSV reader on top of r.
+func streamReadTSV(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (stream.StreamingReader, error) {
+	if r == nil {
+		return nil, fmt.Errorf("streamReadTSV requires a valid reader")
+	}
+	return streamReadDelimited(ctx, r, opts, '\t', false)
+}
+
+// streamWriteTSV opens a row-by-row TSV writer on top of w.
+func streamWriteTSV(ctx context.Context, w io.Writer, resource string, columns []string, opts convert.FormatOptions) (stream.StreamingWriter, error) {
+	if w == nil {
+		return nil, fmt.Errorf("streamWriteTSV requires a valid writer")
+	}
+	return streamWriteDelimited(w, columns, csvDialect(opts, '\t'))
+}
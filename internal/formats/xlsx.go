@@ -1,9 +1,16 @@
 package formats
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/xuri/excelize/v2"
 )
@@ -11,15 +18,28 @@ import (
 // init registers the XLSX format handler in the global Registry
 func init() {
 	convert.RegisterFormat("xlsx", convert.FormatHandler{
-		Name:     "xlsx",
-		ReaderFn: readXLSX,
-		WriterFn: writeXLSX,
+		Name:       "xlsx",
+		ReaderFn:   readXLSX,
+		WriterFn:   writeXLSX,
+		ToDataset:  xlsxToDataset,
+		Extensions: []string{".xlsx"},
+		Signatures: [][]byte{[]byte("PK\x03\x04")},
+		ReaderOptions: []convert.OptionSpec{
+			{Name: "sheet", Type: convert.OptionString, Description: "Sheet to convert (default: first sheet by name)"},
+		},
+		WriterOptions: []convert.OptionSpec{
+			{Name: "sheet", Type: convert.OptionString, Default: defaultSheetName, Description: "Name of the sheet to write"},
+		},
 	})
 }
 
+// defaultSheetName is used when a Dataset is written to a new workbook.
+const defaultSheetName = "Sheet1"
+
 // readXLSX reads an XLSX file from the given reader.
-// Returns a map of sheet names to [][]string representing rows and columns.
-func readXLSX(r io.Reader, resource string) (interface{}, error) {
+// Returns a map of sheet names to [][]interface{} representing rows and columns, with
+// cells typed as described at xlsxSheet.value; with the "sheet" option only that sheet is read.
+func readXLSX(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (interface{}, error) {
 	if r == nil {
 		return nil, fmt.Errorf("readXLSX requires a valid reader")
 	}
@@ -31,29 +51,210 @@ func readXLSX(r io.Reader, resource string) (interface{}, error) {
 	defer f.Close()
 
 	sheets := f.GetSheetList()
-	result := make(map[string][][]string)
+	if name := opts.String("sheet", ""); name != "" {
+		// Only read the requested sheet
+		found := false
+		for _, sheet := range sheets {
+			if sheet == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("sheet '%s' not found in '%s'", name, resource)
+		}
+		sheets = []string{name}
+	}
+	props, err := f.GetWorkbookProps()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workbook properties from '%s': %w", resource, err)
+	}
+	dates := make(map[int]bool)
+	result := make(map[string][][]interface{})
 
 	for _, sheet := range sheets {
-		rows, err := f.GetRows(sheet)
+		rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
 		if err != nil {
 			return nil, fmt.Errorf("failed to read rows from sheet '%s': %w", sheet, err)
 		}
-		result[sheet] = rows
+		x := xlsxSheet{file: f, name: sheet, dates: dates, date1904: props.Date1904 != nil && *props.Date1904}
+		cells := make([][]interface{}, len(rows))
+		for i, row := range rows {
+			cells[i] = make([]interface{}, len(row))
+			for j, raw := range row {
+				if cells[i][j], err = x.value(j+1, i+1, raw); err != nil {
+					return nil, fmt.Errorf("failed to read sheet '%s': %w", sheet, err)
+				}
+			}
+		}
+		result[sheet] = cells
 	}
 
 	return result, nil
 }
 
+// xlsxSheet reads typed cell values from a sheet, caching which styles format dates.
+type xlsxSheet struct {
+	file     *excelize.File
+	name     string
+	dates    map[int]bool
+	date1904 bool
+}
+
+/*
+value returns the typed value of the cell at col and row from its raw text.
+
+- Empty cells are nil and booleans are bool.
+- Numbers are int64, float64 or Decimal (see dataset.Normalize), unless their number format shows a date.
+- Numbers formatted as dates are a Date at midnight, otherwise a time.Time.
+- Strings, formula text and error values stay strings.
+*/
+func (x xlsxSheet) value(col, row int, raw string) (interface{}, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	cell, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil {
+		return nil, err
+	}
+	cellType, err := x.file.GetCellType(x.name, cell)
+	if err != nil {
+		return nil, fmt.Errorf("cell %s: %w", cell, err)
+	}
+
+	switch cellType {
+	case excelize.CellTypeBool:
+		return raw == "1" || strings.EqualFold(raw, "true"), nil
+	case excelize.CellTypeDate:
+		if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+			return t, nil
+		}
+		if d, err := dataset.ParseDate(raw); err == nil {
+			return d, nil
+		}
+		return raw, nil
+	case excelize.CellTypeNumber, excelize.CellTypeUnset:
+		number := dataset.Normalize(json.Number(raw))
+		serial, isNumber := number.(float64)
+		if i, ok := number.(int64); ok {
+			serial, isNumber = float64(i), true
+		}
+		if !isNumber {
+			return number, nil
+		}
+		date, err := x.isDate(cell)
+		if err != nil {
+			return nil, fmt.Errorf("cell %s: %w", cell, err)
+		}
+		if !date {
+			return number, nil
+		}
+		t, err := excelize.ExcelDateToTime(serial, x.date1904)
+		if err != nil {
+			return number, nil
+		}
+		if serial == math.Trunc(serial) {
+			return dataset.DateOf(t), nil
+		}
+		return t, nil
+	default:
+		return raw, nil
+	}
+}
+
+// xlsxDateFormats are the built-in number formats showing dates or times, including
+// the ones reserved for East Asian locales.
+var xlsxDateFormats = map[int]bool{
+	14: true, 15: true, 16: true, 17: true, 18: true, 19: true, 20: true, 21: true, 22: true,
+	27: true, 28: true, 29: true, 30: true, 31: true, 32: true, 33: true, 34: true, 35: true, 36: true,
+	45: true, 46: true, 47: true,
+	50: true, 51: true, 52: true, 53: true, 54: true, 55: true, 56: true, 57: true, 58: true,
+}
+
+// isDate reports whether the number format of a cell shows a date or time.
+func (x xlsxSheet) isDate(cell string) (bool, error) {
+	id, err := x.file.GetCellStyle(x.name, cell)
+	if err != nil {
+		return false, err
+	}
+	if date, ok := x.dates[id]; ok {
+		return date, nil
+	}
+	style, err := x.file.GetStyle(id)
+	if err != nil {
+		// Cells without a style use the General format
+		x.dates[id] = false
+		return false, nil
+	}
+	date := xlsxDateFormats[style.NumFmt]
+	if style.CustomNumFmt != nil {
+		date = isDateFormat(*style.CustomNumFmt)
+	}
+	x.dates[id] = date
+	return date, nil
+}
+
+// isDateFormat reports whether a custom number format code shows a date or time: it
+// holds a year, day, hour or second part outside quoted text, escapes and [...] sections
+// (elapsed times such as [h]:mm are durations, not dates).
+func isDateFormat(code string) bool {
+	inQuote, inBracket := false, false
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case inQuote:
+			inQuote = c != '"'
+		case inBracket:
+			inBracket = c != ']'
+		case c == '"':
+			inQuote = true
+		case c == '[':
+			if i+1 < len(code) && strings.ContainsRune("hHmMsS", rune(code[i+1])) {
+				return false
+			}
+			inBracket = true
+		case c == '\\' || c == '_' || c == '*':
+			// The next character is literal text or padding
+			i++
+		default:
+			switch c | 0x20 {
+			case 'y', 'd', 'h', 's':
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // writeXLSX writes data to an XLSX file to the given writer.
-// Expects data as map[string][][]string (sheet name -> rows).
-func writeXLSX(w io.Writer, resource string, data interface{}) error {
+// Expects data as map[string][][]string or map[string][][]interface{} (sheet name -> rows),
+// or a *dataset.Dataset, which is written to a single sheet with a header row.
+func writeXLSX(ctx context.Context, w io.Writer, resource string, data interface{}, opts convert.FormatOptions) error {
 	if w == nil {
 		return fmt.Errorf("writeXLSX requires a valid writer")
 	}
 
-	x, ok := data.(map[string][][]string)
-	if !ok {
-		return fmt.Errorf("invalid data type for XLSX writer, expected map[string][][]string")
+	sheets := make(map[string][][]interface{})
+	switch v := data.(type) {
+	case map[string][][]string:
+		for sheet, rows := range v {
+			sheets[sheet] = stringsToCells(rows)
+		}
+	case map[string][][]interface{}:
+		for sheet, rows := range v {
+			cells := make([][]interface{}, len(rows))
+			for i, row := range rows {
+				cells[i] = make([]interface{}, len(row))
+				for j, value := range row {
+					cells[i][j] = cellValue(value)
+				}
+			}
+			sheets[sheet] = cells
+		}
+	case *dataset.Dataset:
+		sheets[opts.String("sheet", defaultSheetName)] = datasetToCells(v)
+	default:
+		return fmt.Errorf("invalid data type for XLSX writer, expected map[string][][]string, map[string][][]interface{} or dataset")
 	}
 
 	f := excelize.NewFile()
@@ -64,7 +265,7 @@ func writeXLSX(w io.Writer, resource string, data interface{}) error {
 		_ = f.DeleteSheet(f.GetSheetList()[0])
 	}
 
-	for sheet, rows := range x {
+	for sheet, rows := range sheets {
 		index, err := f.NewSheet(sheet)
 		if err != nil {
 			return fmt.Errorf("failed to create sheet '%s': %w", sheet, err)
@@ -72,6 +273,9 @@ func writeXLSX(w io.Writer, resource string, data interface{}) error {
 
 		for rIdx, row := range rows {
 			for cIdx, cell := range row {
+				if cell == nil {
+					continue
+				}
 				cellName, _ := excelize.CoordinatesToCellName(cIdx+1, rIdx+1)
 				if err := f.SetCellValue(sheet, cellName, cell); err != nil {
 					return fmt.Errorf("failed to set cell value at %s: %w", cellName, err)
@@ -87,3 +291,94 @@ func writeXLSX(w io.Writer, resource string, data interface{}) error {
 	}
 	return nil
 }
+
+// xlsxToDataset adapts the sheet map produced by readXLSX into a Dataset.
+// The first sheet in name order is used, with its first row as the header.
+func xlsxToDataset(data interface{}) (*dataset.Dataset, error) {
+	sheets, ok := data.(map[string][][]interface{})
+	if text, isText := data.(map[string][][]string); isText {
+		sheets, ok = make(map[string][][]interface{}, len(text)), true
+		for name, rows := range text {
+			sheets[name] = stringsToCells(rows)
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("invalid XLSX data type %T, expected map[string][][]interface{}", data)
+	}
+	if len(sheets) == 0 {
+		return dataset.New([]string{}), nil
+	}
+
+	names := make([]string, 0, len(sheets))
+	for name := range sheets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rows := sheets[names[0]]
+	if len(rows) == 0 {
+		return dataset.New([]string{}), nil
+	}
+	columns := make([]string, len(rows[0]))
+	for i, name := range rows[0] {
+		columns[i] = dataset.FormatValue(name)
+	}
+	ds := dataset.New(columns)
+	for _, row := range rows[1:] {
+		rec := make(dataset.Record, len(columns))
+		copy(rec, row)
+		ds.Records = append(ds.Records, rec)
+	}
+	return ds, nil
+}
+
+// stringsToCells converts string rows into generic cell rows.
+func stringsToCells(rows [][]string) [][]interface{} {
+	cells := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		cells[i] = make([]interface{}, len(row))
+		for j, value := range row {
+			cells[i][j] = value
+		}
+	}
+	return cells
+}
+
+// datasetToCells converts a Dataset into cell rows with a leading header row.
+// Numbers, booleans and timestamps stay typed so they become native cells;
+// nested and binary values are rendered as text since cells cannot hold them.
+func datasetToCells(ds *dataset.Dataset) [][]interface{} {
+	cells := make([][]interface{}, 0, len(ds.Records)+1)
+
+	header := make([]interface{}, len(ds.Columns))
+	for i, col := range ds.Columns {
+		header[i] = col
+	}
+	cells = append(cells, header)
+
+	for _, rec := range ds.Records {
+		row := make([]interface{}, len(ds.Columns))
+		for i := range ds.Columns {
+			if i < len(rec) {
+				row[i] = cellValue(rec[i])
+			}
+		}
+		cells = append(cells, row)
+	}
+
+	return cells
+}
+
+// cellValue converts a dataset value into a value excelize writes as a native cell.
+func cellValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case dataset.Decimal:
+		return v.Float64()
+	case dataset.Date:
+		return v.Time()
+	case map[string]interface{}, []interface{}, []byte:
+		return dataset.FormatValue(v)
+	default:
+		return v
+	}
+}
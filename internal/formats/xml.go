@@ -1,22 +1,50 @@
 package formats
 
 import (
+	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
 
 	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+	"omnidata/internal/stream"
 )
 
 // init registers the XML format handler in the global Registry
 func init() {
 	convert.RegisterFormat("xml", convert.FormatHandler{
-		Name:     "xml",
-		ReaderFn: readXML,
-		WriterFn: writeXML,
+		Name:           "xml",
+		ReaderFn:       readXML,
+		WriterFn:       writeXML,
+		ToDataset:      xmlToDataset,
+		StreamReaderFn: streamReadXML,
+		DocumentFn:     readXMLDocument,
+		Nested:         true,
+		Extensions:     []string{".xml"},
+		Signatures:     [][]byte{[]byte("<?xml"), []byte("<")},
+		ReaderOptions: []convert.OptionSpec{
+			{Name: "record_path", Type: convert.OptionString, Description: "Path of the record elements, e.g. /orders/order (default: the children of the root element)"},
+			{Name: "convention", Type: convert.OptionString, Default: xmlSimple, Description: "How --document maps elements, attributes and text to JSON", Values: xmlConventions},
+		},
+		WriterOptions: []convert.OptionSpec{
+			{Name: "root", Type: convert.OptionString, Default: xmlRootElement, Description: "Name of the document root element"},
+			{Name: "row", Type: convert.OptionString, Default: xmlRecordElement, Description: "Name of the element wrapping each record"},
+			{Name: "record_path", Type: convert.OptionString, Description: "Path of the record elements, e.g. /orders/order, instead of root and row"},
+			{Name: "convention", Type: convert.OptionString, Default: xmlSimple, Description: "How --document maps JSON to elements, attributes and text", Values: xmlConventions},
+		},
 	})
 }
 
+// Element names used when writing a Dataset as XML.
+const (
+	xmlRootElement   = "records"
+	xmlRecordElement = "record"
+)
+
 // Node represents a generic XML element to allow round-tripping arbitrary XML
 type Node struct {
 	XMLName xml.Name
@@ -25,35 +53,483 @@ type Node struct {
 	Nodes   []Node     `xml:",any"`
 }
 
+/*
+xmlPath is a path of element names selecting record elements, such as /orders/order.
+
+- An absolute path starts at the document root element, a relative one at its children.
+- A * step matches any element; steps match the local name of elements.
+*/
+type xmlPath struct {
+	steps    []string
+	absolute bool
+}
+
+// parseXMLPath parses a record path.
+func parseXMLPath(path string) (xmlPath, error) {
+	path = strings.TrimSpace(path)
+	p := xmlPath{absolute: strings.HasPrefix(path, "/")}
+	for _, step := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		if i := strings.IndexByte(step, ':'); i >= 0 {
+			step = step[i+1:]
+		}
+		if step == "" {
+			return xmlPath{}, fmt.Errorf("invalid record path %q: expected element names separated by /", path)
+		}
+		p.steps = append(p.steps, step)
+	}
+	return p, nil
+}
+
+// match reports whether an element name matches the step of the path at index i.
+func (p xmlPath) match(i int, name string) bool {
+	return p.steps[i] == "*" || p.steps[i] == name
+}
+
+// matchesAt reports whether the open elements, the root first, are the elements the
+// path selects or ancestors of them. It is true for exactly the records when whole is set.
+func (p xmlPath) matchesAt(open []string, whole bool) bool {
+	if !p.absolute {
+		open = open[1:]
+	}
+	if len(open) > len(p.steps) || (whole && len(open) != len(p.steps)) {
+		return false
+	}
+	for i, name := range open {
+		if !p.match(i, name) {
+			return false
+		}
+	}
+	return true
+}
+
 // readXML reads XML data from the given reader.
-func readXML(r io.Reader, resource string) (interface{}, error) {
+// With a record_path, the selected elements are read as the records of a Dataset, one
+// at a time (see xmlReader), without holding the rest of the document.
+func readXML(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (interface{}, error) {
 	if r == nil {
 		return nil, fmt.Errorf("readXML requires a valid reader")
 	}
+	if opts.String("record_path", "") != "" {
+		rows, err := newXMLReader(r, opts)
+		if err != nil {
+			return nil, err
+		}
+		var objs []map[string]interface{}
+		for {
+			row, err := rows.ReadRow()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode XML from '%s': %w", resource, err)
+			}
+			objs = append(objs, row)
+		}
+		return dataset.FromMapsOrdered(rows.Columns(), objs), nil
+	}
 
 	// Try to decode as a generic Node to preserve structure
 	var node Node
 	if err := xml.NewDecoder(r).Decode(&node); err != nil {
 		return nil, fmt.Errorf("failed to decode XML from '%s': %w", resource, err)
 	}
-
 	return node, nil
 }
 
+// streamReadXML opens a record-at-a-time reader over the elements selected by record_path,
+// by default the children of the root element.
+func streamReadXML(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (stream.StreamingReader, error) {
+	if r == nil {
+		return nil, fmt.Errorf("streamReadXML requires a valid reader")
+	}
+	return newXMLReader(r, opts)
+}
+
+// xmlReader reads the record elements of a document from its tokens. Only the element
+// being read is decoded into a Node; elements off the record path are skipped.
+type xmlReader struct {
+	dec     *xml.Decoder
+	path    xmlPath
+	open    []string
+	rooted  bool
+	columns []string
+	seen    map[string]bool
+}
+
+// newXMLReader creates a reader for the elements selected by the record_path option.
+func newXMLReader(r io.Reader, opts convert.FormatOptions) (*xmlReader, error) {
+	path := xmlPath{steps: []string{"*"}}
+	if text := opts.String("record_path", ""); text != "" {
+		p, err := parseXMLPath(text)
+		if err != nil {
+			return nil, err
+		}
+		path = p
+	}
+	return &xmlReader{dec: xml.NewDecoder(r), path: path, seen: make(map[string]bool)}, nil
+}
+
+// ReadRow returns the next record element as a row.
+func (r *xmlReader) ReadRow() (map[string]interface{}, error) {
+	for {
+		tok, err := r.dec.Token()
+		if err == io.EOF {
+			if !r.rooted {
+				return nil, fmt.Errorf("no XML root element")
+			}
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			r.open = append(r.open, t.Name.Local)
+			r.rooted = true
+			switch {
+			case r.path.matchesAt(r.open, true):
+				var node Node
+				if err := r.dec.DecodeElement(&node, &t); err != nil {
+					return nil, err
+				}
+				r.open = r.open[:len(r.open)-1]
+				row, columns := xmlRecord(node)
+				for _, c := range columns {
+					if !r.seen[c] {
+						r.seen[c] = true
+						r.columns = append(r.columns, c)
+					}
+				}
+				return row, nil
+			case !r.path.matchesAt(r.open, false):
+				if err := r.dec.Skip(); err != nil {
+					return nil, err
+				}
+				r.open = r.open[:len(r.open)-1]
+			}
+		case xml.EndElement:
+			r.open = r.open[:len(r.open)-1]
+		}
+	}
+}
+
+// Columns returns the columns seen so far, in order of first appearance.
+func (r *xmlReader) Columns() []string {
+	return r.columns
+}
+
+// Close is a no-op; the caller owns the underlying reader.
+func (r *xmlReader) Close() error {
+	return nil
+}
+
 // writeXML writes data back to XML.
-func writeXML(w io.Writer, resource string, data interface{}) error {
+// A *dataset.Dataset is written as <records><record><column>value</column></record></records>,
+// and a document (objects and lists) in the convention option (see encodeXMLDocument).
+func writeXML(ctx context.Context, w io.Writer, resource string, data interface{}, opts convert.FormatOptions) error {
 	if w == nil {
 		return fmt.Errorf("writeXML requires a valid writer")
 	}
 
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	if ds, ok := data.(*dataset.Dataset); ok {
+		path := []string{xmlName(opts.String("root", xmlRootElement)), xmlName(opts.String("row", xmlRecordElement))}
+		if text := opts.String("record_path", ""); text != "" {
+			if _, ok := opts["root"]; ok {
+				return fmt.Errorf("give the XML record_path or root and row, not both")
+			}
+			if _, ok := opts["row"]; ok {
+				return fmt.Errorf("give the XML record_path or root and row, not both")
+			}
+			p, err := parseXMLPath(text)
+			if err != nil {
+				return err
+			}
+			if len(p.steps) < 2 {
+				return fmt.Errorf("invalid record path %q: expected a root element and a record element", text)
+			}
+			path = path[:0]
+			for _, step := range p.steps {
+				if step == "*" {
+					return fmt.Errorf("invalid record path %q: * cannot name the elements to write", text)
+				}
+				path = append(path, xmlName(step))
+			}
+		}
+		if err := encodeDatasetXML(w, enc, ds, path[:len(path)-1], path[len(path)-1]); err != nil {
+			return fmt.Errorf("failed to encode XML to '%s': %w", resource, err)
+		}
+		return nil
+	}
+
+	switch data.(type) {
+	case orderedObject, map[string]interface{}, []interface{}:
+		if err := encodeXMLDocument(w, enc, data, opts); err != nil {
+			return fmt.Errorf("failed to encode XML to '%s': %w", resource, err)
+		}
+		return nil
+	}
 	if _, ok := data.(Node); !ok {
-		return fmt.Errorf("data is not a valid XML Node")
+		return fmt.Errorf("data is not a valid XML Node or document")
 	}
 
-	enc := xml.NewEncoder(w)
-	enc.Indent("", "  ")
 	if err := enc.Encode(data); err != nil {
 		return fmt.Errorf("failed to encode XML to '%s': %w", resource, err)
 	}
 	return nil
 }
+
+// xmlToDataset adapts a decoded XML tree into a Dataset, with each child of the root
+// element as a record (see xmlRecords).
+func xmlToDataset(data interface{}) (*dataset.Dataset, error) {
+	root, ok := data.(Node)
+	if !ok {
+		return nil, fmt.Errorf("invalid XML data type %T, expected Node", data)
+	}
+	return xmlRecords(root.Nodes), nil
+}
+
+// xmlRecords maps record elements to the records of a Dataset.
+//
+// Within a record, attributes map to "@name" columns, leaf child elements map to columns
+// holding their text, and nested child elements map to nested objects. The text of a
+// record with attributes but no child elements maps to a "#text" column.
+func xmlRecords(nodes []Node) *dataset.Dataset {
+	objs := make([]map[string]interface{}, 0, len(nodes))
+	columns := make([]string, 0)
+	for _, child := range nodes {
+		obj, keys := xmlRecord(child)
+		objs = append(objs, obj)
+		columns = append(columns, keys...)
+	}
+	return dataset.FromMapsOrdered(columns, objs)
+}
+
+// xmlRecord maps a record element to a record and its columns in document order.
+func xmlRecord(n Node) (map[string]interface{}, []string) {
+	value := nodeValue(n)
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		// Leaf record: use the element name as the column
+		return map[string]interface{}{n.XMLName.Local: value}, []string{n.XMLName.Local}
+	}
+
+	// Keep document order: attributes first, then text or child elements
+	columns := make([]string, 0, len(n.Attrs)+len(n.Nodes))
+	for _, attr := range n.Attrs {
+		columns = append(columns, "@"+attr.Name.Local)
+	}
+	if _, ok := obj["#text"]; ok {
+		columns = append(columns, "#text")
+	}
+	for _, child := range n.Nodes {
+		columns = append(columns, child.XMLName.Local)
+	}
+	return obj, columns
+}
+
+// nodeValue converts a Node into a string (leaf without attributes) or an object.
+// Repeated child elements are collected into a list.
+func nodeValue(n Node) interface{} {
+	if len(n.Nodes) == 0 && len(n.Attrs) == 0 {
+		return nodeText(n)
+	}
+
+	obj := make(map[string]interface{})
+	for _, attr := range n.Attrs {
+		obj["@"+attr.Name.Local] = attr.Value
+	}
+	for _, child := range n.Nodes {
+		name := child.XMLName.Local
+		value := nodeValue(child)
+		if existing, ok := obj[name]; ok {
+			if list, ok := existing.([]interface{}); ok {
+				obj[name] = append(list, value)
+			} else {
+				obj[name] = []interface{}{existing, value}
+			}
+			continue
+		}
+		obj[name] = value
+	}
+	if len(n.Nodes) == 0 {
+		if text := nodeText(n); text != "" {
+			obj["#text"] = text
+		}
+	}
+	return obj
+}
+
+// nodeText returns the decoded character data directly inside a node.
+// Entities and CDATA sections are resolved; text inside child elements is ignored.
+func nodeText(n Node) string {
+	dec := xml.NewDecoder(bytes.NewReader(n.Content))
+	var sb strings.Builder
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 0 {
+				sb.Write(t)
+			}
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// encodeDatasetXML writes a Dataset as nested wrapper elements (the root first) containing
+// one row element per record. "@name" columns become attributes of the row element and a
+// "#text" column its text.
+func encodeDatasetXML(w io.Writer, enc *xml.Encoder, ds *dataset.Dataset, wrappers []string, rowName string) error {
+	for _, name := range wrappers {
+		if err := enc.EncodeToken(xml.StartElement{Name: xml.Name{Local: name}}); err != nil {
+			return err
+		}
+	}
+
+	for _, rec := range ds.Records {
+		start := xml.StartElement{Name: xml.Name{Local: rowName}}
+		children := make([]int, 0, len(ds.Columns))
+		for i, col := range ds.Columns {
+			if strings.HasPrefix(col, "@") {
+				if i < len(rec) && rec[i] != nil {
+					start.Attr = append(start.Attr, xml.Attr{
+						Name:  xml.Name{Local: xmlName(col[1:])},
+						Value: dataset.FormatValue(rec[i]),
+					})
+				}
+				continue
+			}
+			children = append(children, i)
+		}
+
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, i := range children {
+			var value interface{}
+			if i < len(rec) {
+				value = rec[i]
+			}
+			if ds.Columns[i] == "#text" {
+				if text := dataset.FormatValue(value); text != "" {
+					if err := enc.EncodeToken(xml.CharData(text)); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := encodeXMLValue(enc, xmlName(ds.Columns[i]), value); err != nil {
+				return err
+			}
+		}
+		if err := enc.EncodeToken(start.End()); err != nil {
+			return err
+		}
+	}
+
+	for i := len(wrappers) - 1; i >= 0; i-- {
+		if err := enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: wrappers[i]}}); err != nil {
+			return err
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// encodeXMLValue writes a single value as an element, recursing into nested values.
+func encodeXMLValue(enc *xml.Encoder, name string, value interface{}) error {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if err := encodeXMLValue(enc, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		start := xml.StartElement{Name: xml.Name{Local: name}}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			if strings.HasPrefix(k, "@") {
+				start.Attr = append(start.Attr, xml.Attr{
+					Name:  xml.Name{Local: xmlName(k[1:])},
+					Value: dataset.FormatValue(v[k]),
+				})
+				continue
+			}
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		sort.Slice(start.Attr, func(i, j int) bool { return start.Attr[i].Name.Local < start.Attr[j].Name.Local })
+
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if k == "#text" {
+				if err := enc.EncodeToken(xml.CharData(dataset.FormatValue(v[k]))); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := encodeXMLValue(enc, xmlName(k), v[k]); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	default:
+		start := xml.StartElement{Name: xml.Name{Local: name}}
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		if text := dataset.FormatValue(v); text != "" {
+			if err := enc.EncodeToken(xml.CharData(text)); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	}
+}
+
+// xmlName turns an arbitrary column name into a valid XML element name.
+func xmlName(name string) string {
+	if name == "" {
+		return "_"
+	}
+	var sb strings.Builder
+	for i, r := range name {
+		valid := r == '_' || r == '-' || r == '.' ||
+			(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r > 127
+		if i > 0 && r >= '0' && r <= '9' {
+			valid = true
+		}
+		if i == 0 && (r == '-' || r == '.') {
+			valid = false
+		}
+		if i == 0 && r >= '0' && r <= '9' {
+			sb.WriteRune('_')
+			valid = true
+		}
+		if valid {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,339 @@
+package formats
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+)
+
+/*
+Conventions mapping whole XML documents to and from JSON-like documents (--document).
+
+- simple: attributes as "@name" keys, text as "#text" (or the value itself for an element with neither attributes nor children).
+- badgerfish: every element is an object, attributes as "@name" keys, text as "$", namespace declarations under "@xmlns".
+- parker: elements only; attributes and namespace declarations are dropped and the root element is implied.
+
+Repeated elements become lists in all three. Element and attribute names keep their namespace prefix.
+*/
+const (
+	xmlSimple     = "simple"
+	xmlBadgerFish = "badgerfish"
+	xmlParker     = "parker"
+)
+
+// xmlConventions lists the accepted values of the convention option.
+var xmlConventions = []string{xmlSimple, xmlBadgerFish, xmlParker}
+
+// xmlNamespaceURL is the namespace bound to the reserved xml prefix (as in xml:lang).
+const xmlNamespaceURL = "http://www.w3.org/XML/1998/namespace"
+
+// readXMLDocument reads an XML document as a JSON-like document in the convention option.
+func readXMLDocument(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (interface{}, error) {
+	if r == nil {
+		return nil, fmt.Errorf("readXMLDocument requires a valid reader")
+	}
+
+	var node Node
+	if err := xml.NewDecoder(r).Decode(&node); err != nil {
+		return nil, fmt.Errorf("failed to decode XML from '%s': %w", resource, err)
+	}
+	convention := opts.String("convention", xmlSimple)
+	scope := xmlScope{prefixes: map[string]string{xmlNamespaceURL: "xml"}}
+	name, value := xmlDocumentValue(node, scope, convention)
+	if convention == xmlParker {
+		return value, nil
+	}
+	return orderedObject{keys: []string{name}, values: []interface{}{value}}, nil
+}
+
+// xmlScope maps namespace URLs back to the prefixes declared for them, since the
+// decoder resolves element and attribute prefixes to URLs.
+type xmlScope struct {
+	defaultSpace string
+	prefixes     map[string]string
+}
+
+// enter returns the scope inside an element, with the namespaces it declares.
+func (s xmlScope) enter(n Node) xmlScope {
+	next := s
+	copied := false
+	for _, attr := range n.Attrs {
+		if attr.Name.Space != "xmlns" && (attr.Name.Space != "" || attr.Name.Local != "xmlns") {
+			continue
+		}
+		if !copied {
+			next.prefixes = make(map[string]string, len(s.prefixes)+1)
+			for url, prefix := range s.prefixes {
+				next.prefixes[url] = prefix
+			}
+			copied = true
+		}
+		if attr.Name.Space == "xmlns" {
+			next.prefixes[attr.Value] = attr.Name.Local
+		} else {
+			next.defaultSpace = attr.Value
+		}
+	}
+	return next
+}
+
+// name returns the prefixed name of an element or attribute as written in the document.
+func (s xmlScope) name(name xml.Name, element bool) string {
+	switch {
+	case name.Space == "":
+		return name.Local
+	case name.Space == "xmlns":
+		return "xmlns:" + name.Local
+	case element && name.Space == s.defaultSpace:
+		return name.Local
+	}
+	if prefix, ok := s.prefixes[name.Space]; ok {
+		return prefix + ":" + name.Local
+	}
+	// The decoder keeps an undeclared prefix as it is
+	return name.Space + ":" + name.Local
+}
+
+// xmlDocumentValue converts an element into its name and value in the given convention.
+func xmlDocumentValue(n Node, scope xmlScope, convention string) (string, interface{}) {
+	scope = scope.enter(n)
+	name := scope.name(n.XMLName, true)
+	text := nodeText(n)
+
+	var obj xmlObject
+	switch convention {
+	case xmlParker:
+		if len(n.Nodes) == 0 {
+			if text == "" {
+				return name, nil
+			}
+			return name, text
+		}
+	case xmlBadgerFish:
+		var namespaces xmlObject
+		for _, attr := range n.Attrs {
+			switch {
+			case attr.Name.Space == "xmlns":
+				namespaces.add(attr.Name.Local, attr.Value)
+			case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+				namespaces.add("$", attr.Value)
+			}
+		}
+		if len(namespaces.keys) > 0 {
+			obj.add("@xmlns", namespaces.orderedObject)
+		}
+		for _, attr := range n.Attrs {
+			if attr.Name.Space != "xmlns" && (attr.Name.Space != "" || attr.Name.Local != "xmlns") {
+				obj.add("@"+scope.name(attr.Name, false), attr.Value)
+			}
+		}
+		if text != "" {
+			obj.add("$", text)
+		}
+	default:
+		if len(n.Nodes) == 0 && len(n.Attrs) == 0 {
+			return name, text
+		}
+		for _, attr := range n.Attrs {
+			obj.add("@"+scope.name(attr.Name, false), attr.Value)
+		}
+		if text != "" {
+			obj.add("#text", text)
+		}
+	}
+
+	for _, child := range n.Nodes {
+		obj.add(xmlDocumentValue(child, scope, convention))
+	}
+	return name, obj.orderedObject
+}
+
+// xmlObject builds an orderedObject from the parts of an element, collecting the
+// values of repeated names into a list at the position of the first one.
+type xmlObject struct {
+	orderedObject
+	index map[string]int
+}
+
+// add sets key to value, or appends value to the list of values of a repeated key.
+func (o *xmlObject) add(key string, value interface{}) {
+	if o.index == nil {
+		o.index = make(map[string]int)
+	}
+	i, ok := o.index[key]
+	if !ok {
+		o.index[key] = len(o.keys)
+		o.keys = append(o.keys, key)
+		o.values = append(o.values, value)
+		return
+	}
+	if list, ok := o.values[i].([]interface{}); ok {
+		o.values[i] = append(list, value)
+	} else {
+		o.values[i] = []interface{}{o.values[i], value}
+	}
+}
+
+/*
+encodeXMLDocument writes a JSON-like document as XML in the convention option.
+
+- An object with a single key (not a list) names the root element, unless the root option is given.
+- Anything else is wrapped in the root element; lists become row elements.
+- Parker documents are always wrapped, since the convention drops the root element.
+*/
+func encodeXMLDocument(w io.Writer, enc *xml.Encoder, doc interface{}, opts convert.FormatOptions) error {
+	convention := opts.String("convention", xmlSimple)
+	row := xmlQName(opts.String("row", xmlRecordElement))
+
+	name, value := xmlQName(opts.String("root", xmlRootElement)), doc
+	if _, named := opts["root"]; !named && convention != xmlParker {
+		if keys, values, ok := documentEntries(doc); ok && len(keys) == 1 {
+			if _, isList := values[0].([]interface{}); !isList {
+				name, value = xmlQName(keys[0]), values[0]
+			}
+		}
+	}
+
+	if err := encodeXMLElement(enc, convention, name, value, row); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// encodeXMLElement writes a document value as an element in the given convention.
+// A list directly inside a list is written as an element holding row elements.
+func encodeXMLElement(enc *xml.Encoder, convention, name string, value interface{}, row string) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if list, ok := value.([]interface{}); ok {
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, item := range list {
+			if err := encodeXMLElement(enc, convention, row, item, row); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	}
+
+	keys, values, ok := documentEntries(value)
+	if !ok {
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		if text := dataset.FormatValue(value); text != "" {
+			if err := enc.EncodeToken(xml.CharData(text)); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	}
+
+	textKey := "#text"
+	if convention == xmlBadgerFish {
+		textKey = "$"
+	}
+	var text []interface{}
+	var children []int
+	for i, key := range keys {
+		switch {
+		case convention == xmlParker:
+			children = append(children, i)
+		case key == textKey:
+			text = append(text, values[i])
+		case convention == xmlBadgerFish && key == "@xmlns":
+			start.Attr = append(start.Attr, badgerFishNamespaces(values[i])...)
+		case strings.HasPrefix(key, "@"):
+			if values[i] != nil {
+				start.Attr = append(start.Attr, xml.Attr{
+					Name:  xml.Name{Local: xmlQName(key[1:])},
+					Value: dataset.FormatValue(values[i]),
+				})
+			}
+		default:
+			children = append(children, i)
+		}
+	}
+
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, value := range text {
+		if s := dataset.FormatValue(value); s != "" {
+			if err := enc.EncodeToken(xml.CharData(s)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, i := range children {
+		childName := xmlQName(keys[i])
+		items, ok := values[i].([]interface{})
+		if !ok {
+			items = []interface{}{values[i]}
+		}
+		for _, item := range items {
+			if err := encodeXMLElement(enc, convention, childName, item, row); err != nil {
+				return err
+			}
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// badgerFishNamespaces returns the namespace declarations of a BadgerFish "@xmlns"
+// value: "$" declares the default namespace and every other key a prefix.
+func badgerFishNamespaces(value interface{}) []xml.Attr {
+	keys, values, ok := documentEntries(value)
+	if !ok {
+		return []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: dataset.FormatValue(value)}}
+	}
+	attrs := make([]xml.Attr, 0, len(keys))
+	for i, key := range keys {
+		name := "xmlns:" + xmlName(key)
+		if key == "$" {
+			name = "xmlns"
+		}
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: name}, Value: dataset.FormatValue(values[i])})
+	}
+	return attrs
+}
+
+// documentEntries returns the keys and values of a document object, in document
+// order for an orderedObject and sorted for a map.
+func documentEntries(value interface{}) ([]string, []interface{}, bool) {
+	switch v := value.(type) {
+	case orderedObject:
+		return v.keys, v.values, true
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		values := make([]interface{}, len(keys))
+		for i, k := range keys {
+			values[i] = v[k]
+		}
+		return keys, values, true
+	}
+	return nil, nil, false
+}
+
+// xmlQName turns a document key into a valid element or attribute name, keeping a
+// namespace prefix such as soap:Body.
+func xmlQName(name string) string {
+	if i := strings.IndexByte(name, ':'); i > 0 && i < len(name)-1 {
+		return xmlName(name[:i]) + ":" + xmlName(name[i+1:])
+	}
+	return xmlName(name)
+}
@@ -1,10 +1,12 @@
 package formats
 
 import (
+	"context"
 	"fmt"
 	"io"
 
 	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
 
 	"gopkg.in/yaml.v3"
 )
@@ -12,36 +14,174 @@ import (
 // init registers the YAML format handler in the global Registry
 func init() {
 	convert.RegisterFormat("yaml", convert.FormatHandler{
-		Name:     "yaml",
-		ReaderFn: readYAML,
-		WriterFn: writeYAML,
+		Name:       "yaml",
+		ReaderFn:   readYAMLDocument,
+		WriterFn:   writeYAML,
+		ToDataset:  treeToDataset,
+		DocumentFn: readYAMLDocument,
+		Nested:     true,
+		Extensions: []string{".yaml", ".yml"},
+		Signatures: [][]byte{[]byte("---")},
+		WriterOptions: []convert.OptionSpec{
+			{Name: "indent", Type: convert.OptionInt, Default: "4", Description: "Spaces per indentation level"},
+		},
 	})
 }
 
-// readYAML reads YAML data from the given reader.
-func readYAML(r io.Reader, resource string) (interface{}, error) {
+// readYAMLDocument reads a YAML document, keeping the key order of its mappings.
+func readYAMLDocument(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (interface{}, error) {
 	if r == nil {
-		return nil, fmt.Errorf("readYAML requires a valid reader")
+		return nil, fmt.Errorf("readYAMLDocument requires a valid reader")
 	}
 
-	// We'll decode into a generic interface{}.
-	// Depending on structure it could be map[string]interface{} or []interface{}
-	var data interface{}
-	decoder := yaml.NewDecoder(r)
-	if err := decoder.Decode(&data); err != nil {
+	var node yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&node); err != nil {
 		return nil, fmt.Errorf("failed to decode YAML from '%s': %w", resource, err)
 	}
+	doc, err := yamlDocument(&node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode YAML from '%s': %w", resource, err)
+	}
+	return doc, nil
+}
+
+// yamlDocument converts a YAML node into a document, mappings as orderedObject values.
+func yamlDocument(n *yaml.Node) (interface{}, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return yamlDocument(n.Content[0])
+	case yaml.AliasNode:
+		return yamlDocument(n.Alias)
+	case yaml.SequenceNode:
+		list := make([]interface{}, 0, len(n.Content))
+		for _, item := range n.Content {
+			value, err := yamlDocument(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	case yaml.MappingNode:
+		var obj orderedObject
+		index := make(map[string]int)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].ShortTag() == "!!merge" {
+				if err := yamlMerge(&obj, index, n.Content[i+1]); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			value, err := yamlDocument(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			key := n.Content[i].Value
+			if j, ok := index[key]; ok {
+				obj.values[j] = value
+				continue
+			}
+			index[key] = len(obj.keys)
+			obj.keys = append(obj.keys, key)
+			obj.values = append(obj.values, value)
+		}
+		return obj, nil
+	}
+
+	var value interface{}
+	if err := n.Decode(&value); err != nil {
+		return nil, err
+	}
+	return dataset.Normalize(value), nil
+}
+
+// yamlMerge adds the keys of a merge key (<<) value that obj does not have yet. The
+// value is a mapping or a list of mappings, earlier ones taking precedence.
+func yamlMerge(obj *orderedObject, index map[string]int, n *yaml.Node) error {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind == yaml.SequenceNode {
+		for _, item := range n.Content {
+			if err := yamlMerge(obj, index, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	doc, err := yamlDocument(n)
+	if err != nil {
+		return err
+	}
+	src, ok := doc.(orderedObject)
+	if !ok {
+		return fmt.Errorf("line %d: merge key needs a mapping", n.Line)
+	}
+	for i, key := range src.keys {
+		if _, ok := index[key]; !ok {
+			index[key] = len(obj.keys)
+			obj.keys = append(obj.keys, key)
+			obj.values = append(obj.values, src.values[i])
+		}
+	}
+	return nil
+}
+
+// MarshalYAML writes the object keys in their original order.
+func (o orderedObject) MarshalYAML() (interface{}, error) {
+	return yamlDocumentNode(o)
+}
 
-	return data, nil
+// yamlDocumentNode encodes a document, keeping the key order of orderedObject values.
+func yamlDocumentNode(value interface{}) (*yaml.Node, error) {
+	switch v := value.(type) {
+	case orderedObject:
+		mapping := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for i, key := range v.keys {
+			valueNode, err := yamlDocumentNode(v.values[i])
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode key '%s': %w", key, err)
+			}
+			mapping.Content = append(mapping.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+				valueNode)
+		}
+		return mapping, nil
+	case []interface{}:
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			itemNode, err := yamlDocumentNode(item)
+			if err != nil {
+				return nil, err
+			}
+			seq.Content = append(seq.Content, itemNode)
+		}
+		return seq, nil
+	}
+	return yamlValueNode(value)
 }
 
 // writeYAML writes data as YAML to the given writer.
-func writeYAML(w io.Writer, resource string, data interface{}) error {
+// A *dataset.Dataset is written as a sequence of mappings with keys in column order.
+func writeYAML(ctx context.Context, w io.Writer, resource string, data interface{}, opts convert.FormatOptions) error {
 	if w == nil {
 		return fmt.Errorf("writeYAML requires a valid writer")
 	}
 
+	if ds, ok := data.(*dataset.Dataset); ok {
+		node, err := datasetToYAMLNode(ds)
+		if err != nil {
+			return fmt.Errorf("failed to build YAML document for '%s': %w", resource, err)
+		}
+		data = node
+	}
+
 	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(opts.Int("indent", 4))
 	// encoder.Close() is important for flushing any buffered data,
 	// though for YAML it mostly closes the stream structure.
 	defer encoder.Close()
@@ -52,3 +192,43 @@ func writeYAML(w io.Writer, resource string, data interface{}) error {
 
 	return nil
 }
+
+// datasetToYAMLNode builds a YAML sequence of mappings that preserves column order.
+func datasetToYAMLNode(ds *dataset.Dataset) (*yaml.Node, error) {
+	seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for _, rec := range ds.Records {
+		mapping := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for i, col := range ds.Columns {
+			var value interface{}
+			if i < len(rec) {
+				value = rec[i]
+			}
+			valueNode, err := yamlValueNode(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode column '%s': %w", col, err)
+			}
+			mapping.Content = append(mapping.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: col},
+				valueNode)
+		}
+		seq.Content = append(seq.Content, mapping)
+	}
+	return seq, nil
+}
+
+// yamlValueNode encodes a single cell value, keeping decimals as plain numbers
+// and dates as ISO 8601 strings.
+func yamlValueNode(value interface{}) (*yaml.Node, error) {
+	switch v := value.(type) {
+	case dataset.Decimal:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: v.String()}, nil
+	case dataset.Date:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v.String()}, nil
+	}
+
+	node := &yaml.Node{}
+	if err := node.Encode(value); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
@@ -1,8 +1,10 @@
 package inspect
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"sort"
 
 	"omnidata/internal/convert"
 )
@@ -13,6 +15,88 @@ type DiffOptions struct {
 	File2   string
 	Format1 string
 	Format2 string
+
+	// ReaderOptions are passed to each reader that accepts them (--in-opt key=value)
+	ReaderOptions convert.FormatOptions
+	// ReaderOptions1 and ReaderOptions2 are passed to the reader of one file (--in-opt1, --in-opt2)
+	ReaderOptions1 convert.FormatOptions
+	ReaderOptions2 convert.FormatOptions
+	// HeaderOptions are passed to CSV and TSV readers only (--no-header, --columns, --skip-rows, --header-row)
+	HeaderOptions convert.FormatOptions
+}
+
+// headerFormats are the formats HeaderOptions apply to.
+var headerFormats = map[string]bool{"csv": true, "tsv": true}
+
+/*
+FileReaderOptions returns the reader options of each file for the given handlers.
+
+- A shared option goes to every reader declaring it, so a CSV delimiter does not reach a JSON reader.
+- A shared option no reader declares is an error, as are invalid values.
+- The options of a single file are validated against its reader and win over shared ones.
+- Header options go to CSV and TSV files and win over the others; they are an error when neither file is one.
+*/
+func (o DiffOptions) FileReaderOptions(handler1, handler2 convert.FormatHandler) (convert.FormatOptions, convert.FormatOptions, error) {
+	keys := make([]string, 0, len(o.ReaderOptions))
+	for key := range o.ReaderOptions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	opts1, opts2 := convert.FormatOptions{}, convert.FormatOptions{}
+	for _, key := range keys {
+		value := o.ReaderOptions[key]
+		declared1, declared2 := declaresReaderOption(handler1, key), declaresReaderOption(handler2, key)
+		if !declared1 && !declared2 {
+			// Let the first reader report the option with the ones it accepts
+			if err := handler1.ValidateReaderOptions(convert.FormatOptions{key: value}); err != nil {
+				return nil, nil, fmt.Errorf("invalid input options for both files: %w", err)
+			}
+		}
+		if declared1 {
+			opts1[key] = value
+		}
+		if declared2 {
+			opts2[key] = value
+		}
+	}
+	for key, value := range o.ReaderOptions1 {
+		opts1[key] = value
+	}
+	for key, value := range o.ReaderOptions2 {
+		opts2[key] = value
+	}
+	if len(o.HeaderOptions) > 0 {
+		if !headerFormats[handler1.Name] && !headerFormats[handler2.Name] {
+			return nil, nil, fmt.Errorf("header options (--no-header, --columns, --skip-rows, --header-row) apply to CSV and TSV files, and neither file is one (%s, %s)", handler1.Name, handler2.Name)
+		}
+		for key, value := range o.HeaderOptions {
+			if headerFormats[handler1.Name] {
+				opts1[key] = value
+			}
+			if headerFormats[handler2.Name] {
+				opts2[key] = value
+			}
+		}
+	}
+
+	if err := handler1.ValidateReaderOptions(opts1); err != nil {
+		return nil, nil, fmt.Errorf("invalid input options for file1: %w", err)
+	}
+	if err := handler2.ValidateReaderOptions(opts2); err != nil {
+		return nil, nil, fmt.Errorf("invalid input options for file2: %w", err)
+	}
+	return opts1, opts2, nil
+}
+
+// declaresReaderOption reports whether the reader of handler accepts the option name.
+func declaresReaderOption(handler convert.FormatHandler, name string) bool {
+	for _, spec := range handler.ReaderOptions {
+		if spec.Name == name {
+			return true
+		}
+	}
+	return false
 }
 
 // SchemaDiff represents differences between two schemas
@@ -32,8 +116,8 @@ type ColumnChange struct {
 	NewNullable bool
 }
 
-// RunDiff compares two data files and shows schema differences
-func RunDiff(opts DiffOptions) error {
+// RunDiff compares two data files and shows schema differences; cancelling ctx stops reading them
+func RunDiff(ctx context.Context, opts DiffOptions) error {
 	// Get format handlers
 	handler1, ok := convert.GetFormat(opts.Format1)
 	if !ok {
@@ -44,6 +128,10 @@ func RunDiff(opts DiffOptions) error {
 	if !ok {
 		return fmt.Errorf("unsupported format: %s", opts.Format2)
 	}
+	readerOpts1, readerOpts2, err := opts.FileReaderOptions(handler1, handler2)
+	if err != nil {
+		return err
+	}
 
 	// Resolve input paths
 	path1 := opts.File1
@@ -63,25 +151,25 @@ func RunDiff(opts DiffOptions) error {
 	}
 
 	// Open and read file1
-	f1, err := os.Open(path1)
+	f1, err := convert.OpenInput(path1)
 	if err != nil {
 		return fmt.Errorf("failed to open file1: %w", err)
 	}
 	defer f1.Close()
 
-	data1, err := handler1.ReaderFn(f1, path1)
+	data1, err := handler1.ReaderFn(ctx, convert.ContextReader(ctx, f1), path1, readerOpts1)
 	if err != nil {
 		return fmt.Errorf("failed to read file1: %w", err)
 	}
 
 	// Open and read file2
-	f2, err := os.Open(path2)
+	f2, err := convert.OpenInput(path2)
 	if err != nil {
 		return fmt.Errorf("failed to open file2: %w", err)
 	}
 	defer f2.Close()
 
-	data2, err := handler2.ReaderFn(f2, path2)
+	data2, err := handler2.ReaderFn(ctx, convert.ContextReader(ctx, f2), path2, readerOpts2)
 	if err != nil {
 		return fmt.Errorf("failed to read file2: %w", err)
 	}
@@ -126,8 +214,9 @@ func CompareSchemas(schema1, schema2 *Schema) *SchemaDiff {
 		cols2[col.Name] = col
 	}
 
-	// Find added, removed, changed, and same columns
-	for name, col1 := range cols1 {
+	// Find added, removed, changed, and same columns, in schema order
+	for _, col1 := range schema1.Columns {
+		name := col1.Name
 		if col2, exists := cols2[name]; exists {
 			// Column exists in both schemas
 			if col1.Type != col2.Type || col1.Nullable != col2.Nullable {
@@ -148,8 +237,8 @@ func CompareSchemas(schema1, schema2 *Schema) *SchemaDiff {
 	}
 
 	// Find added columns
-	for name, col2 := range cols2 {
-		if _, exists := cols1[name]; !exists {
+	for _, col2 := range schema2.Columns {
+		if _, exists := cols1[col2.Name]; !exists {
 			diff.AddedColumns = append(diff.AddedColumns, col2)
 		}
 	}
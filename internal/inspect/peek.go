@@ -1,6 +1,7 @@
 package inspect
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"strings"
 
 	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
 )
 
 // PeekOptions holds configuration for the peek command
@@ -17,6 +19,9 @@ type PeekOptions struct {
 	Format    string
 	Rows      int
 	ShowStats bool
+
+	// ReaderOptions are passed to the format reader (--in-opt key=value)
+	ReaderOptions convert.FormatOptions
 }
 
 // PeekResult holds the result of peeking at data
@@ -25,14 +30,8 @@ type PeekResult struct {
 	Preview []map[string]string // First N rows as key-value pairs
 }
 
-// RunPeek executes the peek command
-func RunPeek(opts PeekOptions) error {
-	// Get format handler
-	handler, ok := convert.GetFormat(opts.Format)
-	if !ok {
-		return fmt.Errorf("unsupported format: %s", opts.Format)
-	}
-
+// RunPeek executes the peek command; cancelling ctx stops reading the input
+func RunPeek(ctx context.Context, opts PeekOptions) error {
 	// Resolve input path
 	inputPath := opts.InputFile
 	if inputPath == "-" {
@@ -43,38 +42,98 @@ func RunPeek(opts PeekOptions) error {
 		}
 	}
 
-	// Open input
-	var r io.Reader
-	if inputPath == "" {
-		r = os.Stdin
-	} else {
-		f, err := os.Open(inputPath)
+	// Open input (STDIN or file, Gzip decompressed transparently)
+	r, err := convert.OpenInput(inputPath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	result, err := Peek(ctx, r, inputPath, opts)
+	if err != nil {
+		return err
+	}
+
+	// Display results
+	displayPeek(result.Schema, result.Preview, opts)
+
+	return nil
+}
+
+// Peek reads r in opts.Format and returns its schema and first opts.Rows rows.
+// The resource names the input for the format reader (e.g. a file path).
+// Reading fails with ctx.Err() once ctx is done.
+func Peek(ctx context.Context, r io.Reader, resource string, opts PeekOptions) (*PeekResult, error) {
+	// Get format handler
+	handler, ok := convert.GetFormat(opts.Format)
+	if !ok {
+		return nil, fmt.Errorf("unsupported format: %s", opts.Format)
+	}
+	if err := handler.ValidateReaderOptions(opts.ReaderOptions); err != nil {
+		return nil, fmt.Errorf("invalid input options: %w", err)
+	}
+
+	// Formats keeping metadata are described from it, decoding only the preview rows
+	if handler.SummaryFn != nil {
+		summary, err := handler.SummaryFn(ctx, convert.ContextReader(ctx, r), resource, opts.ReaderOptions, opts.Rows)
 		if err != nil {
-			return fmt.Errorf("failed to open input file: %w", err)
+			return nil, fmt.Errorf("failed to read input: %w", err)
 		}
-		defer f.Close()
-		r = f
+		return summaryResult(summary, opts.Format), nil
 	}
 
 	// Read data
-	data, err := handler.ReaderFn(r, inputPath)
+	data, err := handler.ReaderFn(ctx, convert.ContextReader(ctx, r), resource, opts.ReaderOptions)
 	if err != nil {
-		return fmt.Errorf("failed to read input: %w", err)
+		return nil, fmt.Errorf("failed to read input: %w", err)
 	}
 
 	// Infer schema
 	schema, err := InferSchema(data, opts.Format)
 	if err != nil {
-		return fmt.Errorf("failed to infer schema: %w", err)
+		return nil, fmt.Errorf("failed to infer schema: %w", err)
 	}
 
-	// Get preview rows
-	preview := getPreview(data, opts.Format, opts.Rows)
+	return &PeekResult{
+		Schema:  schema,
+		Preview: getPreview(data, opts.Format, opts.Rows),
+	}, nil
+}
+
+// summaryResult builds a peek result from a file summary. Sample values come from
+// the preview rows, and lengths are left unknown.
+func summaryResult(summary *convert.Summary, format string) *PeekResult {
+	schema := &Schema{Format: format, RowCount: int(summary.RowCount), ColumnCount: len(summary.Columns)}
+	preview := make([]map[string]string, 0)
+	for i := 0; summary.Preview != nil && i < summary.Preview.Len(); i++ {
+		row := make(map[string]string)
+		for k, v := range summary.Preview.Map(i) {
+			row[k] = dataset.FormatValue(v)
+		}
+		preview = append(preview, row)
+	}
 
-	// Display results
-	displayPeek(schema, preview, opts)
+	for _, column := range summary.Columns {
+		info := ColumnInfo{
+			Name:         column.Name,
+			Type:         column.Type,
+			Nullable:     column.Nullable,
+			MinLength:    -1,
+			MaxLength:    -1,
+			SampleValues: make([]string, 0, 5),
+		}
+		for _, row := range preview {
+			if value := row[column.Name]; value != "" && len(info.SampleValues) < 5 {
+				info.SampleValues = append(info.SampleValues, value)
+			}
+		}
+		if column.HasStats {
+			info.Stats = &ColumnStats{NullCount: column.NullCount, Min: column.Min, Max: column.Max}
+		}
+		schema.Columns = append(schema.Columns, info)
+	}
 
-	return nil
+	return &PeekResult{Schema: schema, Preview: preview}
 }
 
 func getPreview(data interface{}, format string, maxRows int) []map[string]string {
@@ -84,7 +143,7 @@ func getPreview(data interface{}, format string, maxRows int) []map[string]strin
 	case "csv":
 		if records, ok := data.([][]string); ok && len(records) > 0 {
 			headers := records[0]
-			for i := 1; i < len(records) && i <= maxRows+1; i++ {
+			for i := 1; i < len(records) && i <= maxRows; i++ {
 				row := make(map[string]string)
 				for j, header := range headers {
 					if j < len(records[i]) {
@@ -96,44 +155,23 @@ func getPreview(data interface{}, format string, maxRows int) []map[string]strin
 				preview = append(preview, row)
 			}
 		}
-	case "json":
-		if arr, ok := data.([]interface{}); ok {
-			for i := 0; i < len(arr) && i < maxRows; i++ {
-				if obj, ok := arr[i].(map[string]interface{}); ok {
-					row := make(map[string]string)
-					for k, v := range obj {
-						row[k] = fmt.Sprintf("%v", v)
-					}
-					preview = append(preview, row)
-				}
-			}
-		} else if obj, ok := data.(map[string]interface{}); ok {
+	default:
+		// Other registered formats are previewed through their canonical dataset
+		handler, ok := convert.GetFormat(format)
+		if !ok {
+			break
+		}
+		ds, err := handler.AsDataset(data)
+		if err != nil {
+			break
+		}
+		for i := 0; i < len(ds.Records) && i < maxRows; i++ {
 			row := make(map[string]string)
-			for k, v := range obj {
-				row[k] = fmt.Sprintf("%v", v)
+			for k, v := range ds.Map(i) {
+				row[k] = dataset.FormatValue(v)
 			}
 			preview = append(preview, row)
 		}
-	case "xlsx":
-		if sheets, ok := data.(map[string][][]string); ok {
-			for _, rows := range sheets {
-				if len(rows) > 0 {
-					headers := rows[0]
-					for i := 1; i < len(rows) && i <= maxRows+1; i++ {
-						row := make(map[string]string)
-						for j, header := range headers {
-							if j < len(rows[i]) {
-								row[header] = rows[i][j]
-							} else {
-								row[header] = ""
-							}
-						}
-						preview = append(preview, row)
-					}
-				}
-				break // Only show first sheet
-			}
-		}
 	}
 
 	return preview
@@ -160,6 +198,12 @@ func displayPeek(schema *Schema, preview []map[string]string, opts PeekOptions)
 			if len(col.SampleValues) > 0 {
 				fmt.Printf("    Samples:    %s\n", strings.Join(col.SampleValues, ", "))
 			}
+			if col.Stats != nil {
+				fmt.Printf("    Nulls:      %d\n", col.Stats.NullCount)
+				if col.Stats.Min != "" || col.Stats.Max != "" {
+					fmt.Printf("    Range:      %s - %s\n", col.Stats.Min, col.Stats.Max)
+				}
+			}
 			fmt.Printf("\n")
 		}
 	} else {
@@ -1,10 +1,14 @@
 package inspect
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
-	"strconv"
 	"strings"
+	"time"
+
+	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
 )
 
 // ColumnInfo holds information about a single column
@@ -15,6 +19,15 @@ type ColumnInfo struct {
 	MinLength    int
 	MaxLength    int
 	SampleValues []string
+	Stats        *ColumnStats // nil unless the file keeps column statistics
+}
+
+// ColumnStats holds the statistics a file keeps for a column (e.g. Parquet footers);
+// Min and Max are empty when it keeps no bounds
+type ColumnStats struct {
+	NullCount int64
+	Min       string
+	Max       string
 }
 
 // Schema represents the structure of a dataset
@@ -30,17 +43,128 @@ func InferSchema(data interface{}, format string) (*Schema, error) {
 	switch format {
 	case "csv":
 		return inferCSVSchema(data)
-	case "json":
-		return inferJSONSchema(data)
-	case "xml":
-		return inferXMLSchema(data)
-	case "xlsx":
-		return inferXLSXSchema(data)
 	default:
-		return nil, fmt.Errorf("unsupported format for schema inference: %s", format)
+		// Any other registered format is inspected through its canonical dataset
+		handler, ok := convert.GetFormat(format)
+		if !ok {
+			return nil, fmt.Errorf("unsupported format for schema inference: %s", format)
+		}
+		ds, err := handler.AsDataset(data)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported format for schema inference: %s: %w", format, err)
+		}
+		return InferDatasetSchema(ds, format), nil
 	}
 }
 
+// InferDatasetSchema analyzes a canonical dataset and returns schema information.
+// String values are typed with the same heuristics as CSV; other values by their Go type.
+func InferDatasetSchema(ds *dataset.Dataset, format string) *Schema {
+	columns := make([]ColumnInfo, len(ds.Columns))
+	for i, name := range ds.Columns {
+		columns[i] = ColumnInfo{
+			Name:         name,
+			Type:         "",
+			Nullable:     false,
+			MinLength:    -1,
+			MaxLength:    -1,
+			SampleValues: make([]string, 0, 5),
+		}
+	}
+
+	for _, rec := range ds.Records {
+		for colIdx := range columns {
+			var value interface{}
+			if colIdx < len(rec) {
+				value = rec[colIdx]
+			}
+			col := &columns[colIdx]
+
+			text := dataset.FormatValue(value)
+			if value == nil || text == "" {
+				col.Nullable = true
+				continue
+			}
+
+			length := len(text)
+			if col.MinLength == -1 || length < col.MinLength {
+				col.MinLength = length
+			}
+			if length > col.MaxLength {
+				col.MaxLength = length
+			}
+
+			valueType := inferValueType(value)
+			if col.Type == "" {
+				col.Type = valueType
+			} else if col.Type != valueType {
+				col.Type = "string"
+			}
+
+			if len(col.SampleValues) < 5 {
+				col.SampleValues = append(col.SampleValues, text)
+			}
+		}
+	}
+
+	for i := range columns {
+		if columns[i].Type == "" {
+			columns[i].Type = "null"
+		}
+	}
+
+	return &Schema{
+		Format:      format,
+		RowCount:    len(ds.Records),
+		ColumnCount: len(columns),
+		Columns:     columns,
+	}
+}
+
+// inferValueType returns the schema type name of a single non-null value.
+func inferValueType(value interface{}) string {
+	text, ok := value.(string)
+	if !ok {
+		return inferJSONType(value)
+	}
+	if isNumberText(text) {
+		return "number"
+	}
+	if strings.ToLower(text) == "true" || strings.ToLower(text) == "false" {
+		return "boolean"
+	}
+	return "string"
+}
+
+// isNumberText reports whether text is a plain decimal number that reads back as the
+// same text once stored as one: no sign but a leading minus, no leading zeros (zip codes
+// such as 01234), no exponent and no NaN or Inf.
+func isNumberText(text string) bool {
+	digits := strings.TrimPrefix(text, "-")
+	whole, fraction, hasPoint := strings.Cut(digits, ".")
+	if !allDigits(whole) || hasPoint && !allDigits(fraction) {
+		return false
+	}
+	if len(whole) > 1 && whole[0] == '0' {
+		return false
+	}
+	// -0 would come back as 0
+	return digits == text || strings.Trim(whole+fraction, "0") != ""
+}
+
+// allDigits reports whether s is a non-empty run of ASCII digits.
+func allDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 func inferCSVSchema(data interface{}) (*Schema, error) {
 	records, ok := data.([][]string)
 	if !ok {
@@ -97,7 +221,7 @@ func inferCSVSchema(data interface{}) (*Schema, error) {
 			// Try to infer type
 			if columns[colIdx].Type == "string" {
 				if value != "" {
-					if _, err := strconv.ParseFloat(value, 64); err == nil {
+					if isNumberText(value) {
 						columns[colIdx].Type = "number"
 					} else if strings.ToLower(value) == "true" || strings.ToLower(value) == "false" {
 						columns[colIdx].Type = "boolean"
@@ -120,72 +244,23 @@ func inferCSVSchema(data interface{}) (*Schema, error) {
 	}, nil
 }
 
-func inferJSONSchema(data interface{}) (*Schema, error) {
-	schema := &Schema{Format: "json"}
-
-	switch v := data.(type) {
-	case []interface{}:
-		// Array of objects
-		if len(v) == 0 {
-			return schema, nil
-		}
-
-		// Analyze first object to get structure
-		if firstObj, ok := v[0].(map[string]interface{}); ok {
-			columns := make([]ColumnInfo, 0)
-			for key, val := range firstObj {
-				col := ColumnInfo{
-					Name:         key,
-					Type:         inferJSONType(val),
-					Nullable:     val == nil,
-					SampleValues: make([]string, 0, 5),
-				}
-				columns = append(columns, col)
-			}
-
-			schema.RowCount = len(v)
-			schema.ColumnCount = len(columns)
-			schema.Columns = columns
-		} else {
-			// Array of primitives
-			schema.RowCount = len(v)
-			schema.ColumnCount = 1
-			schema.Columns = []ColumnInfo{
-				{
-					Name:         "value",
-					Type:         inferJSONType(v[0]),
-					Nullable:     false,
-					SampleValues: make([]string, 0, 5),
-				},
-			}
-		}
-	case map[string]interface{}:
-		// Single object
-		columns := make([]ColumnInfo, 0)
-		for key, val := range v {
-			col := ColumnInfo{
-				Name:         key,
-				Type:         inferJSONType(val),
-				Nullable:     val == nil,
-				SampleValues: make([]string, 0, 5),
-			}
-			columns = append(columns, col)
-		}
-		schema.RowCount = 1
-		schema.ColumnCount = len(columns)
-		schema.Columns = columns
-	default:
-		return nil, fmt.Errorf("unsupported JSON structure")
-	}
-
-	return schema, nil
-}
-
 func inferJSONType(val interface{}) string {
 	if val == nil {
 		return "null"
 	}
 
+	// Typed cells that do not map directly onto a reflect kind
+	switch val.(type) {
+	case dataset.Decimal, json.Number:
+		return "number"
+	case dataset.Date:
+		return "date"
+	case time.Time:
+		return "timestamp"
+	case []byte:
+		return "bytes"
+	}
+
 	switch reflect.TypeOf(val).Kind() {
 	case reflect.String:
 		return "string"
@@ -203,86 +278,3 @@ func inferJSONType(val interface{}) string {
 		return "unknown"
 	}
 }
-
-func inferXMLSchema(data interface{}) (*Schema, error) {
-	// XML schema inference is similar to JSON
-	return inferJSONSchema(data)
-}
-
-func inferXLSXSchema(data interface{}) (*Schema, error) {
-	sheets, ok := data.(map[string][][]string)
-	if !ok {
-		return nil, fmt.Errorf("invalid XLSX data type")
-	}
-
-	if len(sheets) == 0 {
-		return &Schema{Format: "xlsx", RowCount: 0, ColumnCount: 0}, nil
-	}
-
-	// Use first sheet for schema
-	var firstSheet string
-	var firstData [][]string
-	for sheet, rows := range sheets {
-		firstSheet = sheet
-		firstData = rows
-		break
-	}
-
-	if len(firstData) == 0 {
-		return &Schema{Format: "xlsx", RowCount: 0, ColumnCount: 0}, nil
-	}
-
-	// Treat XLSX similar to CSV
-	records := firstData
-	headers := records[0]
-	columnCount := len(headers)
-	rowCount := len(records) - 1
-
-	columns := make([]ColumnInfo, columnCount)
-	for i, header := range headers {
-		columns[i] = ColumnInfo{
-			Name:         fmt.Sprintf("%s.%s", firstSheet, header),
-			Type:         "string",
-			Nullable:     false,
-			MinLength:    -1,
-			MaxLength:    -1,
-			SampleValues: make([]string, 0, 5),
-		}
-	}
-
-	// Analyze data rows
-	maxRows := 1000
-	if rowCount > maxRows {
-		maxRows = rowCount
-	}
-
-	for rowIdx := 1; rowIdx <= maxRows && rowIdx < len(records); rowIdx++ {
-		row := records[rowIdx]
-		for colIdx := 0; colIdx < columnCount && colIdx < len(row); colIdx++ {
-			value := row[colIdx]
-
-			if value == "" {
-				columns[colIdx].Nullable = true
-			}
-
-			length := len(value)
-			if columns[colIdx].MinLength == -1 || length < columns[colIdx].MinLength {
-				columns[colIdx].MinLength = length
-			}
-			if length > columns[colIdx].MaxLength {
-				columns[colIdx].MaxLength = length
-			}
-
-			if len(columns[colIdx].SampleValues) < 5 && value != "" {
-				columns[colIdx].SampleValues = append(columns[colIdx].SampleValues, value)
-			}
-		}
-	}
-
-	return &Schema{
-		Format:      "xlsx",
-		RowCount:    rowCount,
-		ColumnCount: columnCount,
-		Columns:     columns,
-	}, nil
-}
@@ -0,0 +1,145 @@
+package inspect
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"omnidata/internal/convert"
+	"omnidata/internal/stream"
+)
+
+// SniffResult describes the dialect detected for a delimited input, together with
+// the reader options that pin it for later runs.
+type SniffResult struct {
+	Delimiter  string   `json:"delimiter"`
+	Quote      string   `json:"quote"`
+	Escape     string   `json:"escape"`      // "double" or "backslash"
+	LineEnding string   `json:"line_ending"` // "LF", "CRLF", "CR" or "" for a single line
+	Encoding   string   `json:"encoding"`
+	BOM        bool     `json:"bom"`
+	Header     bool     `json:"header"`
+	Confidence float64  `json:"confidence"`
+	Options    []string `json:"options"` // reader options as key=value, for --in-opt
+}
+
+// Pin returns the --in-opt flags that select the detected dialect.
+func (s *SniffResult) Pin() string {
+	flags := make([]string, len(s.Options))
+	for i, opt := range s.Options {
+		if strings.ContainsAny(opt, "\t ;|'\"\\") {
+			opt = fmt.Sprintf("%q", opt)
+		}
+		flags[i] = "--in-opt " + opt
+	}
+	return strings.Join(flags, " ")
+}
+
+// RunSniff executes peek --sniff: it sniffs the input and prints the detected dialect.
+func RunSniff(ctx context.Context, inputFile string) error {
+	inputPath := inputFile
+	if inputPath == "-" {
+		inputPath = ""
+	} else if _, err := os.Stat(inputPath); err != nil {
+		return fmt.Errorf("input file does not exist: %s", inputPath)
+	}
+
+	r, err := convert.OpenInput(inputPath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	result, err := Sniff(ctx, r)
+	if err != nil {
+		return err
+	}
+	displaySniff(result)
+	return nil
+}
+
+// Sniff reads the first stream.SniffSize bytes of r and describes the dialect of the
+// delimited data they hold (see stream.Sniff).
+func Sniff(ctx context.Context, r io.Reader) (*SniffResult, error) {
+	sample := make([]byte, stream.SniffSize)
+	n, err := io.ReadFull(convert.ContextReader(ctx, r), sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+	sniffed := stream.Sniff(sample[:n])
+	d := sniffed.Dialect
+
+	result := &SniffResult{
+		Delimiter:  string(d.Delimiter),
+		Quote:      string(d.Quote),
+		Escape:     "double",
+		Encoding:   sniffed.Encoding,
+		BOM:        sniffed.BOM,
+		Header:     sniffed.Header,
+		Confidence: sniffed.Confidence,
+	}
+	if d.Escape != 0 {
+		result.Escape = "backslash"
+	}
+	switch sniffed.LineEnding {
+	case "\n":
+		result.LineEnding = "LF"
+	case "\r\n":
+		result.LineEnding = "CRLF"
+	case "\r":
+		result.LineEnding = "CR"
+	}
+
+	delimiter := result.Delimiter
+	if delimiter == "\t" {
+		delimiter = `\t`
+	}
+	result.Options = []string{
+		"delimiter=" + delimiter,
+		"quote=" + result.Quote,
+		"escape=" + result.Escape,
+		"encoding=" + result.Encoding,
+	}
+	if !result.Header {
+		result.Options = append(result.Options, "header=false")
+	}
+	return result, nil
+}
+
+func displaySniff(result *SniffResult) {
+	delimiter := result.Delimiter
+	if delimiter == "\t" {
+		delimiter = `\t (tab)`
+	}
+	encoding := result.Encoding
+	if result.BOM {
+		encoding += " (with BOM)"
+	}
+	lineEnding := result.LineEnding
+	if lineEnding == "" {
+		lineEnding = "unknown (single line)"
+	}
+	header := "no"
+	if result.Header {
+		header = "yes"
+	}
+
+	fmt.Printf("\n🔍 Detected Dialect\n")
+	fmt.Printf("═══════════════════════════════════════\n")
+	fmt.Printf("Delimiter:   %s\n", delimiter)
+	fmt.Printf("Quote:       %s\n", result.Quote)
+	fmt.Printf("Escape:      %s\n", result.Escape)
+	fmt.Printf("Line ending: %s\n", lineEnding)
+	fmt.Printf("Encoding:    %s\n", encoding)
+	fmt.Printf("Header:      %s\n", header)
+	fmt.Printf("Confidence:  %.0f%%\n", result.Confidence*100)
+	fmt.Printf("\n")
+
+	if result.Confidence == 0 {
+		fmt.Printf("No delimiter splits the sample into columns; the defaults are shown.\n\n")
+		return
+	}
+	fmt.Printf("Pin it in later runs with:\n  %s\n\n", result.Pin())
+}
@@ -14,6 +14,7 @@ import (
 type Formatter interface {
 	FormatSchema(schema *inspect.Schema) (string, error)
 	FormatDiff(diff *inspect.SchemaDiff, schema1, schema2 *inspect.Schema) (string, error)
+	FormatSniff(sniff *inspect.SniffResult) (string, error)
 }
 
 // MarkdownFormatter formats output as Markdown
@@ -100,6 +101,22 @@ func (f *MarkdownFormatter) FormatDiff(diff *inspect.SchemaDiff, schema1, schema
 	return sb.String(), nil
 }
 
+func (f *MarkdownFormatter) FormatSniff(sniff *inspect.SniffResult) (string, error) {
+	var sb strings.Builder
+
+	sb.WriteString("# Detected Dialect\n\n")
+	sb.WriteString(fmt.Sprintf("- **Delimiter:** `%s`\n", strings.ReplaceAll(sniff.Delimiter, "\t", `\t`)))
+	sb.WriteString(fmt.Sprintf("- **Quote:** `%s`\n", sniff.Quote))
+	sb.WriteString(fmt.Sprintf("- **Escape:** %s\n", sniff.Escape))
+	sb.WriteString(fmt.Sprintf("- **Line ending:** %s\n", sniff.LineEnding))
+	sb.WriteString(fmt.Sprintf("- **Encoding:** %s (BOM: %v)\n", sniff.Encoding, sniff.BOM))
+	sb.WriteString(fmt.Sprintf("- **Header:** %v\n", sniff.Header))
+	sb.WriteString(fmt.Sprintf("- **Confidence:** %.0f%%\n\n", sniff.Confidence*100))
+	sb.WriteString(fmt.Sprintf("Pin it with `%s`\n", sniff.Pin()))
+
+	return sb.String(), nil
+}
+
 // HTMLFormatter formats output as HTML
 type HTMLFormatter struct{}
 
@@ -192,6 +209,42 @@ func (f *HTMLFormatter) FormatDiff(diff *inspect.SchemaDiff, schema1, schema2 *i
 	return sb.String(), nil
 }
 
+func (f *HTMLFormatter) FormatSniff(sniff *inspect.SniffResult) (string, error) {
+	tmpl := `<!DOCTYPE html>
+<html>
+<head>
+	<title>Detected Dialect</title>
+	<style>body { font-family: Arial, sans-serif; margin: 20px; }</style>
+</head>
+<body>
+	<h1>Detected Dialect</h1>
+	<p><strong>Delimiter:</strong> <code>{{printf "%q" .Delimiter}}</code></p>
+	<p><strong>Quote:</strong> <code>{{.Quote}}</code></p>
+	<p><strong>Escape:</strong> {{.Escape}}</p>
+	<p><strong>Line ending:</strong> {{.LineEnding}}</p>
+	<p><strong>Encoding:</strong> {{.Encoding}}{{if .BOM}} (with BOM){{end}}</p>
+	<p><strong>Header:</strong> {{if .Header}}Yes{{else}}No{{end}}</p>
+	<p><strong>Confidence:</strong> {{printf "%.0f" (percent .Confidence)}}%</p>
+	<p>Pin it with <code>{{.Pin}}</code></p>
+</body>
+</html>
+`
+
+	t, err := template.New("sniff").Funcs(template.FuncMap{
+		"percent": func(v float64) float64 { return v * 100 },
+	}).Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	if err := t.Execute(&sb, sniff); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
 // JSONFormatter formats output as JSON
 type JSONFormatter struct{}
 
@@ -237,6 +290,15 @@ func (f *JSONFormatter) FormatDiff(diff *inspect.SchemaDiff, schema1, schema2 *i
 	return string(jsonBytes), nil
 }
 
+func (f *JSONFormatter) FormatSniff(sniff *inspect.SniffResult) (string, error) {
+	jsonBytes, err := json.MarshalIndent(sniff, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(jsonBytes), nil
+}
+
 // GetFormatter returns a formatter for the given output type
 func GetFormatter(outputType string) (Formatter, error) {
 	switch strings.ToLower(outputType) {
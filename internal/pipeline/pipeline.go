@@ -0,0 +1,167 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+	"omnidata/internal/inspect"
+	"omnidata/internal/output"
+)
+
+// RunOptions holds settings for executing a recipe.
+type RunOptions struct {
+	DryRun bool      // read, transform and validate, but write no sinks
+	Force  bool      // overwrite existing sink outputs (as if every sink set force)
+	Log    io.Writer // progress messages; nil discards them
+}
+
+// Run executes the recipe: sources, transforms, validations, then sinks.
+//
+// Steps run in declaration order. Failed validations with severity "error" stop
+// the pipeline before anything is written; "warn" failures are only reported.
+// Cancelling ctx stops the step in progress; sinks already written are kept.
+func (r *Recipe) Run(ctx context.Context, opts RunOptions) error {
+	log := opts.Log
+	if log == nil {
+		log = io.Discard
+	}
+	if r.Name != "" {
+		fmt.Fprintf(log, "Running pipeline %s\n", r.Name)
+	}
+
+	datasets := make(map[string]*dataset.Dataset)
+	origins := make(map[string]string) // dataset -> format it was read from
+
+	// ---------------------------
+	// Sources
+	// ---------------------------
+	for _, source := range r.Sources {
+		path := r.resolvePath(source.Path, source.Format)
+		format := strings.ToLower(source.Format)
+		if format == "" {
+			detected, err := convert.DetectFormat(path)
+			if err != nil {
+				return fmt.Errorf("source %s: %w (set format)", source.Name, err)
+			}
+			format = detected
+		}
+
+		ds, err := convert.ReadDataset(ctx, convert.Options{
+			InputFile: path,
+			From:      format,
+			InOptions: formatOptions(source.Options),
+		})
+		if err != nil {
+			return fmt.Errorf("source %s: %w", source.Name, err)
+		}
+		datasets[source.Name] = ds
+		origins[source.Name] = format
+		fmt.Fprintf(log, "Loaded %s: %d rows from %s (%s)\n", source.Name, ds.Len(), path, format)
+	}
+
+	// ---------------------------
+	// Transforms
+	// ---------------------------
+	for i := range r.Transforms {
+		t := &r.Transforms[i]
+		ds, err := t.apply(datasets[t.Input], datasets)
+		if err != nil {
+			return fmt.Errorf("transforms[%d] (%s on %s): %w", i, t.name(), t.Input, err)
+		}
+		datasets[t.Output] = ds
+		if _, ok := origins[t.Output]; !ok {
+			origins[t.Output] = origins[t.Input]
+		}
+		fmt.Fprintf(log, "Transform %s: %s -> %s (%d rows)\n", t.name(), t.Input, t.Output, ds.Len())
+	}
+
+	// ---------------------------
+	// Validations
+	// ---------------------------
+	failed := 0
+	for i := range r.Validations {
+		v := &r.Validations[i]
+		failures := v.check(datasets[v.Input])
+		if len(failures) == 0 {
+			fmt.Fprintf(log, "Validation %d on %s passed\n", i+1, v.Input)
+			continue
+		}
+		label := "FAILED"
+		if v.Severity == "warn" {
+			label = "WARNING"
+		} else {
+			failed++
+		}
+		for _, failure := range failures {
+			fmt.Fprintf(log, "Validation %d on %s %s: %s\n", i+1, v.Input, label, failure)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d validation(s) failed, no sinks were written", failed)
+	}
+
+	// ---------------------------
+	// Sinks
+	// ---------------------------
+	for i, sink := range r.Sinks {
+		if err := ctx.Err(); err != nil {
+			return convert.Stopped(ctx, err)
+		}
+		ds := datasets[sink.Input]
+		path := r.resolvePath(sink.Path, sink.Format)
+		if opts.DryRun {
+			fmt.Fprintf(log, "[Dry-run] Would write %s (%d rows) to %s\n", sink.Input, ds.Len(), path)
+			continue
+		}
+
+		if err := r.writeSink(ctx, sink, path, ds, origins[sink.Input], opts.Force); err != nil {
+			return fmt.Errorf("sinks[%d] (%s): %w", i, path, err)
+		}
+		fmt.Fprintf(log, "Wrote %s (%d rows) to %s\n", sink.Input, ds.Len(), path)
+	}
+
+	return nil
+}
+
+// writeSink writes a dataset, or its schema, to a sink.
+func (r *Recipe) writeSink(ctx context.Context, sink Sink, path string, ds *dataset.Dataset, origin string, force bool) error {
+	force = force || sink.Force
+
+	if sink.Schema != "" {
+		formatter, err := output.GetFormatter(sink.Schema)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err == nil && !force && path != "-" {
+			return fmt.Errorf("output file already exists: %s (set force to overwrite)", path)
+		}
+		content, err := formatter.FormatSchema(inspect.InferDatasetSchema(ds, origin))
+		if err != nil {
+			return err
+		}
+		return output.WriteOutput(content, path)
+	}
+
+	return convert.WriteDataset(ctx, convert.Options{
+		OutputFile: path,
+		From:       origin,
+		To:         strings.ToLower(sink.Format),
+		Force:      force,
+		OutOptions: formatOptions(sink.Options),
+	}, ds)
+}
+
+// formatOptions converts recipe options to format options; keys are case-insensitive
+// as they are for --in-opt and --out-opt.
+func formatOptions(options map[string]string) convert.FormatOptions {
+	opts := make(convert.FormatOptions, len(options))
+	for key, value := range options {
+		opts[strings.ToLower(key)] = value
+	}
+	return opts
+}
@@ -0,0 +1,279 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"omnidata/internal/output"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Recipe is a declarative multi-step job loaded from a YAML file.
+//
+// Sources are read into named datasets, transforms derive new datasets from them,
+// validations check them, and sinks write them out. Steps that do not name an
+// input work on the dataset produced by the previous step.
+type Recipe struct {
+	Name        string            `yaml:"name"`
+	Vars        map[string]string `yaml:"vars"`
+	Sources     []Source          `yaml:"sources"`
+	Transforms  []Transform       `yaml:"transforms"`
+	Validations []Validation      `yaml:"validations"`
+	Sinks       []Sink            `yaml:"sinks"`
+
+	// baseDir is the directory of the recipe file; relative paths resolve against it.
+	baseDir string
+}
+
+// Source reads one input into a named dataset.
+type Source struct {
+	Name    string            `yaml:"name"`
+	Path    string            `yaml:"path"`
+	Format  string            `yaml:"format"`
+	Options map[string]string `yaml:"options"`
+}
+
+// Sink writes a dataset to a file (or STDOUT with "-"), or its schema when Schema is set.
+type Sink struct {
+	Input   string            `yaml:"input"`
+	Path    string            `yaml:"path"`
+	Format  string            `yaml:"format"`
+	Options map[string]string `yaml:"options"`
+	Force   bool              `yaml:"force"`
+
+	// Schema writes the inferred schema (markdown, html or json) instead of the data.
+	Schema string `yaml:"schema"`
+}
+
+// varPattern matches ${NAME}, ${NAME:-default} and the $$ escape.
+var varPattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}`)
+
+// Load reads a recipe file, substituting ${NAME} references in every value.
+//
+// Variables are looked up in overrides (--var), then the environment, then the
+// recipe's own vars block; ${NAME:-default} supplies a fallback and $$ is a literal $.
+// An undefined variable without a default is an error.
+func Load(path string, overrides map[string]string) (*Recipe, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipe: %w", err)
+	}
+
+	recipe, err := Parse(data, overrides)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipe '%s': %w", path, err)
+	}
+	recipe.baseDir = filepath.Dir(path)
+	return recipe, nil
+}
+
+// Parse decodes and validates a recipe, substituting variables as described in Load.
+// Relative paths resolve against the current directory.
+func Parse(data []byte, overrides map[string]string) (*Recipe, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+
+	// The vars block is read first (resolving only overrides and the environment)
+	// so that the rest of the recipe can refer to it
+	var header struct {
+		Vars map[string]string `yaml:"vars"`
+	}
+	if err := root.Decode(&header); err != nil {
+		return nil, fmt.Errorf("failed to parse vars: %w", err)
+	}
+	vars := make(map[string]string, len(header.Vars))
+	for name, value := range header.Vars {
+		expanded, err := expand(value, overrides, nil)
+		if err != nil {
+			return nil, fmt.Errorf("vars.%s: %w", name, err)
+		}
+		vars[name] = expanded
+	}
+
+	if err := substitute(&root, overrides, vars); err != nil {
+		return nil, err
+	}
+
+	// Re-encode the substituted tree so that unknown keys (usually typos) are rejected
+	expanded, err := yaml.Marshal(&root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse recipe: %w", err)
+	}
+	recipe := &Recipe{}
+	decoder := yaml.NewDecoder(bytes.NewReader(expanded))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(recipe); err != nil {
+		return nil, fmt.Errorf("failed to parse recipe: %w", err)
+	}
+	recipe.Vars = vars
+
+	if err := recipe.validate(); err != nil {
+		return nil, err
+	}
+	return recipe, nil
+}
+
+// substitute expands variables in every scalar value of the YAML tree.
+// Mapping keys are left as written.
+func substitute(node *yaml.Node, overrides, vars map[string]string) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		expanded, err := expand(node.Value, overrides, vars)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		if expanded != node.Value && node.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle) == 0 {
+			// A plain ${LIMIT} becomes whatever its value is (e.g. an integer)
+			node.Tag = ""
+		}
+		node.Value = expanded
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := substitute(node.Content[i], overrides, vars); err != nil {
+				return err
+			}
+		}
+	default:
+		for _, child := range node.Content {
+			if err := substitute(child, overrides, vars); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expand replaces variable references in s.
+func expand(s string, overrides, vars map[string]string) (string, error) {
+	var missing []string
+	out := varPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if match == "$$" {
+			return "$"
+		}
+		parts := varPattern.FindStringSubmatch(match)
+		name := parts[1]
+		if value, ok := overrides[name]; ok {
+			return value
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			return value
+		}
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		if parts[2] != "" {
+			return strings.TrimPrefix(parts[2], ":-")
+		}
+		missing = append(missing, name)
+		return match
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("undefined variable %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// validate checks the recipe structure and that every step refers to a dataset
+// defined by an earlier step.
+func (r *Recipe) validate() error {
+	if len(r.Sources) == 0 {
+		return fmt.Errorf("at least one source is required")
+	}
+	if len(r.Sinks) == 0 {
+		return fmt.Errorf("at least one sink is required")
+	}
+
+	defined := make(map[string]bool)
+	current := ""
+	for i := range r.Sources {
+		source := &r.Sources[i]
+		if source.Path == "" {
+			return fmt.Errorf("sources[%d]: path is required", i)
+		}
+		if source.Name == "" {
+			source.Name = defaultSourceName(source.Path)
+		}
+		if defined[source.Name] {
+			return fmt.Errorf("sources[%d]: duplicate dataset name %q", i, source.Name)
+		}
+		defined[source.Name] = true
+		current = source.Name
+	}
+
+	resolve := func(step string, input *string) error {
+		if *input == "" {
+			*input = current
+		}
+		if !defined[*input] {
+			return fmt.Errorf("%s: unknown dataset %q", step, *input)
+		}
+		return nil
+	}
+
+	for i := range r.Transforms {
+		t := &r.Transforms[i]
+		step := fmt.Sprintf("transforms[%d]", i)
+		if err := resolve(step, &t.Input); err != nil {
+			return err
+		}
+		if err := t.validate(defined); err != nil {
+			return fmt.Errorf("%s: %w", step, err)
+		}
+		if t.Output == "" {
+			t.Output = t.Input
+		}
+		defined[t.Output] = true
+		current = t.Output
+	}
+
+	for i := range r.Validations {
+		v := &r.Validations[i]
+		step := fmt.Sprintf("validations[%d]", i)
+		if err := resolve(step, &v.Input); err != nil {
+			return err
+		}
+		if err := v.validate(); err != nil {
+			return fmt.Errorf("%s: %w", step, err)
+		}
+	}
+
+	for i := range r.Sinks {
+		s := &r.Sinks[i]
+		step := fmt.Sprintf("sinks[%d]", i)
+		if err := resolve(step, &s.Input); err != nil {
+			return err
+		}
+		if s.Path == "" {
+			return fmt.Errorf("%s: path is required", step)
+		}
+		if s.Schema != "" {
+			if _, err := output.GetFormatter(s.Schema); err != nil {
+				return fmt.Errorf("%s: %w", step, err)
+			}
+		}
+	}
+	return nil
+}
+
+// defaultSourceName names a source after its file, without extensions.
+func defaultSourceName(path string) string {
+	base := strings.TrimSuffix(filepath.Base(path), ".gz")
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// resolvePath makes a recipe path relative to the recipe file.
+// STDIN/STDOUT ("-"), absolute paths and SQL connection strings are kept.
+func (r *Recipe) resolvePath(path, format string) string {
+	if path == "-" || format == "sql" || strings.Contains(path, "://") ||
+		filepath.IsAbs(path) || r.baseDir == "" {
+		return path
+	}
+	return filepath.Join(r.baseDir, path)
+}
@@ -0,0 +1,381 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"omnidata/internal/dataset"
+)
+
+// Transform derives a dataset from Input (default: the previous step's dataset) and
+// stores it as Output (default: Input). Exactly one operation must be set.
+type Transform struct {
+	Input  string `yaml:"input"`
+	Output string `yaml:"output"`
+
+	Select []string          `yaml:"select"` // keep only these columns, in this order
+	Drop   []string          `yaml:"drop"`   // remove these columns
+	Rename map[string]string `yaml:"rename"` // old name -> new name
+	Filter *Filter           `yaml:"filter"` // keep matching rows
+	Cast   map[string]string `yaml:"cast"`   // column -> type (integer, float, decimal, boolean, date, ...)
+	Fill   map[string]string `yaml:"fill"`   // column -> value for null or empty cells
+	Sort   []string          `yaml:"sort"`   // columns; a leading "-" sorts descending
+	Limit  int               `yaml:"limit"`  // keep the first N rows
+	Union  []string          `yaml:"union"`  // append the rows of these datasets
+}
+
+// Filter keeps the rows whose Column satisfies Op.
+//
+// Ops: eq, ne, gt, ge, lt, le (Value, compared numerically when both sides are
+// numbers), contains (Value), in (Values), empty and not_empty.
+type Filter struct {
+	Column string   `yaml:"column"`
+	Op     string   `yaml:"op"`
+	Value  string   `yaml:"value"`
+	Values []string `yaml:"values"`
+}
+
+// filterOps lists the supported filter operators.
+var filterOps = []string{"eq", "ne", "gt", "ge", "lt", "le", "contains", "in", "empty", "not_empty"}
+
+// name returns the name of the transform operation.
+func (t *Transform) name() string {
+	var names []string
+	if len(t.Select) > 0 {
+		names = append(names, "select")
+	}
+	if len(t.Drop) > 0 {
+		names = append(names, "drop")
+	}
+	if len(t.Rename) > 0 {
+		names = append(names, "rename")
+	}
+	if t.Filter != nil {
+		names = append(names, "filter")
+	}
+	if len(t.Cast) > 0 {
+		names = append(names, "cast")
+	}
+	if len(t.Fill) > 0 {
+		names = append(names, "fill")
+	}
+	if len(t.Sort) > 0 {
+		names = append(names, "sort")
+	}
+	if t.Limit > 0 {
+		names = append(names, "limit")
+	}
+	if len(t.Union) > 0 {
+		names = append(names, "union")
+	}
+	return strings.Join(names, ",")
+}
+
+// validate checks that exactly one operation is set and that it is well formed.
+func (t *Transform) validate(defined map[string]bool) error {
+	name := t.name()
+	switch {
+	case name == "":
+		return fmt.Errorf("no operation set (select, drop, rename, filter, cast, fill, sort, limit or union)")
+	case strings.Contains(name, ","):
+		return fmt.Errorf("only one operation per transform is allowed, got %s", name)
+	}
+
+	if t.Filter != nil {
+		if t.Filter.Column == "" {
+			return fmt.Errorf("filter: column is required")
+		}
+		t.Filter.Op = strings.ToLower(t.Filter.Op)
+		if t.Filter.Op == "" {
+			t.Filter.Op = "eq"
+		}
+		if !containsString(filterOps, t.Filter.Op) {
+			return fmt.Errorf("filter: unknown op %q (%s)", t.Filter.Op, strings.Join(filterOps, "|"))
+		}
+	}
+	for column, typeName := range t.Cast {
+		if _, err := dataset.ParseKind(typeName); err != nil {
+			return fmt.Errorf("cast %s: %w", column, err)
+		}
+	}
+	for _, other := range t.Union {
+		if !defined[other] {
+			return fmt.Errorf("union: unknown dataset %q", other)
+		}
+	}
+	return nil
+}
+
+// apply runs the transform on ds; datasets holds the other named datasets (for union).
+// The input dataset is never modified.
+func (t *Transform) apply(ds *dataset.Dataset, datasets map[string]*dataset.Dataset) (*dataset.Dataset, error) {
+	switch {
+	case len(t.Select) > 0:
+		return selectColumns(ds, t.Select)
+	case len(t.Drop) > 0:
+		keep := make([]string, 0, len(ds.Columns))
+		for _, col := range ds.Columns {
+			if !containsString(t.Drop, col) {
+				keep = append(keep, col)
+			}
+		}
+		return selectColumns(ds, keep)
+	case len(t.Rename) > 0:
+		return renameColumns(ds, t.Rename)
+	case t.Filter != nil:
+		return filterRows(ds, t.Filter)
+	case len(t.Cast) > 0:
+		return castColumns(ds, t.Cast)
+	case len(t.Fill) > 0:
+		return fillColumns(ds, t.Fill)
+	case len(t.Sort) > 0:
+		return sortRows(ds, t.Sort)
+	case t.Limit > 0:
+		out := dataset.New(append([]string(nil), ds.Columns...))
+		n := min(t.Limit, ds.Len())
+		out.Records = append(out.Records, ds.Records[:n]...)
+		return out, nil
+	case len(t.Union) > 0:
+		out := dataset.New(nil)
+		for _, part := range append([]*dataset.Dataset{ds}, lookupAll(datasets, t.Union)...) {
+			appendByName(out, part)
+		}
+		return out, nil
+	}
+	return ds, nil
+}
+
+// selectColumns returns a copy of ds with only the given columns, in order.
+func selectColumns(ds *dataset.Dataset, columns []string) (*dataset.Dataset, error) {
+	indexes := make([]int, len(columns))
+	for i, col := range columns {
+		indexes[i] = ds.ColumnIndex(col)
+		if indexes[i] < 0 {
+			return nil, fmt.Errorf("unknown column %q", col)
+		}
+	}
+
+	out := dataset.New(append([]string(nil), columns...))
+	for _, rec := range ds.Records {
+		row := make(dataset.Record, len(indexes))
+		for i, idx := range indexes {
+			if idx < len(rec) {
+				row[i] = rec[idx]
+			}
+		}
+		out.Records = append(out.Records, row)
+	}
+	return out, nil
+}
+
+// renameColumns returns ds with renamed columns; the records are shared.
+func renameColumns(ds *dataset.Dataset, names map[string]string) (*dataset.Dataset, error) {
+	columns := append([]string(nil), ds.Columns...)
+	for old, name := range names {
+		idx := ds.ColumnIndex(old)
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown column %q", old)
+		}
+		columns[idx] = name
+	}
+
+	seen := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		if seen[col] {
+			return nil, fmt.Errorf("rename produces duplicate column %q", col)
+		}
+		seen[col] = true
+	}
+	return &dataset.Dataset{Columns: columns, Records: ds.Records}, nil
+}
+
+// filterRows returns the rows of ds that match f.
+func filterRows(ds *dataset.Dataset, f *Filter) (*dataset.Dataset, error) {
+	idx := ds.ColumnIndex(f.Column)
+	if idx < 0 {
+		return nil, fmt.Errorf("unknown column %q", f.Column)
+	}
+
+	out := dataset.New(append([]string(nil), ds.Columns...))
+	for _, rec := range ds.Records {
+		var v interface{}
+		if idx < len(rec) {
+			v = rec[idx]
+		}
+		if f.match(v) {
+			out.Records = append(out.Records, rec)
+		}
+	}
+	return out, nil
+}
+
+// match reports whether a cell value satisfies the filter.
+func (f *Filter) match(v interface{}) bool {
+	text := dataset.FormatValue(v)
+	switch f.Op {
+	case "empty":
+		return text == ""
+	case "not_empty":
+		return text != ""
+	case "contains":
+		return strings.Contains(text, f.Value)
+	case "in":
+		return containsString(f.Values, text)
+	}
+
+	cmp := dataset.Compare(filterOperands(v, f.Value))
+	switch f.Op {
+	case "ne":
+		return cmp != 0
+	case "gt":
+		return cmp > 0
+	case "ge":
+		return cmp >= 0
+	case "lt":
+		return cmp < 0
+	case "le":
+		return cmp <= 0
+	default:
+		return cmp == 0
+	}
+}
+
+// filterOperands returns the cell and filter value in comparable form: the value
+// is converted to the kind of a typed cell, and text cells holding numbers (as read
+// from CSV) are compared numerically with numeric values.
+func filterOperands(cell interface{}, value string) (interface{}, interface{}) {
+	switch dataset.KindOf(cell) {
+	case dataset.KindNull:
+		return cell, value
+	case dataset.KindString:
+		a, errA := dataset.Cast(cell, dataset.KindFloat)
+		b, errB := dataset.Cast(value, dataset.KindFloat)
+		if errA == nil && errB == nil && a != nil && b != nil {
+			return a, b
+		}
+		return cell, value
+	}
+	if typed, err := dataset.Cast(value, dataset.KindOf(cell)); err == nil && typed != nil {
+		return cell, typed
+	}
+	return cell, value
+}
+
+// castColumns returns a copy of ds with the given columns converted to types.
+func castColumns(ds *dataset.Dataset, types map[string]string) (*dataset.Dataset, error) {
+	out := copyDataset(ds)
+	for col, typeName := range types {
+		kind, err := dataset.ParseKind(typeName)
+		if err != nil {
+			return nil, err
+		}
+		idx := out.ColumnIndex(col)
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown column %q", col)
+		}
+		for row, rec := range out.Records {
+			v, err := dataset.Cast(rec[idx], kind)
+			if err != nil {
+				return nil, fmt.Errorf("row %d, column %q: %w", row+1, col, err)
+			}
+			rec[idx] = v
+		}
+	}
+	return out, nil
+}
+
+// fillColumns returns a copy of ds with null or empty cells replaced.
+// Columns that do not exist yet are added.
+func fillColumns(ds *dataset.Dataset, values map[string]string) (*dataset.Dataset, error) {
+	out := copyDataset(ds)
+	for col, value := range values {
+		idx := out.AddColumn(col)
+		for _, rec := range out.Records {
+			if dataset.FormatValue(rec[idx]) == "" {
+				rec[idx] = value
+			}
+		}
+	}
+	return out, nil
+}
+
+// sortRows returns a copy of ds sorted by the given columns ("-name" for descending).
+// The sort is stable, so rows with equal keys keep their order.
+func sortRows(ds *dataset.Dataset, keys []string) (*dataset.Dataset, error) {
+	type sortKey struct {
+		idx  int
+		desc bool
+	}
+	sortKeys := make([]sortKey, len(keys))
+	for i, key := range keys {
+		col := strings.TrimPrefix(key, "-")
+		idx := ds.ColumnIndex(col)
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown column %q", col)
+		}
+		sortKeys[i] = sortKey{idx: idx, desc: strings.HasPrefix(key, "-")}
+	}
+
+	out := copyDataset(ds)
+	sort.SliceStable(out.Records, func(a, b int) bool {
+		for _, key := range sortKeys {
+			cmp := dataset.Compare(out.Records[a][key.idx], out.Records[b][key.idx])
+			if cmp == 0 {
+				continue
+			}
+			if key.desc {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+	return out, nil
+}
+
+// appendByName appends the records of src to dst, matching columns by name.
+// Columns missing from dst are added.
+func appendByName(dst, src *dataset.Dataset) {
+	indexes := make([]int, len(src.Columns))
+	for i, col := range src.Columns {
+		indexes[i] = dst.AddColumn(col)
+	}
+	for _, rec := range src.Records {
+		row := make(dataset.Record, len(dst.Columns))
+		for i, idx := range indexes {
+			if i < len(rec) {
+				row[idx] = rec[i]
+			}
+		}
+		dst.Records = append(dst.Records, row)
+	}
+}
+
+// copyDataset returns a copy of ds whose records can be modified, padded to the column count.
+func copyDataset(ds *dataset.Dataset) *dataset.Dataset {
+	out := dataset.New(append([]string(nil), ds.Columns...))
+	for _, rec := range ds.Records {
+		row := make(dataset.Record, len(ds.Columns))
+		copy(row, rec)
+		out.Records = append(out.Records, row)
+	}
+	return out
+}
+
+func lookupAll(datasets map[string]*dataset.Dataset, names []string) []*dataset.Dataset {
+	out := make([]*dataset.Dataset, len(names))
+	for i, name := range names {
+		out[i] = datasets[name]
+	}
+	return out
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"omnidata/internal/dataset"
+)
+
+// maxReportedRows caps how many offending rows a failed check lists.
+const maxReportedRows = 5
+
+// Validation checks a dataset (Input, default: the previous step's dataset).
+// Every check that is set must pass; with Severity "warn" failures are reported
+// without stopping the pipeline.
+type Validation struct {
+	Input    string `yaml:"input"`
+	Severity string `yaml:"severity"` // error (default) or warn
+
+	Columns  []string          `yaml:"columns"`  // these columns must exist
+	Required []string          `yaml:"required"` // these columns must have a value in every row
+	Unique   []string          `yaml:"unique"`   // the combination of these columns must be unique
+	Pattern  map[string]string `yaml:"pattern"`  // column -> regular expression for non-empty values
+	Types    map[string]string `yaml:"types"`    // column -> type that every non-empty value must cast to
+	MinRows  *int              `yaml:"min_rows"`
+	MaxRows  *int              `yaml:"max_rows"`
+
+	patterns map[string]*regexp.Regexp
+}
+
+// validate checks the validation definition and compiles its patterns.
+func (v *Validation) validate() error {
+	v.Severity = strings.ToLower(v.Severity)
+	if v.Severity == "" {
+		v.Severity = "error"
+	}
+	if v.Severity != "error" && v.Severity != "warn" {
+		return fmt.Errorf("unknown severity %q (error|warn)", v.Severity)
+	}
+
+	if len(v.Columns) == 0 && len(v.Required) == 0 && len(v.Unique) == 0 &&
+		len(v.Pattern) == 0 && len(v.Types) == 0 && v.MinRows == nil && v.MaxRows == nil {
+		return fmt.Errorf("no check set (columns, required, unique, pattern, types, min_rows or max_rows)")
+	}
+
+	v.patterns = make(map[string]*regexp.Regexp, len(v.Pattern))
+	for col, expr := range v.Pattern {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("pattern %s: %w", col, err)
+		}
+		v.patterns[col] = re
+	}
+	for col, typeName := range v.Types {
+		if _, err := dataset.ParseKind(typeName); err != nil {
+			return fmt.Errorf("types %s: %w", col, err)
+		}
+	}
+	return nil
+}
+
+// check runs every check on ds and returns one message per failed check.
+func (v *Validation) check(ds *dataset.Dataset) []string {
+	var failures []string
+
+	for _, col := range v.allColumns() {
+		if ds.ColumnIndex(col) < 0 {
+			failures = append(failures, fmt.Sprintf("missing column %q", col))
+		}
+	}
+
+	if v.MinRows != nil && ds.Len() < *v.MinRows {
+		failures = append(failures, fmt.Sprintf("expected at least %d rows, got %d", *v.MinRows, ds.Len()))
+	}
+	if v.MaxRows != nil && ds.Len() > *v.MaxRows {
+		failures = append(failures, fmt.Sprintf("expected at most %d rows, got %d", *v.MaxRows, ds.Len()))
+	}
+
+	for _, col := range v.Required {
+		if ds.ColumnIndex(col) < 0 {
+			continue
+		}
+		failures = appendRows(failures, fmt.Sprintf("column %q has empty values", col), ds, func(row int) bool {
+			return dataset.FormatValue(ds.Value(row, col)) == ""
+		})
+	}
+
+	if len(v.Unique) > 0 && hasColumns(ds, v.Unique) {
+		seen := make(map[string]bool, ds.Len())
+		failures = appendRows(failures, fmt.Sprintf("duplicate values for (%s)", strings.Join(v.Unique, ", ")), ds, func(row int) bool {
+			parts := make([]string, len(v.Unique))
+			for i, col := range v.Unique {
+				parts[i] = dataset.FormatValue(ds.Value(row, col))
+			}
+			key := strings.Join(parts, "\x00")
+			duplicate := seen[key]
+			seen[key] = true
+			return duplicate
+		})
+	}
+
+	for _, col := range sortedKeys(v.Pattern) {
+		if ds.ColumnIndex(col) < 0 {
+			continue
+		}
+		re := v.patterns[col]
+		failures = appendRows(failures, fmt.Sprintf("column %q does not match %s", col, v.Pattern[col]), ds, func(row int) bool {
+			text := dataset.FormatValue(ds.Value(row, col))
+			return text != "" && !re.MatchString(text)
+		})
+	}
+
+	for _, col := range sortedKeys(v.Types) {
+		if ds.ColumnIndex(col) < 0 {
+			continue
+		}
+		kind, _ := dataset.ParseKind(v.Types[col])
+		failures = appendRows(failures, fmt.Sprintf("column %q has values that are not %s", col, kind), ds, func(row int) bool {
+			_, err := dataset.Cast(ds.Value(row, col), kind)
+			return err != nil
+		})
+	}
+
+	return failures
+}
+
+// allColumns returns every column the validation refers to, without duplicates.
+func (v *Validation) allColumns() []string {
+	var columns []string
+	add := func(cols ...string) {
+		for _, col := range cols {
+			if !containsString(columns, col) {
+				columns = append(columns, col)
+			}
+		}
+	}
+	add(v.Columns...)
+	add(v.Required...)
+	add(v.Unique...)
+	add(sortedKeys(v.Pattern)...)
+	add(sortedKeys(v.Types)...)
+	return columns
+}
+
+// appendRows appends a failure listing the (1-based) rows for which bad returns true.
+func appendRows(failures []string, message string, ds *dataset.Dataset, bad func(row int) bool) []string {
+	var rows []string
+	count := 0
+	for row := 0; row < ds.Len(); row++ {
+		if !bad(row) {
+			continue
+		}
+		count++
+		if len(rows) < maxReportedRows {
+			rows = append(rows, fmt.Sprintf("%d", row+1))
+		}
+	}
+	if count == 0 {
+		return failures
+	}
+	if count > len(rows) {
+		rows = append(rows, fmt.Sprintf("and %d more", count-len(rows)))
+	}
+	return append(failures, fmt.Sprintf("%s (rows %s)", message, strings.Join(rows, ", ")))
+}
+
+func hasColumns(ds *dataset.Dataset, columns []string) bool {
+	for _, col := range columns {
+		if ds.ColumnIndex(col) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
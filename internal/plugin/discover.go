@@ -0,0 +1,177 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"omnidata/internal/convert"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Candidate is a plugin executable found during discovery, not yet probed.
+type Candidate struct {
+	Name string
+	Path string
+}
+
+// Config is the plugin configuration file (see ConfigPath).
+type Config struct {
+	Plugins []struct {
+		Name string `yaml:"name"`
+		Path string `yaml:"path"`
+	} `yaml:"plugins"`
+}
+
+// ConfigPath returns the plugin configuration file: $OMNIDATA_PLUGIN_CONFIG, or
+// omnidata/plugins.yaml in the user configuration directory.
+func ConfigPath() string {
+	if path := os.Getenv("OMNIDATA_PLUGIN_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "omnidata", "plugins.yaml")
+}
+
+// Discover lists plugin executables, first match per name winning:
+// - entries of the configuration file (any executable name),
+// - omnidata-format-<name> executables in $OMNIDATA_PLUGIN_PATH directories,
+// - omnidata-format-<name> executables on $PATH.
+func Discover() ([]Candidate, error) {
+	var candidates []Candidate
+	seen := make(map[string]bool)
+	add := func(name, path string) {
+		name = strings.ToLower(name)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			candidates = append(candidates, Candidate{Name: name, Path: path})
+		}
+	}
+
+	if path := ConfigPath(); path != "" {
+		config, err := loadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range config.Plugins {
+			if entry.Path == "" {
+				return nil, fmt.Errorf("plugin config '%s': plugin %q has no path", path, entry.Name)
+			}
+			add(entry.Name, entry.Path)
+		}
+	}
+
+	dirs := filepath.SplitList(os.Getenv("OMNIDATA_PLUGIN_PATH"))
+	dirs = append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+	for _, dir := range dirs {
+		for _, c := range scanDir(dir) {
+			add(c.Name, c.Path)
+		}
+	}
+	return candidates, nil
+}
+
+// loadConfig reads the plugin configuration file; a missing file is an empty config.
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin config: %w", err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid plugin config '%s': %w", path, err)
+	}
+	return &config, nil
+}
+
+// scanDir returns the plugin executables in dir, sorted by name.
+func scanDir(dir string) []Candidate {
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var found []Candidate
+	for _, entry := range entries {
+		name, ok := strings.CutPrefix(entry.Name(), Prefix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if runtime.GOOS == "windows" {
+			if name, ok = strings.CutSuffix(name, ".exe"); !ok {
+				continue
+			}
+		}
+		path := filepath.Join(dir, entry.Name())
+		if !isExecutable(path) {
+			continue
+		}
+		found = append(found, Candidate{Name: name, Path: path})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Name < found[j].Name })
+	return found
+}
+
+// isExecutable reports whether path is a regular file that may be executed.
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode().Perm()&0111 != 0
+}
+
+/*
+RegisterAll discovers, probes and registers every plugin as a format.
+
+- Built-in formats are never replaced by a plugin of the same name.
+- Plugins that fail the handshake are skipped.
+- Problems are reported on warn (nil discards them) and never abort the CLI.
+- Setting OMNIDATA_NO_PLUGINS=1 disables plugins entirely.
+*/
+func RegisterAll(warn io.Writer) []*Plugin {
+	if warn == nil {
+		warn = io.Discard
+	}
+	if os.Getenv("OMNIDATA_NO_PLUGINS") == "1" {
+		return nil
+	}
+
+	candidates, err := Discover()
+	if err != nil {
+		fmt.Fprintf(warn, "Warning: %v\n", err)
+		return nil
+	}
+
+	var registered []*Plugin
+	for _, c := range candidates {
+		if _, exists := convert.GetFormat(c.Name); exists {
+			fmt.Fprintf(warn, "Warning: ignoring format plugin %s (%s): a format named %s already exists\n",
+				c.Name, c.Path, c.Name)
+			continue
+		}
+		p, err := Probe(c.Path, c.Name)
+		if err != nil {
+			fmt.Fprintf(warn, "Warning: ignoring format plugin %s (%s): %v\n", c.Name, c.Path, err)
+			continue
+		}
+		convert.RegisterFormat(p.Name, p.Handler())
+		registered = append(registered, p)
+	}
+	return registered
+}
@@ -0,0 +1,401 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+	"omnidata/internal/stream"
+)
+
+// Prefix is the file name prefix of plugin executables on PATH.
+const Prefix = "omnidata-format-"
+
+// ProtocolVersion is the plugin protocol version spoken by this build.
+const ProtocolVersion = 1
+
+// handshakeTimeout bounds how long a plugin may take to report its capabilities.
+const handshakeTimeout = 5 * time.Second
+
+// maxStderr caps how much of a plugin's STDERR is kept for error messages.
+const maxStderr = 4096
+
+// Capabilities is what a plugin reports for "<executable> capabilities".
+type Capabilities struct {
+	Protocol      int      `json:"protocol"`
+	Name          string   `json:"name"`
+	Read          bool     `json:"read"`
+	Write         bool     `json:"write"`
+	Nested        bool     `json:"nested"`
+	Extensions    []string `json:"extensions"`
+	Signatures    []string `json:"signatures"`
+	ReaderOptions []Option `json:"reader_options"`
+	WriterOptions []Option `json:"writer_options"`
+}
+
+// Option declares a reader or writer option of a plugin (see convert.OptionSpec).
+type Option struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Default     string   `json:"default"`
+	Description string   `json:"description"`
+	Values      []string `json:"values"`
+}
+
+// Plugin is an external executable that implements a format.
+//
+// Protocol:
+// - "<executable> capabilities" prints a Capabilities JSON object and exits.
+// - "<executable> read" gets the raw input on STDIN and prints one JSON object per record (NDJSON).
+// - "<executable> write" gets NDJSON records on STDIN and prints the raw output.
+// - OMNIDATA_PROTOCOL, OMNIDATA_RESOURCE (the file path, empty for STDIN/STDOUT) and
+// OMNIDATA_OPTIONS (a JSON object of --in-opt/--out-opt values) are set in the environment.
+// - A non-zero exit status fails the conversion; STDERR is included in the error.
+type Plugin struct {
+	Name         string
+	Path         string
+	Capabilities Capabilities
+}
+
+// Probe runs the capabilities handshake of the executable at path.
+// name is the format name to register; empty means the name the plugin reports.
+func Probe(path, name string) (*Plugin, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	stderr := &limitedBuffer{max: maxStderr}
+	cmd := exec.CommandContext(ctx, path, "capabilities")
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+	cmd.Env = append(os.Environ(), fmt.Sprintf("OMNIDATA_PROTOCOL=%d", ProtocolVersion))
+	if err := cmd.Run(); err != nil {
+		return nil, processError("capabilities handshake failed", err, stderr)
+	}
+
+	var caps Capabilities
+	if err := json.Unmarshal(stdout.Bytes(), &caps); err != nil {
+		return nil, fmt.Errorf("invalid capabilities: %w", err)
+	}
+	if caps.Protocol != ProtocolVersion {
+		return nil, fmt.Errorf("unsupported protocol version %d (expected %d)", caps.Protocol, ProtocolVersion)
+	}
+	if !caps.Read && !caps.Write {
+		return nil, fmt.Errorf("plugin supports neither read nor write")
+	}
+	for _, opt := range append(caps.ReaderOptions, caps.WriterOptions...) {
+		switch convert.OptionType(opt.Type) {
+		case convert.OptionString, convert.OptionInt, convert.OptionBool, convert.OptionChar, "":
+		default:
+			return nil, fmt.Errorf("option %s has unknown type %q", opt.Name, opt.Type)
+		}
+	}
+
+	if name == "" {
+		name = caps.Name
+	}
+	if name == "" {
+		return nil, fmt.Errorf("plugin reports no format name")
+	}
+	return &Plugin{Name: strings.ToLower(name), Path: path, Capabilities: caps}, nil
+}
+
+// Handler returns a format handler that runs the plugin for every read and write.
+func (p *Plugin) Handler() convert.FormatHandler {
+	caps := p.Capabilities
+	handler := convert.FormatHandler{
+		Name:          p.Name,
+		Nested:        caps.Nested,
+		Extensions:    caps.Extensions,
+		ReaderOptions: optionSpecs(caps.ReaderOptions),
+		WriterOptions: optionSpecs(caps.WriterOptions),
+		Plugin:        p.Path,
+	}
+	for _, sig := range caps.Signatures {
+		if sig != "" {
+			handler.Signatures = append(handler.Signatures, []byte(sig))
+		}
+	}
+
+	if caps.Read {
+		handler.ReaderFn = p.read
+		handler.StreamReaderFn = p.streamRead
+	} else {
+		handler.ReaderFn = func(context.Context, io.Reader, string, convert.FormatOptions) (interface{}, error) {
+			return nil, fmt.Errorf("format plugin %s does not support reading", p.Name)
+		}
+	}
+	if caps.Write {
+		handler.WriterFn = p.write
+		handler.StreamWriterFn = p.streamWrite
+	} else {
+		handler.WriterFn = func(context.Context, io.Writer, string, interface{}, convert.FormatOptions) error {
+			return fmt.Errorf("format plugin %s does not support writing", p.Name)
+		}
+	}
+	return handler
+}
+
+// read runs the plugin reader and collects every record into a dataset.
+func (p *Plugin) read(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (interface{}, error) {
+	rows, err := p.streamRead(ctx, r, resource, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []map[string]interface{}
+	for {
+		row, err := rows.ReadRow()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, row)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	return dataset.FromMapsOrdered(rows.(stream.ColumnReader).Columns(), records), nil
+}
+
+// write runs the plugin writer over every record of a dataset.
+func (p *Plugin) write(ctx context.Context, w io.Writer, resource string, data interface{}, opts convert.FormatOptions) error {
+	ds, ok := data.(*dataset.Dataset)
+	if !ok {
+		return fmt.Errorf("invalid data type for format plugin %s, expected dataset", p.Name)
+	}
+
+	rows, err := p.streamWrite(ctx, w, resource, ds.Columns, opts)
+	if err != nil {
+		return err
+	}
+	for i := range ds.Records {
+		if err := rows.WriteRow(ds.Map(i)); err != nil {
+			rows.Close()
+			return err
+		}
+	}
+	return rows.Close()
+}
+
+// streamRead starts the plugin reader; rows are decoded as the plugin prints them.
+func (p *Plugin) streamRead(ctx context.Context, r io.Reader, resource string, opts convert.FormatOptions) (stream.StreamingReader, error) {
+	cmd, stderr, err := p.command(ctx, "read", resource, opts)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdin = r
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start format plugin %s: %w", p.Name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start format plugin %s: %w", p.Name, err)
+	}
+
+	return &processReader{
+		NDJSONStreamingReader: stream.NewNDJSONStreamingReaderFrom(stdout),
+		plugin:                p,
+		cmd:                   cmd,
+		stderr:                stderr,
+	}, nil
+}
+
+// streamWrite starts the plugin writer; rows are sent to it as they are written.
+func (p *Plugin) streamWrite(ctx context.Context, w io.Writer, resource string, columns []string, opts convert.FormatOptions) (stream.StreamingWriter, error) {
+	cmd, stderr, err := p.command(ctx, "write", resource, opts)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout = w
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start format plugin %s: %w", p.Name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start format plugin %s: %w", p.Name, err)
+	}
+
+	return &processWriter{
+		rows:   stream.NewNDJSONStreamingWriterTo(stdin, columns),
+		stdin:  stdin,
+		plugin: p,
+		cmd:    cmd,
+		stderr: stderr,
+	}, nil
+}
+
+// command prepares a plugin invocation with the protocol environment; the plugin
+// is killed when ctx is done.
+func (p *Plugin) command(ctx context.Context, action, resource string, opts convert.FormatOptions) (*exec.Cmd, *limitedBuffer, error) {
+	options, err := json.Marshal(map[string]string(opts))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode plugin options: %w", err)
+	}
+	if opts == nil {
+		options = []byte("{}")
+	}
+
+	stderr := &limitedBuffer{max: maxStderr}
+	cmd := exec.CommandContext(ctx, p.Path, action)
+	cmd.Stderr = stderr
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("OMNIDATA_PROTOCOL=%d", ProtocolVersion),
+		"OMNIDATA_RESOURCE="+resource,
+		"OMNIDATA_OPTIONS="+string(options),
+	)
+	return cmd, stderr, nil
+}
+
+// processReader reads NDJSON records from a running plugin.
+type processReader struct {
+	*stream.NDJSONStreamingReader
+	plugin *Plugin
+	cmd    *exec.Cmd
+	stderr *limitedBuffer
+	done   bool
+	err    error
+}
+
+// ReadRow returns the next record; at the end of the output the plugin's exit status is checked.
+func (r *processReader) ReadRow() (map[string]interface{}, error) {
+	row, err := r.NDJSONStreamingReader.ReadRow()
+	if err == io.EOF {
+		if waitErr := r.wait(false); waitErr != nil {
+			return nil, waitErr
+		}
+		return nil, io.EOF
+	}
+	if err != nil {
+		r.wait(true)
+		return nil, fmt.Errorf("format plugin %s: invalid output: %w", r.plugin.Name, err)
+	}
+	return row, nil
+}
+
+// Close stops the plugin if it is still running and reports its exit status.
+func (r *processReader) Close() error {
+	return r.wait(true)
+}
+
+// wait waits for the plugin to exit, killing it first if kill is set.
+func (r *processReader) wait(kill bool) error {
+	if r.done {
+		return r.err
+	}
+	r.done = true
+	if kill {
+		r.cmd.Process.Kill()
+		r.cmd.Wait()
+		return nil
+	}
+	if err := r.cmd.Wait(); err != nil {
+		r.err = processError(fmt.Sprintf("format plugin %s failed to read", r.plugin.Name), err, r.stderr)
+	}
+	return r.err
+}
+
+// processWriter sends NDJSON records to a running plugin.
+type processWriter struct {
+	rows   *stream.NDJSONStreamingWriter
+	stdin  io.WriteCloser
+	plugin *Plugin
+	cmd    *exec.Cmd
+	stderr *limitedBuffer
+	done   bool
+}
+
+// WriteRow sends a record to the plugin.
+func (w *processWriter) WriteRow(row map[string]interface{}) error {
+	if err := w.rows.WriteRow(row); err != nil {
+		// Usually the plugin exited early; its exit status explains why
+		if waitErr := w.finish(); waitErr != nil {
+			return waitErr
+		}
+		return fmt.Errorf("format plugin %s: %w", w.plugin.Name, err)
+	}
+	return nil
+}
+
+// Close flushes the remaining records, closes the plugin's STDIN and waits for it to exit.
+func (w *processWriter) Close() error {
+	if w.done {
+		return nil
+	}
+	flushErr := w.rows.Close()
+	if err := w.finish(); err != nil {
+		return err
+	}
+	if flushErr != nil {
+		return fmt.Errorf("format plugin %s: %w", w.plugin.Name, flushErr)
+	}
+	return nil
+}
+
+// finish closes the plugin's STDIN and waits for it to exit.
+func (w *processWriter) finish() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	w.stdin.Close()
+	if err := w.cmd.Wait(); err != nil {
+		return processError(fmt.Sprintf("format plugin %s failed to write", w.plugin.Name), err, w.stderr)
+	}
+	return nil
+}
+
+// processError describes a failed plugin process, including what it printed on STDERR.
+func processError(message string, err error, stderr *limitedBuffer) error {
+	if text := strings.TrimSpace(stderr.String()); text != "" {
+		return fmt.Errorf("%s: %w: %s", message, err, text)
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+// optionSpecs converts plugin options to convert option specs.
+func optionSpecs(options []Option) []convert.OptionSpec {
+	specs := make([]convert.OptionSpec, len(options))
+	for i, opt := range options {
+		optType := convert.OptionType(opt.Type)
+		if optType == "" {
+			optType = convert.OptionString
+		}
+		specs[i] = convert.OptionSpec{
+			Name:        strings.ToLower(opt.Name),
+			Type:        optType,
+			Default:     opt.Default,
+			Description: opt.Description,
+			Values:      opt.Values,
+		}
+	}
+	return specs
+}
+
+// limitedBuffer keeps the first max bytes written to it and discards the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,318 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Dialect describes the flavour of a delimited text file such as CSV or TSV.
+// Zero values select the RFC 4180 defaults.
+type Dialect struct {
+	Delimiter        rune   // field separator (default ',')
+	Quote            rune   // quote character (default '"')
+	Escape           rune   // escapes the next character, e.g. '\\'; 0 means quotes are doubled
+	Comment          string // lines starting with this prefix are skipped when reading
+	LazyQuotes       bool   // tolerate bare quotes in unquoted fields and stray quotes in quoted ones
+	TrimLeadingSpace bool   // ignore white space at the start of fields when reading
+	CRLF             bool   // end written lines with \r\n
+	AlwaysQuote      bool   // quote every written field
+}
+
+// OffsetRecordReader is a RecordReader that reports how many bytes of its input
+// the records read so far span, such as *csv.Reader.
+type OffsetRecordReader interface {
+	RecordReader
+	InputOffset() int64
+}
+
+// RecordWriter is a sink of CSV-like records, such as *csv.Writer.
+type RecordWriter interface {
+	Write(record []string) error
+	Flush()
+	Error() error
+}
+
+// withDefaults fills in the default delimiter and quote.
+func (d Dialect) withDefaults() Dialect {
+	if d.Delimiter == 0 {
+		d.Delimiter = ','
+	}
+	if d.Quote == 0 {
+		d.Quote = '"'
+	}
+	return d
+}
+
+// Validate checks that the special characters of the dialect do not clash.
+func (d Dialect) Validate() error {
+	d = d.withDefaults()
+	for _, c := range []struct {
+		name string
+		r    rune
+	}{{"delimiter", d.Delimiter}, {"quote", d.Quote}, {"escape", d.Escape}} {
+		if c.r == '\r' || c.r == '\n' || c.r == utf8.RuneError {
+			return fmt.Errorf("invalid %s %q", c.name, c.r)
+		}
+	}
+	if d.Delimiter == d.Quote || d.Delimiter == d.Escape {
+		return fmt.Errorf("the delimiter %q must differ from the quote and escape characters", d.Delimiter)
+	}
+	if d.Comment != "" && strings.HasPrefix(d.Comment, string(d.Delimiter)) {
+		return fmt.Errorf("the comment prefix %q must not start with the delimiter", d.Comment)
+	}
+	return nil
+}
+
+// standard reports whether encoding/csv implements the dialect.
+func (d Dialect) standard() bool {
+	d = d.withDefaults()
+	return d.Quote == '"' && d.Escape == 0 && utf8.RuneCountInString(d.Comment) <= 1
+}
+
+// NewReader returns a record reader for the dialect. Malformed records are reported
+// as *csv.ParseError, as encoding/csv does; the reader carries on after them.
+func (d Dialect) NewReader(r io.Reader) OffsetRecordReader {
+	d = d.withDefaults()
+	if d.standard() {
+		reader := csv.NewReader(r)
+		reader.Comma = d.Delimiter
+		reader.Comment, _ = utf8.DecodeRuneInString(d.Comment)
+		if d.Comment == "" {
+			reader.Comment = 0
+		}
+		reader.LazyQuotes = d.LazyQuotes
+		reader.TrimLeadingSpace = d.TrimLeadingSpace
+		return reader
+	}
+	return &dialectReader{dialect: d, reader: bufio.NewReader(r)}
+}
+
+// NewWriter returns a record writer for the dialect.
+func (d Dialect) NewWriter(w io.Writer) RecordWriter {
+	d = d.withDefaults()
+	if d.Quote == '"' && d.Escape == 0 && !d.AlwaysQuote {
+		writer := csv.NewWriter(w)
+		writer.Comma = d.Delimiter
+		writer.UseCRLF = d.CRLF
+		return writer
+	}
+	return &dialectWriter{dialect: d, writer: bufio.NewWriter(w)}
+}
+
+// dialectReader parses the dialects encoding/csv does not support: other quote
+// characters, backslash-style escapes and multi-character comment prefixes.
+type dialectReader struct {
+	dialect Dialect
+	reader  *bufio.Reader
+	offset  int64 // bytes consumed
+	line    int   // lines consumed
+	fields  int   // fields per record, set by the first record
+}
+
+// InputOffset returns the input offset of the end of the last record read.
+func (r *dialectReader) InputOffset() int64 {
+	return r.offset
+}
+
+// readLine returns the next line, always ending with a single "\n".
+func (r *dialectReader) readLine() (string, error) {
+	line, err := r.reader.ReadString('\n')
+	if line == "" {
+		return "", err
+	}
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	r.offset += int64(len(line))
+	r.line++
+
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return line + "\n", nil
+}
+
+// Read returns the next record, skipping blank and comment lines.
+func (r *dialectReader) Read() ([]string, error) {
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "\n" || (r.dialect.Comment != "" && strings.HasPrefix(line, r.dialect.Comment)) {
+			continue
+		}
+
+		record, err := r.parseRecord(line)
+		if err != nil {
+			return nil, err
+		}
+		if r.fields == 0 {
+			r.fields = len(record)
+		} else if len(record) != r.fields {
+			return record, &csv.ParseError{StartLine: r.line, Line: r.line, Column: 1, Err: csv.ErrFieldCount}
+		}
+		return record, nil
+	}
+}
+
+// parseRecord splits the record starting on line, reading more lines for quoted
+// or escaped line breaks.
+func (r *dialectReader) parseRecord(line string) ([]string, error) {
+	d := r.dialect
+	startLine := r.line
+	parseErr := func(pos int, err error) error {
+		return &csv.ParseError{StartLine: startLine, Line: r.line, Column: pos + 1, Err: err}
+	}
+
+	var record []string
+	var field strings.Builder
+	pos := 0
+	for {
+		if d.TrimLeadingSpace {
+			for pos < len(line) && (line[pos] == ' ' || line[pos] == '\t') {
+				pos++
+			}
+		}
+
+		field.Reset()
+		quoted := strings.HasPrefix(line[pos:], string(d.Quote))
+		if quoted {
+			pos += utf8.RuneLen(d.Quote)
+		}
+
+		// Scan the field up to its delimiter or the end of the record
+		for {
+			if pos >= len(line) {
+				// Only quoted fields and escaped line breaks continue on the next line
+				next, err := r.readLine()
+				if err == io.EOF {
+					if quoted && !d.LazyQuotes {
+						return nil, parseErr(pos, csv.ErrQuote)
+					}
+					record = append(record, field.String())
+					return record, nil
+				}
+				if err != nil {
+					return nil, err
+				}
+				line, pos = next, 0
+			}
+
+			c, size := utf8.DecodeRuneInString(line[pos:])
+			switch {
+			case d.Escape != 0 && c == d.Escape && pos+size < len(line):
+				escaped, escapedSize := utf8.DecodeRuneInString(line[pos+size:])
+				field.WriteRune(escaped)
+				pos += size + escapedSize
+				continue
+			case quoted && c == d.Quote:
+				pos += size
+				next, nextSize := utf8.DecodeRuneInString(line[pos:])
+				switch {
+				case d.Escape == 0 && next == d.Quote:
+					field.WriteRune(d.Quote)
+					pos += nextSize
+				case next == d.Delimiter || next == '\n':
+					quoted = false
+				case d.LazyQuotes:
+					field.WriteRune(d.Quote)
+				default:
+					return nil, parseErr(pos, csv.ErrQuote)
+				}
+				continue
+			case !quoted && (c == d.Delimiter || c == '\n'):
+				record = append(record, field.String())
+				pos += size
+				if c == '\n' {
+					return record, nil
+				}
+			case !quoted && c == d.Quote && !d.LazyQuotes:
+				return nil, parseErr(pos, csv.ErrBareQuote)
+			default:
+				field.WriteRune(c)
+				pos += size
+				continue
+			}
+			break
+		}
+	}
+}
+
+// dialectWriter writes the dialects encoding/csv does not support.
+type dialectWriter struct {
+	dialect Dialect
+	writer  *bufio.Writer
+	err     error
+}
+
+// Write writes a single record.
+func (w *dialectWriter) Write(record []string) error {
+	if w.err != nil {
+		return w.err
+	}
+	d := w.dialect
+	var b strings.Builder
+	for i, field := range record {
+		if i > 0 {
+			b.WriteRune(d.Delimiter)
+		}
+		if !w.needsQuotes(field) {
+			b.WriteString(field)
+			continue
+		}
+
+		b.WriteRune(d.Quote)
+		for _, c := range field {
+			switch {
+			case d.Escape != 0 && (c == d.Quote || c == d.Escape):
+				b.WriteRune(d.Escape)
+			case d.Escape == 0 && c == d.Quote:
+				b.WriteRune(d.Quote)
+			case c == '\n' && d.CRLF:
+				b.WriteRune('\r')
+			}
+			b.WriteRune(c)
+		}
+		b.WriteRune(d.Quote)
+	}
+	if d.CRLF {
+		b.WriteString("\r\n")
+	} else {
+		b.WriteByte('\n')
+	}
+
+	_, w.err = w.writer.WriteString(b.String())
+	return w.err
+}
+
+// needsQuotes reports whether a field must be quoted to read back unchanged.
+func (w *dialectWriter) needsQuotes(field string) bool {
+	d := w.dialect
+	if d.AlwaysQuote {
+		return true
+	}
+	if field == "" {
+		return false
+	}
+	if field[0] == ' ' || field[0] == '\t' || (d.Comment != "" && strings.HasPrefix(field, d.Comment)) {
+		return true
+	}
+	return strings.ContainsAny(field, string([]rune{d.Delimiter, d.Quote, '\r', '\n'})) ||
+		(d.Escape != 0 && strings.ContainsRune(field, d.Escape))
+}
+
+// Flush writes buffered records to the underlying writer.
+func (w *dialectWriter) Flush() {
+	if w.err == nil {
+		w.err = w.writer.Flush()
+	}
+}
+
+// Error returns the first error that occurred while writing or flushing.
+func (w *dialectWriter) Error() error {
+	return w.err
+}
@@ -0,0 +1,55 @@
+package stream
+
+import (
+	"omnidata/internal/dataset"
+)
+
+// Header describes where the column names of a CSV-like input come from.
+type Header struct {
+	Absent  bool     // the first record is data; names come from Columns or are col1..colN
+	Columns []string // names used instead of those in the header row
+}
+
+// NewReader returns a record reader whose first record holds the column names as h
+// describes, followed by the data records of reader. The names are made unique and
+// non-blank with dataset.UniqueColumns; when Columns is shorter than the first record
+// the remaining columns are named by position.
+func (h Header) NewReader(reader RecordReader) RecordReader {
+	return &headerReader{reader: reader, header: h}
+}
+
+// headerReader rewrites the header of a record source.
+type headerReader struct {
+	reader  RecordReader
+	header  Header
+	started bool
+	pending []string // first data record of a headerless input
+}
+
+// Read returns the column names first, then the data records.
+func (r *headerReader) Read() ([]string, error) {
+	if r.started {
+		if record := r.pending; record != nil {
+			r.pending = nil
+			return record, nil
+		}
+		return r.reader.Read()
+	}
+
+	first, err := r.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	r.started = true
+
+	names := first
+	if r.header.Absent {
+		r.pending = first
+		names = make([]string, len(first))
+	}
+	if len(r.header.Columns) > 0 {
+		names = make([]string, max(len(first), len(r.header.Columns)))
+		copy(names, r.header.Columns)
+	}
+	return dataset.UniqueColumns(names), nil
+}
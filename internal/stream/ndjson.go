@@ -0,0 +1,167 @@
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"omnidata/internal/dataset"
+)
+
+// NDJSONStreamingReader reads newline-delimited JSON: one object per line.
+// Blank lines are skipped, and errors report the line they occurred on.
+type NDJSONStreamingReader struct {
+	reader  *bufio.Reader
+	line    int
+	columns []string
+	seen    map[string]bool
+}
+
+// NewNDJSONStreamingReaderFrom creates a streaming NDJSON reader on top of an existing reader.
+// The caller keeps ownership of r; Close does not close it.
+func NewNDJSONStreamingReaderFrom(r io.Reader) *NDJSONStreamingReader {
+	return &NDJSONStreamingReader{
+		reader: bufio.NewReader(r),
+		seen:   make(map[string]bool),
+	}
+}
+
+// LineError reports a line that does not hold a valid JSON object. The reader
+// can carry on with the next line after it.
+type LineError struct {
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// ReadRow reads the object on the next non-blank line. A malformed line is
+// reported as a *LineError.
+func (r *NDJSONStreamingReader) ReadRow() (map[string]interface{}, error) {
+	for {
+		text, err := r.reader.ReadBytes('\n')
+		if len(text) == 0 && err != nil {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("line %d: %w", r.line+1, err)
+		}
+		r.line++
+
+		text = bytes.TrimSpace(text)
+		if len(text) == 0 {
+			continue
+		}
+
+		keys, row, decodeErr := decodeOrderedObject(text)
+		if decodeErr != nil {
+			return nil, &LineError{Line: r.line, Text: string(text), Err: decodeErr}
+		}
+		for _, k := range keys {
+			if !r.seen[k] {
+				r.seen[k] = true
+				r.columns = append(r.columns, k)
+			}
+		}
+		return row, nil
+	}
+}
+
+// Columns returns the keys seen so far, in order of first appearance.
+func (r *NDJSONStreamingReader) Columns() []string {
+	return r.columns
+}
+
+// Line returns the line number of the last row read.
+func (r *NDJSONStreamingReader) Line() int {
+	return r.line
+}
+
+// Close is a no-op; the caller owns the underlying reader.
+func (r *NDJSONStreamingReader) Close() error {
+	return nil
+}
+
+// decodeOrderedObject decodes a single JSON object, returning its keys in document order.
+func decodeOrderedObject(data []byte) ([]string, map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, nil, fmt.Errorf("expected a JSON object")
+	}
+
+	var keys []string
+	row := make(map[string]interface{})
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		key := token.(string)
+
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, nil, fmt.Errorf("invalid JSON value for %q: %w", key, err)
+		}
+		if _, dup := row[key]; !dup {
+			keys = append(keys, key)
+		}
+		row[key] = dataset.Normalize(value)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, nil, fmt.Errorf("unexpected data after the JSON object")
+	}
+	return keys, row, nil
+}
+
+// NDJSONStreamingWriter writes one compact JSON object per line.
+type NDJSONStreamingWriter struct {
+	writer *bufio.Writer
+	header []string
+}
+
+// NewNDJSONStreamingWriterTo creates a streaming NDJSON writer on top of an existing writer.
+// Object keys follow header order; keys missing from the header are appended sorted.
+// The caller keeps ownership of w; Close flushes but does not close it.
+func NewNDJSONStreamingWriterTo(w io.Writer, header []string) *NDJSONStreamingWriter {
+	return &NDJSONStreamingWriter{
+		writer: bufio.NewWriter(w),
+		header: header,
+	}
+}
+
+// WriteRow writes a row as the next line.
+func (w *NDJSONStreamingWriter) WriteRow(row map[string]interface{}) error {
+	if err := writeObject(w.writer, orderedKeys(w.header, row), row); err != nil {
+		return err
+	}
+	if err := w.writer.WriteByte('\n'); err != nil {
+		return fmt.Errorf("failed to write NDJSON row: %w", err)
+	}
+	return nil
+}
+
+// Close flushes buffered rows.
+func (w *NDJSONStreamingWriter) Close() error {
+	if err := w.writer.Flush(); err != nil {
+		return fmt.Errorf("NDJSON writer error: %w", err)
+	}
+	return nil
+}
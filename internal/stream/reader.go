@@ -2,23 +2,40 @@ package stream
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strings"
+
+	"omnidata/internal/dataset"
 )
 
-// StreamingReader provides streaming read capabilities for large files
+// StreamingReader provides streaming read capabilities for large files.
+// Row values are typed cells as described by dataset.Kind.
 type StreamingReader interface {
-	ReadRow() (map[string]string, error)
+	ReadRow() (map[string]interface{}, error)
 	Close() error
 }
 
+// ColumnReader is implemented by streaming readers that know their column order
+// up front (e.g. from a header row). Writers use it to keep the source order.
+type ColumnReader interface {
+	Columns() []string
+}
+
+// RecordReader is a source of CSV-like records, such as *csv.Reader.
+type RecordReader interface {
+	Read() ([]string, error)
+}
+
 // CSVStreamingReader reads CSV files row by row
 type CSVStreamingReader struct {
-	file   *os.File
-	reader *csv.Reader
+	closer io.Closer
+	reader RecordReader
 	header []string
 }
 
@@ -29,24 +46,54 @@ func NewCSVStreamingReader(path string) (*CSVStreamingReader, error) {
 		return nil, fmt.Errorf("failed to open CSV file: %w", err)
 	}
 
-	reader := csv.NewReader(bufio.NewReader(file))
-
-	// Read header
-	header, err := reader.Read()
+	r, err := NewCSVStreamingReaderFrom(file)
 	if err != nil {
 		file.Close()
+		return nil, err
+	}
+	r.closer = file
+
+	return r, nil
+}
+
+// NewCSVStreamingReaderFrom creates a streaming CSV reader on top of an existing reader.
+// The caller keeps ownership of r; Close does not close it.
+func NewCSVStreamingReaderFrom(r io.Reader) (*CSVStreamingReader, error) {
+	return NewCSVStreamingReaderWith(csv.NewReader(bufio.NewReader(r)))
+}
+
+// NewCSVStreamingReaderWith creates a streaming CSV reader on top of a configured csv.Reader
+// (e.g. with a custom delimiter). The first record is read as the header.
+func NewCSVStreamingReaderWith(reader *csv.Reader) (*CSVStreamingReader, error) {
+	return NewCSVStreamingReaderFromRecords(reader)
+}
+
+// NewCSVStreamingReaderFromRecords creates a streaming CSV reader on top of any record
+// source (e.g. one that skips malformed records). The first record is read as the header.
+func NewCSVStreamingReaderFromRecords(reader RecordReader) (*CSVStreamingReader, error) {
+	// Read header (an empty input simply has no rows)
+	header, err := reader.Read()
+	if err != nil && err != io.EOF {
 		return nil, fmt.Errorf("failed to read CSV header: %w", err)
 	}
 
 	return &CSVStreamingReader{
-		file:   file,
 		reader: reader,
 		header: header,
 	}, nil
 }
 
+// Columns returns the header row of the CSV input
+func (r *CSVStreamingReader) Columns() []string {
+	return r.header
+}
+
 // ReadRow reads the next row from the CSV file
-func (r *CSVStreamingReader) ReadRow() (map[string]string, error) {
+func (r *CSVStreamingReader) ReadRow() (map[string]interface{}, error) {
+	if r.header == nil {
+		return nil, io.EOF
+	}
+
 	record, err := r.reader.Read()
 	if err == io.EOF {
 		return nil, io.EOF
@@ -55,7 +102,7 @@ func (r *CSVStreamingReader) ReadRow() (map[string]string, error) {
 		return nil, fmt.Errorf("failed to read CSV row: %w", err)
 	}
 
-	row := make(map[string]string)
+	row := make(map[string]interface{}, len(r.header))
 	for i, value := range record {
 		if i < len(r.header) {
 			row[r.header[i]] = value
@@ -67,15 +114,17 @@ func (r *CSVStreamingReader) ReadRow() (map[string]string, error) {
 
 // Close closes the underlying file
 func (r *CSVStreamingReader) Close() error {
-	return r.file.Close()
+	return closeIfOwned(r.closer)
 }
 
 // JSONStreamingReader reads JSON files with streaming support
 // For JSON arrays, reads one object at a time
 type JSONStreamingReader struct {
-	file    *os.File
+	closer  io.Closer
 	decoder *json.Decoder
 	first   bool
+	columns []string
+	seen    map[string]bool
 }
 
 // NewJSONStreamingReader creates a new streaming JSON reader
@@ -85,30 +134,42 @@ func NewJSONStreamingReader(path string) (*JSONStreamingReader, error) {
 		return nil, fmt.Errorf("failed to open JSON file: %w", err)
 	}
 
-	decoder := json.NewDecoder(file)
+	r, err := NewJSONStreamingReaderFrom(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	r.closer = file
+
+	return r, nil
+}
+
+// NewJSONStreamingReaderFrom creates a streaming JSON reader on top of an existing reader.
+// The caller keeps ownership of r; Close does not close it.
+func NewJSONStreamingReaderFrom(r io.Reader) (*JSONStreamingReader, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
 
 	// Check if it's an array
 	token, err := decoder.Token()
 	if err != nil {
-		file.Close()
 		return nil, fmt.Errorf("failed to read JSON: %w", err)
 	}
 
 	// If it's not a delimiter, it's a single object
 	if delim, ok := token.(json.Delim); !ok || delim != '[' {
-		file.Close()
 		return nil, fmt.Errorf("JSON streaming only supports arrays")
 	}
 
 	return &JSONStreamingReader{
-		file:    file,
 		decoder: decoder,
 		first:   true,
+		seen:    make(map[string]bool),
 	}, nil
 }
 
 // ReadRow reads the next object from the JSON array
-func (r *JSONStreamingReader) ReadRow() (map[string]string, error) {
+func (r *JSONStreamingReader) ReadRow() (map[string]interface{}, error) {
 	// Check if we've reached the end
 	if !r.decoder.More() {
 		// Consume closing bracket
@@ -116,35 +177,46 @@ func (r *JSONStreamingReader) ReadRow() (map[string]string, error) {
 		return nil, io.EOF
 	}
 
-	var obj map[string]interface{}
-	if err := r.decoder.Decode(&obj); err != nil {
+	var raw json.RawMessage
+	if err := r.decoder.Decode(&raw); err != nil {
 		return nil, fmt.Errorf("failed to decode JSON object: %w", err)
 	}
 
-	// Convert to map[string]string
-	row := make(map[string]string)
-	for k, v := range obj {
-		row[k] = fmt.Sprintf("%v", v)
+	// Keep JSON types: numbers become int64/float64/Decimal, nested values stay nested
+	keys, row, err := decodeOrderedObject(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JSON object: %w", err)
+	}
+	for _, k := range keys {
+		if !r.seen[k] {
+			r.seen[k] = true
+			r.columns = append(r.columns, k)
+		}
 	}
 
 	return row, nil
 }
 
+// Columns returns the keys seen so far, in order of first appearance.
+func (r *JSONStreamingReader) Columns() []string {
+	return r.columns
+}
+
 // Close closes the underlying file
 func (r *JSONStreamingReader) Close() error {
-	return r.file.Close()
+	return closeIfOwned(r.closer)
 }
 
 // StreamingWriter provides streaming write capabilities
 type StreamingWriter interface {
-	WriteRow(row map[string]string) error
+	WriteRow(row map[string]interface{}) error
 	Close() error
 }
 
 // CSVStreamingWriter writes CSV files row by row
 type CSVStreamingWriter struct {
-	file          *os.File
-	writer        *csv.Writer
+	closer        io.Closer
+	writer        RecordWriter
 	header        []string
 	headerWritten bool
 }
@@ -156,18 +228,30 @@ func NewCSVStreamingWriter(path string, header []string) (*CSVStreamingWriter, e
 		return nil, fmt.Errorf("failed to create CSV file: %w", err)
 	}
 
-	writer := csv.NewWriter(file)
+	w := NewCSVStreamingWriterTo(file, header)
+	w.closer = file
+
+	return w, nil
+}
+
+// NewCSVStreamingWriterTo creates a streaming CSV writer on top of an existing writer.
+// The caller keeps ownership of w; Close flushes but does not close it.
+func NewCSVStreamingWriterTo(w io.Writer, header []string) *CSVStreamingWriter {
+	return NewCSVStreamingWriterWith(csv.NewWriter(w), header)
+}
 
+// NewCSVStreamingWriterWith creates a streaming CSV writer on top of a configured csv.Writer
+// or any other record sink (see Dialect.NewWriter).
+func NewCSVStreamingWriterWith(writer RecordWriter, header []string) *CSVStreamingWriter {
 	return &CSVStreamingWriter{
-		file:          file,
 		writer:        writer,
 		header:        header,
 		headerWritten: false,
-	}, nil
+	}
 }
 
 // WriteRow writes a row to the CSV file
-func (w *CSVStreamingWriter) WriteRow(row map[string]string) error {
+func (w *CSVStreamingWriter) WriteRow(row map[string]interface{}) error {
 	if !w.headerWritten {
 		if err := w.writer.Write(w.header); err != nil {
 			return fmt.Errorf("failed to write CSV header: %w", err)
@@ -178,7 +262,7 @@ func (w *CSVStreamingWriter) WriteRow(row map[string]string) error {
 	// Convert row map to slice in header order
 	record := make([]string, len(w.header))
 	for i, col := range w.header {
-		record[i] = row[col]
+		record[i] = dataset.FormatValue(row[col])
 	}
 
 	if err := w.writer.Write(record); err != nil {
@@ -190,10 +274,174 @@ func (w *CSVStreamingWriter) WriteRow(row map[string]string) error {
 
 // Close closes the writer and flushes data
 func (w *CSVStreamingWriter) Close() error {
+	// Emit the header even when no rows were written
+	if !w.headerWritten && len(w.header) > 0 {
+		if err := w.writer.Write(w.header); err != nil {
+			closeIfOwned(w.closer)
+			return fmt.Errorf("failed to write CSV header: %w", err)
+		}
+		w.headerWritten = true
+	}
+
 	w.writer.Flush()
 	if err := w.writer.Error(); err != nil {
-		w.file.Close()
+		closeIfOwned(w.closer)
 		return fmt.Errorf("CSV writer error: %w", err)
 	}
-	return w.file.Close()
+	return closeIfOwned(w.closer)
+}
+
+// JSONStreamingWriter writes a JSON array one object at a time
+type JSONStreamingWriter struct {
+	closer io.Closer
+	writer *bufio.Writer
+	header []string
+	count  int
+
+	// Array punctuation, and the indentation of object members ("" for compact objects)
+	open, sep, end string
+	indent         string
+	object         bytes.Buffer
+	indented       bytes.Buffer
+}
+
+// NewJSONStreamingWriterTo creates a streaming JSON writer on top of an existing writer
+// that writes one compact object per line.
+// Object keys follow header order; keys missing from the header are appended sorted.
+// The caller keeps ownership of w; Close terminates the array but does not close it.
+func NewJSONStreamingWriterTo(w io.Writer, header []string) *JSONStreamingWriter {
+	return &JSONStreamingWriter{
+		writer: bufio.NewWriter(w),
+		header: header,
+		open:   "[\n  ",
+		sep:    ",\n  ",
+		end:    "\n]\n",
+	}
+}
+
+// NewJSONStreamingWriterIndent is like NewJSONStreamingWriterTo but lays the array out
+// like json.Encoder with the given number of spaces per level; 0 writes compact JSON.
+func NewJSONStreamingWriterIndent(w io.Writer, header []string, indent int) *JSONStreamingWriter {
+	if indent <= 0 {
+		return &JSONStreamingWriter{writer: bufio.NewWriter(w), header: header, open: "[", sep: ",", end: "]\n"}
+	}
+	prefix := strings.Repeat(" ", indent)
+	return &JSONStreamingWriter{
+		writer: bufio.NewWriter(w),
+		header: header,
+		open:   "[\n" + prefix,
+		sep:    ",\n" + prefix,
+		end:    "\n]\n",
+		indent: prefix,
+	}
+}
+
+// WriteRow writes a row as the next object of the JSON array
+func (w *JSONStreamingWriter) WriteRow(row map[string]interface{}) error {
+	sep := w.sep
+	if w.count == 0 {
+		sep = w.open
+	}
+	if _, err := w.writer.WriteString(sep); err != nil {
+		return fmt.Errorf("failed to write JSON row: %w", err)
+	}
+
+	keys := orderedKeys(w.header, row)
+	if w.indent == "" {
+		if err := writeObject(w.writer, keys, row); err != nil {
+			return err
+		}
+	} else {
+		w.object.Reset()
+		w.indented.Reset()
+		if err := writeObject(&w.object, keys, row); err != nil {
+			return err
+		}
+		if err := json.Indent(&w.indented, w.object.Bytes(), w.indent, w.indent); err != nil {
+			return fmt.Errorf("failed to indent JSON row: %w", err)
+		}
+		if _, err := w.writer.Write(w.indented.Bytes()); err != nil {
+			return fmt.Errorf("failed to write JSON row: %w", err)
+		}
+	}
+
+	w.count++
+	return nil
+}
+
+// Close terminates the JSON array and flushes data
+func (w *JSONStreamingWriter) Close() error {
+	tail := w.end
+	if w.count == 0 {
+		tail = "[]\n"
+	}
+	if _, err := w.writer.WriteString(tail); err != nil {
+		closeIfOwned(w.closer)
+		return fmt.Errorf("failed to terminate JSON array: %w", err)
+	}
+	if err := w.writer.Flush(); err != nil {
+		closeIfOwned(w.closer)
+		return fmt.Errorf("JSON writer error: %w", err)
+	}
+	return closeIfOwned(w.closer)
+}
+
+// orderedKeys returns the keys of row in header order, followed by any keys missing
+// from the header in sorted order.
+func orderedKeys(header []string, row map[string]interface{}) []string {
+	keys := make([]string, 0, len(row))
+	inHeader := make(map[string]bool, len(header))
+	for _, col := range header {
+		inHeader[col] = true
+		if _, ok := row[col]; ok {
+			keys = append(keys, col)
+		}
+	}
+	extra := make([]string, 0)
+	for k := range row {
+		if !inHeader[k] {
+			extra = append(extra, k)
+		}
+	}
+	sort.Strings(extra)
+	return append(keys, extra...)
+}
+
+// objectWriter is where writeObject writes, such as a *bufio.Writer or *bytes.Buffer.
+type objectWriter interface {
+	io.Writer
+	io.ByteWriter
+}
+
+// writeObject writes row as a compact JSON object with the given key order.
+func writeObject(w objectWriter, keys []string, row map[string]interface{}) error {
+	w.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return fmt.Errorf("failed to encode JSON key: %w", err)
+		}
+		value, err := json.Marshal(row[k])
+		if err != nil {
+			return fmt.Errorf("failed to encode JSON value: %w", err)
+		}
+		w.Write(key)
+		w.WriteByte(':')
+		w.Write(value)
+	}
+	if err := w.WriteByte('}'); err != nil {
+		return fmt.Errorf("failed to write JSON row: %w", err)
+	}
+	return nil
+}
+
+// closeIfOwned closes c when the stream owns the underlying resource.
+func closeIfOwned(c io.Closer) error {
+	if c == nil {
+		return nil
+	}
+	return c.Close()
 }
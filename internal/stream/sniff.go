@@ -0,0 +1,268 @@
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// SniffSize is the number of leading bytes of an input that Sniff looks at.
+const SniffSize = 1024
+
+// Encodings lists the text encodings NewDecoder accepts.
+var Encodings = []string{"utf-8", "utf-16le", "utf-16be", "iso-8859-1", "windows-1252"}
+
+// Sniffed describes the layout of a delimited file as guessed by Sniff.
+type Sniffed struct {
+	Dialect    Dialect // detected delimiter, quote, escape and line ending
+	Encoding   string  // one of Encodings
+	BOM        bool    // the input starts with a byte order mark
+	LineEnding string  // "\n", "\r\n", "\r", or "" when the sample holds a single line
+	Header     bool    // the first record looks like column names rather than data
+	Confidence float64 // 0 to 1: how consistently the sample splits into records of equal width
+}
+
+// Candidate characters, in order of preference when several fit equally well.
+var (
+	sniffDelimiters = []rune{',', ';', '\t', '|'}
+	sniffQuotes     = []rune{'"', '\''}
+	sniffEscapes    = []rune{0, '\\'}
+)
+
+// Sniff guesses the dialect, encoding and header presence of a delimited file from
+// a sample of its first bytes, normally SniffSize of them. When the sample fills
+// SniffSize bytes its last line is assumed to be cut short and is ignored.
+//
+// The delimiter, quote and escape characters are chosen by parsing the sample with
+// every candidate dialect and keeping the one that yields the most records of the
+// same width. Quote characters other than '"' are only tried when they open a field
+// in the sample, and escapes when they precede a quote, so that a malformed file is
+// not mistaken for another dialect. When no candidate splits the sample into two or more columns the
+// dialect keeps its defaults and the confidence is 0.
+func Sniff(sample []byte) Sniffed {
+	s := Sniffed{Dialect: Dialect{Delimiter: ',', Quote: '"'}}
+	s.Encoding, s.BOM = sniffEncoding(sample)
+
+	decoder, _ := NewDecoder(bytes.NewReader(sample), s.Encoding)
+	decoded, _ := io.ReadAll(decoder)
+	text := string(decoded)
+
+	crlf := strings.Count(text, "\r\n")
+	endings := map[string]int{
+		"\r\n": crlf,
+		"\n":   strings.Count(text, "\n") - crlf,
+		"\r":   strings.Count(text, "\r") - crlf,
+	}
+	for _, ending := range []string{"\n", "\r\n", "\r"} {
+		if endings[ending] > 0 && (s.LineEnding == "" || endings[ending] > endings[s.LineEnding]) {
+			s.LineEnding = ending
+		}
+	}
+	s.Dialect.CRLF = s.LineEnding == "\r\n"
+
+	if len(sample) >= SniffSize {
+		if i := strings.LastIndexAny(text, "\r\n"); i > 0 {
+			text = text[:i+1]
+		}
+	}
+
+	// Try every candidate dialect and keep the most consistent one
+	var best [][]string
+	bestWidth := 0
+	for _, delimiter := range sniffDelimiters {
+		for _, quote := range sniffQuotes {
+			if quote != '"' && !opensField(text, delimiter, quote) {
+				continue
+			}
+			for _, escape := range sniffEscapes {
+				if escape != 0 && !strings.Contains(text, string([]rune{escape, quote})) {
+					continue
+				}
+				d := Dialect{Delimiter: delimiter, Quote: quote, Escape: escape, CRLF: s.Dialect.CRLF}
+				records, width, score := scoreDialect(d, text)
+				if width >= 2 && (score > s.Confidence || (score == s.Confidence && width > bestWidth)) {
+					s.Dialect, s.Confidence = d, score
+					best, bestWidth = records, width
+				}
+			}
+		}
+	}
+
+	// A single record says little about consistency
+	if len(best) < 2 {
+		s.Confidence /= 2
+	}
+	s.Header = looksLikeHeader(best)
+	return s
+}
+
+// opensField reports whether quote appears at the start of a field in text.
+func opensField(text string, delimiter, quote rune) bool {
+	q := string(quote)
+	return strings.HasPrefix(text, q) ||
+		strings.Contains(text, string(delimiter)+q) ||
+		strings.Contains(text, "\n"+q)
+}
+
+// scoreDialect parses text in the dialect and returns the well-formed records, their
+// most common width and the share of records, malformed ones included, of that width.
+func scoreDialect(d Dialect, text string) ([][]string, int, float64) {
+	reader := d.NewReader(strings.NewReader(text))
+	var records [][]string
+	widths := make(map[int]int)
+	total := 0
+	for total <= len(text) {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		total++
+		if err != nil {
+			continue
+		}
+		records = append(records, record)
+		widths[len(record)]++
+	}
+
+	width := 0
+	for w, n := range widths {
+		if n > widths[width] || (n == widths[width] && w > width) {
+			width = w
+		}
+	}
+	if total == 0 {
+		return nil, 0, 0
+	}
+	return records, width, float64(widths[width]) / float64(total)
+}
+
+// looksLikeHeader reports whether the first record reads like column names. Each
+// column votes: for a header when its first value differs in kind (number, empty,
+// text) or, for text columns of fixed length, in length from the values below it;
+// against when the first value is a number like the rest. Ties favour a header.
+func looksLikeHeader(records [][]string) bool {
+	if len(records) < 2 {
+		return true
+	}
+
+	votes := 0
+	for col, name := range records[0] {
+		kind, length := "", -1
+		consistent := true
+		for _, record := range records[1:] {
+			if col >= len(record) || record[col] == "" {
+				continue
+			}
+			k := valueKind(record[col])
+			if kind == "" {
+				kind = k
+			}
+			if k != kind {
+				consistent = false
+				break
+			}
+			switch {
+			case length == -1:
+				length = utf8.RuneCountInString(record[col])
+			case length != utf8.RuneCountInString(record[col]):
+				length = -2
+			}
+		}
+		if !consistent || kind == "" {
+			continue
+		}
+
+		switch {
+		case valueKind(name) != kind:
+			votes++
+		case kind != "text":
+			votes--
+		case length >= 0 && utf8.RuneCountInString(name) != length:
+			votes++
+		}
+	}
+	return votes >= 0
+}
+
+// valueKind classifies a field as "empty", "number" or "text".
+func valueKind(v string) string {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return "empty"
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return "number"
+	}
+	return "text"
+}
+
+// sniffEncoding detects a byte order mark, or else guesses the encoding from the bytes.
+func sniffEncoding(sample []byte) (string, bool) {
+	switch {
+	case bytes.HasPrefix(sample, []byte("\xef\xbb\xbf")):
+		return "utf-8", true
+	case bytes.HasPrefix(sample, []byte("\xff\xfe")):
+		return "utf-16le", true
+	case bytes.HasPrefix(sample, []byte("\xfe\xff")):
+		return "utf-16be", true
+	}
+
+	// ASCII text in UTF-16 has a zero byte in every other position
+	var zeros [2]int
+	for i, b := range sample {
+		if b == 0 {
+			zeros[i%2]++
+		}
+	}
+	if pairs := len(sample) / 2; pairs > 0 {
+		if zeros[1] > pairs/4 && zeros[0] == 0 {
+			return "utf-16le", false
+		}
+		if zeros[0] > pairs/4 && zeros[1] == 0 {
+			return "utf-16be", false
+		}
+	}
+
+	// The sample may end in the middle of a multi-byte character
+	for i := len(sample) - 1; i >= 0 && i >= len(sample)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(sample[i]) {
+			if !utf8.FullRune(sample[i:]) {
+				sample = sample[:i]
+			}
+			break
+		}
+	}
+	if utf8.Valid(sample) {
+		return "utf-8", false
+	}
+	return "windows-1252", false
+}
+
+// NewDecoder returns r decoded from the named encoding (see Encodings) to UTF-8. A
+// leading byte order mark is dropped and, whatever the encoding, selects UTF-8 or
+// UTF-16 as it indicates. UTF-8 input is passed through unchanged.
+func NewDecoder(r io.Reader, encoding string) (io.Reader, error) {
+	var decoder transform.Transformer
+	switch strings.ToLower(strings.ReplaceAll(encoding, "_", "-")) {
+	case "", "utf-8", "utf8":
+		decoder = transform.Nop
+	case "utf-16le":
+		decoder = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder()
+	case "utf-16be":
+		decoder = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewDecoder()
+	case "iso-8859-1", "latin-1", "latin1":
+		decoder = charmap.ISO8859_1.NewDecoder()
+	case "windows-1252", "cp1252":
+		decoder = charmap.Windows1252.NewDecoder()
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q (supported: %s)", encoding, strings.Join(Encodings, ", "))
+	}
+	return transform.NewReader(r, unicode.BOMOverride(decoder)), nil
+}
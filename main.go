@@ -8,14 +8,21 @@ Entry point for the OmniData CLI tool.
 Responsibilities:
 - Execute the root command of the CLI.
 - Handle errors gracefully and exit with a non-zero status code if needed.
-- Ensure proper initialization of subcommands and format handlers.
+- Ensure proper initialization of subcommands, format handlers and format plugins.
 */
 
 import (
-	"omnidata/cmd" // Import the CLI commands using the module path
+	"os"
+
+	"omnidata/cmd"                // Import the CLI commands using the module path
+	_ "omnidata/internal/formats" // Register all built-in format handlers via init()
+	"omnidata/internal/plugin"
 )
 
 func main() {
+	// Register external omnidata-format-<name> plugins after the built-in formats
+	plugin.RegisterAll(os.Stderr)
+
 	// Execute the root command (defined in cmd/root.go)
 	// Errors are handled internally by cmd.Execute()
 	cmd.Execute()
@@ -0,0 +1,198 @@
+// Package omnidata is the public Go API of OmniData.
+//
+// It converts and inspects data held in any io.Reader, with the same format
+// handlers as the omnidata CLI, and returns structured results instead of
+// printing them. Every built-in format is available once the package is
+// imported; format plugins can be added with RegisterPlugins.
+//
+//	result, err := omnidata.Convert(ctx, in, out, omnidata.ConvertOptions{To: "json"})
+//	if err != nil {
+//		return err
+//	}
+//	log.Printf("converted %d rows from %s", result.RowsWritten, result.From)
+package omnidata
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+
+	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+	_ "omnidata/internal/formats" // Register all built-in format handlers via init()
+	"omnidata/internal/plugin"
+)
+
+// Options are the reader or writer options of a format, as given to --in-opt
+// and --out-opt on the command line (e.g. "delimiter": ";").
+type Options map[string]string
+
+// ArrayMode controls how lists are mapped onto columns when nested data is
+// flattened for a tabular format.
+type ArrayMode string
+
+const (
+	// ArrayIndex puts each element in its own indexed column: tags[0], tags[1].
+	ArrayIndex ArrayMode = "index"
+	// ArrayJoin joins scalar elements into a single column.
+	ArrayJoin ArrayMode = "join"
+	// ArrayExplode writes one row per element.
+	ArrayExplode ArrayMode = "explode"
+)
+
+// FlattenOptions control how nested values are flattened into columns for
+// tabular targets, and rebuilt from column names for nested ones.
+// Zero values select the CLI defaults.
+type FlattenOptions struct {
+	Separator     string    // between nested keys in column names (default ".")
+	Arrays        ArrayMode // how lists are flattened (default ArrayIndex)
+	JoinSeparator string    // between elements with ArrayJoin (default ",")
+}
+
+// ConvertOptions configure Convert.
+type ConvertOptions struct {
+	// From is the source format; it is detected from the content when empty.
+	From string
+	// To is the target format (required).
+	To string
+
+	ReaderOptions Options
+	WriterOptions Options
+
+	// Stream converts row by row with constant memory when both formats
+	// support it; otherwise the conversion happens in memory with a warning.
+	Stream bool
+
+	Flatten FlattenOptions
+	// NoFlatten keeps nested values as-is (JSON text in tabular targets).
+	NoFlatten bool
+
+	// SkipBadRecords drops records that cannot be read or written (such as a
+	// malformed CSV line) instead of failing; they are counted in
+	// ConvertResult.Rejected. When Rejects is set, each one is also written
+	// to it as a JSON line with its line or row number and the error.
+	SkipBadRecords bool
+	Rejects        io.Writer
+	// MaxErrors fails the conversion anyway once more records were dropped
+	// (0 means no limit).
+	MaxErrors int
+
+	// Schema, when set, casts every record to the declared column types, checks
+	// nullability and orders the columns as declared. Records that do not
+	// conform are bad records (see SkipBadRecords); only the Name, Type and
+	// Nullable fields of its columns are used.
+	Schema *Schema
+}
+
+// ConvertResult describes a completed conversion.
+type ConvertResult struct {
+	From        string   `json:"from"`
+	To          string   `json:"to"`
+	RowsRead    int      `json:"rows_read"`
+	RowsWritten int      `json:"rows_written"`
+	Columns     []string `json:"columns"`
+	Rejected    int      `json:"rejected"`
+	Warnings    []string `json:"warnings,omitempty"`
+}
+
+// Formats returns the names of the registered formats, sorted.
+func Formats() []string {
+	names := convert.ListFormats()
+	sort.Strings(names)
+	return names
+}
+
+// RegisterPlugins discovers external omnidata-format-<name> executables, as the
+// CLI does on start-up, and registers them as formats. It returns the names of
+// the registered plugins; problems with individual plugins are reported on warn
+// (nil discards them).
+func RegisterPlugins(warn io.Writer) []string {
+	var names []string
+	for _, p := range plugin.RegisterAll(warn) {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// Convert reads r in opts.From and writes it to w in opts.To.
+//
+// Gzip input is decompressed transparently; the output is never compressed.
+// SQL needs a database connection and cannot be converted from or to a stream.
+// When ctx is cancelled, reading and writing stop and the error wraps the
+// context's cause (context.Canceled or context.DeadlineExceeded by default).
+func Convert(ctx context.Context, r io.Reader, w io.Writer, opts ConvertOptions) (*ConvertResult, error) {
+	arrays, err := dataset.ParseArrayMode(string(opts.Flatten.Arrays))
+	if opts.Flatten.Arrays == "" {
+		arrays, err = dataset.ArrayIndex, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var schema *convert.Schema
+	if opts.Schema != nil {
+		schema = &convert.Schema{}
+		for _, col := range opts.Schema.Columns {
+			schema.Columns = append(schema.Columns, convert.SchemaColumn{
+				Name:     col.Name,
+				Type:     col.Type,
+				Nullable: col.Nullable,
+			})
+		}
+	}
+
+	result, err := convert.Transcode(ctx, r, w, convert.Options{
+		From:   strings.ToLower(opts.From),
+		To:     strings.ToLower(opts.To),
+		Stream: opts.Stream,
+		Flatten: dataset.FlattenOptions{
+			Separator:     opts.Flatten.Separator,
+			Arrays:        arrays,
+			JoinSeparator: opts.Flatten.JoinSeparator,
+		},
+		NoFlatten:  opts.NoFlatten,
+		InOptions:  formatOptions(opts.ReaderOptions),
+		OutOptions: formatOptions(opts.WriterOptions),
+		OnError:    errorPolicy(opts),
+		Schema:     schema,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConvertResult{
+		From:        result.From,
+		To:          result.To,
+		RowsRead:    result.RowsRead,
+		RowsWritten: result.RowsWritten,
+		Columns:     result.Columns,
+		Rejected:    result.Rejected,
+		Warnings:    result.Warnings,
+	}, nil
+}
+
+// errorPolicy returns the bad-record policy selected by opts.
+func errorPolicy(opts ConvertOptions) convert.ErrorPolicy {
+	switch {
+	case !opts.SkipBadRecords:
+		return convert.ErrorPolicy{}
+	case opts.Rejects != nil:
+		return convert.ErrorPolicy{Mode: convert.OnErrorQuarantine, MaxErrors: opts.MaxErrors, Rejects: opts.Rejects}
+	default:
+		return convert.ErrorPolicy{Mode: convert.OnErrorSkip, MaxErrors: opts.MaxErrors}
+	}
+}
+
+// formatOptions converts public options to format options, lowercasing the
+// names as the command line does.
+func formatOptions(opts Options) convert.FormatOptions {
+	if len(opts) == 0 {
+		return nil
+	}
+	out := make(convert.FormatOptions, len(opts))
+	for k, v := range opts {
+		out[strings.ToLower(k)] = v
+	}
+	return out
+}
@@ -0,0 +1,182 @@
+package omnidata
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"omnidata/internal/convert"
+	"omnidata/internal/inspect"
+)
+
+// ReadOptions describe how an input is read by Peek, InferSchema and Diff.
+type ReadOptions struct {
+	// Format is the input format; it is detected from the content when empty.
+	Format  string
+	Options Options
+}
+
+// Column describes a single column of a schema.
+type Column struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Nullable  bool     `json:"nullable"`
+	MinLength int      `json:"min_length"`
+	MaxLength int      `json:"max_length"`
+	Samples   []string `json:"samples,omitempty"`
+}
+
+// Schema is the inferred structure of an input.
+type Schema struct {
+	Format   string   `json:"format"`
+	RowCount int      `json:"row_count"`
+	Columns  []Column `json:"columns"`
+}
+
+// Column returns the column with the given name, or nil.
+func (s *Schema) Column(name string) *Column {
+	for i := range s.Columns {
+		if s.Columns[i].Name == name {
+			return &s.Columns[i]
+		}
+	}
+	return nil
+}
+
+// PeekResult is the schema of an input together with its first rows.
+type PeekResult struct {
+	Schema *Schema `json:"schema"`
+	// Rows holds the first rows, each mapping column names to display values.
+	Rows []map[string]string `json:"rows"`
+}
+
+// ColumnChange is a column whose type or nullability differs between two schemas.
+type ColumnChange struct {
+	Name        string `json:"name"`
+	OldType     string `json:"old_type"`
+	NewType     string `json:"new_type"`
+	OldNullable bool   `json:"old_nullable"`
+	NewNullable bool   `json:"new_nullable"`
+}
+
+// SchemaDiff lists the differences between an old and a new schema.
+// Columns appear in the order of the schema they come from.
+type SchemaDiff struct {
+	Old       *Schema        `json:"old"`
+	New       *Schema        `json:"new"`
+	Added     []Column       `json:"added"`
+	Removed   []Column       `json:"removed"`
+	Changed   []ColumnChange `json:"changed"`
+	Unchanged []Column       `json:"unchanged"`
+}
+
+// Equal reports whether both schemas have the same columns, types and nullability.
+func (d *SchemaDiff) Equal() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// InferSchema reads r and infers its schema.
+func InferSchema(ctx context.Context, r io.Reader, opts ReadOptions) (*Schema, error) {
+	result, err := Peek(ctx, r, opts, 0)
+	if err != nil {
+		return nil, err
+	}
+	return result.Schema, nil
+}
+
+// Peek reads r, infers its schema and returns it with the first rows of data.
+func Peek(ctx context.Context, r io.Reader, opts ReadOptions, rows int) (*PeekResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	input, format, err := convert.PrepareInput(convert.ContextReader(ctx, r), strings.ToLower(opts.Format))
+	if err != nil {
+		return nil, err
+	}
+	if format == "sql" {
+		return nil, fmt.Errorf("sql cannot be read from a stream")
+	}
+
+	result, err := inspect.Peek(ctx, input, "", inspect.PeekOptions{
+		Format:        format,
+		Rows:          rows,
+		ReaderOptions: formatOptions(opts.Options),
+	})
+	if err != nil {
+		return nil, convert.Stopped(ctx, err)
+	}
+	return &PeekResult{
+		Schema: newSchema(result.Schema),
+		Rows:   result.Preview,
+	}, nil
+}
+
+// Diff infers the schemas of two inputs and compares them.
+func Diff(ctx context.Context, oldData, newData io.Reader, oldOpts, newOpts ReadOptions) (*SchemaDiff, error) {
+	oldSchema, err := InferSchema(ctx, oldData, oldOpts)
+	if err != nil {
+		return nil, fmt.Errorf("old input: %w", err)
+	}
+	newSchema, err := InferSchema(ctx, newData, newOpts)
+	if err != nil {
+		return nil, fmt.Errorf("new input: %w", err)
+	}
+	return CompareSchemas(oldSchema, newSchema), nil
+}
+
+// CompareSchemas returns the differences between an old and a new schema.
+func CompareSchemas(oldSchema, newSchema *Schema) *SchemaDiff {
+	diff := &SchemaDiff{
+		Old:       oldSchema,
+		New:       newSchema,
+		Added:     []Column{},
+		Removed:   []Column{},
+		Changed:   []ColumnChange{},
+		Unchanged: []Column{},
+	}
+	for _, col := range oldSchema.Columns {
+		other := newSchema.Column(col.Name)
+		switch {
+		case other == nil:
+			diff.Removed = append(diff.Removed, col)
+		case other.Type != col.Type || other.Nullable != col.Nullable:
+			diff.Changed = append(diff.Changed, ColumnChange{
+				Name:        col.Name,
+				OldType:     col.Type,
+				NewType:     other.Type,
+				OldNullable: col.Nullable,
+				NewNullable: other.Nullable,
+			})
+		default:
+			diff.Unchanged = append(diff.Unchanged, col)
+		}
+	}
+	for _, col := range newSchema.Columns {
+		if oldSchema.Column(col.Name) == nil {
+			diff.Added = append(diff.Added, col)
+		}
+	}
+	return diff
+}
+
+// newSchema converts an inferred schema to its public form.
+func newSchema(s *inspect.Schema) *Schema {
+	schema := &Schema{
+		Format:   s.Format,
+		RowCount: s.RowCount,
+		Columns:  make([]Column, 0, len(s.Columns)),
+	}
+	for _, col := range s.Columns {
+		schema.Columns = append(schema.Columns, Column{
+			Name:      col.Name,
+			Type:      col.Type,
+			Nullable:  col.Nullable,
+			MinLength: col.MinLength,
+			MaxLength: col.MaxLength,
+			Samples:   col.SampleValues,
+		})
+	}
+	return schema
+}
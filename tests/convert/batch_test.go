@@ -0,0 +1,162 @@
+package convert_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"omnidata/internal/convert"
+)
+
+// writeBatchInputs creates a directory of CSV files, one of them malformed.
+func writeBatchInputs(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.csv":     "id,name\n1,Alice\n",
+		"b.csv":     "id,name\n2,Bob\n",
+		"bad.csv":   "id,name\n\"3,Carol\n",
+		"notes.txt": "ignored when --from is given",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+// TestIsBatch verifies that globs and directories select batch mode, and that a file
+// whose name looks like a glob is converted on its own.
+func TestIsBatch(t *testing.T) {
+	dir := t.TempDir()
+	bracketed := filepath.Join(dir, "report[1].csv")
+	if err := os.WriteFile(bracketed, []byte("id\n1\n"), 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+	cases := map[string]bool{
+		bracketed:                           false,
+		filepath.Join(dir, "report[2].csv"): true,
+		"-":                                 false,
+		"data.csv":                          false,
+		filepath.Join(dir, "*.csv"):         true,
+		filepath.Join(dir, "data-?.csv"):    true,
+		dir:                                 true,
+	}
+	for input, want := range cases {
+		if got := convert.IsBatch(convert.Options{InputFile: input}); got != want {
+			t.Errorf("IsBatch(%q) = %v; want %v", input, got, want)
+		}
+	}
+
+	output := filepath.Join(dir, "report.json")
+	if err := convert.Run(context.Background(), convert.Options{InputFile: bracketed, OutputFile: output}); err != nil {
+		t.Fatalf("converting %s failed: %v", bracketed, err)
+	}
+	if data, err := os.ReadFile(output); err != nil || !strings.Contains(string(data), `"id": "1"`) {
+		t.Errorf("unexpected output %s (%v)", data, err)
+	}
+}
+
+// TestRunBatchTemplate converts a glob with a {name} template and keeps going past a bad file.
+func TestRunBatchTemplate(t *testing.T) {
+	dir := writeBatchInputs(t)
+	outDir := t.TempDir()
+
+	result, err := convert.RunBatch(context.Background(), convert.Options{
+		InputFile:  filepath.Join(dir, "*.csv"),
+		OutputFile: filepath.Join(outDir, "nested", "{name}.json"),
+		Workers:    2,
+	})
+	if err != nil {
+		t.Fatalf("RunBatch failed: %v", err)
+	}
+
+	if len(result.Items) != 3 || result.Failed != 1 {
+		t.Fatalf("expected 3 files with 1 failure, got %d with %d", len(result.Items), result.Failed)
+	}
+	if !strings.HasSuffix(result.Items[2].Input, "bad.csv") || result.Items[2].Err == nil {
+		t.Errorf("expected bad.csv to fail in input order, got %+v", result.Items[2])
+	}
+
+	for _, name := range []string{"a.json", "b.json"} {
+		data, err := os.ReadFile(filepath.Join(outDir, "nested", name))
+		if err != nil {
+			t.Fatalf("missing output %s: %v", name, err)
+		}
+		if !strings.Contains(string(data), `"name"`) {
+			t.Errorf("unexpected %s content: %s", name, data)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "nested", "bad.json")); err == nil {
+		t.Error("a failed conversion should not leave an output behind")
+	}
+
+	var summary bytes.Buffer
+	result.WriteSummary(&summary)
+	if !strings.Contains(summary.String(), "Converted 2 of 3 files (1 failed)") {
+		t.Errorf("unexpected summary:\n%s", summary.String())
+	}
+}
+
+// TestRunBatchDirectory converts a directory into an output directory named by --to.
+func TestRunBatchDirectory(t *testing.T) {
+	dir := writeBatchInputs(t)
+	outDir := t.TempDir()
+
+	result, err := convert.RunBatch(context.Background(), convert.Options{
+		InputFile:  dir,
+		OutputFile: outDir,
+		From:       "csv",
+		To:         "yaml",
+	})
+	if err != nil {
+		t.Fatalf("RunBatch failed: %v", err)
+	}
+	if len(result.Items) != 3 {
+		t.Fatalf("expected only the CSV files to be converted, got %d items", len(result.Items))
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "a.yaml")); err != nil {
+		t.Errorf("expected a.yaml in the output directory: %v", err)
+	}
+}
+
+// TestRunBatchErrors verifies the setup errors of a batch.
+func TestRunBatchErrors(t *testing.T) {
+	dir := writeBatchInputs(t)
+
+	cases := map[string]convert.Options{
+		"no input files match":    {InputFile: filepath.Join(dir, "*.parquet"), OutputFile: "out/"},
+		"must be a directory":     {InputFile: filepath.Join(dir, "*.csv"), OutputFile: filepath.Join(dir, "all.json")},
+		"without a target format": {InputFile: filepath.Join(dir, "*.csv"), OutputFile: t.TempDir()},
+		"not STDOUT":              {InputFile: filepath.Join(dir, "*.csv"), OutputFile: "-"},
+	}
+	for want, opts := range cases {
+		_, err := convert.RunBatch(context.Background(), opts)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got %v", want, err)
+		}
+	}
+}
+
+// TestRunBatchOutputCollision verifies that two inputs never write the same output.
+func TestRunBatchOutputCollision(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "data.csv"), []byte("id\n1\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "data.json"), []byte(`[{"id": 2}]`), 0644)
+
+	result, err := convert.RunBatch(context.Background(), convert.Options{
+		InputFile:  dir,
+		OutputFile: t.TempDir(),
+		To:         "yaml",
+	})
+	if err != nil {
+		t.Fatalf("RunBatch failed: %v", err)
+	}
+	if result.Failed != 1 || result.Items[1].Err == nil ||
+		!strings.Contains(result.Items[1].Err.Error(), "also produced from") {
+		t.Errorf("expected the second input to be rejected, got %+v", result.Items)
+	}
+}
@@ -0,0 +1,98 @@
+package convert_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"omnidata/internal/convert"
+)
+
+// cancellingReader cancels its context once the first chunk has been read.
+type cancellingReader struct {
+	r      io.Reader
+	cancel context.CancelFunc
+}
+
+func (c *cancellingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p[:min(len(p), 64)])
+	c.cancel()
+	return n, err
+}
+
+// TestRunCancelled verifies that a cancelled conversion reports the cause and
+// leaves neither an output nor temporary files behind.
+func TestRunCancelled(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.csv")
+	if err := os.WriteFile(input, []byte("name\nAlice\nBob\n"), 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+
+	interrupted := errors.New("interrupted")
+	for _, stream := range []bool{false, true} {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		cancel(interrupted)
+
+		err := convert.Run(ctx, convert.Options{
+			InputFile:  input,
+			OutputFile: filepath.Join(dir, "out.json"),
+			Stream:     stream,
+		})
+		if !errors.Is(err, interrupted) || !strings.Contains(err.Error(), "conversion stopped: interrupted") {
+			t.Errorf("stream=%v: expected the conversion to be stopped, got %v", stream, err)
+		}
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected only the input to remain, found %d entries", len(entries))
+	}
+}
+
+// TestTranscodeCancelledMidStream verifies that a cancellation stops a conversion
+// that is already reading.
+func TestTranscodeCancelledMidStream(t *testing.T) {
+	input := "id\n" + strings.Repeat("1\n", 10000)
+	for _, stream := range []bool{false, true} {
+		ctx, cancel := context.WithCancel(context.Background())
+		r := &cancellingReader{r: strings.NewReader(input), cancel: cancel}
+
+		_, err := convert.Transcode(ctx, r, io.Discard, convert.Options{From: "csv", To: "json", Stream: stream})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("stream=%v: expected context.Canceled, got %v", stream, err)
+		}
+	}
+}
+
+// TestRunBatchCancelled verifies that pending files of a cancelled batch fail without running.
+func TestRunBatchCancelled(t *testing.T) {
+	dir := writeBatchInputs(t)
+	out := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := convert.RunBatch(ctx, convert.Options{
+		InputFile:  filepath.Join(dir, "*.csv"),
+		OutputFile: out + "/",
+		To:         "json",
+	})
+	if err != nil {
+		t.Fatalf("RunBatch failed: %v", err)
+	}
+	if result.Failed != len(result.Items) {
+		t.Errorf("expected every file to fail, got %d of %d", result.Failed, len(result.Items))
+	}
+	for _, item := range result.Items {
+		if !errors.Is(item.Err, context.Canceled) {
+			t.Errorf("%s: expected context.Canceled, got %v", item.Input, item.Err)
+		}
+	}
+	if entries, _ := os.ReadDir(out); len(entries) != 0 {
+		t.Errorf("expected no outputs, found %d", len(entries))
+	}
+}
@@ -0,0 +1,95 @@
+package convert_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"omnidata/internal/convert"
+)
+
+// TestDetectFormatFromPath verifies extension matching, including compressed files.
+func TestDetectFormatFromPath(t *testing.T) {
+	cases := map[string]string{
+		"data.csv":         "csv",
+		"DATA.JSON":        "json",
+		"config.yml":       "yaml",
+		"export.csv.gz":    "csv",
+		"report.xlsx":      "xlsx",
+		"events.parquet":   "parquet",
+		"dir/records.avro": "avro",
+	}
+	for path, want := range cases {
+		got, ok := convert.DetectFormatFromPath(path)
+		if !ok || got != want {
+			t.Errorf("DetectFormatFromPath(%q) = %q, %v; want %q", path, got, ok, want)
+		}
+	}
+
+	if _, ok := convert.DetectFormatFromPath("README"); ok {
+		t.Error("expected no format for a path without extension")
+	}
+}
+
+// TestDetectFormatFromContent verifies magic-byte sniffing, including Gzip content.
+func TestDetectFormatFromContent(t *testing.T) {
+	cases := map[string][]byte{
+		"json":    []byte("  \n[{\"a\": 1}]"),
+		"xml":     []byte("<?xml version=\"1.0\"?><records/>"),
+		"xlsx":    []byte("PK\x03\x04rest-of-zip"),
+		"parquet": []byte("PAR1...."),
+		"avro":    []byte("Obj\x01...."),
+	}
+	for want, head := range cases {
+		got, ok := convert.DetectFormatFromContent(head)
+		if !ok || got != want {
+			t.Errorf("DetectFormatFromContent(%q) = %q, %v; want %q", head, got, ok, want)
+		}
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(`{"compressed": true}`))
+	gz.Close()
+	if got, ok := convert.DetectFormatFromContent(buf.Bytes()); !ok || got != "json" {
+		t.Errorf("expected gzip JSON to be detected as json, got %q, %v", got, ok)
+	}
+}
+
+// TestRunDetectsFormats verifies that convert works without explicit formats,
+// sniffing the content of a file that has no extension.
+func TestRunDetectsFormats(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "payload")
+	if err := os.WriteFile(input, []byte(`[{"name": "Alice"}]`), 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+
+	output := filepath.Join(dir, "out.csv")
+	if err := convert.Run(context.Background(), convert.Options{InputFile: input, OutputFile: output}); err != nil {
+		t.Fatalf("conversion with detected formats failed: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if string(data) != "name\nAlice\n" {
+		t.Errorf("unexpected output: %q", string(data))
+	}
+
+	// Explicit formats still override detection: the JSON payload is parsed as CSV
+	err = convert.Run(context.Background(), convert.Options{InputFile: input, OutputFile: filepath.Join(dir, "out2.csv"), From: "csv"})
+	if err == nil || !strings.Contains(err.Error(), "CSV") {
+		t.Errorf("expected the explicit csv reader to be used, got %v", err)
+	}
+
+	// STDOUT has no name to detect the target format from
+	err = convert.Run(context.Background(), convert.Options{InputFile: input, OutputFile: "-"})
+	if err == nil || !strings.Contains(err.Error(), "--to") {
+		t.Errorf("expected a hint to use --to, got %v", err)
+	}
+}
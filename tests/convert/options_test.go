@@ -0,0 +1,97 @@
+package convert_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"omnidata/internal/convert"
+)
+
+// TestParseFormatOptions verifies key=value parsing, including empty values and '=' in values.
+func TestParseFormatOptions(t *testing.T) {
+	opts, err := convert.ParseFormatOptions([]string{"Delimiter=;", "comment=", "root=a=b"})
+	if err != nil {
+		t.Fatalf("ParseFormatOptions failed: %v", err)
+	}
+	if opts.Char("delimiter", ',') != ';' {
+		t.Errorf("delimiter = %q, want ';'", opts.Char("delimiter", ','))
+	}
+	if opts.Char("comment", '#') != 0 {
+		t.Errorf("empty comment should disable the option, got %q", opts.Char("comment", '#'))
+	}
+	if opts.String("root", "") != "a=b" {
+		t.Errorf("root = %q, want a=b", opts.String("root", ""))
+	}
+	if opts.Int("indent", 2) != 2 {
+		t.Error("missing option should fall back to the default")
+	}
+
+	if _, err := convert.ParseFormatOptions([]string{"novalue"}); err == nil {
+		t.Error("expected error for option without '='")
+	}
+}
+
+// TestValidateOptions verifies that unknown options and badly typed values are rejected.
+func TestValidateOptions(t *testing.T) {
+	handler, ok := convert.GetFormat("csv")
+	if !ok {
+		t.Fatal("csv format not registered")
+	}
+
+	if err := handler.ValidateReaderOptions(convert.FormatOptions{"delimiter": `\t`, "lazy_quotes": "true"}); err != nil {
+		t.Errorf("valid options rejected: %v", err)
+	}
+
+	err := handler.ValidateReaderOptions(convert.FormatOptions{"delim": ";"})
+	if err == nil || !strings.Contains(err.Error(), "delimiter") {
+		t.Errorf("expected unknown option error listing accepted options, got %v", err)
+	}
+	if err := handler.ValidateReaderOptions(convert.FormatOptions{"delimiter": ";;"}); err == nil {
+		t.Error("expected error for multi-character delimiter")
+	}
+	if err := handler.ValidateWriterOptions(convert.FormatOptions{"crlf": "maybe"}); err == nil {
+		t.Error("expected error for invalid boolean")
+	}
+}
+
+// TestRunWithFormatOptions verifies that reader and writer options reach the handlers.
+func TestRunWithFormatOptions(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.csv")
+	if err := os.WriteFile(input, []byte("name;city\nAlice;Paris\n"), 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+
+	output := filepath.Join(dir, "out.xml")
+	opts := convert.Options{
+		InputFile:  input,
+		OutputFile: output,
+		InOptions:  convert.FormatOptions{"delimiter": ";"},
+		OutOptions: convert.FormatOptions{"root": "people", "row": "person"},
+	}
+	if err := convert.Run(context.Background(), opts); err != nil {
+		t.Fatalf("conversion failed: %v", err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if !strings.Contains(string(data), "<people>") || !strings.Contains(string(data), "<person>") ||
+		!strings.Contains(string(data), "<city>Paris</city>") {
+		t.Errorf("unexpected XML output:\n%s", data)
+	}
+
+	// Invalid options are rejected before anything is written
+	opts.OutputFile = filepath.Join(dir, "bad.xml")
+	opts.OutOptions = convert.FormatOptions{"sheet": "x"}
+	if err := convert.Run(context.Background(), opts); err == nil {
+		t.Error("expected error for an option the xml writer does not accept")
+	}
+	if _, err := os.Stat(opts.OutputFile); err == nil {
+		t.Error("output should not be created when options are invalid")
+	}
+}
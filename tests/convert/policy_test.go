@@ -0,0 +1,141 @@
+package convert_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"omnidata/internal/convert"
+)
+
+// badCSV has a malformed record spanning lines 3-4 and a record with too many fields on line 6.
+const badCSV = "id,name\n1,Alice\n2,\"Bob\n3,Carol\"x\n4,Dave\n5,Eve,extra\n6,Frank\n"
+
+// TestParseErrorMode verifies the accepted --on-error values.
+func TestParseErrorMode(t *testing.T) {
+	for input, want := range map[string]convert.ErrorMode{
+		"":           convert.OnErrorFail,
+		"skip":       convert.OnErrorSkip,
+		"Quarantine": convert.OnErrorQuarantine,
+	} {
+		if got, err := convert.ParseErrorMode(input); err != nil || got != want {
+			t.Errorf("ParseErrorMode(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := convert.ParseErrorMode("ignore"); err == nil {
+		t.Error("expected an unknown mode to be rejected")
+	}
+
+	policy := convert.ErrorPolicy{Mode: convert.OnErrorQuarantine}
+	if err := policy.Validate(); err == nil || !strings.Contains(err.Error(), "rejects output") {
+		t.Errorf("expected quarantine without a rejects output to be invalid, got %v", err)
+	}
+}
+
+// TestTranscodeErrorPolicy converts a CSV with bad records under each policy, in memory and streaming.
+func TestTranscodeErrorPolicy(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		var out bytes.Buffer
+		_, err := convert.Transcode(context.Background(), strings.NewReader(badCSV), &out,
+			convert.Options{From: "csv", To: "json", Stream: streaming})
+		if err == nil || !strings.Contains(err.Error(), "extraneous or missing \" in quoted-field") {
+			t.Errorf("stream=%v: expected the default policy to fail, got %v", streaming, err)
+		}
+
+		out.Reset()
+		var rejects bytes.Buffer
+		result, err := convert.Transcode(context.Background(), strings.NewReader(badCSV), &out, convert.Options{
+			From:    "csv",
+			To:      "json",
+			Stream:  streaming,
+			OnError: convert.ErrorPolicy{Mode: convert.OnErrorQuarantine, Rejects: &rejects},
+		})
+		if err != nil {
+			t.Fatalf("stream=%v: quarantine failed: %v", streaming, err)
+		}
+		if result.RowsWritten != 3 || result.Rejected != 2 {
+			t.Errorf("stream=%v: expected 3 rows written and 2 rejected, got %d and %d",
+				streaming, result.RowsWritten, result.Rejected)
+		}
+		if strings.Contains(out.String(), "Bob") || !strings.Contains(out.String(), "Frank") {
+			t.Errorf("stream=%v: unexpected output:\n%s", streaming, out.String())
+		}
+
+		var rejected []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(rejects.String()), "\n") {
+			var rec map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				t.Fatalf("stream=%v: invalid rejects line %q: %v", streaming, line, err)
+			}
+			rejected = append(rejected, rec)
+		}
+		if len(rejected) != 2 {
+			t.Fatalf("stream=%v: expected 2 rejects, got %d:\n%s", streaming, len(rejected), rejects.String())
+		}
+		if rejected[0]["line"] != 3.0 || rejected[0]["row"] != 2.0 || rejected[0]["record"] != "2,\"Bob\n3,Carol\"x" {
+			t.Errorf("stream=%v: unexpected first reject: %v", streaming, rejected[0])
+		}
+		if rejected[1]["line"] != 6.0 || rejected[1]["record"] != "5,Eve,extra" ||
+			!strings.Contains(rejected[1]["error"].(string), "wrong number of fields") {
+			t.Errorf("stream=%v: unexpected second reject: %v", streaming, rejected[1])
+		}
+
+		_, err = convert.Transcode(context.Background(), strings.NewReader(badCSV), &out, convert.Options{
+			From:    "csv",
+			To:      "json",
+			Stream:  streaming,
+			OnError: convert.ErrorPolicy{Mode: convert.OnErrorSkip, MaxErrors: 1},
+		})
+		if err == nil || !strings.Contains(err.Error(), "more than 1 bad records") {
+			t.Errorf("stream=%v: expected --max-errors to stop the conversion, got %v", streaming, err)
+		}
+	}
+}
+
+// TestRunSkipFailedInserts verifies that rows rejected by the database are skipped and the rest committed.
+func TestRunSkipFailedInserts(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE people (id INTEGER PRIMARY KEY, name TEXT NOT NULL)"); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	input := filepath.Join(dir, "people.csv")
+	os.WriteFile(input, []byte("id,name\n1,Alice\n1,Duplicate\n2,\n3,Carol\n"), 0644)
+
+	var rejects bytes.Buffer
+	err = convert.Run(context.Background(), convert.Options{
+		InputFile:  input,
+		OutputFile: "sqlite3://" + dbPath + "?table=people",
+		To:         "sql",
+		Quiet:      true,
+		OnError:    convert.ErrorPolicy{Mode: convert.OnErrorQuarantine, Rejects: &rejects},
+	})
+	if err != nil {
+		t.Fatalf("conversion failed: %v", err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM people").Scan(&count); err != nil {
+		t.Fatalf("failed to count rows: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected Alice and Carol to be inserted, found %d rows", count)
+	}
+
+	lines := strings.Split(strings.TrimSpace(rejects.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"row":2`) || !strings.Contains(lines[0], `"name":"Duplicate"`) ||
+		!strings.Contains(lines[1], `"row":3`) || !strings.Contains(lines[1], "NOT NULL") {
+		t.Errorf("unexpected rejects:\n%s", rejects.String())
+	}
+}
@@ -1,12 +1,15 @@
 package convert_test
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
 	_ "omnidata/internal/formats" // triggers init() for format registration
 )
 
@@ -36,7 +39,7 @@ func TestRunDryRun(t *testing.T) {
 		DryRun:     true,
 	}
 
-	if err := convert.Run(opts); err != nil {
+	if err := convert.Run(context.Background(), opts); err != nil {
 		t.Fatalf("dry-run failed: %v", err)
 	}
 
@@ -62,7 +65,7 @@ func TestRunCSVtoJSON(t *testing.T) {
 		DryRun:     false,
 	}
 
-	if err := convert.Run(opts); err != nil {
+	if err := convert.Run(context.Background(), opts); err != nil {
 		t.Fatalf("conversion failed: %v", err)
 	}
 
@@ -93,7 +96,7 @@ func TestRunGzip(t *testing.T) {
 		To:         "csv",
 	}
 
-	if err := convert.Run(opts); err != nil {
+	if err := convert.Run(context.Background(), opts); err != nil {
 		t.Fatalf("conversion to gzip failed: %v", err)
 	}
 
@@ -118,7 +121,7 @@ func TestRunGzip(t *testing.T) {
 		To:         "csv",
 	}
 
-	if err := convert.Run(opts2); err != nil {
+	if err := convert.Run(context.Background(), opts2); err != nil {
 		t.Fatalf("conversion from gzip failed: %v", err)
 	}
 
@@ -144,21 +147,21 @@ func TestRun_Errors(t *testing.T) {
 		From:       "csv",
 		To:         "json",
 	}
-	err := convert.Run(opts)
+	err := convert.Run(context.Background(), opts)
 	if err == nil {
 		t.Error("expected error for missing input file, got nil")
 	}
 	// Unsupported from format
 	opts.InputFile = input
 	opts.From = "NOTREAL"
-	err = convert.Run(opts)
+	err = convert.Run(context.Background(), opts)
 	if err == nil {
 		t.Error("expected error for unknown input format, got nil")
 	}
 	// Unsupported to format
 	opts.From = "csv"
 	opts.To = "UNK"
-	err = convert.Run(opts)
+	err = convert.Run(context.Background(), opts)
 	if err == nil {
 		t.Error("expected error for unknown output format, got nil")
 	}
@@ -168,7 +171,7 @@ func TestRun_Errors(t *testing.T) {
 	defer os.Remove(f.Name())
 	opts.To = "json"
 	opts.OutputFile = f.Name()
-	err = convert.Run(opts)
+	err = convert.Run(context.Background(), opts)
 	if err == nil {
 		t.Error("expected error for existing output file, got nil")
 	}
@@ -177,21 +180,234 @@ func TestRun_Errors(t *testing.T) {
 	opts.OutputFile = ""
 	opts.From = "xlsx"
 	opts.To = "csv"
-	err = convert.Run(opts)
+	err = convert.Run(context.Background(), opts)
 	if err == nil {
 		t.Error("expected error for reading xlsx from STDIN, got nil")
 	}
 	opts.From = "csv"
 	opts.To = "xlsx"
-	err = convert.Run(opts)
+	err = convert.Run(context.Background(), opts)
 	if err == nil {
 		t.Error("expected error for writing xlsx to STDOUT, got nil")
 	}
 	// Both formats unknown
 	opts.From = "?"
 	opts.To = "?"
-	err = convert.Run(opts)
+	err = convert.Run(context.Background(), opts)
 	if err == nil {
 		t.Error("expected error for both formats unknown, got nil")
 	}
 }
+
+// TestRunConversionMatrix converts a sample dataset between every pair of tabular formats
+// and verifies that the data survives each hop.
+func TestRunConversionMatrix(t *testing.T) {
+	formats := []string{"csv", "json", "yaml", "xml", "xlsx"}
+	dir := t.TempDir()
+
+	// Seed one input file per format from a CSV source
+	source := tempFile(t, []byte("name,city\nAlice,Paris\nBob,Berlin\n"))
+	defer os.Remove(source)
+
+	inputs := make(map[string]string)
+	for _, format := range formats {
+		path := filepath.Join(dir, "seed."+format)
+		opts := convert.Options{InputFile: source, OutputFile: path, From: "csv", To: format}
+		if err := convert.Run(context.Background(), opts); err != nil {
+			t.Fatalf("seeding %s failed: %v", format, err)
+		}
+		inputs[format] = path
+	}
+
+	for _, from := range formats {
+		for _, to := range formats {
+			output := filepath.Join(dir, from+"_to_"+to+"."+to)
+			opts := convert.Options{InputFile: inputs[from], OutputFile: output, From: from, To: to}
+			if err := convert.Run(context.Background(), opts); err != nil {
+				t.Errorf("%s -> %s failed: %v", from, to, err)
+				continue
+			}
+
+			// Read the result back as CSV to check the content
+			check := filepath.Join(dir, from+"_to_"+to+"_check.csv")
+			back := convert.Options{InputFile: output, OutputFile: check, From: to, To: "csv"}
+			if err := convert.Run(context.Background(), back); err != nil {
+				t.Errorf("%s -> %s readback failed: %v", from, to, err)
+				continue
+			}
+			data, err := os.ReadFile(check)
+			if err != nil {
+				t.Fatalf("failed to read check file: %v", err)
+			}
+			content := string(data)
+			if !strings.Contains(content, "Alice") || !strings.Contains(content, "Berlin") {
+				t.Errorf("%s -> %s lost data, got:\n%s", from, to, content)
+			}
+		}
+	}
+}
+
+// TestRunStream verifies row-by-row conversion in both directions, including Gzip.
+func TestRunStream(t *testing.T) {
+	dir := t.TempDir()
+	inputCSV := tempFile(t, []byte("name,age\nAlice,30\nBob,25\n"))
+	defer os.Remove(inputCSV)
+
+	outputJSON := filepath.Join(dir, "out.json")
+	opts := convert.Options{InputFile: inputCSV, OutputFile: outputJSON, From: "csv", To: "json", Stream: true}
+	if err := convert.Run(context.Background(), opts); err != nil {
+		t.Fatalf("streaming CSV -> JSON failed: %v", err)
+	}
+	data, err := os.ReadFile(outputJSON)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if !strings.Contains(string(data), `"name": "Bob"`) {
+		t.Errorf("unexpected JSON output: %s", data)
+	}
+
+	outputGZ := filepath.Join(dir, "back.csv.gz")
+	opts = convert.Options{InputFile: outputJSON, OutputFile: outputGZ, From: "json", To: "csv", Stream: true}
+	if err := convert.Run(context.Background(), opts); err != nil {
+		t.Fatalf("streaming JSON -> CSV.gz failed: %v", err)
+	}
+
+	outputCSV := filepath.Join(dir, "back.csv")
+	opts = convert.Options{InputFile: outputGZ, OutputFile: outputCSV, From: "csv", To: "csv", Stream: true}
+	if err := convert.Run(context.Background(), opts); err != nil {
+		t.Fatalf("streaming CSV.gz -> CSV failed: %v", err)
+	}
+	data, err = os.ReadFile(outputCSV)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if string(data) != "name,age\nAlice,30\nBob,25\n" {
+		t.Errorf("unexpected CSV output: %q", string(data))
+	}
+
+	// Formats without streaming support fall back to the in-memory path
+	outputYAML := filepath.Join(dir, "out.yaml")
+	opts = convert.Options{InputFile: inputCSV, OutputFile: outputYAML, From: "csv", To: "yaml", Stream: true}
+	if err := convert.Run(context.Background(), opts); err != nil {
+		t.Fatalf("streaming fallback failed: %v", err)
+	}
+}
+
+// TestRunFlatten verifies that nested JSON is flattened for CSV and rebuilt on the way back,
+// both in memory and in streaming mode.
+func TestRunFlatten(t *testing.T) {
+	dir := t.TempDir()
+	input := tempFile(t, []byte(`[{"id":1,"user":{"name":"Alice"},"tags":["a","b"]},{"id":2,"user":{"name":"Bob"},"tags":["c"]}]`))
+	defer os.Remove(input)
+
+	for i, streaming := range []bool{false, true} {
+		outputCSV := filepath.Join(dir, fmt.Sprintf("flat%d.csv", i))
+		opts := convert.Options{InputFile: input, OutputFile: outputCSV, From: "json", To: "csv", Stream: streaming}
+		if err := convert.Run(context.Background(), opts); err != nil {
+			t.Fatalf("JSON -> CSV (stream=%v) failed: %v", streaming, err)
+		}
+		data, err := os.ReadFile(outputCSV)
+		if err != nil {
+			t.Fatalf("failed to read output: %v", err)
+		}
+		if !strings.HasPrefix(string(data), "id,user.name,tags[0],tags[1]\n1,Alice,a,b\n") {
+			t.Errorf("unexpected flattened CSV (stream=%v): %q", streaming, string(data))
+		}
+
+		outputJSON := filepath.Join(dir, fmt.Sprintf("nested%d.json", i))
+		opts = convert.Options{InputFile: outputCSV, OutputFile: outputJSON, From: "csv", To: "json", Stream: streaming}
+		if err := convert.Run(context.Background(), opts); err != nil {
+			t.Fatalf("CSV -> JSON (stream=%v) failed: %v", streaming, err)
+		}
+		data, err = os.ReadFile(outputJSON)
+		if err != nil {
+			t.Fatalf("failed to read output: %v", err)
+		}
+		compact := strings.Join(strings.Fields(string(data)), "")
+		if !strings.Contains(compact, `"user":{"name":"Bob"},"tags":["c"]`) {
+			t.Errorf("unexpected unflattened JSON (stream=%v): %s", streaming, data)
+		}
+	}
+
+	// Explode mode writes one row per array element
+	outputCSV := filepath.Join(dir, "exploded.csv")
+	opts := convert.Options{
+		InputFile: input, OutputFile: outputCSV, From: "json", To: "csv",
+		Flatten: dataset.FlattenOptions{Arrays: dataset.ArrayExplode},
+	}
+	if err := convert.Run(context.Background(), opts); err != nil {
+		t.Fatalf("exploding JSON -> CSV failed: %v", err)
+	}
+	data, err := os.ReadFile(outputCSV)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if string(data) != "id,user.name,tags\n1,Alice,a\n1,Alice,b\n2,Bob,c\n" {
+		t.Errorf("unexpected exploded CSV: %q", string(data))
+	}
+
+	// Records gaining columns keep them grouped in memory and are refused when streamed
+	mixed := `[{"id":1,"tags":["a"],"user":null},{"id":2,"tags":["b","c"],"user":{"name":"Bob"}}]`
+	var out strings.Builder
+	if _, err := convert.Transcode(context.Background(), strings.NewReader(mixed), &out, convert.Options{From: "json", To: "csv"}); err != nil {
+		t.Fatalf("mixed JSON -> CSV failed: %v", err)
+	}
+	if out.String() != "id,tags[0],tags[1],user.name\n1,a,,\n2,b,c,Bob\n" {
+		t.Errorf("unexpected mixed CSV: %q", out.String())
+	}
+	_, err = convert.Transcode(context.Background(), strings.NewReader(mixed), &strings.Builder{}, convert.Options{From: "json", To: "csv", Stream: true})
+	if err == nil || !strings.Contains(err.Error(), `has a value for column "tags[1]"`) {
+		t.Errorf("expected the streamed conversion to refuse tags[1], got %v", err)
+	}
+}
+
+// TestRunForceAndAtomicWrites verifies that existing outputs are only replaced with Force,
+// and that a failed conversion leaves the previous output and no temporary files behind.
+func TestRunForceAndAtomicWrites(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.csv")
+	if err := os.WriteFile(input, []byte("name\nAlice\n"), 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+	output := filepath.Join(dir, "out.csv")
+	if err := os.WriteFile(output, []byte("previous\n"), 0600); err != nil {
+		t.Fatalf("failed to write existing output: %v", err)
+	}
+
+	opts := convert.Options{InputFile: input, OutputFile: output}
+	if err := convert.Run(context.Background(), opts); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Fatalf("expected existing output to be refused, got %v", err)
+	}
+
+	// A writer failure (a quote is not a valid delimiter) must not touch the existing file
+	opts.Force = true
+	opts.OutOptions = convert.FormatOptions{"delimiter": `"`}
+	if err := convert.Run(context.Background(), opts); err == nil {
+		t.Fatal("expected the conversion to fail")
+	}
+	data, _ := os.ReadFile(output)
+	if string(data) != "previous\n" {
+		t.Errorf("failed conversion modified the output: %q", string(data))
+	}
+
+	opts.OutOptions = nil
+	if err := convert.Run(context.Background(), opts); err != nil {
+		t.Fatalf("forced conversion failed: %v", err)
+	}
+	data, _ = os.ReadFile(output)
+	if string(data) != "name\nAlice\n" {
+		t.Errorf("unexpected output after --force: %q", string(data))
+	}
+	if info, err := os.Stat(output); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("replaced output should keep its permissions, got %v (err %v)", info.Mode().Perm(), err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		names := make([]string, len(entries))
+		for i, e := range entries {
+			names[i] = e.Name()
+		}
+		t.Errorf("temporary files left behind: %v", names)
+	}
+}
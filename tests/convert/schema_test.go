@@ -0,0 +1,141 @@
+package convert_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+	"omnidata/internal/inspect"
+	"omnidata/internal/output"
+)
+
+// schemaCSV has a non-integer id on row 2, an empty non-nullable name on row 3 and an invalid date on row 4.
+const schemaCSV = "name,id,joined\nAlice,1,2024-01-02\nBob,x,2024-02-03\n,3,\nDan,4,2024-13-01\nEve,5,\n"
+
+const handSchema = `{"columns": [
+	{"name": "id", "type": "integer", "nullable": false},
+	{"name": "name", "type": "string", "nullable": false},
+	{"name": "joined", "type": "date"},
+	{"name": "note"}
+]}`
+
+// TestParseSchema verifies hand-written schemas and the output of the JSON schema formatter.
+func TestParseSchema(t *testing.T) {
+	schema, err := convert.ParseSchema([]byte(handSchema))
+	if err != nil {
+		t.Fatalf("ParseSchema failed: %v", err)
+	}
+	if len(schema.Columns) != 4 || schema.Columns[0].Nullable || !schema.Columns[3].Nullable {
+		t.Errorf("unexpected columns: %+v", schema.Columns)
+	}
+
+	ds := dataset.FromRows([][]string{{"id", "score"}, {"1", "9.5"}, {"2", ""}})
+	content, err := (&output.JSONFormatter{}).FormatSchema(inspect.InferDatasetSchema(ds, "csv"))
+	if err != nil {
+		t.Fatalf("FormatSchema failed: %v", err)
+	}
+	schema, err = convert.ParseSchema([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseSchema failed on formatter output: %v\n%s", err, content)
+	}
+	if len(schema.Columns) != 2 || schema.Columns[0].Name != "id" || !schema.Columns[1].Nullable {
+		t.Errorf("unexpected columns from formatter output: %+v", schema.Columns)
+	}
+
+	for input, want := range map[string]string{
+		`{"columns": []}`: "no columns",
+		`{"columns": [{"name": "a", "type": "varchar"}]}`: `unknown type "varchar"`,
+		`{"columns": [{"name": "a"}, {"name": "a"}]}`:     "declared twice",
+	} {
+		if _, err := convert.ParseSchema([]byte(input)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseSchema(%s): expected error containing %q, got %v", input, want, err)
+		}
+	}
+}
+
+// TestTranscodeSchema casts CSV text to the declared types, in memory and streaming.
+func TestTranscodeSchema(t *testing.T) {
+	schema, _ := convert.ParseSchema([]byte(handSchema))
+
+	for _, streaming := range []bool{false, true} {
+		var out bytes.Buffer
+		_, err := convert.Transcode(context.Background(), strings.NewReader(schemaCSV), &out,
+			convert.Options{From: "csv", To: "json", Stream: streaming, Schema: schema})
+		if err == nil || !strings.Contains(err.Error(), `row 2: column "id": cannot cast string "x" to integer`) {
+			t.Errorf("stream=%v: expected the type mismatch to fail the conversion, got %v", streaming, err)
+		}
+
+		out.Reset()
+		var rejects bytes.Buffer
+		result, err := convert.Transcode(context.Background(), strings.NewReader(schemaCSV), &out, convert.Options{
+			From:    "csv",
+			To:      "json",
+			Stream:  streaming,
+			Schema:  schema,
+			OnError: convert.ErrorPolicy{Mode: convert.OnErrorQuarantine, Rejects: &rejects},
+		})
+		if err != nil {
+			t.Fatalf("stream=%v: conversion failed: %v", streaming, err)
+		}
+		if result.RowsRead != 5 || result.RowsWritten != 2 || result.Rejected != 3 {
+			t.Errorf("stream=%v: unexpected result %+v", streaming, result)
+		}
+		if strings.Join(result.Columns, ",") != "id,name,joined,note" {
+			t.Errorf("stream=%v: expected the schema column order, got %v", streaming, result.Columns)
+		}
+
+		var records []map[string]interface{}
+		if err := json.Unmarshal(out.Bytes(), &records); err != nil {
+			t.Fatalf("stream=%v: invalid JSON output: %v\n%s", streaming, err, out.String())
+		}
+		if len(records) != 2 || records[0]["id"] != 1.0 || records[1]["joined"] != nil || records[1]["note"] != nil {
+			t.Errorf("stream=%v: unexpected records: %v", streaming, records)
+		}
+		text := out.String()
+		if !(strings.Index(text, `"id"`) < strings.Index(text, `"name"`) && strings.Index(text, `"name"`) < strings.Index(text, `"joined"`)) {
+			t.Errorf("stream=%v: expected keys in schema order:\n%s", streaming, text)
+		}
+
+		for _, want := range []string{`"row":2,`, `"row":3,"error":"column \"name\": null value`, `"row":4,"error":"column \"joined\"`} {
+			if !strings.Contains(rejects.String(), want) {
+				t.Errorf("stream=%v: expected rejects to contain %s:\n%s", streaming, want, rejects.String())
+			}
+		}
+	}
+}
+
+// TestRunSchemaColumns verifies that an input whose columns do not match the schema fails as a whole.
+func TestRunSchemaColumns(t *testing.T) {
+	schema, _ := convert.ParseSchema([]byte(handSchema))
+	dir := t.TempDir()
+
+	cases := map[string]string{
+		"missing.csv": "name,joined\nAlice,2024-01-02\n",
+		"extra.csv":   "id,name,email\n1,Alice,a@example.com\n",
+	}
+	wants := map[string]string{
+		"missing.csv": `column "id" is missing from the input`,
+		"extra.csv":   `input column "email" is not declared in the schema`,
+	}
+	for name, content := range cases {
+		input := filepath.Join(dir, name)
+		os.WriteFile(input, []byte(content), 0644)
+
+		err := convert.Run(context.Background(), convert.Options{
+			InputFile:  input,
+			OutputFile: filepath.Join(dir, name+".json"),
+			Quiet:      true,
+			Schema:     schema,
+			OnError:    convert.ErrorPolicy{Mode: convert.OnErrorSkip},
+		})
+		if err == nil || !strings.Contains(err.Error(), wants[name]) {
+			t.Errorf("%s: expected error containing %q, got %v", name, wants[name], err)
+		}
+	}
+}
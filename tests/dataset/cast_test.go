@@ -0,0 +1,91 @@
+package dataset_test
+
+import (
+	"testing"
+	"time"
+
+	"omnidata/internal/dataset"
+)
+
+// TestParseKind verifies canonical type names and their aliases.
+func TestParseKind(t *testing.T) {
+	cases := map[string]dataset.Kind{
+		"integer":  dataset.KindInt,
+		"INT":      dataset.KindInt,
+		"decimal":  dataset.KindDecimal,
+		"bool":     dataset.KindBool,
+		"datetime": dataset.KindTimestamp,
+		"date":     dataset.KindDate,
+	}
+	for name, want := range cases {
+		if got, err := dataset.ParseKind(name); err != nil || got != want {
+			t.Errorf("ParseKind(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	if _, err := dataset.ParseKind("money"); err == nil {
+		t.Error("expected an error for an unknown type")
+	}
+}
+
+// TestCast verifies conversions between kinds, and that lossy ones are refused.
+func TestCast(t *testing.T) {
+	cases := []struct {
+		in   interface{}
+		kind dataset.Kind
+		want string
+	}{
+		{" 42 ", dataset.KindInt, "42"},
+		{float64(3), dataset.KindInt, "3"},
+		{"12.50", dataset.KindDecimal, "12.50"},
+		{int64(7), dataset.KindFloat, "7"},
+		{"yes", dataset.KindBool, "true"},
+		{"2024-02-29", dataset.KindDate, "2024-02-29"},
+		{"2024-02-29T10:00:00Z", dataset.KindDate, "2024-02-29"},
+		{dataset.Date{Year: 2024, Month: time.March, Day: 1}, dataset.KindTimestamp, "2024-03-01T00:00:00Z"},
+		{int64(5), dataset.KindString, "5"},
+		{`["a", 1]`, dataset.KindList, `["a",1]`},
+		{`{"n": 2.5}`, dataset.KindMap, `{"n":2.5}`},
+	}
+	for _, c := range cases {
+		got, err := dataset.Cast(c.in, c.kind)
+		if err != nil {
+			t.Errorf("Cast(%#v, %s) failed: %v", c.in, c.kind, err)
+			continue
+		}
+		if dataset.KindOf(got) != c.kind || dataset.FormatValue(got) != c.want {
+			t.Errorf("Cast(%#v, %s) = %#v, want %s", c.in, c.kind, got, c.want)
+		}
+	}
+
+	if v, err := dataset.Cast("", dataset.KindInt); err != nil || v != nil {
+		t.Errorf("empty text should cast to nil, got %#v, %v", v, err)
+	}
+	for _, bad := range []interface{}{"abc", float64(1.5), "1e400x"} {
+		if _, err := dataset.Cast(bad, dataset.KindInt); err == nil {
+			t.Errorf("expected Cast(%#v, integer) to fail", bad)
+		}
+	}
+	if _, err := dataset.Cast(`{"n": 1}`, dataset.KindList); err == nil {
+		t.Error("expected an object to be refused as an array")
+	}
+}
+
+// TestCompare verifies ordering across numeric kinds, dates and nulls.
+func TestCompare(t *testing.T) {
+	dec, _ := dataset.ParseDecimal("2.5")
+	cases := []struct {
+		a, b interface{}
+		want int
+	}{
+		{int64(2), dec, -1},
+		{float64(2.5), dec, 0},
+		{nil, int64(0), -1},
+		{"b", "a", 1},
+		{dataset.Date{Year: 2024, Month: 1, Day: 2}, time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC), 1},
+	}
+	for _, c := range cases {
+		if got := dataset.Compare(c.a, c.b); got != c.want {
+			t.Errorf("Compare(%#v, %#v) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package dataset_test
+
+import (
+	"reflect"
+	"testing"
+
+	"omnidata/internal/dataset"
+)
+
+// TestFromRows verifies that the first row becomes the header and short rows are padded.
+func TestFromRows(t *testing.T) {
+	ds := dataset.FromRows([][]string{
+		{"name", "age"},
+		{"Alice", "30"},
+		{"Bob"},
+	})
+
+	if !reflect.DeepEqual(ds.Columns, []string{"name", "age"}) {
+		t.Fatalf("unexpected columns: %v", ds.Columns)
+	}
+	if ds.Len() != 2 {
+		t.Fatalf("expected 2 records, got %d", ds.Len())
+	}
+	if ds.Value(1, "age") != "" {
+		t.Errorf("expected short row to be padded, got %v", ds.Value(1, "age"))
+	}
+
+	rows := ds.StringRows()
+	if len(rows) != 3 || rows[1][0] != "Alice" {
+		t.Errorf("unexpected string rows: %v", rows)
+	}
+}
+
+// TestFromMaps verifies deterministic column ordering and nil filling for missing keys.
+func TestFromMaps(t *testing.T) {
+	ds := dataset.FromMaps([]map[string]interface{}{
+		{"b": 1, "a": "x"},
+		{"c": true, "a": "y"},
+	})
+
+	if !reflect.DeepEqual(ds.Columns, []string{"a", "b", "c"}) {
+		t.Fatalf("unexpected columns: %v", ds.Columns)
+	}
+	if ds.Value(0, "c") != nil {
+		t.Errorf("expected nil for missing key, got %v", ds.Value(0, "c"))
+	}
+	if ds.Value(1, "c") != true {
+		t.Errorf("expected true, got %v", ds.Value(1, "c"))
+	}
+}
+
+// TestAddColumn verifies that existing records are padded when a column is added.
+func TestAddColumn(t *testing.T) {
+	ds := dataset.New([]string{"a"})
+	ds.Append("1")
+	idx := ds.AddColumn("b")
+	if idx != 1 || len(ds.Records[0]) != 2 {
+		t.Fatalf("column not added correctly: idx=%d record=%v", idx, ds.Records[0])
+	}
+	if ds.AddColumn("a") != 0 {
+		t.Error("AddColumn should return the index of an existing column")
+	}
+}
+
+// TestFormatValue verifies text rendering of values.
+func TestFormatValue(t *testing.T) {
+	cases := map[string]interface{}{
+		"":          nil,
+		"abc":       "abc",
+		"42":        42,
+		"true":      true,
+		`{"k":"v"}`: map[string]interface{}{"k": "v"},
+		`[1,"two"]`: []interface{}{1, "two"},
+	}
+	for want, in := range cases {
+		if got := dataset.FormatValue(in); got != want {
+			t.Errorf("FormatValue(%v) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestUniqueColumns names blank columns by position and suffixes repeated names.
+func TestUniqueColumns(t *testing.T) {
+	got := dataset.UniqueColumns([]string{"id", "", "id", " ", "id_2", "id"})
+	want := []string{"id", "col2", "id_2", "col4", "id_2_2", "id_3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UniqueColumns = %q, want %q", got, want)
+	}
+}
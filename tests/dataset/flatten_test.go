@@ -0,0 +1,120 @@
+package dataset_test
+
+import (
+	"reflect"
+	"testing"
+
+	"omnidata/internal/dataset"
+)
+
+// nestedDataset returns two records with nested objects and arrays of different lengths.
+func nestedDataset() *dataset.Dataset {
+	ds := dataset.New([]string{"id", "user", "tags"})
+	ds.Append(int64(1), map[string]interface{}{
+		"name":    "Alice",
+		"address": map[string]interface{}{"city": "Paris"},
+	}, []interface{}{"a", "b"})
+	ds.Append(int64(2), map[string]interface{}{"name": "Bob"}, []interface{}{"c"})
+	return ds
+}
+
+// TestFlattenIndex verifies dotted object keys and indexed array columns.
+func TestFlattenIndex(t *testing.T) {
+	flat := dataset.Flatten(nestedDataset(), dataset.FlattenOptions{})
+
+	want := []string{"id", "user.address.city", "user.name", "tags[0]", "tags[1]"}
+	if !reflect.DeepEqual(flat.Columns, want) {
+		t.Fatalf("columns = %v, want %v", flat.Columns, want)
+	}
+	if flat.Value(0, "user.address.city") != "Paris" || flat.Value(0, "tags[1]") != "b" {
+		t.Errorf("unexpected first record: %v", flat.Records[0])
+	}
+	if flat.Value(1, "tags[1]") != nil || flat.Value(1, "user.address.city") != nil {
+		t.Errorf("missing values should be nil: %v", flat.Records[1])
+	}
+}
+
+// TestFlattenGroupsColumns keeps the columns of a path together when later records add
+// them, without a column for a parent that was null or empty.
+func TestFlattenGroupsColumns(t *testing.T) {
+	ds := dataset.New([]string{"id", "tags", "user"})
+	ds.Append(int64(1), []interface{}{"a"}, nil)
+	ds.Append(int64(2), []interface{}{"b", "c"}, map[string]interface{}{"name": "Bob"})
+	ds.Append(int64(3), []interface{}{}, map[string]interface{}{})
+
+	flat := dataset.Flatten(ds, dataset.FlattenOptions{})
+	want := []string{"id", "tags[0]", "tags[1]", "user.name"}
+	if !reflect.DeepEqual(flat.Columns, want) {
+		t.Fatalf("columns = %v, want %v", flat.Columns, want)
+	}
+	if flat.Value(1, "tags[1]") != "c" || flat.Value(1, "user.name") != "Bob" || flat.Value(0, "user.name") != nil {
+		t.Errorf("unexpected records %v", flat.Records)
+	}
+
+	// A parent that holds a value in some record keeps its column
+	ds = dataset.New([]string{"user"})
+	ds.Append("anonymous")
+	ds.Append(map[string]interface{}{"name": "Bob"})
+	flat = dataset.Flatten(ds, dataset.FlattenOptions{})
+	if want := []string{"user", "user.name"}; !reflect.DeepEqual(flat.Columns, want) {
+		t.Errorf("columns = %v, want %v", flat.Columns, want)
+	}
+}
+
+// TestFlattenJoinAndExplode verifies the alternative array modes.
+func TestFlattenJoinAndExplode(t *testing.T) {
+	joined := dataset.Flatten(nestedDataset(), dataset.FlattenOptions{Arrays: dataset.ArrayJoin, JoinSeparator: "|"})
+	if joined.Value(0, "tags") != "a|b" {
+		t.Errorf("joined tags = %v, want a|b", joined.Value(0, "tags"))
+	}
+
+	exploded := dataset.Flatten(nestedDataset(), dataset.FlattenOptions{Arrays: dataset.ArrayExplode})
+	if exploded.Len() != 3 {
+		t.Fatalf("expected 3 exploded records, got %d", exploded.Len())
+	}
+	got := []interface{}{exploded.Value(0, "tags"), exploded.Value(1, "tags"), exploded.Value(2, "tags")}
+	if !reflect.DeepEqual(got, []interface{}{"a", "b", "c"}) {
+		t.Errorf("exploded tags = %v", got)
+	}
+	if exploded.Value(1, "user.name") != "Alice" {
+		t.Errorf("exploded rows should repeat other columns, got %v", exploded.Records[1])
+	}
+}
+
+// TestUnflattenRoundTrip verifies that index-mode flattening can be reversed,
+// including from string cells with empty padding as read back from CSV.
+func TestUnflattenRoundTrip(t *testing.T) {
+	flat := dataset.FromRows([][]string{
+		{"id", "user.address.city", "user.name", "tags[0]", "tags[1]"},
+		{"1", "Paris", "Alice", "a", "b"},
+		{"2", "", "Bob", "c", ""},
+	})
+
+	nested, err := dataset.Unflatten(flat, dataset.FlattenOptions{})
+	if err != nil {
+		t.Fatalf("Unflatten failed: %v", err)
+	}
+	if !reflect.DeepEqual(nested.Columns, []string{"id", "user", "tags"}) {
+		t.Fatalf("unexpected columns: %v", nested.Columns)
+	}
+
+	wantUser := map[string]interface{}{
+		"name":    "Alice",
+		"address": map[string]interface{}{"city": "Paris"},
+	}
+	if !reflect.DeepEqual(nested.Value(0, "user"), wantUser) {
+		t.Errorf("user = %v, want %v", nested.Value(0, "user"), wantUser)
+	}
+	if !reflect.DeepEqual(nested.Value(1, "tags"), []interface{}{"c"}) {
+		t.Errorf("padding should be dropped from lists, got %v", nested.Value(1, "tags"))
+	}
+}
+
+// TestUnflattenPlainColumns verifies that datasets without paths are left untouched.
+func TestUnflattenPlainColumns(t *testing.T) {
+	ds := dataset.FromRows([][]string{{"a", "b"}, {"1", "2"}})
+	out, err := dataset.Unflatten(ds, dataset.FlattenOptions{})
+	if err != nil || out != ds {
+		t.Errorf("expected dataset to be returned unchanged, got %v (err %v)", out, err)
+	}
+}
@@ -0,0 +1,97 @@
+package dataset_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"omnidata/internal/dataset"
+)
+
+// TestNormalize verifies that decoder and driver values map onto canonical cell types.
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		in   interface{}
+		kind dataset.Kind
+	}{
+		{nil, dataset.KindNull},
+		{true, dataset.KindBool},
+		{42, dataset.KindInt},
+		{int32(7), dataset.KindInt},
+		{uint64(1) << 63, dataset.KindDecimal},
+		{float32(1.5), dataset.KindFloat},
+		{json.Number("12"), dataset.KindInt},
+		{json.Number("1.25"), dataset.KindFloat},
+		{json.Number("123456789012345678901234567890"), dataset.KindDecimal},
+		{"text", dataset.KindString},
+		{[]byte{1, 2}, dataset.KindBytes},
+		{time.Now(), dataset.KindTimestamp},
+		{[]interface{}{1}, dataset.KindList},
+		{map[interface{}]interface{}{"a": 1}, dataset.KindMap},
+	}
+	for _, c := range cases {
+		if got := dataset.KindOf(dataset.Normalize(c.in)); got != c.kind {
+			t.Errorf("Normalize(%v) kind = %s, want %s", c.in, got, c.kind)
+		}
+	}
+
+	nested := dataset.Normalize(map[string]interface{}{"n": []interface{}{json.Number("3")}}).(map[string]interface{})
+	if nested["n"].([]interface{})[0] != int64(3) {
+		t.Errorf("nested values were not normalized: %v", nested)
+	}
+}
+
+// TestDecimal verifies exact parsing, rendering and JSON encoding of decimals.
+func TestDecimal(t *testing.T) {
+	for _, text := range []string{"0", "-12.340", "0.001", "123456789012345678901234567890.5"} {
+		d, err := dataset.ParseDecimal(text)
+		if err != nil {
+			t.Fatalf("ParseDecimal(%q) failed: %v", text, err)
+		}
+		if d.String() != text {
+			t.Errorf("ParseDecimal(%q).String() = %q", text, d.String())
+		}
+	}
+
+	if _, err := dataset.ParseDecimal("abc"); err == nil {
+		t.Error("expected error for invalid decimal")
+	}
+
+	d := dataset.Decimal{Unscaled: big.NewInt(-5), Scale: 3}
+	b, err := json.Marshal(map[string]interface{}{"v": d})
+	if err != nil || string(b) != `{"v":-0.005}` {
+		t.Errorf("unexpected JSON for decimal: %s (%v)", b, err)
+	}
+}
+
+// TestDate verifies date parsing and rendering.
+func TestDate(t *testing.T) {
+	d, err := dataset.ParseDate("2024-02-29")
+	if err != nil {
+		t.Fatalf("ParseDate failed: %v", err)
+	}
+	if d.String() != "2024-02-29" || d.Time().Weekday() != time.Thursday {
+		t.Errorf("unexpected date: %v", d)
+	}
+	if _, err := dataset.ParseDate("29/02/2024"); err == nil {
+		t.Error("expected error for non-ISO date")
+	}
+}
+
+// TestFormatTypedValues verifies text rendering of typed cells.
+func TestFormatTypedValues(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	cases := map[string]interface{}{
+		"1000000":              float64(1000000),
+		"0.5":                  0.5,
+		"2024-01-02T03:04:05Z": ts,
+		"2024-01-02":           dataset.DateOf(ts),
+		"AQI=":                 []byte{1, 2},
+	}
+	for want, in := range cases {
+		if got := dataset.FormatValue(in); got != want {
+			t.Errorf("FormatValue(%v) = %q, want %q", in, got, want)
+		}
+	}
+}
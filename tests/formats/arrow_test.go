@@ -0,0 +1,208 @@
+package formats_test
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+	_ "omnidata/internal/formats"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+)
+
+func TestArrowReader_Errors(t *testing.T) {
+	handler, ok := convert.GetFormat("arrow")
+	if !ok {
+		t.Fatal("Arrow handler not registered")
+	}
+	// Test nil reader
+	_, err := handler.ReaderFn(context.Background(), nil, "", nil)
+	if err == nil || err.Error() == "" {
+		t.Error("Expected error for nil reader")
+	}
+
+	// Test empty, truncated and non-Arrow input
+	for name, input := range map[string]string{
+		"empty":     "",
+		"truncated": "ARROW1\x00\x00",
+		"CSV":       "id,name\n1,Ann\n",
+	} {
+		_, err = handler.ReaderFn(context.Background(), strings.NewReader(input), "data.arrow", nil)
+		if err == nil || !strings.Contains(err.Error(), "invalid Arrow IPC") {
+			t.Errorf("Expected %s input to be rejected, got %v", name, err)
+		}
+	}
+}
+
+func TestArrowWriter_Errors(t *testing.T) {
+	handler, ok := convert.GetFormat("arrow")
+	if !ok {
+		t.Fatal("Arrow handler not registered")
+	}
+	// Nil writer
+	err := handler.WriterFn(context.Background(), nil, "", [][]string{}, nil)
+	if err == nil {
+		t.Error("Expected error for nil writer")
+	}
+	// Wrong type
+	err = handler.WriterFn(context.Background(), os.Stdout, "foo.arrow", 123, nil)
+	if err == nil || err.Error() == "" {
+		t.Error("Expected error for Arrow write with wrong type")
+	}
+	// Unknown layout and compression, bad batch size
+	for _, opts := range []convert.FormatOptions{{"ipc": "feather"}, {"compression": "snappy"}} {
+		if err := handler.ValidateWriterOptions(opts); err == nil {
+			t.Errorf("Expected %v to be rejected", opts)
+		}
+	}
+	err = handler.WriterFn(context.Background(), &bytes.Buffer{}, "foo.arrow", [][]string{{"a"}}, convert.FormatOptions{"batch_size": "0"})
+	if err == nil || !strings.Contains(err.Error(), "batch_size must be 1 or more") {
+		t.Errorf("Expected a bad batch size to be rejected, got %v", err)
+	}
+}
+
+// TestArrowRoundTrip converts CSV to Arrow and back in both IPC layouts with each
+// compression, in memory and streaming.
+func TestArrowRoundTrip(t *testing.T) {
+	input := "id,name,score,active,note\n1,Ann,3.5,true,\n2,Zoë,4,false,x\n3,Bob,,true,y\n"
+
+	for _, layout := range []string{"file", "stream"} {
+		for _, compression := range []string{"none", "lz4", "zstd"} {
+			for _, streaming := range []bool{false, true} {
+				var out, back bytes.Buffer
+				_, err := convert.Transcode(context.Background(), strings.NewReader(input), &out, convert.Options{
+					From: "csv", To: "arrow", Stream: streaming,
+					OutOptions: convert.FormatOptions{"ipc": layout, "compression": compression, "batch_size": "2"},
+				})
+				if err != nil {
+					t.Fatalf("%s/%s, stream=%v: conversion to Arrow failed: %v", layout, compression, streaming, err)
+				}
+				if layout == "file" && !bytes.HasPrefix(out.Bytes(), []byte("ARROW1")) {
+					t.Fatalf("%s/%s, stream=%v: output is not an Arrow file", layout, compression, streaming)
+				}
+
+				// The layout is detected from the content
+				_, err = convert.Transcode(context.Background(), &out, &back, convert.Options{To: "csv", Stream: streaming})
+				if err != nil {
+					t.Fatalf("%s/%s, stream=%v: conversion from Arrow failed: %v", layout, compression, streaming, err)
+				}
+				if back.String() != input {
+					t.Errorf("%s/%s, stream=%v: expected %q, got %q", layout, compression, streaming, input, back.String())
+				}
+			}
+		}
+	}
+}
+
+// TestArrowTypes writes dates, timestamps, decimals, bytes and nulls and reads them back typed.
+func TestArrowTypes(t *testing.T) {
+	handler, _ := convert.GetFormat("arrow")
+	seen := time.Date(2024, 5, 1, 10, 30, 0, 123456000, time.UTC)
+
+	ds := dataset.New([]string{"id", "born", "seen", "amount", "photo", "name"})
+	ds.Append(int64(1), dataset.Date{Year: 1960, Month: time.March, Day: 4}, seen, dataset.Decimal{Unscaled: big.NewInt(1250), Scale: 2}, []byte{0, 1}, "Ann")
+	ds.Append(int64(2), nil, seen.Add(time.Hour), dataset.Decimal{Unscaled: big.NewInt(-31), Scale: 1}, nil, nil)
+
+	var out bytes.Buffer
+	if err := handler.WriterFn(context.Background(), &out, "people.feather", ds, nil); err != nil {
+		t.Fatalf("failed to write Arrow: %v", err)
+	}
+	file, err := ipc.NewFileReader(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatalf("failed to open the output: %v", err)
+	}
+	want := "schema:\n  fields: 6\n" +
+		"    - id: type=int64\n" +
+		"    - born: type=date32, nullable\n" +
+		"    - seen: type=timestamp[us, tz=UTC]\n" +
+		"    - amount: type=decimal(4, 2)\n" +
+		"    - photo: type=binary, nullable\n" +
+		"    - name: type=utf8, nullable"
+	if got := file.Schema().String(); got != want {
+		t.Errorf("expected the schema\n%s\ngot\n%s", want, got)
+	}
+	file.Close()
+
+	data, err := handler.ReaderFn(context.Background(), &out, "people.feather", nil)
+	if err != nil {
+		t.Fatalf("failed to read Arrow: %v", err)
+	}
+	got := data.(*dataset.Dataset)
+	if v := got.Value(0, "born"); v != (dataset.Date{Year: 1960, Month: time.March, Day: 4}) {
+		t.Errorf("expected a date, got %#v", v)
+	}
+	if v, ok := got.Value(0, "seen").(time.Time); !ok || !v.Equal(seen) {
+		t.Errorf("expected a timestamp, got %#v", got.Value(0, "seen"))
+	}
+	if v, ok := got.Value(1, "amount").(dataset.Decimal); !ok || v.String() != "-3.10" {
+		t.Errorf("expected a decimal, got %#v", got.Value(1, "amount"))
+	}
+	if !bytes.Equal(got.Value(0, "photo").([]byte), []byte{0, 1}) || got.Value(1, "born") != nil || got.Value(1, "name") != nil {
+		t.Errorf("unexpected records %v", got.Records)
+	}
+}
+
+// TestArrowForeignTypes reads Arrow types OmniData does not write, as a dataframe
+// library would produce them.
+func TestArrowForeignTypes(t *testing.T) {
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "small", Type: arrow.PrimitiveTypes.Int16},
+		{Name: "big", Type: arrow.PrimitiveTypes.Uint64},
+		{Name: "ratio", Type: arrow.PrimitiveTypes.Float32},
+		{Name: "kind", Type: &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int8, ValueType: arrow.BinaryTypes.String}},
+		{Name: "tags", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+		{Name: "point", Type: arrow.StructOf(arrow.Field{Name: "x", Type: arrow.PrimitiveTypes.Int32}, arrow.Field{Name: "y", Type: arrow.PrimitiveTypes.Int32})},
+		{Name: "counts", Type: arrow.MapOf(arrow.BinaryTypes.String, arrow.PrimitiveTypes.Int64)},
+		{Name: "at", Type: arrow.FixedWidthTypes.Time32ms},
+		{Name: "took", Type: arrow.FixedWidthTypes.Duration_s},
+	}, nil)
+
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer builder.Release()
+	builder.Field(0).(*array.Int16Builder).Append(-3)
+	builder.Field(1).(*array.Uint64Builder).Append(1 << 63)
+	builder.Field(2).(*array.Float32Builder).Append(0.5)
+	builder.Field(3).(*array.BinaryDictionaryBuilder).AppendString("fruit")
+	tags := builder.Field(4).(*array.ListBuilder)
+	tags.Append(true)
+	tags.ValueBuilder().(*array.StringBuilder).AppendValues([]string{"a", "b"}, nil)
+	point := builder.Field(5).(*array.StructBuilder)
+	point.Append(true)
+	point.FieldBuilder(0).(*array.Int32Builder).Append(1)
+	point.FieldBuilder(1).(*array.Int32Builder).Append(2)
+	counts := builder.Field(6).(*array.MapBuilder)
+	counts.Append(true)
+	counts.KeyBuilder().(*array.StringBuilder).Append("x")
+	counts.ItemBuilder().(*array.Int64Builder).Append(7)
+	builder.Field(7).(*array.Time32Builder).Append(arrow.Time32((9*3600 + 30*60) * 1000))
+	builder.Field(8).(*array.DurationBuilder).Append(90)
+	batch := builder.NewRecordBatch()
+	defer batch.Release()
+
+	var out bytes.Buffer
+	writer := ipc.NewWriter(&out, ipc.WithSchema(schema))
+	if err := writer.Write(batch); err != nil {
+		t.Fatalf("failed to write the stream: %v", err)
+	}
+	writer.Close()
+
+	var ndjson bytes.Buffer
+	_, err := convert.Transcode(context.Background(), &out, &ndjson, convert.Options{From: "arrow", To: "ndjson", Stream: true})
+	if err != nil {
+		t.Fatalf("conversion failed: %v", err)
+	}
+	want := `{"small":-3,"big":9223372036854775808,"ratio":0.5,"kind":"fruit","tags":["a","b"],` +
+		`"point":{"x":1,"y":2},"counts":{"x":7},"at":"09:30:00","took":"1m30s"}` + "\n"
+	if ndjson.String() != want {
+		t.Errorf("expected %s, got %s", want, ndjson.String())
+	}
+}
@@ -1,12 +1,19 @@
 package formats_test
 
 import (
+	"bytes"
+	"context"
+	"math/big"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
 	_ "omnidata/internal/formats"
+	"omnidata/internal/inspect"
 )
 
 func TestAvroReader_Errors(t *testing.T) {
@@ -15,18 +22,22 @@ func TestAvroReader_Errors(t *testing.T) {
 		t.Fatal("Avro handler not registered")
 	}
 	// Test nil reader
-	_, err := handler.ReaderFn(nil, "")
+	_, err := handler.ReaderFn(context.Background(), nil, "", nil)
 	if err == nil || err.Error() == "" {
 		t.Error("Expected error for nil reader")
 	}
 
-	// Test dependency error
+	// Test empty and non-Avro input
 	f, _ := os.CreateTemp(os.TempDir(), "tmpavro.avro")
 	defer os.Remove(f.Name())
 	defer f.Close()
-	_, err = handler.ReaderFn(f, f.Name())
-	if err == nil || err.Error() == "" || !strings.Contains(err.Error(), "Avro format support") {
-		t.Error("Expected Avro dependency error")
+	_, err = handler.ReaderFn(context.Background(), f, f.Name(), nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid Avro object container file") {
+		t.Errorf("Expected an empty file to be rejected, got %v", err)
+	}
+	_, err = handler.ReaderFn(context.Background(), strings.NewReader("id,name\n1,Ann\n"), "data.csv", nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid Avro object container file") {
+		t.Errorf("Expected CSV input to be rejected, got %v", err)
 	}
 }
 
@@ -36,18 +47,220 @@ func TestAvroWriter_Errors(t *testing.T) {
 		t.Fatal("Avro handler not registered")
 	}
 	// Nil writer
-	err := handler.WriterFn(nil, "", [][]string{})
+	err := handler.WriterFn(context.Background(), nil, "", [][]string{}, nil)
 	if err == nil {
 		t.Error("Expected error for nil writer")
 	}
 	// Wrong type
-	err = handler.WriterFn(os.Stdout, "foo.avro", 123)
+	err = handler.WriterFn(context.Background(), os.Stdout, "foo.avro", 123, nil)
 	if err == nil || err.Error() == "" {
 		t.Error("Expected error for Avro write with wrong type")
 	}
-	// Correct type, dependency error
-	err = handler.WriterFn(os.Stdout, "foo.avro", [][]string{{"a"}})
-	if err == nil || err.Error() == "" || !strings.Contains(err.Error(), "Avro format support") {
-		t.Error("Expected Avro dependency error writing file")
+	// Unknown codec, bad block size and missing schema file
+	if err := handler.ValidateWriterOptions(convert.FormatOptions{"codec": "zstd"}); err == nil {
+		t.Error("Expected an unknown codec to be rejected")
+	}
+	for want, opts := range map[string]convert.FormatOptions{
+		"block_size must be 1 or more": {"block_size": "0"},
+		"failed to read Avro schema":   {"schema": filepath.Join(t.TempDir(), "missing.avsc")},
+	} {
+		err = handler.WriterFn(context.Background(), &bytes.Buffer{}, "foo.avro", [][]string{{"a"}}, opts)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected an error containing %q, got %v", want, err)
+		}
+	}
+}
+
+// TestAvroRoundTrip converts CSV to Avro and back with each codec, in memory and streaming.
+func TestAvroRoundTrip(t *testing.T) {
+	input := "id,name,score,active,note\n1,Ann,3.5,true,\n2,Zoë,4,false,x\n3,Bob,,true,y\n"
+
+	for _, codec := range []string{"null", "deflate", "snappy"} {
+		for _, streaming := range []bool{false, true} {
+			var avro bytes.Buffer
+			_, err := convert.Transcode(context.Background(), strings.NewReader(input), &avro, convert.Options{
+				From: "csv", To: "avro", Stream: streaming,
+				OutOptions: convert.FormatOptions{"codec": codec, "block_size": "2"},
+			})
+			if err != nil {
+				t.Fatalf("%s, stream=%v: conversion to Avro failed: %v", codec, streaming, err)
+			}
+			if !bytes.HasPrefix(avro.Bytes(), []byte("Obj\x01")) {
+				t.Fatalf("%s, stream=%v: output is not an Avro file", codec, streaming)
+			}
+
+			var out bytes.Buffer
+			_, err = convert.Transcode(context.Background(), &avro, &out, convert.Options{From: "avro", To: "csv", Stream: streaming})
+			if err != nil {
+				t.Fatalf("%s, stream=%v: conversion from Avro failed: %v", codec, streaming, err)
+			}
+			if out.String() != input {
+				t.Errorf("%s, stream=%v: expected %q, got %q", codec, streaming, input, out.String())
+			}
+		}
+	}
+}
+
+// TestAvroLogicalTypes writes dates, timestamps, decimals and nulls and reads them back typed.
+func TestAvroLogicalTypes(t *testing.T) {
+	handler, _ := convert.GetFormat("avro")
+	seen := time.Date(2024, 5, 1, 10, 30, 0, 123000000, time.UTC)
+
+	ds := dataset.New([]string{"id", "born", "seen", "amount", "first name"})
+	ds.Append(int64(1), dataset.Date{Year: 1990, Month: time.March, Day: 4}, seen, dataset.Decimal{Unscaled: big.NewInt(1250), Scale: 2}, "Ann")
+	ds.Append(int64(2), nil, seen.Add(time.Hour), dataset.Decimal{Unscaled: big.NewInt(-31), Scale: 1}, nil)
+
+	var avro bytes.Buffer
+	if err := handler.WriterFn(context.Background(), &avro, "people.avro", ds, convert.FormatOptions{"name": "Person", "namespace": "com.example"}); err != nil {
+		t.Fatalf("failed to write Avro: %v", err)
+	}
+	for _, want := range []string{
+		`"name":"com.example.Person"`,
+		`"name":"born","type":["null",{"logicalType":"date","type":"int"}]`,
+		`{"logicalType":"timestamp-millis","type":"long"}`,
+		`{"logicalType":"decimal","precision":4,"scale":2,"type":"bytes"}`,
+		`"name":"first_name"`,
+	} {
+		if !strings.Contains(avro.String(), want) {
+			t.Errorf("expected the schema to contain %s", want)
+		}
+	}
+
+	data, err := handler.ReaderFn(context.Background(), &avro, "people.avro", nil)
+	if err != nil {
+		t.Fatalf("failed to read Avro: %v", err)
+	}
+	got := data.(*dataset.Dataset)
+	if strings.Join(got.Columns, ",") != "id,born,seen,amount,first_name" {
+		t.Fatalf("unexpected columns %v", got.Columns)
+	}
+	if v := got.Value(0, "born"); v != (dataset.Date{Year: 1990, Month: time.March, Day: 4}) {
+		t.Errorf("expected a date, got %#v", v)
+	}
+	if v, ok := got.Value(0, "seen").(time.Time); !ok || !v.Equal(seen) {
+		t.Errorf("expected a timestamp, got %#v", got.Value(0, "seen"))
+	}
+	if v, ok := got.Value(1, "amount").(dataset.Decimal); !ok || v.String() != "-3.10" {
+		t.Errorf("expected a decimal, got %#v", got.Value(1, "amount"))
+	}
+	if got.Value(1, "born") != nil || got.Value(1, "first_name") != nil || got.Value(0, "id") != int64(1) {
+		t.Errorf("unexpected records %v", got.Records)
+	}
+}
+
+// TestAvroSchemaFile writes records with a schema file, and reads nested and enum fields.
+func TestAvroSchemaFile(t *testing.T) {
+	handler, _ := convert.GetFormat("avro")
+	path := filepath.Join(t.TempDir(), "order.avsc")
+	os.WriteFile(path, []byte(`{"type": "record", "name": "Order", "namespace": "shop", "fields": [
+		{"name": "id", "type": "int"},
+		{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["NEW", "SHIPPED"]}},
+		{"name": "total", "type": ["null", "float", "string"]},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "customer", "type": ["null", {"type": "record", "name": "Customer", "fields": [{"name": "name", "type": "string"}]}]}
+	]}`), 0644)
+
+	rows := []map[string]interface{}{
+		{"id": "7", "status": "NEW", "total": "9.5", "tags": []interface{}{"a", "b"}, "customer": map[string]interface{}{"name": "Ann"}},
+		{"id": int64(8), "status": "SHIPPED", "total": "n/a", "tags": []interface{}{}, "customer": nil},
+	}
+	var avro bytes.Buffer
+	ds := dataset.FromMapsOrdered([]string{"id", "status", "total", "tags", "customer"}, rows)
+	if err := handler.WriterFn(context.Background(), &avro, "", ds, convert.FormatOptions{"schema": path}); err != nil {
+		t.Fatalf("failed to write Avro: %v", err)
+	}
+
+	data, err := handler.ReaderFn(context.Background(), bytes.NewReader(avro.Bytes()), "", nil)
+	if err != nil {
+		t.Fatalf("failed to read Avro: %v", err)
+	}
+	got := data.(*dataset.Dataset)
+	if got.Value(0, "id") != int64(7) || got.Value(1, "status") != "SHIPPED" || got.Value(0, "total") != 9.5 || got.Value(1, "total") != "n/a" {
+		t.Errorf("unexpected records %v", got.Records)
+	}
+	if c, ok := got.Value(0, "customer").(map[string]interface{}); !ok || c["name"] != "Ann" || got.Value(1, "customer") != nil {
+		t.Errorf("expected a nested record, got %#v", got.Value(0, "customer"))
+	}
+
+	for want, row := range map[string]map[string]interface{}{
+		"LOST":                               {"id": "7", "status": "LOST", "tags": "[]"},
+		`column "tags": null is not allowed`: {"id": "7", "status": "NEW"},
+	} {
+		ds = dataset.FromMapsOrdered([]string{"id", "status", "tags"}, []map[string]interface{}{row})
+		err = handler.WriterFn(context.Background(), &bytes.Buffer{}, "", ds, convert.FormatOptions{"schema": path})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected an error containing %q, got %v", want, err)
+		}
+	}
+}
+
+// TestAvroDecimalDigits refuses decimals that do not fit the scale or precision of the
+// schema instead of letting them be rounded.
+func TestAvroDecimalDigits(t *testing.T) {
+	handler, _ := convert.GetFormat("avro")
+	path := filepath.Join(t.TempDir(), "price.avsc")
+	os.WriteFile(path, []byte(`{"type": "record", "name": "Price", "fields": [
+		{"name": "amount", "type": {"type": "bytes", "logicalType": "decimal", "precision": 4, "scale": 1}}
+	]}`), 0644)
+
+	ds := dataset.New([]string{"amount"})
+	ds.Append("123.4")
+	ds.Append("-5")
+	var avro bytes.Buffer
+	if err := handler.WriterFn(context.Background(), &avro, "", ds, convert.FormatOptions{"schema": path}); err != nil {
+		t.Fatalf("failed to write Avro: %v", err)
+	}
+	data, err := handler.ReaderFn(context.Background(), &avro, "", nil)
+	if err != nil {
+		t.Fatalf("failed to read Avro: %v", err)
+	}
+	if got := data.(*dataset.Dataset); dataset.FormatValue(got.Value(0, "amount")) != "123.4" || dataset.FormatValue(got.Value(1, "amount")) != "-5.0" {
+		t.Errorf("unexpected records %v", got.Records)
+	}
+
+	for value, want := range map[string]string{
+		"1.25":     `record 1, column "amount": decimal 1.25 has more than 1 fractional digits`,
+		"-1.25":    `record 1, column "amount": decimal -1.25 has more than 1 fractional digits`,
+		"123456.7": `record 1, column "amount": decimal 123456.7 does not fit in 4 digits`,
+	} {
+		ds := dataset.New([]string{"amount"})
+		ds.Append(value)
+		err := handler.WriterFn(context.Background(), &bytes.Buffer{}, "", ds, convert.FormatOptions{"schema": path})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", value, want, err)
+		}
+	}
+
+	// Streaming output infers the precision from the first block
+	ds = dataset.New([]string{"amount"})
+	ds.Append(dataset.Decimal{Unscaled: big.NewInt(15), Scale: 1})
+	ds.Append(dataset.Decimal{Unscaled: big.NewInt(123455), Scale: 1})
+	avro.Reset()
+	if err := handler.WriterFn(context.Background(), &avro, "", ds, nil); err != nil {
+		t.Fatalf("failed to write Avro: %v", err)
+	}
+	_, err = convert.Transcode(context.Background(), &avro, &bytes.Buffer{}, convert.Options{
+		From: "avro", To: "avro", Stream: true, OutOptions: convert.FormatOptions{"block_size": "1"},
+	})
+	if err == nil || !strings.Contains(err.Error(), `record 2, column "amount": decimal 12345.5 does not fit in 2 digits`) {
+		t.Errorf("expected the second streamed record to be refused, got %v", err)
+	}
+}
+
+// TestAvroPeek inspects an Avro file through peek, with its typed columns.
+func TestAvroPeek(t *testing.T) {
+	var avro bytes.Buffer
+	input := "id,name,price\n1,Ann,2.5\n2,,3\n"
+	if _, err := convert.Transcode(context.Background(), strings.NewReader(input), &avro, convert.Options{From: "csv", To: "avro"}); err != nil {
+		t.Fatalf("conversion failed: %v", err)
+	}
+
+	preview, err := inspect.Peek(context.Background(), &avro, "", inspect.PeekOptions{Format: "avro", Rows: 5})
+	if err != nil {
+		t.Fatalf("Peek failed: %v", err)
+	}
+	schema := preview.Schema
+	if schema.RowCount != 2 || schema.Columns[0].Type != "number" || !schema.Columns[1].Nullable || preview.Preview[0]["price"] != "2.5" {
+		t.Errorf("unexpected peek result: %+v %v", schema, preview.Preview)
 	}
 }
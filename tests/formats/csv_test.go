@@ -1,12 +1,16 @@
 package formats_test
 
 import (
+	"bytes"
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"omnidata/internal/convert"
 	_ "omnidata/internal/formats" // triggers init() for format registration
+	"omnidata/internal/inspect"
 )
 
 // TestCSVReadWrite verifies that CSV format handler can read and write CSV files correctly.
@@ -31,7 +35,7 @@ func TestCSVReadWrite(t *testing.T) {
 	}
 	defer f.Close()
 
-	data, err := handler.ReaderFn(f, inputCSV)
+	data, err := handler.ReaderFn(context.Background(), f, inputCSV, nil)
 	if err != nil {
 		t.Fatalf("failed to read CSV: %v", err)
 	}
@@ -47,7 +51,7 @@ func TestCSVReadWrite(t *testing.T) {
 	}
 	defer fOut.Close()
 
-	if err := handler.WriterFn(fOut, outputCSV, data); err != nil {
+	if err := handler.WriterFn(context.Background(), fOut, outputCSV, data, nil); err != nil {
 		t.Fatalf("failed to write CSV: %v", err)
 	}
 
@@ -67,11 +71,11 @@ func TestCSV_InvalidCases(t *testing.T) {
 		t.Fatal("CSV handler not registered")
 	}
 	// Invalid type for WriterFn
-	if err := handler.WriterFn(os.Stdout, "foo.csv", 12345); err == nil {
+	if err := handler.WriterFn(context.Background(), os.Stdout, "foo.csv", 12345, nil); err == nil {
 		t.Error("expected error for invalid type, got nil")
 	}
 	// Nil reader
-	_, err := handler.ReaderFn(nil, "foo.csv")
+	_, err := handler.ReaderFn(context.Background(), nil, "foo.csv", nil)
 	if err == nil {
 		t.Error("expected error for nil reader, got nil")
 	}
@@ -82,9 +86,208 @@ func TestCSV_InvalidCases(t *testing.T) {
 	defer fEmpty.Close()
 	defer os.Remove(tmp.Name())
 
-	_, err = handler.ReaderFn(fEmpty, tmp.Name())
+	_, err = handler.ReaderFn(context.Background(), fEmpty, tmp.Name(), nil)
 	if err != nil {
 		// Accept EOF, but no error (should not panic)
 		t.Errorf("unexpected error for empty file: %v", err)
 	}
 }
+
+// TestCSVDialectOptions reads and writes a semicolon dialect with single quotes and backslash escapes.
+func TestCSVDialectOptions(t *testing.T) {
+	handler, _ := convert.GetFormat("csv")
+
+	in := convert.FormatOptions{"delimiter": ";", "quote": "'", "escape": "backslash", "comment": "--"}
+	data, err := handler.ReaderFn(context.Background(), strings.NewReader("-- header\nid;name\n1;'O\\'Brien; Jr'\n"), "eu.csv", in)
+	if err != nil {
+		t.Fatalf("failed to read CSV: %v", err)
+	}
+	rows := data.([][]string)
+	if len(rows) != 2 || rows[1][1] != "O'Brien; Jr" {
+		t.Fatalf("unexpected rows: %q", rows)
+	}
+
+	var out bytes.Buffer
+	outOpts := convert.FormatOptions{"delimiter": "|", "always_quote": "true", "crlf": "true"}
+	if err := handler.WriterFn(context.Background(), &out, "", rows, outOpts); err != nil {
+		t.Fatalf("failed to write CSV: %v", err)
+	}
+	if want := "\"id\"|\"name\"\r\n\"1\"|\"O'Brien; Jr\"\r\n"; out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+
+	if err := handler.ValidateReaderOptions(convert.FormatOptions{"escape": "slash"}); err == nil {
+		t.Error("expected an unknown escape style to be rejected")
+	}
+	if _, err := handler.ReaderFn(context.Background(), strings.NewReader("a\n"), "", convert.FormatOptions{"quote": ","}); err == nil {
+		t.Error("expected a quote equal to the delimiter to be rejected")
+	}
+}
+
+// TestTSVFormat converts TSV files, detected by extension, in memory and streaming.
+func TestTSVFormat(t *testing.T) {
+	if got, ok := convert.DetectFormatFromPath("dump.tsv"); !ok || got != "tsv" {
+		t.Errorf("expected .tsv to be detected as tsv, got %q", got)
+	}
+
+	for _, streaming := range []bool{false, true} {
+		var out bytes.Buffer
+		_, err := convert.Transcode(context.Background(), strings.NewReader("id\tname\n1\tAlice, Bob\n"), &out,
+			convert.Options{From: "tsv", To: "csv", Stream: streaming})
+		if err != nil {
+			t.Fatalf("stream=%v: conversion failed: %v", streaming, err)
+		}
+		if want := "id,name\n1,\"Alice, Bob\"\n"; out.String() != want {
+			t.Errorf("stream=%v: expected %q, got %q", streaming, want, out.String())
+		}
+
+		var tsv bytes.Buffer
+		if _, err := convert.Transcode(context.Background(), &out, &tsv, convert.Options{From: "csv", To: "tsv", Stream: streaming}); err != nil {
+			t.Fatalf("stream=%v: conversion back failed: %v", streaming, err)
+		}
+		if want := "id\tname\n1\tAlice, Bob\n"; tsv.String() != want {
+			t.Errorf("stream=%v: expected %q, got %q", streaming, want, tsv.String())
+		}
+	}
+}
+
+// TestCSVSniffedDialect reads an unknown dialect without options, and only when none is pinned.
+func TestCSVSniffedDialect(t *testing.T) {
+	handler, _ := convert.GetFormat("csv")
+	input := "\xef\xbb\xbfid;name\r\n1;'Zoë; Jr'\r\n"
+	latin := "id;name\r\n1;\"Zo\xeb; Jr\"\r\n"
+
+	data, err := handler.ReaderFn(context.Background(), strings.NewReader(latin), "", nil)
+	if err != nil {
+		t.Fatalf("failed to read CSV: %v", err)
+	}
+	rows := data.([][]string)
+	if len(rows) != 2 || rows[0][0] != "id" || rows[1][1] != "Zoë; Jr" {
+		t.Fatalf("expected the delimiter and encoding to be detected, got %q", rows)
+	}
+
+	for _, streaming := range []bool{false, true} {
+		var out bytes.Buffer
+		_, err := convert.Transcode(context.Background(), strings.NewReader(input), &out, convert.Options{From: "csv", To: "csv", Stream: streaming})
+		if err != nil {
+			t.Fatalf("stream=%v: conversion failed: %v", streaming, err)
+		}
+		if want := "id,name\n1,Zoë; Jr\n"; out.String() != want {
+			t.Errorf("stream=%v: expected the BOM and quote to be detected: %q, got %q", streaming, want, out.String())
+		}
+	}
+
+	pinned := convert.FormatOptions{"delimiter": ",", "encoding": "utf-8"}
+	data, err = handler.ReaderFn(context.Background(), strings.NewReader("id;name\n1;2\n"), "", pinned)
+	if err != nil {
+		t.Fatalf("failed to read CSV: %v", err)
+	}
+	if rows := data.([][]string); len(rows[0]) != 1 || rows[0][0] != "id;name" {
+		t.Errorf("expected pinned options to win over sniffing, got %q", rows)
+	}
+}
+
+// TestCSVSniffedHeader reads a file whose first row looks like data as headerless,
+// unless the header options say otherwise.
+func TestCSVSniffedHeader(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		var out bytes.Buffer
+		_, err := convert.Transcode(context.Background(), strings.NewReader("1,2\n3,4\n"), &out, convert.Options{From: "csv", To: "csv", Stream: streaming})
+		if err != nil {
+			t.Fatalf("stream=%v: conversion failed: %v", streaming, err)
+		}
+		if want := "col1,col2\n1,2\n3,4\n"; out.String() != want {
+			t.Errorf("stream=%v: expected %q, got %q", streaming, want, out.String())
+		}
+	}
+
+	var out bytes.Buffer
+	opts := convert.Options{From: "csv", To: "csv", InOptions: convert.FormatOptions{"header": "true"}}
+	if _, err := convert.Transcode(context.Background(), strings.NewReader("1,2\n3,4\n"), &out, opts); err != nil {
+		t.Fatalf("conversion failed: %v", err)
+	}
+	if out.String() != "1,2\n3,4\n" {
+		t.Errorf("expected header=true to keep the first row as the header, got %q", out.String())
+	}
+}
+
+// TestInspectSniff reports the detected dialect with the options that pin it.
+func TestInspectSniff(t *testing.T) {
+	result, err := inspect.Sniff(context.Background(), strings.NewReader("id\tname\r\n1\tAlice\r\n2\tBob\r\n"))
+	if err != nil {
+		t.Fatalf("Sniff failed: %v", err)
+	}
+	if result.Delimiter != "\t" || result.LineEnding != "CRLF" || !result.Header || result.Confidence != 1 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if pin := result.Pin(); !strings.Contains(pin, `--in-opt "delimiter=\\t"`) || !strings.Contains(pin, "encoding=utf-8") {
+		t.Errorf("unexpected pin flags: %s", pin)
+	}
+}
+
+// TestCSVHeaderOptions reads files with a preamble or no header, in memory, streaming and through peek.
+func TestCSVHeaderOptions(t *testing.T) {
+	handler, _ := convert.GetFormat("csv")
+	input := "Sales report\nGenerated: 2024-05-01\nregion;units\nNorth;3\nSouth;5\n"
+
+	data, err := handler.ReaderFn(context.Background(), strings.NewReader(input), "", convert.FormatOptions{"header_row": "3"})
+	if err != nil {
+		t.Fatalf("failed to read CSV: %v", err)
+	}
+	if rows := data.([][]string); len(rows) != 3 || strings.Join(rows[0], ",") != "region,units" {
+		t.Errorf("expected the header on line 3 and a sniffed delimiter, got %q", rows)
+	}
+
+	opts := convert.FormatOptions{"skip_rows": "3", "header": "false", "columns": "region"}
+	for _, streaming := range []bool{false, true} {
+		var out bytes.Buffer
+		_, err := convert.Transcode(context.Background(), strings.NewReader(input), &out,
+			convert.Options{From: "csv", To: "csv", Stream: streaming, InOptions: opts})
+		if err != nil {
+			t.Fatalf("stream=%v: conversion failed: %v", streaming, err)
+		}
+		if want := "region,col2\nNorth,3\nSouth,5\n"; out.String() != want {
+			t.Errorf("stream=%v: expected %q, got %q", streaming, want, out.String())
+		}
+	}
+
+	preview, err := inspect.Peek(context.Background(), strings.NewReader("1,2\n3,4\n"), "", inspect.PeekOptions{
+		Format: "csv", Rows: 5, ReaderOptions: convert.FormatOptions{"header": "false"},
+	})
+	if err != nil {
+		t.Fatalf("Peek failed: %v", err)
+	}
+	if preview.Schema.RowCount != 2 || preview.Schema.Columns[1].Name != "col2" || preview.Preview[0]["col1"] != "1" {
+		t.Errorf("unexpected peek result: %+v %v", preview.Schema, preview.Preview)
+	}
+
+	for want, bad := range map[string]convert.FormatOptions{
+		"skip_rows must be 0 or more":    {"skip_rows": "-1"},
+		"header_row must be 1 or more":   {"header_row": "0"},
+		"cannot be combined with header": {"header_row": "2", "header": "false"},
+	} {
+		if _, err := handler.ReaderFn(context.Background(), strings.NewReader(input), "", bad); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected an error containing %q, got %v", want, err)
+		}
+	}
+}
+
+// TestCSVRejectLinesAfterSkippedRows verifies that quarantined records report lines of the whole file.
+func TestCSVRejectLinesAfterSkippedRows(t *testing.T) {
+	var out, rejects bytes.Buffer
+	result, err := convert.Transcode(context.Background(), strings.NewReader("# export\n1,a\n2,b,extra\n3,c\n"), &out, convert.Options{
+		From:      "csv",
+		To:        "csv",
+		InOptions: convert.FormatOptions{"skip_rows": "1", "header": "false"},
+		OnError:   convert.ErrorPolicy{Mode: convert.OnErrorQuarantine, Rejects: &rejects},
+	})
+	if err != nil || result.Rejected != 1 {
+		t.Fatalf("conversion failed: %v %+v", err, result)
+	}
+	if out.String() != "col1,col2\n1,a\n3,c\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+	if !strings.Contains(rejects.String(), `"line":3,"row":2,"error":"record on line 3: wrong number of fields"`) {
+		t.Errorf("unexpected rejects: %s", rejects.String())
+	}
+}
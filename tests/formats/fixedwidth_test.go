@@ -0,0 +1,136 @@
+package formats_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+	_ "omnidata/internal/formats"
+)
+
+const statement = "STATEMENT 2024-05\r\n" +
+	"00001Alice Smith         0000012345\r\n" +
+	"00002Bob                 0000000000\r\n" +
+	"\r\n" +
+	"00003Zoë Ünal            -000099900\r\n"
+
+// TestFixedWidthRead slices records by an inline spec, stripping padding.
+func TestFixedWidthRead(t *testing.T) {
+	handler, ok := convert.GetFormat("fixedwidth")
+	if !ok {
+		t.Fatal("fixedwidth handler not registered")
+	}
+
+	opts := convert.FormatOptions{"columns": "id:1:5:right:0, name::20, amount:26:10:right:0", "skip_rows": "1"}
+	data, err := handler.ReaderFn(context.Background(), strings.NewReader(statement), "statement.fwf", opts)
+	if err != nil {
+		t.Fatalf("failed to read fixed-width data: %v", err)
+	}
+	ds := data.(*dataset.Dataset)
+	if strings.Join(ds.Columns, ",") != "id,name,amount" || ds.Len() != 3 {
+		t.Fatalf("unexpected dataset: %v with %d records", ds.Columns, ds.Len())
+	}
+	for row, want := range []string{"1|Alice Smith|12345", "2|Bob|0", "3|Zoë Ünal|-000099900"} {
+		got := strings.Join([]string{ds.Value(row, "id").(string), ds.Value(row, "name").(string), ds.Value(row, "amount").(string)}, "|")
+		if got != want {
+			t.Errorf("row %d = %q, want %q", row, got, want)
+		}
+	}
+
+	opts["trim"] = "false"
+	data, _ = handler.ReaderFn(context.Background(), strings.NewReader(statement), "", opts)
+	if v := data.(*dataset.Dataset).Value(1, "name"); v != "Bob                 " {
+		t.Errorf("expected untrimmed values, got %q", v)
+	}
+
+	if _, err := handler.ReaderFn(context.Background(), strings.NewReader(statement), "", nil); err == nil || !strings.Contains(err.Error(), "needs a column spec") {
+		t.Errorf("expected a missing spec to be reported, got %v", err)
+	}
+}
+
+// TestFixedWidthSpec loads spec files and rejects invalid specs.
+func TestFixedWidthSpec(t *testing.T) {
+	handler, _ := convert.GetFormat("fixedwidth")
+	dir := t.TempDir()
+	files := map[string]string{
+		"spec.json": `{"columns": [{"name": "code", "width": 3}, {"name": "qty", "start": 5, "width": 4, "align": "right"}]}`,
+		"spec.yaml": "columns:\n  - name: code\n    width: 3\n  - name: qty\n    start: 5\n    width: 4\n    align: right\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0644)
+
+		data, err := handler.ReaderFn(context.Background(), strings.NewReader("AB     7\nXYZ   42\n"), "", convert.FormatOptions{"spec": path})
+		if err != nil {
+			t.Fatalf("%s: failed to read: %v", name, err)
+		}
+		ds := data.(*dataset.Dataset)
+		if ds.Value(0, "code") != "AB" || ds.Value(1, "qty") != "42" {
+			t.Errorf("%s: unexpected records %v", name, ds.Records)
+		}
+	}
+
+	for spec, want := range map[string]string{
+		"a:1:5,b:3:2":    `column "b" overlaps`,
+		"a:1:5:center":   `unknown alignment "center"`,
+		"a:1:0":          "at least 1 character wide",
+		"a:1:2,a:3:2":    "declared twice",
+		"a:1":            "expected name:start:width",
+		"a:1:2:left:abc": "single character",
+	} {
+		_, err := handler.ReaderFn(context.Background(), strings.NewReader(""), "", convert.FormatOptions{"columns": spec})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("columns=%s: expected an error containing %q, got %v", spec, want, err)
+		}
+	}
+	_, err := handler.ReaderFn(context.Background(), strings.NewReader(""), "", convert.FormatOptions{"columns": "a:1:2", "spec": "x.json"})
+	if err == nil || !strings.Contains(err.Error(), "not both") {
+		t.Errorf("expected spec and columns together to be rejected, got %v", err)
+	}
+}
+
+// TestFixedWidthWrite converts CSV to fixed-width text, with a derived or given spec.
+func TestFixedWidthWrite(t *testing.T) {
+	input := "id,name,amount\n1,Ann,3.5\n22,Bob,12\n"
+
+	var out bytes.Buffer
+	_, err := convert.Transcode(context.Background(), strings.NewReader(input), &out,
+		convert.Options{From: "csv", To: "fixedwidth", OutOptions: convert.FormatOptions{"header": "true"}})
+	if err != nil {
+		t.Fatalf("conversion failed: %v", err)
+	}
+	if want := "id name amount\n1  Ann  3.5   \n22 Bob  12    \n"; out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+
+	spec := convert.FormatOptions{"columns": "amount:1:6:right:0,name:8:5"}
+	for _, streaming := range []bool{false, true} {
+		out.Reset()
+		_, err := convert.Transcode(context.Background(), strings.NewReader(input), &out,
+			convert.Options{From: "csv", To: "fixedwidth", Stream: streaming, OutOptions: spec})
+		if err != nil {
+			t.Fatalf("stream=%v: conversion failed: %v", streaming, err)
+		}
+		if want := "0003.5 Ann  \n000012 Bob  \n"; out.String() != want {
+			t.Errorf("stream=%v: expected %q, got %q", streaming, want, out.String())
+		}
+
+		var back bytes.Buffer
+		_, err = convert.Transcode(context.Background(), &out, &back,
+			convert.Options{From: "fixedwidth", To: "csv", Stream: streaming, InOptions: spec})
+		if err != nil || back.String() != "amount,name\n3.5,Ann\n12,Bob\n" {
+			t.Errorf("stream=%v: expected to read the output back, got %q, %v", streaming, back.String(), err)
+		}
+	}
+
+	_, err = convert.Transcode(context.Background(), strings.NewReader(input), &out,
+		convert.Options{From: "csv", To: "fixedwidth", OutOptions: convert.FormatOptions{"columns": "id:1:2,name:3:2"}})
+	if err == nil || !strings.Contains(err.Error(), `value "Ann" of column "name" is longer than 2 characters`) {
+		t.Errorf("expected a value too long to fail, got %v", err)
+	}
+}
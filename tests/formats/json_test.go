@@ -1,14 +1,61 @@
 package formats_test
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"omnidata/internal/convert"
 	_ "omnidata/internal/formats" // triggers init() for format registration
 )
 
+// TestJSONKeyOrder keeps the key order of JSON and YAML records as the column order,
+// in memory and when streamed.
+func TestJSONKeyOrder(t *testing.T) {
+	for _, tc := range []struct {
+		from, input string
+		stream      bool
+	}{
+		{"json", `[{"id":1,"price":2.5,"when":"today","name":"Ann"},{"name":"Bob","id":2}]`, false},
+		{"json", `[{"id":1,"price":2.5,"when":"today","name":"Ann"},{"name":"Bob","id":2}]`, true},
+		{"yaml", "- id: 1\n  price: 2.5\n  when: today\n  name: Ann\n- name: Bob\n  id: 2\n", false},
+	} {
+		var out strings.Builder
+		opts := convert.Options{From: tc.from, To: "csv", Stream: tc.stream}
+		if _, err := convert.Transcode(context.Background(), strings.NewReader(tc.input), &out, opts); err != nil {
+			t.Fatalf("%s -> CSV (stream=%v) failed: %v", tc.from, tc.stream, err)
+		}
+		if out.String() != "id,price,when,name\n1,2.5,today,Ann\n2,,,Bob\n" {
+			t.Errorf("%s -> CSV (stream=%v): unexpected output %q", tc.from, tc.stream, out.String())
+		}
+	}
+}
+
+// TestJSONStreamIndent writes the same JSON with --stream as in memory for every indent.
+func TestJSONStreamIndent(t *testing.T) {
+	input := "id,name\n1,Ann\n2,Bob\n"
+	for _, indent := range []string{"", "0", "8"} {
+		outOpts := convert.FormatOptions{}
+		if indent != "" {
+			outOpts["indent"] = indent
+		}
+		var inMemory, streamed strings.Builder
+		opts := convert.Options{From: "csv", To: "json", OutOptions: outOpts}
+		if _, err := convert.Transcode(context.Background(), strings.NewReader(input), &inMemory, opts); err != nil {
+			t.Fatalf("CSV -> JSON (indent=%q) failed: %v", indent, err)
+		}
+		opts.Stream = true
+		if _, err := convert.Transcode(context.Background(), strings.NewReader(input), &streamed, opts); err != nil {
+			t.Fatalf("streaming CSV -> JSON (indent=%q) failed: %v", indent, err)
+		}
+		if streamed.String() != inMemory.String() {
+			t.Errorf("indent=%q: streamed %q, in memory %q", indent, streamed.String(), inMemory.String())
+		}
+	}
+}
+
 // TestJSONReadWrite verifies that the JSON format handler can correctly write and read JSON files.
 func TestJSONReadWrite(t *testing.T) {
 	handler, ok := convert.GetFormat("json")
@@ -33,7 +80,7 @@ func TestJSONReadWrite(t *testing.T) {
 	}
 	defer fOut.Close()
 
-	if err := handler.WriterFn(fOut, outputJSON, data); err != nil {
+	if err := handler.WriterFn(context.Background(), fOut, outputJSON, data, nil); err != nil {
 		t.Fatalf("failed to write JSON: %v", err)
 	}
 
@@ -44,7 +91,7 @@ func TestJSONReadWrite(t *testing.T) {
 	}
 	defer fIn.Close()
 
-	readData, err := handler.ReaderFn(fIn, outputJSON)
+	readData, err := handler.ReaderFn(context.Background(), fIn, outputJSON, nil)
 	if err != nil {
 		t.Fatalf("failed to read JSON: %v", err)
 	}
@@ -65,11 +112,11 @@ func TestJSON_InvalidCases(t *testing.T) {
 		t.Fatal("JSON handler not registered")
 	}
 	// Invalid type for WriterFn
-	if err := handler.WriterFn(os.Stdout, "foo.json", make(chan int)); err == nil {
+	if err := handler.WriterFn(context.Background(), os.Stdout, "foo.json", make(chan int), nil); err == nil {
 		t.Error("expected error for invalid type, got nil")
 	}
 	// Nil reader
-	_, err := handler.ReaderFn(nil, "foo.json")
+	_, err := handler.ReaderFn(context.Background(), nil, "foo.json", nil)
 	if err == nil {
 		t.Error("expected error for nil reader, got nil")
 	}
@@ -81,7 +128,7 @@ func TestJSON_InvalidCases(t *testing.T) {
 	defer tmp.Close()
 	defer os.Remove(tmp.Name())
 
-	_, err = handler.ReaderFn(tmp, tmp.Name())
+	_, err = handler.ReaderFn(context.Background(), tmp, tmp.Name(), nil)
 	if err == nil {
 		t.Error("expected error for malformed JSON, got nil")
 	}
@@ -90,7 +137,7 @@ func TestJSON_InvalidCases(t *testing.T) {
 	defer empty.Close()
 	defer os.Remove(empty.Name())
 
-	_, err = handler.ReaderFn(empty, empty.Name())
+	_, err = handler.ReaderFn(context.Background(), empty, empty.Name(), nil)
 	if err == nil {
 		t.Error("expected error for empty file, got nil")
 	}
@@ -0,0 +1,94 @@
+package formats_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+	_ "omnidata/internal/formats"
+	"omnidata/pkg/omnidata"
+)
+
+// TestNDJSONReadWrite reads objects line by line, skipping blank lines, and writes them back.
+func TestNDJSONReadWrite(t *testing.T) {
+	handler, ok := convert.GetFormat("ndjson")
+	if !ok {
+		t.Fatal("NDJSON handler not registered")
+	}
+
+	input := "{\"id\":1,\"user\":{\"name\":\"Alice\"}}\n\n   \n{\"id\":2,\"score\":9.5}\n"
+	data, err := handler.ReaderFn(context.Background(), strings.NewReader(input), "in.ndjson", nil)
+	if err != nil {
+		t.Fatalf("failed to read NDJSON: %v", err)
+	}
+	ds := data.(*dataset.Dataset)
+	if strings.Join(ds.Columns, ",") != "id,user,score" || ds.Len() != 2 {
+		t.Fatalf("unexpected dataset: %v with %d records", ds.Columns, ds.Len())
+	}
+	if ds.Value(1, "id") != int64(2) || ds.Value(0, "score") != nil {
+		t.Errorf("unexpected values: %v", ds.Records)
+	}
+
+	var out bytes.Buffer
+	if err := handler.WriterFn(context.Background(), &out, "", ds, nil); err != nil {
+		t.Fatalf("failed to write NDJSON: %v", err)
+	}
+	want := "{\"id\":1,\"user\":{\"name\":\"Alice\"},\"score\":null}\n{\"id\":2,\"user\":null,\"score\":9.5}\n"
+	if out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+
+	_, err = handler.ReaderFn(context.Background(), strings.NewReader("{\"id\":1}\n\n[1]\n"), "bad.jsonl", nil)
+	if err == nil || !strings.Contains(err.Error(), "line 3: expected a JSON object") {
+		t.Errorf("expected the error to report line 3, got %v", err)
+	}
+}
+
+// TestNDJSONDetection verifies the jsonl alias, path detection and the hint for JSON readers.
+func TestNDJSONDetection(t *testing.T) {
+	for path, want := range map[string]string{"logs.jsonl": "ndjson", "logs.ndjson.gz": "ndjson", "logs.json": "json"} {
+		if got, ok := convert.DetectFormatFromPath(path); !ok || got != want {
+			t.Errorf("DetectFormatFromPath(%q) = %q; want %q", path, got, want)
+		}
+	}
+	if _, ok := convert.GetFormat("jsonl"); !ok {
+		t.Error("expected jsonl to be registered")
+	}
+
+	jsonHandler, _ := convert.GetFormat("json")
+	_, err := jsonHandler.ReaderFn(context.Background(), strings.NewReader("{\"id\":1}\n{\"id\":2}\n"), "logs.json", nil)
+	if err == nil || !strings.Contains(err.Error(), "ndjson") {
+		t.Errorf("expected reading JSON Lines as JSON to fail with a hint, got %v", err)
+	}
+}
+
+// TestNDJSONStreamAndPeek streams CSV to JSON Lines, skipping bad lines, and peeks at the result.
+func TestNDJSONStreamAndPeek(t *testing.T) {
+	var out bytes.Buffer
+	result, err := convert.Transcode(context.Background(), strings.NewReader("id,name\n1,Alice\n2,Bob\n"), &out,
+		convert.Options{From: "csv", To: "jsonl", Stream: true})
+	if err != nil || len(result.Warnings) != 0 {
+		t.Fatalf("Transcode failed: %v %v", err, result)
+	}
+	if out.String() != "{\"id\":\"1\",\"name\":\"Alice\"}\n{\"id\":\"2\",\"name\":\"Bob\"}\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	var csv bytes.Buffer
+	result, err = convert.Transcode(context.Background(), strings.NewReader("{\"id\":1}\n{oops\n{\"id\":3}\n"), &csv,
+		convert.Options{From: "ndjson", To: "csv", Stream: true, OnError: convert.ErrorPolicy{Mode: convert.OnErrorSkip}})
+	if err != nil || result.Rejected != 1 || csv.String() != "id\n1\n3\n" {
+		t.Errorf("expected the bad line to be skipped, got %q, %+v, %v", csv.String(), result, err)
+	}
+
+	preview, err := omnidata.Peek(context.Background(), &out, omnidata.ReadOptions{Format: "ndjson"}, 5)
+	if err != nil {
+		t.Fatalf("Peek failed: %v", err)
+	}
+	if preview.Schema.RowCount != 2 || preview.Schema.Column("name") == nil || len(preview.Rows) != 2 {
+		t.Errorf("unexpected peek result: %+v", preview.Schema)
+	}
+}
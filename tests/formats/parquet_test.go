@@ -1,12 +1,21 @@
 package formats_test
 
 import (
+	"bytes"
+	"context"
+	"math/big"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
 	_ "omnidata/internal/formats"
+	"omnidata/internal/inspect"
+
+	"github.com/parquet-go/parquet-go"
 )
 
 func TestParquetReader_Errors(t *testing.T) {
@@ -15,18 +24,22 @@ func TestParquetReader_Errors(t *testing.T) {
 		t.Fatal("Parquet handler not registered")
 	}
 	// Test nil reader
-	_, err := handler.ReaderFn(nil, "")
+	_, err := handler.ReaderFn(context.Background(), nil, "", nil)
 	if err == nil || err.Error() == "" {
 		t.Error("Expected error for nil reader")
 	}
 
-	// Test dependency error
+	// Test empty and non-Parquet input
 	f, _ := os.CreateTemp(os.TempDir(), "tmpparquet.parquet")
 	defer os.Remove(f.Name())
 	defer f.Close()
-	_, err = handler.ReaderFn(f, f.Name())
-	if err == nil || err.Error() == "" || !strings.Contains(err.Error(), "Parquet format support") {
-		t.Error("Expected Parquet dependency error")
+	_, err = handler.ReaderFn(context.Background(), f, f.Name(), nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid Parquet file") {
+		t.Errorf("Expected an empty file to be rejected, got %v", err)
+	}
+	_, err = handler.ReaderFn(context.Background(), strings.NewReader("id,name\n1,Ann\n"), "data.csv", nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid Parquet file") {
+		t.Errorf("Expected CSV input to be rejected, got %v", err)
 	}
 }
 
@@ -36,18 +49,241 @@ func TestParquetWriter_Errors(t *testing.T) {
 		t.Fatal("Parquet handler not registered")
 	}
 	// Nil writer
-	err := handler.WriterFn(nil, "", [][]string{})
+	err := handler.WriterFn(context.Background(), nil, "", [][]string{}, nil)
 	if err == nil {
 		t.Error("Expected error for nil writer")
 	}
 	// Wrong type
-	err = handler.WriterFn(os.Stdout, "foo.parquet", 123)
+	err = handler.WriterFn(context.Background(), os.Stdout, "foo.parquet", 123, nil)
 	if err == nil || err.Error() == "" {
 		t.Error("Expected error for Parquet write with wrong type")
 	}
-	// Correct type, dependency error
-	err = handler.WriterFn(os.Stdout, "foo.parquet", [][]string{{"a"}})
-	if err == nil || err.Error() == "" || !strings.Contains(err.Error(), "Parquet format support") {
-		t.Error("Expected Parquet dependency error writing file")
+	// Unknown compression and bad row group size
+	if err := handler.ValidateWriterOptions(convert.FormatOptions{"compression": "lz4"}); err == nil {
+		t.Error("Expected an unknown compression to be rejected")
+	}
+	err = handler.WriterFn(context.Background(), &bytes.Buffer{}, "foo.parquet", [][]string{{"a"}}, convert.FormatOptions{"row_group_size": "0"})
+	if err == nil || !strings.Contains(err.Error(), "row_group_size must be 1 or more") {
+		t.Errorf("Expected a bad row group size to be rejected, got %v", err)
+	}
+}
+
+// TestParquetRoundTrip converts CSV to Parquet and back with each codec, in memory and streaming.
+func TestParquetRoundTrip(t *testing.T) {
+	input := "id,name,score,active,note\n1,Ann,3.5,true,\n2,Zoë,4,false,x\n3,Bob,,true,y\n"
+
+	for _, codec := range []string{"snappy", "gzip", "uncompressed"} {
+		for _, streaming := range []bool{false, true} {
+			var pq bytes.Buffer
+			_, err := convert.Transcode(context.Background(), strings.NewReader(input), &pq, convert.Options{
+				From: "csv", To: "parquet", Stream: streaming,
+				OutOptions: convert.FormatOptions{"compression": codec, "row_group_size": "2"},
+			})
+			if err != nil {
+				t.Fatalf("%s, stream=%v: conversion to Parquet failed: %v", codec, streaming, err)
+			}
+			if !bytes.HasPrefix(pq.Bytes(), []byte("PAR1")) {
+				t.Fatalf("%s, stream=%v: output is not a Parquet file", codec, streaming)
+			}
+
+			var out bytes.Buffer
+			_, err = convert.Transcode(context.Background(), &pq, &out, convert.Options{From: "parquet", To: "csv", Stream: streaming})
+			if err != nil {
+				t.Fatalf("%s, stream=%v: conversion from Parquet failed: %v", codec, streaming, err)
+			}
+			if out.String() != input {
+				t.Errorf("%s, stream=%v: expected %q, got %q", codec, streaming, input, out.String())
+			}
+		}
+	}
+}
+
+// TestTypedFormatsKeepNumericText keeps zip codes, phone numbers and integers beyond
+// int64 as they were written when CSV goes through Parquet, Avro or Arrow.
+func TestTypedFormatsKeepNumericText(t *testing.T) {
+	input := "zip,phone,count,id\n01234,+15550100,7,12345678901234567890\n90210,+15550101,-3,1\n"
+	for _, format := range []string{"parquet", "avro", "arrow"} {
+		var typed, out bytes.Buffer
+		if _, err := convert.Transcode(context.Background(), strings.NewReader(input), &typed, convert.Options{From: "csv", To: format}); err != nil {
+			t.Fatalf("%s: conversion failed: %v", format, err)
+		}
+		if _, err := convert.Transcode(context.Background(), &typed, &out, convert.Options{From: format, To: "csv"}); err != nil {
+			t.Fatalf("%s: conversion back failed: %v", format, err)
+		}
+		if out.String() != input {
+			t.Errorf("%s: expected %q, got %q", format, input, out.String())
+		}
+	}
+
+	ds := dataset.FromRows([][]string{{"zip", "count"}, {"01234", "7"}, {"NaN", "-3"}})
+	for i, want := range []string{"string", "number"} {
+		if got := inspect.InferDatasetSchema(ds, "csv").Columns[i].Type; got != want {
+			t.Errorf("column %d: expected %s, got %s", i, want, got)
+		}
+	}
+}
+
+// TestParquetLogicalTypes writes dates, timestamps, decimals and nulls and reads them back typed.
+func TestParquetLogicalTypes(t *testing.T) {
+	handler, _ := convert.GetFormat("parquet")
+	seen := time.Date(2024, 5, 1, 10, 30, 0, 123456000, time.UTC)
+	wide, _ := new(big.Int).SetString("-12345678901234567890123", 10)
+
+	ds := dataset.New([]string{"id", "born", "seen", "amount", "total", "photo", "name"})
+	ds.Append(int64(1), dataset.Date{Year: 1960, Month: time.March, Day: 4}, seen, dataset.Decimal{Unscaled: big.NewInt(1250), Scale: 2}, dataset.Decimal{Unscaled: wide, Scale: 3}, []byte{0, 1}, "Ann")
+	ds.Append(int64(2), nil, seen.Add(time.Hour), dataset.Decimal{Unscaled: big.NewInt(-31), Scale: 1}, dataset.Decimal{Unscaled: big.NewInt(5)}, nil, nil)
+
+	var pq bytes.Buffer
+	if err := handler.WriterFn(context.Background(), &pq, "people.parquet", ds, nil); err != nil {
+		t.Fatalf("failed to write Parquet: %v", err)
+	}
+	file, err := parquet.OpenFile(bytes.NewReader(pq.Bytes()), int64(pq.Len()))
+	if err != nil {
+		t.Fatalf("failed to open the output: %v", err)
+	}
+	schema := file.Schema().String()
+	for _, want := range []string{
+		"required int64 id (INT(64,true));",
+		"optional int32 born (DATE);",
+		"required int64 seen (TIMESTAMP(isAdjustedToUTC=true,unit=MICROS));",
+		"required int64 amount (DECIMAL(4,2));",
+		"required fixed_len_byte_array(16) total (DECIMAL(23,3));",
+		"optional binary photo;",
+		"optional binary name (STRING);",
+	} {
+		if !strings.Contains(schema, want) {
+			t.Errorf("expected the schema to contain %s, got\n%s", want, schema)
+		}
+	}
+	if strings.Index(schema, " id ") > strings.Index(schema, " born ") {
+		t.Errorf("expected the columns in dataset order, got\n%s", schema)
+	}
+
+	data, err := handler.ReaderFn(context.Background(), &pq, "people.parquet", nil)
+	if err != nil {
+		t.Fatalf("failed to read Parquet: %v", err)
+	}
+	got := data.(*dataset.Dataset)
+	if strings.Join(got.Columns, ",") != "id,born,seen,amount,total,photo,name" {
+		t.Fatalf("unexpected columns %v", got.Columns)
+	}
+	if v := got.Value(0, "born"); v != (dataset.Date{Year: 1960, Month: time.March, Day: 4}) {
+		t.Errorf("expected a date, got %#v", v)
+	}
+	if v, ok := got.Value(0, "seen").(time.Time); !ok || !v.Equal(seen) {
+		t.Errorf("expected a timestamp, got %#v", got.Value(0, "seen"))
+	}
+	if v, ok := got.Value(1, "amount").(dataset.Decimal); !ok || v.String() != "-3.10" {
+		t.Errorf("expected a decimal, got %#v", got.Value(1, "amount"))
+	}
+	if v, ok := got.Value(0, "total").(dataset.Decimal); !ok || v.String() != "-12345678901234567890.123" {
+		t.Errorf("expected a wide decimal, got %#v", got.Value(0, "total"))
+	}
+	if !bytes.Equal(got.Value(0, "photo").([]byte), []byte{0, 1}) || got.Value(1, "born") != nil || got.Value(1, "name") != nil {
+		t.Errorf("unexpected records %v", got.Records)
+	}
+}
+
+// TestParquetNested reads lists, maps and groups written by another Parquet writer.
+func TestParquetNested(t *testing.T) {
+	type address struct {
+		City string  `parquet:"city"`
+		Zip  *string `parquet:"zip,optional"`
+	}
+	type order struct {
+		ID      int64            `parquet:"id"`
+		Tags    []string         `parquet:"tags,list"`
+		Scores  []int32          `parquet:"scores"`
+		Counts  map[string]int64 `parquet:"counts"`
+		Address address          `parquet:"address"`
+	}
+	var pq bytes.Buffer
+	err := parquet.Write(&pq, []order{
+		{ID: 1, Tags: []string{"a", "b"}, Scores: []int32{3}, Counts: map[string]int64{"x": 2}, Address: address{City: "Oslo"}},
+		{ID: 2},
+	})
+	if err != nil {
+		t.Fatalf("failed to write Parquet: %v", err)
+	}
+
+	var out bytes.Buffer
+	_, err = convert.Transcode(context.Background(), &pq, &out, convert.Options{From: "parquet", To: "ndjson", Stream: true})
+	if err != nil {
+		t.Fatalf("conversion failed: %v", err)
+	}
+	want := `{"id":1,"tags":["a","b"],"scores":[3],"counts":{"x":2},"address":{"city":"Oslo","zip":null}}` + "\n" +
+		`{"id":2,"tags":[],"scores":[],"counts":{},"address":{"city":"","zip":null}}` + "\n"
+	if out.String() != want {
+		t.Errorf("expected %s, got %s", want, out.String())
+	}
+}
+
+// TestParquetRowGroups splits rows into row groups, and stops on values the inferred types reject.
+func TestParquetRowGroups(t *testing.T) {
+	input := "id,name\n1,a\n2,b\n3,c\n4,d\n5,e\n"
+	var pq bytes.Buffer
+	_, err := convert.Transcode(context.Background(), strings.NewReader(input), &pq, convert.Options{
+		From: "csv", To: "parquet", Stream: true, OutOptions: convert.FormatOptions{"row_group_size": "2"},
+	})
+	if err != nil {
+		t.Fatalf("conversion failed: %v", err)
+	}
+	file, err := parquet.OpenFile(bytes.NewReader(pq.Bytes()), int64(pq.Len()))
+	if err != nil {
+		t.Fatalf("failed to open the output: %v", err)
+	}
+	if len(file.RowGroups()) != 3 || file.NumRows() != 5 {
+		t.Errorf("expected 5 rows in 3 row groups, got %d in %d", file.NumRows(), len(file.RowGroups()))
+	}
+
+	_, err = convert.Transcode(context.Background(), strings.NewReader("id\n1\n2\nx\n"), &bytes.Buffer{}, convert.Options{
+		From: "csv", To: "parquet", Stream: true, OutOptions: convert.FormatOptions{"row_group_size": "2"},
+	})
+	if err == nil || !strings.Contains(err.Error(), `row 3, column "id"`) || !strings.Contains(err.Error(), "raise row_group_size") {
+		t.Errorf("expected a value of another type to fail, got %v", err)
+	}
+}
+
+// TestParquetPeek inspects a Parquet file through peek, with the statistics of its footer.
+func TestParquetPeek(t *testing.T) {
+	handler, _ := convert.GetFormat("parquet")
+	ds := dataset.New([]string{"id", "name", "price", "day"})
+	ds.Append(int64(1), "Ann", 2.5, dataset.Date{Year: 2024, Month: time.January, Day: 3})
+	ds.Append(int64(2), nil, 3.0, dataset.Date{Year: 2024, Month: time.January, Day: 1})
+	ds.Append(int64(3), "Cid", 10.0, dataset.Date{Year: 2024, Month: time.February, Day: 1})
+
+	path := filepath.Join(t.TempDir(), "prices.parquet")
+	f, _ := os.Create(path)
+	err := handler.WriterFn(context.Background(), f, path, ds, convert.FormatOptions{"row_group_size": "2"})
+	f.Close()
+	if err != nil {
+		t.Fatalf("failed to write Parquet: %v", err)
+	}
+
+	f, _ = os.Open(path)
+	defer f.Close()
+	preview, err := inspect.Peek(context.Background(), f, path, inspect.PeekOptions{Format: "parquet", Rows: 1})
+	if err != nil {
+		t.Fatalf("Peek failed: %v", err)
+	}
+	schema := preview.Schema
+	if schema.RowCount != 3 || len(preview.Preview) != 1 || preview.Preview[0]["price"] != "2.5" {
+		t.Fatalf("unexpected peek result: %+v %v", schema, preview.Preview)
+	}
+	for i, want := range []struct {
+		typ      string
+		nullable bool
+		stats    inspect.ColumnStats
+	}{
+		{"number", false, inspect.ColumnStats{Min: "1", Max: "3"}},
+		{"string", true, inspect.ColumnStats{NullCount: 1, Min: "Ann", Max: "Cid"}},
+		{"number", false, inspect.ColumnStats{Min: "2.5", Max: "10"}},
+		{"date", false, inspect.ColumnStats{Min: "2024-01-01", Max: "2024-02-01"}},
+	} {
+		column := schema.Columns[i]
+		if column.Type != want.typ || column.Nullable != want.nullable || column.Stats == nil || *column.Stats != want.stats {
+			t.Errorf("column %s: expected %s nullable=%v %+v, got %s nullable=%v %+v",
+				column.Name, want.typ, want.nullable, want.stats, column.Type, column.Nullable, column.Stats)
+		}
 	}
 }
@@ -0,0 +1,133 @@
+package formats_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+	_ "omnidata/internal/formats"
+)
+
+// TestSQLTypedReadWrite verifies that SQL values keep their types through a dataset
+// and that NULLs survive as null in JSON output.
+func TestSQLTypedReadWrite(t *testing.T) {
+	handler, ok := convert.GetFormat("sql")
+	if !ok {
+		t.Fatal("SQL handler not registered")
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	stmts := []string{
+		"CREATE TABLE people (id INTEGER, name TEXT, score REAL, price NUMERIC, born DATE, nick TEXT)",
+		"INSERT INTO people VALUES (1, 'Alice', 9.5, '12.50', '1990-05-01', NULL)",
+		"CREATE TABLE copy (id INTEGER, name TEXT, score REAL, price NUMERIC, born DATE, nick TEXT)",
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("setup failed (%s): %v", stmt, err)
+		}
+	}
+
+	data, err := handler.ReaderFn(context.Background(), nil, "sqlite3://"+dbPath+"?table=people", nil)
+	if err != nil {
+		t.Fatalf("failed to read SQL: %v", err)
+	}
+	ds, err := handler.AsDataset(data)
+	if err != nil {
+		t.Fatalf("failed to adapt SQL data: %v", err)
+	}
+	if ds.Len() != 1 {
+		t.Fatalf("expected 1 record, got %d", ds.Len())
+	}
+
+	expect := map[string]dataset.Kind{
+		"id":    dataset.KindInt,
+		"name":  dataset.KindString,
+		"score": dataset.KindFloat,
+		"born":  dataset.KindDate,
+		"nick":  dataset.KindNull,
+	}
+	for col, kind := range expect {
+		if got := dataset.KindOf(ds.Value(0, col)); got != kind {
+			t.Errorf("column %s: kind %s, want %s", col, got, kind)
+		}
+	}
+
+	// JSON renders numbers as numbers and NULL as null
+	jsonHandler, _ := convert.GetFormat("json")
+	var buf bytes.Buffer
+	if err := jsonHandler.WriterFn(context.Background(), &buf, "out.json", ds, nil); err != nil {
+		t.Fatalf("failed to write JSON: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{`"id": 1`, `"score": 9.5`, `"nick": null`, `"born": "1990-05-01"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("JSON output missing %s:\n%s", want, out)
+		}
+	}
+
+	// Write the typed dataset back into another table
+	if err := handler.WriterFn(context.Background(), nil, "sqlite3://"+dbPath+"?table=copy", ds, nil); err != nil {
+		t.Fatalf("failed to write SQL: %v", err)
+	}
+	var id int64
+	var nick sql.NullString
+	if err := db.QueryRow("SELECT id, nick FROM copy").Scan(&id, &nick); err != nil {
+		t.Fatalf("failed to query copy: %v", err)
+	}
+	if id != 1 || nick.Valid {
+		t.Errorf("unexpected copied row: id=%d nick=%v", id, nick)
+	}
+}
+
+// TestSQLWriteTransaction verifies that a failed or cancelled write leaves the table untouched.
+func TestSQLWriteTransaction(t *testing.T) {
+	handler, _ := convert.GetFormat("sql")
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE people (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	ds := dataset.FromRows([][]string{{"id", "name"}, {"1", "Alice"}, {"2", "Bob"}, {"1", "Duplicate"}})
+	resource := "sqlite3://" + dbPath + "?table=people"
+
+	err = handler.WriterFn(context.Background(), nil, resource, ds, nil)
+	if err == nil || !strings.Contains(err.Error(), "failed to insert row 3") {
+		t.Fatalf("expected the duplicate key to fail row 3, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := handler.WriterFn(ctx, nil, resource, dataset.FromRows([][]string{{"id"}, {"5"}}), nil); err == nil {
+		t.Fatal("expected a cancelled write to fail")
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM people").Scan(&count); err != nil {
+		t.Fatalf("failed to count rows: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("expected the failed writes to be rolled back, found %d rows", count)
+	}
+
+	if _, err := handler.ReaderFn(ctx, nil, resource, nil); err == nil {
+		t.Error("expected a cancelled read to fail")
+	}
+}
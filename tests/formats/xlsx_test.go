@@ -1,12 +1,17 @@
 package formats_test
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"omnidata/internal/convert"
 	_ "omnidata/internal/formats" // triggers init() for format registration
+
+	"github.com/xuri/excelize/v2"
 )
 
 // TestXLSXReadWrite verifies that the XLSX format handler can correctly write and read Excel files.
@@ -35,7 +40,7 @@ func TestXLSXReadWrite(t *testing.T) {
 	}
 	defer fOut.Close()
 
-	if err := handler.WriterFn(fOut, outputXLSX, data); err != nil {
+	if err := handler.WriterFn(context.Background(), fOut, outputXLSX, data, nil); err != nil {
 		t.Fatalf("failed to write XLSX: %v", err)
 	}
 
@@ -46,13 +51,13 @@ func TestXLSXReadWrite(t *testing.T) {
 	}
 	defer fIn.Close()
 
-	readData, err := handler.ReaderFn(fIn, outputXLSX)
+	readData, err := handler.ReaderFn(context.Background(), fIn, outputXLSX, nil)
 	if err != nil {
 		t.Fatalf("failed to read XLSX: %v", err)
 	}
 
 	// Type assertion
-	readMap, ok := readData.(map[string][][]string)
+	readMap, ok := readData.(map[string][][]interface{})
 	if !ok {
 		t.Fatal("read XLSX data has incorrect type")
 	}
@@ -64,7 +69,7 @@ func TestXLSXReadWrite(t *testing.T) {
 
 	// Verify specific cell value
 	if readMap["Sheet1"][1][0] != "Alice" {
-		t.Fatalf("unexpected value in row 2, col 1: %s", readMap["Sheet1"][1][0])
+		t.Fatalf("unexpected value in row 2, col 1: %v", readMap["Sheet1"][1][0])
 	}
 }
 
@@ -74,13 +79,13 @@ func TestXLSX_InvalidCases(t *testing.T) {
 		t.Fatal("XLSX handler not registered")
 	}
 	// Nil reader
-	_, err := handler.ReaderFn(nil, "foo.xlsx")
+	_, err := handler.ReaderFn(context.Background(), nil, "foo.xlsx", nil)
 	if err == nil {
 		t.Error("expected error for nil reader, got nil")
 	}
 
 	// WriterFn wrong type
-	if err := handler.WriterFn(os.Stdout, "foo.xlsx", 12345); err == nil {
+	if err := handler.WriterFn(context.Background(), os.Stdout, "foo.xlsx", 12345, nil); err == nil {
 		t.Error("expected error for WriterFn wrong type, got nil")
 	}
 
@@ -88,7 +93,58 @@ func TestXLSX_InvalidCases(t *testing.T) {
 	f, _ := os.CreateTemp(os.TempDir(), "empty-out.xlsx")
 	defer f.Close()
 	defer os.Remove(f.Name())
-	if err := handler.WriterFn(f, f.Name(), map[string][][]string{}); err != nil {
+	if err := handler.WriterFn(context.Background(), f, f.Name(), map[string][][]string{}, nil); err != nil {
 		// Acceptable if fails gracefully, but not panic
 	}
 }
+
+// TestXLSXTypedCells reads numbers, booleans and dates from their cell types and number
+// formats, so they reach JSON typed instead of as text.
+func TestXLSXTypedCells(t *testing.T) {
+	f := excelize.NewFile()
+	defer f.Close()
+	dayMonth, err := f.NewStyle(&excelize.Style{CustomNumFmt: strPtr(`dd.mm.yyyy`)})
+	if err != nil {
+		t.Fatalf("failed to create style: %v", err)
+	}
+	price, err := f.NewStyle(&excelize.Style{CustomNumFmt: strPtr(`#,##0.00 "EUR"`)})
+	if err != nil {
+		t.Fatalf("failed to create style: %v", err)
+	}
+	for cell, value := range map[string]interface{}{
+		"A1": "id", "B1": "price", "C1": "paid", "D1": "born", "E1": "seen", "F1": "code", "G1": "note",
+		"A2": 1, "B2": 9.5, "C2": true, "D2": 32937, "E2": time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC), "F2": "007", "G2": "first",
+		"A3": 2, "B3": 12, "C3": false, "D3": 45000, "F3": "008",
+	} {
+		if err := f.SetCellValue("Sheet1", cell, value); err != nil {
+			t.Fatalf("failed to set %s: %v", cell, err)
+		}
+	}
+	f.SetCellStyle("Sheet1", "D2", "D3", dayMonth)
+	f.SetCellStyle("Sheet1", "B2", "B3", price)
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "typed.xlsx")
+	if err := f.SaveAs(input); err != nil {
+		t.Fatalf("failed to save workbook: %v", err)
+	}
+	output := filepath.Join(dir, "typed.json")
+	opts := convert.Options{InputFile: input, OutputFile: output, OutOptions: convert.FormatOptions{"indent": "0"}}
+	if err := convert.Run(context.Background(), opts); err != nil {
+		t.Fatalf("XLSX -> JSON failed: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	want := `[{"id":1,"price":9.5,"paid":true,"born":"1990-03-05","seen":"2024-05-01T10:30:00Z","code":"007","note":"first"},` +
+		`{"id":2,"price":12,"paid":false,"born":"2023-03-15","seen":null,"code":"008","note":null}]`
+	if got := strings.TrimSpace(string(data)); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+// strPtr returns a pointer to s, for excelize style options.
+func strPtr(s string) *string {
+	return &s
+}
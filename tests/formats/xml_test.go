@@ -1,12 +1,18 @@
 package formats_test
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"os"
 	"strings"
 	"testing"
 
 	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
 	_ "omnidata/internal/formats" // triggers init() for format registration
+	"omnidata/internal/inspect"
+	"omnidata/internal/stream"
 )
 
 // normalizeXML removes whitespace and newlines for comparison
@@ -48,7 +54,7 @@ func TestXMLReadWrite(t *testing.T) {
 	if _, err := inputFile.Seek(0, 0); err != nil {
 		t.Fatalf("failed to seek: %v", err)
 	}
-	data, err := handler.ReaderFn(inputFile, inputFile.Name())
+	data, err := handler.ReaderFn(context.Background(), inputFile, inputFile.Name(), nil)
 	if err != nil {
 		t.Fatalf("failed to read XML: %v", err)
 	}
@@ -68,7 +74,7 @@ func TestXMLReadWrite(t *testing.T) {
 	}()
 
 	// Write XML back to output file
-	if err := handler.WriterFn(outputFile, outputFile.Name(), data); err != nil {
+	if err := handler.WriterFn(context.Background(), outputFile, outputFile.Name(), data, nil); err != nil {
 		t.Fatalf("failed to write XML: %v", err)
 	}
 
@@ -90,12 +96,12 @@ func TestXML_InvalidCases(t *testing.T) {
 	}
 
 	// Invalid type for WriterFn
-	if err := handler.WriterFn(os.Stdout, "foo.xml", make(chan int)); err == nil {
+	if err := handler.WriterFn(context.Background(), os.Stdout, "foo.xml", make(chan int), nil); err == nil {
 		t.Error("expected error for invalid type, got nil")
 	}
 
 	// Nil reader
-	_, err := handler.ReaderFn(nil, "foo.xml")
+	_, err := handler.ReaderFn(context.Background(), nil, "foo.xml", nil)
 	if err == nil {
 		t.Error("expected error for nil reader, got nil")
 	}
@@ -118,7 +124,7 @@ func TestXML_InvalidCases(t *testing.T) {
 	}()
 	fBad, _ := os.Open(tmp.Name())
 	defer fBad.Close()
-	_, err = handler.ReaderFn(fBad, tmp.Name())
+	_, err = handler.ReaderFn(context.Background(), fBad, tmp.Name(), nil)
 	if err == nil {
 		t.Error("expected error for malformed XML, got nil")
 	}
@@ -138,8 +144,239 @@ func TestXML_InvalidCases(t *testing.T) {
 	}()
 	fEmpty, _ := os.Open(empty.Name())
 	defer fEmpty.Close()
-	_, err = handler.ReaderFn(fEmpty, empty.Name())
+	_, err = handler.ReaderFn(context.Background(), fEmpty, empty.Name(), nil)
 	if err == nil {
 		t.Error("expected error for empty file, got nil")
 	}
 }
+
+const ordersXML = `<?xml version="1.0"?>
+<shop>
+  <meta><name>Corner</name></meta>
+  <orders>
+    <order id="1" status="paid"><customer>Ann</customer><total>9.50</total></order>
+    <order id="2"><customer>Bob</customer><total>12</total><note>gift</note></order>
+  </orders>
+</shop>`
+
+// TestXMLRecordPath selects repeating elements by path and maps their attributes and
+// child elements to columns.
+func TestXMLRecordPath(t *testing.T) {
+	for _, path := range []string{"/shop/orders/order", "orders/order", "/*/orders/order"} {
+		var out bytes.Buffer
+		_, err := convert.Transcode(context.Background(), strings.NewReader(ordersXML), &out, convert.Options{
+			From: "xml", To: "csv", InOptions: convert.FormatOptions{"record_path": path},
+		})
+		if err != nil {
+			t.Fatalf("%s: conversion failed: %v", path, err)
+		}
+		want := "@id,@status,customer,total,note\n1,paid,Ann,9.50,\n2,,Bob,12,gift\n"
+		if out.String() != want {
+			t.Errorf("%s: expected %q, got %q", path, want, out.String())
+		}
+	}
+
+	handler, _ := convert.GetFormat("xml")
+	data, err := handler.ReaderFn(context.Background(), strings.NewReader(ordersXML), "", convert.FormatOptions{"record_path": "/orders/order"})
+	if err != nil {
+		t.Fatalf("failed to read XML: %v", err)
+	}
+	if ds := data.(*dataset.Dataset); len(ds.Records) != 0 {
+		t.Errorf("expected no records under another root, got %v", ds.Records)
+	}
+	for _, path := range []string{"/", "orders//order"} {
+		_, err := handler.ReaderFn(context.Background(), strings.NewReader(ordersXML), "", convert.FormatOptions{"record_path": path})
+		if err == nil || !strings.Contains(err.Error(), "invalid record path") {
+			t.Errorf("expected %q to be rejected, got %v", path, err)
+		}
+	}
+}
+
+// TestXMLRecordPathWrite writes rows under a record path and reads them back.
+func TestXMLRecordPathWrite(t *testing.T) {
+	input := "@id,customer,total\n1,Ann,9.50\n2,Bob & Co,12\n"
+	var out bytes.Buffer
+	_, err := convert.Transcode(context.Background(), strings.NewReader(input), &out, convert.Options{
+		From: "csv", To: "xml", OutOptions: convert.FormatOptions{"record_path": "/shop/orders/order"},
+	})
+	if err != nil {
+		t.Fatalf("conversion to XML failed: %v", err)
+	}
+	want := `<shop><orders><orderid="1"><customer>Ann</customer><total>9.50</total></order>` +
+		`<orderid="2"><customer>Bob&amp;Co</customer><total>12</total></order></orders></shop>`
+	if got := normalizeXML(out.String()); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	var back bytes.Buffer
+	_, err = convert.Transcode(context.Background(), &out, &back, convert.Options{
+		From: "xml", To: "csv", InOptions: convert.FormatOptions{"record_path": "/shop/orders/order"},
+	})
+	if err != nil {
+		t.Fatalf("conversion from XML failed: %v", err)
+	}
+	if back.String() != input {
+		t.Errorf("expected %q, got %q", input, back.String())
+	}
+
+	// Element text alongside attributes
+	ds := dataset.New([]string{"@lang", "#text"})
+	ds.Append("en", "Hello")
+	handler, _ := convert.GetFormat("xml")
+	var text bytes.Buffer
+	if err := handler.WriterFn(context.Background(), &text, "", ds, convert.FormatOptions{"record_path": "greetings/greeting"}); err != nil {
+		t.Fatalf("failed to write XML: %v", err)
+	}
+	if got := normalizeXML(text.String()); got != `<greetings><greetinglang="en">Hello</greeting></greetings>` {
+		t.Errorf("unexpected XML %s", got)
+	}
+	data, err := handler.ReaderFn(context.Background(), &text, "", nil)
+	if err != nil {
+		t.Fatalf("failed to read XML: %v", err)
+	}
+	got, err := handler.AsDataset(data)
+	if err != nil || strings.Join(got.Columns, ",") != "@lang,#text" || got.Value(0, "#text") != "Hello" {
+		t.Errorf("unexpected dataset %v %v (%v)", got.Columns, got.Records, err)
+	}
+
+	// record_path replaces root and row, and names a root and a record element
+	for _, opts := range []convert.FormatOptions{
+		{"record_path": "/shop/order", "row": "order"},
+		{"record_path": "/order"},
+		{"record_path": "/shop/*"},
+	} {
+		if err := handler.WriterFn(context.Background(), &bytes.Buffer{}, "", ds, opts); err == nil {
+			t.Errorf("expected %v to be rejected", opts)
+		}
+	}
+}
+
+// TestXMLPeek infers the schema of XML records like any other tabular input.
+func TestXMLPeek(t *testing.T) {
+	preview, err := inspect.Peek(context.Background(), strings.NewReader(ordersXML), "", inspect.PeekOptions{
+		Format: "xml", Rows: 5, ReaderOptions: convert.FormatOptions{"record_path": "/shop/orders/order"},
+	})
+	if err != nil {
+		t.Fatalf("Peek failed: %v", err)
+	}
+	schema := preview.Schema
+	if schema.RowCount != 2 || len(schema.Columns) != 5 || schema.Columns[0].Name != "@id" || !schema.Columns[4].Nullable {
+		t.Errorf("unexpected schema %+v", schema)
+	}
+	if preview.Preview[1]["customer"] != "Bob" || preview.Preview[1]["note"] != "gift" {
+		t.Errorf("unexpected preview %v", preview.Preview)
+	}
+
+	// Without a record path the children of the root element are the records
+	preview, err = inspect.Peek(context.Background(), strings.NewReader(`<people><person><name>Ann</name></person></people>`), "", inspect.PeekOptions{Format: "xml", Rows: 5})
+	if err != nil {
+		t.Fatalf("Peek failed: %v", err)
+	}
+	if preview.Schema.RowCount != 1 || preview.Preview[0]["name"] != "Ann" {
+		t.Errorf("unexpected peek result %+v %v", preview.Schema, preview.Preview)
+	}
+}
+
+// endlessFeed produces a document that never ends: a root element with ever more items.
+type endlessFeed struct {
+	n       int
+	pending []byte
+}
+
+func (f *endlessFeed) Read(p []byte) (int, error) {
+	if len(f.pending) == 0 {
+		if f.n == 0 {
+			f.pending = []byte(`<feed><meta><skip>me</skip></meta>`)
+		} else {
+			f.pending = []byte(fmt.Sprintf(`<item n="%d"><title>Item %d</title></item>`, f.n, f.n))
+		}
+		f.n++
+	}
+	n := copy(p, f.pending)
+	f.pending = f.pending[n:]
+	return n, nil
+}
+
+// TestXMLStreamReader reads records one at a time from a document that is never complete.
+func TestXMLStreamReader(t *testing.T) {
+	handler, _ := convert.GetFormat("xml")
+	rows, err := handler.StreamReaderFn(context.Background(), &endlessFeed{}, "", convert.FormatOptions{"record_path": "/feed/item"})
+	if err != nil {
+		t.Fatalf("failed to open the stream: %v", err)
+	}
+	defer rows.Close()
+	for i := 1; i <= 3; i++ {
+		row, err := rows.ReadRow()
+		if err != nil {
+			t.Fatalf("failed to read row %d: %v", i, err)
+		}
+		if row["@n"] != fmt.Sprint(i) || row["title"] != fmt.Sprintf("Item %d", i) {
+			t.Errorf("unexpected row %d: %v", i, row)
+		}
+	}
+	if cols := rows.(stream.ColumnReader).Columns(); strings.Join(cols, ",") != "@n,title" {
+		t.Errorf("unexpected columns %v", cols)
+	}
+
+	// Truncated and empty documents
+	for input, want := range map[string]string{
+		`<feed><item n="1"/><item>`: "unexpected EOF",
+		`<feed><item n="1"/>`:       "unexpected EOF",
+		``:                          "no XML root element",
+		`<feed><item></feed>`:       "syntax error",
+	} {
+		rows, _ := handler.StreamReaderFn(context.Background(), strings.NewReader(input), "", nil)
+		var err error
+		for err == nil {
+			_, err = rows.ReadRow()
+		}
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: expected %q, got %v", input, want, err)
+		}
+	}
+}
+
+// TestXMLStreamConversion converts the selected records to CSV and NDJSON row by row.
+func TestXMLStreamConversion(t *testing.T) {
+	schema := &convert.Schema{}
+	for _, name := range []string{"@id", "@status", "customer", "total", "note"} {
+		schema.Columns = append(schema.Columns, convert.SchemaColumn{Name: name, Type: "string", Nullable: true})
+	}
+	for name, c := range map[string]struct {
+		to     string
+		schema *convert.Schema
+		want   string
+	}{
+		"ndjson": {to: "ndjson", want: `{"@id":"1","@status":"paid","customer":"Ann","total":"9.50"}` + "\n" +
+			`{"@id":"2","customer":"Bob","total":"12","note":"gift"}` + "\n"},
+		"csv with schema": {to: "csv", schema: schema, want: "@id,@status,customer,total,note\n1,paid,Ann,9.50,\n2,,Bob,12,gift\n"},
+	} {
+		var out bytes.Buffer
+		_, err := convert.Transcode(context.Background(), strings.NewReader(ordersXML), &out, convert.Options{
+			From: "xml", To: c.to, Stream: true, Schema: c.schema, InOptions: convert.FormatOptions{"record_path": "orders/order"},
+		})
+		if err != nil {
+			t.Fatalf("%s: conversion failed: %v", name, err)
+		}
+		if out.String() != c.want {
+			t.Errorf("%s: expected %q, got %q", name, c.want, out.String())
+		}
+	}
+
+	// The CSV header comes from the first record, so a later element must not be dropped
+	_, err := convert.Transcode(context.Background(), strings.NewReader(ordersXML), &bytes.Buffer{}, convert.Options{
+		From: "xml", To: "csv", Stream: true, InOptions: convert.FormatOptions{"record_path": "orders/order"},
+	})
+	if err == nil || !strings.Contains(err.Error(), `row 2 of '' has a value for column "note"`) {
+		t.Errorf("expected the note of the second record to be refused, got %v", err)
+	}
+
+	// Without a record path the children of the root element are the records
+	var out bytes.Buffer
+	_, err = convert.Transcode(context.Background(), strings.NewReader(`<people><person><name>Ann</name></person><person><name>Bob</name></person></people>`), &out, convert.Options{
+		From: "xml", To: "csv", Stream: true,
+	})
+	if err != nil || out.String() != "name\nAnn\nBob\n" {
+		t.Errorf("unexpected output %q (%v)", out.String(), err)
+	}
+}
@@ -0,0 +1,185 @@
+package formats_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"omnidata/internal/convert"
+	"omnidata/internal/formats"
+)
+
+const soapXML = `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns="urn:shop">
+  <soap:Header><auth soap:mustUnderstand="1">token</auth></soap:Header>
+  <soap:Body>
+    <order id="7" xml:lang="en"><item sku="a">Tea &amp; milk</item><item sku="b">Jam</item><note/></order>
+  </soap:Body>
+</soap:Envelope>`
+
+// canonicalNode describes an element tree for comparison: resolved names, sorted
+// attributes, the text directly inside each element and the children in order.
+func canonicalNode(t *testing.T, n formats.Node) string {
+	t.Helper()
+	attrs := make([]string, 0, len(n.Attrs))
+	for _, a := range n.Attrs {
+		attrs = append(attrs, fmt.Sprintf("%s:%s=%q", a.Name.Space, a.Name.Local, a.Value))
+	}
+	sort.Strings(attrs)
+
+	var text strings.Builder
+	dec := xml.NewDecoder(bytes.NewReader(n.Content))
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 0 {
+				text.Write(tok)
+			}
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<%s:%s %s>%q", n.XMLName.Space, n.XMLName.Local, strings.Join(attrs, " "), strings.TrimSpace(text.String()))
+	for _, child := range n.Nodes {
+		sb.WriteString(canonicalNode(t, child))
+	}
+	sb.WriteString("</>")
+	return sb.String()
+}
+
+// parseNode decodes an XML document into a formats.Node.
+func parseNode(t *testing.T, data []byte) formats.Node {
+	t.Helper()
+	var n formats.Node
+	if err := xml.Unmarshal(data, &n); err != nil {
+		t.Fatalf("invalid XML %s: %v", data, err)
+	}
+	return n
+}
+
+// convertDocument runs a document conversion with the given convention on both sides.
+func convertDocument(t *testing.T, input []byte, from, to, convention string) []byte {
+	t.Helper()
+	opts := convert.Options{From: from, To: to, Document: true}
+	if from == "xml" {
+		opts.InOptions = convert.FormatOptions{"convention": convention}
+	}
+	if to == "xml" {
+		opts.OutOptions = convert.FormatOptions{"convention": convention}
+	}
+	var out bytes.Buffer
+	if _, err := convert.Transcode(context.Background(), bytes.NewReader(input), &out, opts); err != nil {
+		t.Fatalf("%s -> %s (%s): conversion failed: %v", from, to, convention, err)
+	}
+	return out.Bytes()
+}
+
+// TestXMLDocumentRoundTrip converts XML to JSON or YAML and back, and expects the same
+// elements, attributes, namespaces, text and repeated elements.
+func TestXMLDocumentRoundTrip(t *testing.T) {
+	want := canonicalNode(t, parseNode(t, []byte(soapXML)))
+	for _, convention := range []string{"simple", "badgerfish"} {
+		for _, via := range []string{"json", "yaml"} {
+			doc := convertDocument(t, []byte(soapXML), "xml", via, convention)
+			back := convertDocument(t, doc, via, "xml", convention)
+			if got := canonicalNode(t, parseNode(t, back)); got != want {
+				t.Errorf("%s via %s: expected\n%s\ngot\n%s\n%s", convention, via, want, got, back)
+			}
+			if !strings.Contains(string(back), `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns="urn:shop">`) {
+				t.Errorf("%s via %s: expected the namespace prefixes to be kept, got\n%s", convention, via, back)
+			}
+		}
+	}
+}
+
+// TestXMLDocumentConventions checks how each convention maps the same document.
+func TestXMLDocumentConventions(t *testing.T) {
+	input := `<shop xmlns:x="urn:x"><order id="1"><item>Tea</item><item x:sku="b">Jam</item><note/></order></shop>`
+	for convention, want := range map[string]string{
+		"simple": `{"shop":{"@xmlns:x":"urn:x","order":{"@id":"1","item":["Tea",{"@x:sku":"b","#text":"Jam"}],"note":""}}}`,
+		"badgerfish": `{"shop":{"@xmlns":{"x":"urn:x"},"order":{"@id":"1","item":[{"$":"Tea"},{"@x:sku":"b","$":"Jam"}],` +
+			`"note":{}}}}`,
+		"parker": `{"order":{"item":["Tea","Jam"],"note":null}}`,
+	} {
+		var out bytes.Buffer
+		_, err := convert.Transcode(context.Background(), strings.NewReader(input), &out, convert.Options{
+			From: "xml", To: "json", Document: true,
+			InOptions:  convert.FormatOptions{"convention": convention},
+			OutOptions: convert.FormatOptions{"indent": "0"},
+		})
+		if err != nil {
+			t.Fatalf("%s: conversion failed: %v", convention, err)
+		}
+		if got := strings.TrimSpace(out.String()); got != want {
+			t.Errorf("%s: expected %s, got %s", convention, want, got)
+		}
+	}
+
+	// Parker keeps elements and text only, under the root option
+	back := convertDocument(t, []byte(`{"order":{"item":["Tea","Jam"],"note":null}}`), "json", "xml", "parker")
+	if got := normalizeXML(string(back)); got != `<records><order><item>Tea</item><item>Jam</item><note></note></order></records>` {
+		t.Errorf("unexpected Parker XML %s", got)
+	}
+}
+
+// TestXMLDocumentFromJSON writes JSON documents that were not converted from XML.
+func TestXMLDocumentFromJSON(t *testing.T) {
+	for input, want := range map[string]string{
+		// Key order is kept, not sorted
+		`{"config":{"name":"api","port":8080,"debug":false}}`: `<config><name>api</name><port>8080</port><debug>false</debug></config>`,
+		// Several keys or a list need the root element
+		`{"b":1,"a":2}`:             `<records><b>1</b><a>2</a></records>`,
+		`[{"id":1},{"id":2}]`:       `<records><record><id>1</id></record><record><id>2</id></record></records>`,
+		`{"m":[[1,2],[3]]}`:         `<records><m><record>1</record><record>2</record></m><m><record>3</record></m></records>`,
+		`{"first name":{"@a b":1}}`: `<first_namea_b="1"></first_name>`,
+	} {
+		got := normalizeXML(string(convertDocument(t, []byte(input), "json", "xml", "simple")))
+		if got != want {
+			t.Errorf("%s: expected %s, got %s", input, want, got)
+		}
+	}
+
+	// JSON to JSON keeps the key order of every object
+	input := `{"b":1,"a":[2,{"d":null,"c":true}],"e":1.5}`
+	var out bytes.Buffer
+	_, err := convert.Transcode(context.Background(), strings.NewReader(input), &out, convert.Options{
+		From: "json", To: "json", Document: true, OutOptions: convert.FormatOptions{"indent": "0"},
+	})
+	if err != nil || strings.TrimSpace(out.String()) != input {
+		t.Errorf("expected %s, got %s (%v)", input, out.String(), err)
+	}
+}
+
+// TestXMLDocumentErrors rejects document conversions that cannot work.
+func TestXMLDocumentErrors(t *testing.T) {
+	for name, opts := range map[string]convert.Options{
+		"tabular": {From: "xml", To: "csv", Document: true},
+		"stream":  {From: "xml", To: "json", Document: true, Stream: true},
+	} {
+		_, err := convert.Transcode(context.Background(), strings.NewReader(soapXML), &bytes.Buffer{}, opts)
+		if err == nil || !strings.Contains(err.Error(), "invalid document conversion") {
+			t.Errorf("%s: expected the conversion to be rejected, got %v", name, err)
+		}
+	}
+
+	handler, _ := convert.GetFormat("xml")
+	if err := handler.ValidateReaderOptions(convert.FormatOptions{"convention": "gdata"}); err == nil {
+		t.Error("expected an unknown convention to be rejected")
+	}
+	if err := handler.WriterFn(context.Background(), &bytes.Buffer{}, "", "text", nil); err == nil {
+		t.Error("expected a scalar document to be rejected")
+	}
+}
@@ -0,0 +1,94 @@
+package inspect_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"omnidata/internal/convert"
+	_ "omnidata/internal/formats" // triggers init() for format registration
+	"omnidata/internal/inspect"
+)
+
+// TestDiffReaderOptions gives shared options only to the readers that accept them, and
+// per-file options to their own file.
+func TestDiffReaderOptions(t *testing.T) {
+	csv, _ := convert.GetFormat("csv")
+	json, _ := convert.GetFormat("json")
+
+	opts := inspect.DiffOptions{
+		ReaderOptions:  convert.FormatOptions{"delimiter": ";"},
+		ReaderOptions2: convert.FormatOptions{"quote": "'"},
+	}
+	opts1, opts2, err := opts.FileReaderOptions(json, csv)
+	if err != nil {
+		t.Fatalf("FileReaderOptions failed: %v", err)
+	}
+	if len(opts1) != 0 || opts2["delimiter"] != ";" || opts2["quote"] != "'" {
+		t.Errorf("unexpected options %v and %v", opts1, opts2)
+	}
+
+	for name, opts := range map[string]inspect.DiffOptions{
+		"invalid input options for both files": {ReaderOptions: convert.FormatOptions{"sheet": "x"}},
+		"invalid input options for file1":      {ReaderOptions1: convert.FormatOptions{"delimiter": ";"}},
+		"invalid input options for file2":      {ReaderOptions: convert.FormatOptions{"header": "maybe"}},
+	} {
+		if _, _, err := opts.FileReaderOptions(json, csv); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("expected an error containing %q, got %v", name, err)
+		}
+	}
+
+	// A semicolon-separated CSV compares with JSON
+	dir := t.TempDir()
+	file1 := filepath.Join(dir, "eu.csv")
+	file2 := filepath.Join(dir, "us.json")
+	os.WriteFile(file1, []byte("id;name\n1;Ann\n"), 0644)
+	os.WriteFile(file2, []byte(`[{"id":1,"name":"Ann"}]`), 0644)
+	err = inspect.RunDiff(context.Background(), inspect.DiffOptions{
+		File1: file1, File2: file2, Format1: "csv", Format2: "json",
+		ReaderOptions: convert.FormatOptions{"delimiter": ";"},
+	})
+	if err != nil {
+		t.Errorf("RunDiff failed: %v", err)
+	}
+}
+
+// TestDiffHeaderOptions applies the header flags to the CSV side of a diff only.
+func TestDiffHeaderOptions(t *testing.T) {
+	csv, _ := convert.GetFormat("csv")
+	json, _ := convert.GetFormat("json")
+	fixed, _ := convert.GetFormat("fixedwidth")
+
+	opts := inspect.DiffOptions{
+		ReaderOptions1: convert.FormatOptions{"header": "true"},
+		HeaderOptions:  convert.FormatOptions{"header": "false", "columns": "id,name"},
+	}
+	opts1, opts2, err := opts.FileReaderOptions(csv, json)
+	if err != nil {
+		t.Fatalf("FileReaderOptions failed: %v", err)
+	}
+	if opts1["header"] != "false" || opts1["columns"] != "id,name" || len(opts2) != 0 {
+		t.Errorf("unexpected options %v and %v", opts1, opts2)
+	}
+
+	// Fixed-width declares columns too, but as a column spec
+	opts = inspect.DiffOptions{HeaderOptions: convert.FormatOptions{"columns": "id,name"}}
+	if _, _, err := opts.FileReaderOptions(fixed, json); err == nil || !strings.Contains(err.Error(), "neither file is one") {
+		t.Errorf("expected the header options to be refused, got %v", err)
+	}
+
+	dir := t.TempDir()
+	file1 := filepath.Join(dir, "raw.csv")
+	file2 := filepath.Join(dir, "people.json")
+	os.WriteFile(file1, []byte("# export\n1,Ann\n"), 0644)
+	os.WriteFile(file2, []byte(`[{"id":1,"name":"Ann"}]`), 0644)
+	err = inspect.RunDiff(context.Background(), inspect.DiffOptions{
+		File1: file1, File2: file2, Format1: "csv", Format2: "json",
+		HeaderOptions: convert.FormatOptions{"header": "false", "skip_rows": "1", "columns": "id,name"},
+	})
+	if err != nil {
+		t.Errorf("RunDiff failed: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package omnidata_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"omnidata/pkg/omnidata"
+)
+
+const people = "name,age,city\nAlice,30,Paris\nBob,25,\nCarol,41,Rome\n"
+
+// TestConvert converts JSON to CSV with the source format detected from the content.
+func TestConvert(t *testing.T) {
+	var out bytes.Buffer
+	result, err := omnidata.Convert(context.Background(), strings.NewReader(`[{"name":"Alice","tags":["a","b"]}]`), &out,
+		omnidata.ConvertOptions{To: "csv", Flatten: omnidata.FlattenOptions{Arrays: omnidata.ArrayJoin}})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if result.From != "json" || result.To != "csv" || result.RowsRead != 1 || result.RowsWritten != 1 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if want := "name,tags\nAlice,\"a,b\"\n"; out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+}
+
+// TestConvertOptions passes reader options, gzip input and streaming mode.
+func TestConvertOptions(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	io.WriteString(zw, "name;age\nAlice;30\nBob;25\n")
+	zw.Close()
+
+	var out bytes.Buffer
+	result, err := omnidata.Convert(context.Background(), &gz, &out, omnidata.ConvertOptions{
+		From:          "CSV",
+		To:            "json",
+		ReaderOptions: omnidata.Options{"Delimiter": ";"},
+		Stream:        true,
+	})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if result.RowsWritten != 2 || len(result.Warnings) != 0 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &rows); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", out.String(), err)
+	}
+	if len(rows) != 2 || rows[1]["name"] != "Bob" {
+		t.Errorf("unexpected rows: %v", rows)
+	}
+}
+
+// TestConvertSchema conforms records to a schema, skipping and quarantining those that do not fit.
+func TestConvertSchema(t *testing.T) {
+	schema := &omnidata.Schema{Columns: []omnidata.Column{
+		{Name: "age", Type: "integer"},
+		{Name: "name", Type: "string"},
+	}}
+
+	var out, rejects bytes.Buffer
+	result, err := omnidata.Convert(context.Background(), strings.NewReader("name,age\nAlice,30\nBob,old\n"), &out,
+		omnidata.ConvertOptions{From: "csv", To: "json", Schema: schema, SkipBadRecords: true, Rejects: &rejects})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if result.RowsWritten != 1 || result.Rejected != 1 || strings.Join(result.Columns, ",") != "age,name" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if !strings.Contains(out.String(), `"age": 30`) || !strings.Contains(rejects.String(), `"name":"Bob"`) {
+		t.Errorf("unexpected output %q or rejects %q", out.String(), rejects.String())
+	}
+
+	_, err = omnidata.Convert(context.Background(), strings.NewReader("name,age\nBob,old\n"), io.Discard,
+		omnidata.ConvertOptions{From: "csv", To: "json", Schema: schema})
+	if err == nil || !strings.Contains(err.Error(), "cannot cast") {
+		t.Errorf("expected a bad record to fail by default, got %v", err)
+	}
+}
+
+// TestConvertErrors checks invalid selections and a cancelled context.
+func TestConvertErrors(t *testing.T) {
+	ctx := context.Background()
+	cases := map[string]omnidata.ConvertOptions{
+		"target format is required": {From: "csv"},
+		"unsupported target format": {From: "csv", To: "nope"},
+		"sql cannot be converted":   {From: "csv", To: "sql"},
+		"unknown option":            {From: "csv", To: "json", WriterOptions: omnidata.Options{"bogus": "1"}},
+	}
+	for want, opts := range cases {
+		_, err := omnidata.Convert(ctx, strings.NewReader(people), io.Discard, opts)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got %v", want, err)
+		}
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := omnidata.Convert(cancelled, strings.NewReader(people), io.Discard, omnidata.ConvertOptions{To: "json"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+// TestPeekAndInferSchema returns the schema and the requested number of rows.
+func TestPeekAndInferSchema(t *testing.T) {
+	ctx := context.Background()
+	result, err := omnidata.Peek(ctx, strings.NewReader(people), omnidata.ReadOptions{Format: "csv"}, 2)
+	if err != nil {
+		t.Fatalf("Peek failed: %v", err)
+	}
+	if len(result.Rows) != 2 || result.Rows[0]["name"] != "Alice" {
+		t.Errorf("unexpected rows: %v", result.Rows)
+	}
+	if result.Schema.RowCount != 3 || len(result.Schema.Columns) != 3 {
+		t.Errorf("unexpected schema: %+v", result.Schema)
+	}
+
+	schema, err := omnidata.InferSchema(ctx, strings.NewReader(people), omnidata.ReadOptions{Format: "CSV"})
+	if err != nil {
+		t.Fatalf("InferSchema failed: %v", err)
+	}
+	city := schema.Column("city")
+	if city == nil || !city.Nullable {
+		t.Errorf("expected a nullable city column, got %+v", city)
+	}
+}
+
+// TestDiff compares the schemas of two inputs in different formats.
+func TestDiff(t *testing.T) {
+	newData := `[{"name":"Alice","age":"30","email":"a@example.com"}]`
+	diff, err := omnidata.Diff(context.Background(), strings.NewReader(people), strings.NewReader(newData),
+		omnidata.ReadOptions{Format: "csv"}, omnidata.ReadOptions{})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if diff.New.Format != "json" {
+		t.Errorf("expected the new format to be detected as json, got %q", diff.New.Format)
+	}
+	if diff.Equal() {
+		t.Fatal("expected the schemas to differ")
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Name != "email" {
+		t.Errorf("expected email to be added, got %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Name != "city" {
+		t.Errorf("expected city to be removed, got %+v", diff.Removed)
+	}
+
+	same := omnidata.CompareSchemas(diff.Old, diff.Old)
+	if !same.Equal() || len(same.Unchanged) != 3 {
+		t.Errorf("expected a schema to equal itself, got %+v", same)
+	}
+}
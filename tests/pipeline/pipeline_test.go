@@ -0,0 +1,155 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "omnidata/internal/formats" // triggers init() for format registration
+	"omnidata/internal/pipeline"
+)
+
+// writeRecipe creates a recipe file next to an orders CSV and returns its path.
+func writeRecipe(t *testing.T, recipe string) string {
+	dir := t.TempDir()
+	orders := "id,customer,total,status\n1,alice,100,shipped\n2,bob,25.5,pending\n3,carol,300,shipped\n"
+	if err := os.WriteFile(filepath.Join(dir, "orders.csv"), []byte(orders), 0644); err != nil {
+		t.Fatalf("failed to write orders: %v", err)
+	}
+	path := filepath.Join(dir, "pipeline.yaml")
+	if err := os.WriteFile(path, []byte(recipe), 0644); err != nil {
+		t.Fatalf("failed to write recipe: %v", err)
+	}
+	return path
+}
+
+// TestRunPipeline runs sources, transforms, validations and sinks end to end.
+func TestRunPipeline(t *testing.T) {
+	t.Setenv("OMNIDATA_TEST_STATUS", "shipped")
+	path := writeRecipe(t, `
+name: shipped-orders
+vars:
+  OUT: out
+sources:
+  - path: orders.csv
+transforms:
+  - filter: {column: status, value: "${OMNIDATA_TEST_STATUS}"}
+  - filter: {column: total, op: ge, value: "${MIN_TOTAL:-50}"}
+  - cast: {total: decimal}
+  - rename: {total: amount}
+  - sort: ["-amount"]
+  - select: [id, amount]
+validations:
+  - required: [id]
+    unique: [id]
+    min_rows: 1
+sinks:
+  - path: ${OUT}.json
+`)
+
+	recipe, err := pipeline.Load(path, nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	var log bytes.Buffer
+	if err := recipe.Run(context.Background(), pipeline.RunOptions{Log: &log}); err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, log.String())
+	}
+
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(path), "out.json"))
+	if err != nil {
+		t.Fatalf("sink not written: %v", err)
+	}
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if len(rows) != 2 || rows[0]["amount"] != float64(300) || rows[1]["id"] != "1" {
+		t.Errorf("unexpected output: %s", data)
+	}
+	if !strings.Contains(log.String(), "Running pipeline shipped-orders") {
+		t.Errorf("missing progress log:\n%s", log.String())
+	}
+}
+
+// TestRunPipelineValidationFailure verifies that failed validations stop before sinks.
+func TestRunPipelineValidationFailure(t *testing.T) {
+	path := writeRecipe(t, `
+sources:
+  - path: orders.csv
+validations:
+  - max_rows: 1
+    severity: warn
+  - pattern: {customer: "^[ab]"}
+sinks:
+  - path: out.csv
+`)
+
+	recipe, err := pipeline.Load(path, nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	var log bytes.Buffer
+	err = recipe.Run(context.Background(), pipeline.RunOptions{Log: &log})
+	if err == nil || !strings.Contains(err.Error(), "1 validation(s) failed") {
+		t.Fatalf("expected a validation failure, got %v", err)
+	}
+	if !strings.Contains(log.String(), "WARNING: expected at most 1 rows, got 3") ||
+		!strings.Contains(log.String(), `column "customer" does not match ^[ab] (rows 3)`) {
+		t.Errorf("unexpected log:\n%s", log.String())
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(path), "out.csv")); err == nil {
+		t.Error("no sink should be written after a failed validation")
+	}
+}
+
+// TestLoadErrors verifies that invalid recipes are rejected before anything runs.
+func TestLoadErrors(t *testing.T) {
+	cases := map[string]string{
+		"undefined variable NOPE": "sources: [{path: '${NOPE}'}]\nsinks: [{path: out.csv}]",
+		"field limt not found":    "sources: [{path: orders.csv}]\ntransforms: [{limt: 1}]\nsinks: [{path: out.csv}]",
+		"only one operation":      "sources: [{path: orders.csv}]\ntransforms: [{limit: 1, select: [id]}]\nsinks: [{path: out.csv}]",
+		`unknown dataset "other"`: "sources: [{path: orders.csv}]\nsinks: [{input: other, path: out.csv}]",
+		"at least one sink":       "sources: [{path: orders.csv}]",
+		"unknown type":            "sources: [{path: orders.csv}]\ntransforms: [{cast: {id: money}}]\nsinks: [{path: out.csv}]",
+	}
+	for want, recipe := range cases {
+		_, err := pipeline.Load(writeRecipe(t, recipe), nil)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got %v", want, err)
+		}
+	}
+}
+
+// TestLoadVariables verifies the lookup order of variables and the $$ escape.
+func TestLoadVariables(t *testing.T) {
+	t.Setenv("OMNIDATA_TEST_DIR", "from-env")
+	recipe, err := pipeline.Parse([]byte(`
+vars:
+  DIR: from-vars
+  NAME: orders
+sources:
+  - path: ${OMNIDATA_TEST_DIR}/${NAME}.csv
+transforms:
+  - limit: ${LIMIT}
+sinks:
+  - path: ${DIR}/$${NAME}.csv
+`), map[string]string{"LIMIT": "5", "NAME": "override"})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if recipe.Sources[0].Path != "from-env/override.csv" {
+		t.Errorf("source path = %q", recipe.Sources[0].Path)
+	}
+	if recipe.Transforms[0].Limit != 5 {
+		t.Errorf("limit = %d, want 5", recipe.Transforms[0].Limit)
+	}
+	if recipe.Sinks[0].Path != "from-vars/${NAME}.csv" {
+		t.Errorf("sink path = %q", recipe.Sinks[0].Path)
+	}
+}
@@ -0,0 +1,161 @@
+package plugin_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"omnidata/internal/convert"
+	"omnidata/internal/dataset"
+	_ "omnidata/internal/formats" // triggers init() for format registration
+	"omnidata/internal/plugin"
+)
+
+// pipeScript implements a "pipe" format ("x|y" per line) with the plugin protocol.
+const pipeScript = `#!/bin/sh
+case "$1" in
+capabilities)
+  echo '{"protocol":1,"name":"pipe","read":true,"write":true,"extensions":[".pipe"],"signatures":["#pipe"],"writer_options":[{"name":"fail","type":"bool"}]}' ;;
+read)
+  grep -v '^#' | sed -E 's/^([^|]*)\|(.*)$/{"x":"\1","y":\2}/' ;;
+write)
+  case "$OMNIDATA_OPTIONS" in *fail*) echo "cannot write $OMNIDATA_RESOURCE" >&2; exit 3 ;; esac
+  sed -E 's/^\{"x":"([^"]*)","y":([^}]*)\}$/\1|\2/' ;;
+esac
+`
+
+// writePlugin installs an executable script in dir and returns its path.
+func writePlugin(t *testing.T, dir, name, script string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugins are not supported on Windows")
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write plugin: %v", err)
+	}
+	return path
+}
+
+// TestPluginReadWrite runs a plugin through its handler in both directions.
+func TestPluginReadWrite(t *testing.T) {
+	path := writePlugin(t, t.TempDir(), plugin.Prefix+"pipe", pipeScript)
+	p, err := plugin.Probe(path, "")
+	if err != nil {
+		t.Fatalf("Probe failed: %v", err)
+	}
+	handler := p.Handler()
+	if handler.Name != "pipe" || handler.Extensions[0] != ".pipe" || handler.Plugin != path {
+		t.Fatalf("unexpected handler: %+v", handler)
+	}
+
+	data, err := handler.ReaderFn(context.Background(), strings.NewReader("a|1\nb|2.5\n"), "in.pipe", nil)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	ds := data.(*dataset.Dataset)
+	if len(ds.Columns) != 2 || ds.Columns[0] != "x" || ds.Len() != 2 {
+		t.Fatalf("unexpected dataset: %+v", ds)
+	}
+	if ds.Value(0, "y") != int64(1) || ds.Value(1, "y") != float64(2.5) {
+		t.Errorf("values should keep their JSON types: %v", ds.Records)
+	}
+
+	var out bytes.Buffer
+	if err := handler.WriterFn(context.Background(), &out, "out.pipe", ds, nil); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if out.String() != "a|1\nb|2.5\n" {
+		t.Errorf("unexpected output: %q", out.String())
+	}
+}
+
+// TestPluginFailure verifies that a failing plugin reports its exit status and STDERR.
+func TestPluginFailure(t *testing.T) {
+	path := writePlugin(t, t.TempDir(), plugin.Prefix+"pipe", pipeScript)
+	p, err := plugin.Probe(path, "")
+	if err != nil {
+		t.Fatalf("Probe failed: %v", err)
+	}
+
+	ds := dataset.New([]string{"x", "y"})
+	ds.Append("a", int64(1))
+	err = p.Handler().WriterFn(context.Background(), &bytes.Buffer{}, "out.pipe", ds, convert.FormatOptions{"fail": "true"})
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") || !strings.Contains(err.Error(), "cannot write out.pipe") {
+		t.Errorf("expected the plugin error, got %v", err)
+	}
+}
+
+// TestProbeErrors verifies that broken plugins are rejected at the handshake.
+func TestProbeErrors(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string]string{
+		"unsupported protocol version": "#!/bin/sh\necho '{\"protocol\":99,\"read\":true}'\n",
+		"invalid capabilities":         "#!/bin/sh\necho 'not json'\n",
+		"handshake failed":             "#!/bin/sh\necho 'oops' >&2\nexit 1\n",
+		"neither read nor write":       "#!/bin/sh\necho '{\"protocol\":1}'\n",
+	}
+	i := 0
+	for want, script := range cases {
+		i++
+		path := writePlugin(t, dir, plugin.Prefix+"bad"+string(rune('a'+i)), script)
+		if _, err := plugin.Probe(path, ""); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got %v", want, err)
+		}
+	}
+}
+
+// TestDiscoverAndRegister finds plugins on PATH and in the config file, and never
+// replaces a built-in format.
+func TestDiscoverAndRegister(t *testing.T) {
+	binDir := t.TempDir()
+	writePlugin(t, binDir, plugin.Prefix+"pipe", pipeScript)
+	writePlugin(t, binDir, plugin.Prefix+"csv", pipeScript)
+	os.WriteFile(filepath.Join(binDir, plugin.Prefix+"noexec"), []byte(pipeScript), 0644)
+
+	otherDir := t.TempDir()
+	custom := writePlugin(t, otherDir, "acme-converter", pipeScript)
+	config := filepath.Join(otherDir, "plugins.yaml")
+	os.WriteFile(config, []byte("plugins:\n  - name: acme\n    path: "+custom+"\n"), 0644)
+
+	t.Setenv("PATH", binDir)
+	t.Setenv("OMNIDATA_PLUGIN_PATH", "")
+	t.Setenv("OMNIDATA_PLUGIN_CONFIG", config)
+
+	candidates, err := plugin.Discover()
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	var names []string
+	for _, c := range candidates {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names, ",") != "acme,csv,pipe" {
+		t.Fatalf("unexpected candidates: %v", names)
+	}
+
+	var warnings bytes.Buffer
+	registered := plugin.RegisterAll(&warnings)
+	defer func() {
+		for _, p := range registered {
+			delete(convert.Registry, p.Name)
+		}
+	}()
+
+	if len(registered) != 2 {
+		t.Fatalf("expected acme and pipe to be registered, got %d", len(registered))
+	}
+	if handler, _ := convert.GetFormat("csv"); handler.Plugin != "" {
+		t.Error("a plugin must not replace the built-in csv format")
+	}
+	if !strings.Contains(warnings.String(), "a format named csv already exists") {
+		t.Errorf("expected a warning for the csv plugin, got %q", warnings.String())
+	}
+	// Both registered plugins run the same script and share its signature
+	if name, ok := convert.DetectFormatFromContent([]byte("#pipe\na|1\n")); !ok || (name != "acme" && name != "pipe") {
+		t.Errorf("plugin signatures should be used for detection, got %q", name)
+	}
+}
@@ -0,0 +1,121 @@
+package stream_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"omnidata/internal/stream"
+)
+
+// readAll reads every record, collecting errors instead of stopping at them.
+func readAll(t *testing.T, r stream.RecordReader) ([][]string, []error) {
+	var records [][]string
+	var errs []error
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records, errs
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		records = append(records, record)
+	}
+}
+
+// TestDialectReader parses custom quotes, backslash escapes, comment prefixes and trimmed fields.
+func TestDialectReader(t *testing.T) {
+	d := stream.Dialect{Delimiter: '|', Quote: '\'', Escape: '\\', Comment: "//", TrimLeadingSpace: true}
+	input := "// exported\r\nid|name|note\r\n1|'O\\'Brien'|a\\|b\r\n\r\n2|  'two\nlines'|x\n"
+
+	records, errs := readAll(t, d.NewReader(strings.NewReader(input)))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	want := [][]string{{"id", "name", "note"}, {"1", "O'Brien", "a|b"}, {"2", "two\nlines", "x"}}
+	if len(records) != len(want) {
+		t.Fatalf("expected %d records, got %q", len(want), records)
+	}
+	for i := range want {
+		if strings.Join(records[i], ",") != strings.Join(want[i], ",") {
+			t.Errorf("record %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+}
+
+// TestDialectReaderErrors verifies that errors are csv.ParseErrors with line numbers and
+// that reading carries on with the next record.
+func TestDialectReaderErrors(t *testing.T) {
+	d := stream.Dialect{Delimiter: ';', Quote: '\''}
+	input := "a;b\n1;x'y\n2;'z'w\n3;4;5\n6;7\n"
+
+	r := d.NewReader(strings.NewReader(input))
+	records, errs := readAll(t, r)
+	if len(records) != 2 || records[1][0] != "6" {
+		t.Errorf("expected the header and the last record, got %q", records)
+	}
+	wantErrs := []error{csv.ErrBareQuote, csv.ErrQuote, csv.ErrFieldCount}
+	if len(errs) != len(wantErrs) {
+		t.Fatalf("expected %d errors, got %v", len(wantErrs), errs)
+	}
+	for i, err := range errs {
+		var parseErr *csv.ParseError
+		if !errors.As(err, &parseErr) || !errors.Is(err, wantErrs[i]) || parseErr.StartLine != i+2 {
+			t.Errorf("error %d = %v, want %v on line %d", i, err, wantErrs[i], i+2)
+		}
+	}
+	if r.InputOffset() != int64(len(input)) {
+		t.Errorf("InputOffset = %d, want %d", r.InputOffset(), len(input))
+	}
+
+	lazy := stream.Dialect{Delimiter: ';', Quote: '\'', LazyQuotes: true}
+	records, errs = readAll(t, lazy.NewReader(strings.NewReader("a;b\n1;x'y\n")))
+	if len(errs) != 0 || records[1][1] != "x'y" {
+		t.Errorf("expected lazy quotes to keep the bare quote, got %q, %v", records, errs)
+	}
+}
+
+// TestDialectWriter writes custom quotes and escapes that the reader reads back.
+func TestDialectWriter(t *testing.T) {
+	records := [][]string{{"id", "note"}, {"1", `it's "quoted"`}, {"2", "a\tb"}, {"3", ""}}
+
+	cases := []struct {
+		dialect stream.Dialect
+		want    string
+	}{
+		{stream.Dialect{Delimiter: '\t', AlwaysQuote: true, CRLF: true},
+			"\"id\"\t\"note\"\r\n\"1\"\t\"it's \"\"quoted\"\"\"\r\n\"2\"\t\"a\tb\"\r\n\"3\"\t\"\"\r\n"},
+		{stream.Dialect{Quote: '\'', Escape: '\\'},
+			"id,note\n1,'it\\'s \"quoted\"'\n2,a\tb\n3,\n"},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		w := c.dialect.NewWriter(&buf)
+		for _, record := range records {
+			if err := w.Write(record); err != nil {
+				t.Fatalf("write failed: %v", err)
+			}
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			t.Fatalf("flush failed: %v", err)
+		}
+		if buf.String() != c.want {
+			t.Errorf("%+v wrote %q, want %q", c.dialect, buf.String(), c.want)
+		}
+
+		back, errs := readAll(t, c.dialect.NewReader(&buf))
+		if len(errs) != 0 || len(back) != len(records) || back[1][1] != records[1][1] {
+			t.Errorf("%+v did not read back: %q, %v", c.dialect, back, errs)
+		}
+	}
+
+	if err := (stream.Dialect{Delimiter: '"'}).Validate(); err == nil {
+		t.Error("expected a delimiter equal to the quote to be rejected")
+	}
+}
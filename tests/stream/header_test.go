@@ -0,0 +1,35 @@
+package stream_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"omnidata/internal/stream"
+)
+
+// TestHeaderReader names columns from the header row, from given names or by position.
+func TestHeaderReader(t *testing.T) {
+	input := "id,,id\n1,x,2\n"
+	cases := []struct {
+		name   string
+		header stream.Header
+		want   [][]string
+	}{
+		{"header row", stream.Header{}, [][]string{{"id", "col2", "id_2"}, {"1", "x", "2"}}},
+		{"no header", stream.Header{Absent: true}, [][]string{{"col1", "col2", "col3"}, {"id", "", "id"}, {"1", "x", "2"}}},
+		{"given names", stream.Header{Absent: true, Columns: []string{"a", "b"}}, [][]string{{"a", "b", "col3"}, {"id", "", "id"}, {"1", "x", "2"}}},
+		{"renamed header", stream.Header{Columns: []string{"a", "a", "b", "c"}}, [][]string{{"a", "a_2", "b", "c"}, {"1", "x", "2"}}},
+	}
+	for _, c := range cases {
+		records, errs := readAll(t, c.header.NewReader(stream.Dialect{}.NewReader(strings.NewReader(input))))
+		if len(errs) != 0 || !reflect.DeepEqual(records, c.want) {
+			t.Errorf("%s: got %q, %v; want %q", c.name, records, errs, c.want)
+		}
+	}
+
+	records, errs := readAll(t, stream.Header{Absent: true}.NewReader(stream.Dialect{}.NewReader(strings.NewReader(""))))
+	if len(records) != 0 || len(errs) != 0 {
+		t.Errorf("expected an empty input to have no header, got %q, %v", records, errs)
+	}
+}
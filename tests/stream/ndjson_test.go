@@ -0,0 +1,64 @@
+package stream_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"omnidata/internal/stream"
+)
+
+// TestNDJSONStreamingReader verifies key order, blank lines and line numbers in errors.
+func TestNDJSONStreamingReader(t *testing.T) {
+	input := "{\"b\":1,\"a\":{\"x\":[1,2]}}\n\n  \n{\"c\":\"z\",\"a\":null}\n{broken\n"
+	r := stream.NewNDJSONStreamingReaderFrom(strings.NewReader(input))
+
+	if _, err := r.ReadRow(); err != nil {
+		t.Fatalf("row 1: %v", err)
+	}
+	row, err := r.ReadRow()
+	if err != nil {
+		t.Fatalf("row 2: %v", err)
+	}
+	if row["c"] != "z" || r.Line() != 4 {
+		t.Errorf("unexpected row %v at line %d", row, r.Line())
+	}
+	if got := strings.Join(r.Columns(), ","); got != "b,a,c" {
+		t.Errorf("columns = %s, want b,a,c", got)
+	}
+
+	if _, err := r.ReadRow(); err == nil || !strings.Contains(err.Error(), "line 5") {
+		t.Errorf("expected an error on line 5, got %v", err)
+	}
+}
+
+// TestNDJSONStreamingWriter verifies one compact object per line in header order.
+func TestNDJSONStreamingWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := stream.NewNDJSONStreamingWriterTo(&buf, []string{"id", "name"})
+	w.WriteRow(map[string]interface{}{"name": "Alice", "id": int64(1), "extra": true})
+	w.WriteRow(map[string]interface{}{"id": int64(2)})
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	want := "{\"id\":1,\"name\":\"Alice\",\"extra\":true}\n{\"id\":2}\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	r := stream.NewNDJSONStreamingReaderFrom(&buf)
+	count := 0
+	for {
+		if _, err := r.ReadRow(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("re-read failed: %v", err)
+		}
+		count++
+	}
+	if count != 2 {
+		t.Errorf("expected 2 rows, got %d", count)
+	}
+}
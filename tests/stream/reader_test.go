@@ -0,0 +1,137 @@
+package stream_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"omnidata/internal/stream"
+)
+
+// TestCSVStreamingReaderFrom verifies header handling and row-by-row reading.
+func TestCSVStreamingReaderFrom(t *testing.T) {
+	r, err := stream.NewCSVStreamingReaderFrom(strings.NewReader("name,age\nAlice,30\nBob,25\n"))
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+	defer r.Close()
+
+	if cols := r.Columns(); len(cols) != 2 || cols[0] != "name" {
+		t.Fatalf("unexpected columns: %v", cols)
+	}
+
+	count := 0
+	for {
+		row, err := r.ReadRow()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if row["name"] == "" {
+			t.Errorf("row %d has no name: %v", count, row)
+		}
+		count++
+	}
+	if count != 2 {
+		t.Errorf("expected 2 rows, got %d", count)
+	}
+}
+
+// TestCSVStreamingReaderEmpty verifies that empty input yields no rows instead of an error.
+func TestCSVStreamingReaderEmpty(t *testing.T) {
+	r, err := stream.NewCSVStreamingReaderFrom(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("unexpected error for empty input: %v", err)
+	}
+	if _, err := r.ReadRow(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+// TestJSONStreamingReaderFrom verifies reading objects from a JSON array.
+func TestJSONStreamingReaderFrom(t *testing.T) {
+	r, err := stream.NewJSONStreamingReaderFrom(strings.NewReader(`[{"a":"1"},{"a":"2"}]`))
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+	defer r.Close()
+
+	row, err := r.ReadRow()
+	if err != nil || row["a"] != "1" {
+		t.Fatalf("unexpected first row: %v (%v)", row, err)
+	}
+	if _, err := r.ReadRow(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := r.ReadRow(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+
+	// Non-array input is rejected
+	if _, err := stream.NewJSONStreamingReaderFrom(strings.NewReader(`{"a":1}`)); err == nil {
+		t.Error("expected error for non-array JSON")
+	}
+}
+
+// TestJSONStreamingWriterTo verifies that keys follow header order and the array is terminated.
+func TestJSONStreamingWriterTo(t *testing.T) {
+	var buf bytes.Buffer
+	w := stream.NewJSONStreamingWriterTo(&buf, []string{"b", "a"})
+	if err := w.WriteRow(map[string]interface{}{"a": "1", "b": "2", "c": "3"}); err != nil {
+		t.Fatalf("failed to write row: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close writer: %v", err)
+	}
+
+	want := "[\n  {\"b\":\"2\",\"a\":\"1\",\"c\":\"3\"}\n]\n"
+	if buf.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	// No rows still produces a valid array
+	buf.Reset()
+	empty := stream.NewJSONStreamingWriterTo(&buf, nil)
+	empty.Close()
+	if buf.String() != "[]\n" {
+		t.Errorf("unexpected empty output: %q", buf.String())
+	}
+}
+
+// TestCSVStreamingWriter verifies the path-based writer round-trips through the reader.
+func TestCSVStreamingWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.csv")
+	w, err := stream.NewCSVStreamingWriter(path, []string{"x", "y"})
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	if err := w.WriteRow(map[string]interface{}{"x": "1", "y": "2"}); err != nil {
+		t.Fatalf("failed to write row: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close writer: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if string(data) != "x,y\n1,2\n" {
+		t.Errorf("unexpected CSV output: %q", string(data))
+	}
+
+	r, err := stream.NewCSVStreamingReader(path)
+	if err != nil {
+		t.Fatalf("failed to reopen CSV: %v", err)
+	}
+	defer r.Close()
+	row, err := r.ReadRow()
+	if err != nil || row["y"] != "2" {
+		t.Errorf("unexpected row: %v (%v)", row, err)
+	}
+}
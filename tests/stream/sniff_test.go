@@ -0,0 +1,102 @@
+package stream_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"omnidata/internal/stream"
+)
+
+// TestSniff detects delimiters, quotes, escapes, line endings and header rows.
+func TestSniff(t *testing.T) {
+	cases := []struct {
+		name   string
+		sample string
+		want   stream.Dialect
+		ending string
+		header bool
+	}{
+		{"comma", "id,name\n1,Alice\n2,Bob\n", stream.Dialect{Delimiter: ',', Quote: '"'}, "\n", true},
+		{"semicolon", "id;name;note\r\n1;Ann;\"a;b\"\r\n2;Bob;x\r\n", stream.Dialect{Delimiter: ';', Quote: '"', CRLF: true}, "\r\n", true},
+		{"tab", "name\tcity\nAlice\tParis, France\nBob\tRome\n", stream.Dialect{Delimiter: '\t', Quote: '"'}, "\n", true},
+		{"pipe single quotes", "id|note\n1|'a|b'\n2|'c'\n", stream.Dialect{Delimiter: '|', Quote: '\''}, "\n", true},
+		{"backslash escape", "id,note\n1,\"say \\\"hi\\\", then go\"\n2,x\n", stream.Dialect{Delimiter: ',', Quote: '"', Escape: '\\'}, "\n", true},
+		{"no header", "1,2.5,x\n2,3.5,y\n3,4.5,z\n", stream.Dialect{Delimiter: ',', Quote: '"'}, "\n", false},
+		{"fixed length codes", "code,country\nFR,France\nDE,Germany\n", stream.Dialect{Delimiter: ',', Quote: '"'}, "\n", true},
+	}
+	for _, c := range cases {
+		got := stream.Sniff([]byte(c.sample))
+		if got.Dialect != c.want {
+			t.Errorf("%s: dialect = %+v, want %+v", c.name, got.Dialect, c.want)
+		}
+		if got.LineEnding != c.ending || got.Header != c.header || got.Confidence != 1 {
+			t.Errorf("%s: line ending %q, header %v, confidence %v", c.name, got.LineEnding, got.Header, got.Confidence)
+		}
+		if got.Encoding != "utf-8" || got.BOM {
+			t.Errorf("%s: encoding %q, BOM %v", c.name, got.Encoding, got.BOM)
+		}
+	}
+}
+
+// TestSniffUncertain verifies that single columns, malformed quoting and cut-off samples
+// do not lead to a wrong dialect.
+func TestSniffUncertain(t *testing.T) {
+	single := stream.Sniff([]byte("name\nAlice\nBob\n"))
+	if single.Confidence != 0 || single.Dialect.Delimiter != ',' {
+		t.Errorf("expected no delimiter to be found, got %+v", single)
+	}
+
+	bad := stream.Sniff([]byte("id,name\n1,Alice\n2,\"Bob\n3,Carol\"x\n4,Dave\n"))
+	if bad.Dialect.Quote != '"' || bad.Confidence >= 1 {
+		t.Errorf("expected malformed quotes to lower the confidence, got %+v", bad)
+	}
+
+	// The last line of a full-size sample is cut short and ignored
+	long := "a;b;c\n" + strings.Repeat("1;2;3\n", stream.SniffSize/6) + "4;5"
+	if got := stream.Sniff([]byte(long[:stream.SniffSize])); got.Dialect.Delimiter != ';' || got.Confidence != 1 {
+		t.Errorf("expected a cut-off last line to be ignored, got %+v", got)
+	}
+}
+
+// TestSniffEncoding detects byte order marks, UTF-16 and 8-bit text, and decodes them.
+func TestSniffEncoding(t *testing.T) {
+	cases := []struct {
+		name     string
+		sample   string
+		encoding string
+		bom      bool
+	}{
+		{"utf-8 BOM", "\xef\xbb\xbfid,name\n1,Zoë\n", "utf-8", true},
+		{"utf-16le BOM", "\xff\xfei\x00d\x00,\x00n\x00\n\x001\x00,\x00\xeb\x00\n\x00", "utf-16le", true},
+		{"utf-16be", "\x00i\x00d\x00,\x00n\x00\n\x001\x00,\x00\xeb\x00\n", "utf-16be", false},
+		{"windows-1252", "id,name\n1,Zo\xeb\n", "windows-1252", false},
+		{"utf-8 cut short", "id,name\n1,Zo\xc3", "utf-8", false},
+	}
+	for _, c := range cases {
+		got := stream.Sniff([]byte(c.sample))
+		if got.Encoding != c.encoding || got.BOM != c.bom {
+			t.Errorf("%s: encoding %q, BOM %v; want %q, %v", c.name, got.Encoding, got.BOM, c.encoding, c.bom)
+			continue
+		}
+		if c.name == "utf-8 cut short" {
+			continue
+		}
+		if got.Dialect.Delimiter != ',' || got.Confidence != 1 {
+			t.Errorf("%s: expected the decoded sample to be sniffed, got %+v", c.name, got)
+		}
+
+		r, err := stream.NewDecoder(strings.NewReader(c.sample), got.Encoding)
+		if err != nil {
+			t.Fatalf("%s: NewDecoder failed: %v", c.name, err)
+		}
+		text, _ := io.ReadAll(r)
+		if !strings.HasPrefix(string(text), "id,n") || !strings.Contains(string(text), "ë") {
+			t.Errorf("%s: decoded %q", c.name, text)
+		}
+	}
+
+	if _, err := stream.NewDecoder(strings.NewReader(""), "ebcdic"); err == nil {
+		t.Error("expected an unknown encoding to be rejected")
+	}
+}